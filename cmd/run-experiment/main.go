@@ -0,0 +1,258 @@
+// Command run-experiment drives one reproducible experiment against a running
+// server: it loads one endpoint through a series of phases, marks each phase
+// on the server's dashboards, captures the server's state at every phase
+// boundary and writes everything to one report directory.
+//
+//	go run ./cmd/run-experiment -server http://localhost:8080 -endpoint /async-limited -phases "30s@10;1m@50;30s@10"
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"go-routine-stress/internal/client"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/loadgen"
+)
+
+// capture is one server endpoint saved at every phase boundary.
+type capture struct {
+	name, path string
+}
+
+var (
+	boundaryCaptures = []capture{
+		{"stats", "/stats"},
+		{"status", "/status"},
+		{"inflight", "/admin/inflight"},
+		{"goroutines", "/admin/goroutines"},
+	}
+	finalCaptures = []capture{
+		{"recommendations", "/recommendations"},
+		{"markers", "/admin/markers"},
+		{"audit", "/admin/audit"},
+	}
+)
+
+// Report is the run summary written as report.json.
+type Report struct {
+	Server    string            `json:"server"`
+	Endpoint  string            `json:"endpoint"`
+	Transport loadgen.Transport `json:"transport"`
+	// ClientLimit is set when the load client limited its own concurrency.
+	ClientLimit *ClientLimit    `json:"clientLimit,omitempty"`
+	StartedAt   time.Time       `json:"startedAt"`
+	FinishedAt  time.Time       `json:"finishedAt"`
+	Version     json.RawMessage `json:"version,omitempty"`
+	Phases      []PhaseReport   `json:"phases"`
+}
+
+// ClientLimit is the load client's adaptive concurrency limit.
+type ClientLimit struct {
+	Algorithm string `json:"algorithm"`
+	Initial   int    `json:"initial"`
+	Min       int    `json:"min"`
+	Max       int    `json:"max"`
+}
+
+// PhaseReport is one phase's load and the client-side result.
+type PhaseReport struct {
+	Index       int            `json:"index"`
+	DurationSec float64        `json:"durationSec"`
+	Concurrency int            `json:"concurrency"`
+	Result      loadgen.Result `json:"result"`
+}
+
+// ctlClient marks and captures around the load, on connections of its own.
+type ctlClient struct {
+	base  string
+	admin string // base URL of /admin/* paths
+	token string
+	http  *http.Client
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "server base URL")
+	admin := flag.String("admin", "http://localhost:9091", "admin endpoints base URL (the server URL when ADMIN_PORT=PORT)")
+	endpoint := flag.String("endpoint", "/async", "endpoint to load, with any query")
+	phasesSpec := flag.String("phases", "30s@10;1m@50;30s@10", "load phases as duration@concurrency;...")
+	out := flag.String("out", "", "report directory (default experiments/<UTC timestamp>)")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin bearer token, when the server requires one (operator, to set markers)")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request client timeout")
+	var tr loadgen.Transport
+	flag.StringVar(&tr.Protocol, "proto", "auto", "load client protocol: auto, http1, http2 (TLS) or h2c (server with HTTP_H2C=true)")
+	flag.IntVar(&tr.MaxConnsPerHost, "max-conns", 0, "load client connection cap (0 = none)")
+	flag.IntVar(&tr.MaxIdleConnsPerHost, "max-idle-conns", 0, "idle connections the load client keeps (0 = net/http default, 2)")
+	flag.DurationVar(&tr.IdleConnTimeout, "idle-timeout", 0, "how long the load client keeps an idle connection (0 = net/http default, 90s)")
+	keepAlive := flag.Bool("keepalive", true, "reuse load client connections; false opens one per request")
+	flag.BoolVar(&tr.InsecureSkipVerify, "insecure", false, "accept any server certificate with an https -server")
+	flag.StringVar(&tr.CAFile, "ca", "", "PEM file of extra CA certificates to trust with an https -server")
+	limitAlg := flag.String("client-limit", "none", "limit the load client's own concurrency adaptively: none, vegas or gradient")
+	var cl ClientLimit
+	flag.IntVar(&cl.Initial, "client-limit-initial", 10, "requests in flight the client limit starts at")
+	flag.IntVar(&cl.Min, "client-limit-min", 1, "lowest the client limit goes")
+	flag.IntVar(&cl.Max, "client-limit-max", 1000, "highest the client limit goes")
+	flag.Parse()
+	tr.DisableKeepAlives = !*keepAlive
+
+	phases, err := loadgen.ParsePhases(*phasesSpec)
+	if err != nil {
+		log.Fatalf("-phases: %v", err)
+	}
+	if tr.Protocol == "http2" && !strings.HasPrefix(*server, "https://") {
+		// net/http would fall back to HTTP/1.1 without a word.
+		log.Fatal("-proto http2 needs an https -server; use h2c for cleartext HTTP/2")
+	}
+	loadClient, err := tr.NewClient(*timeout)
+	if err != nil {
+		log.Fatalf("transport: %v", err)
+	}
+	var limiter *client.Limiter
+	if *limitAlg != "none" {
+		alg, err := client.NewAlgorithm(*limitAlg)
+		if err != nil {
+			log.Fatalf("-client-limit: %v", err)
+		}
+		if cl.Min < 1 || cl.Max < cl.Min || cl.Initial < cl.Min || cl.Initial > cl.Max {
+			log.Fatal("-client-limit-*: want 1 <= min <= initial <= max")
+		}
+		cl.Algorithm = *limitAlg
+		limiter = client.NewLimiter(clock.Real{}, alg, cl.Initial, cl.Min, cl.Max)
+	}
+	// Markers and captures go over connections of their own, trusting the
+	// same certificates, so they do not count in the load client's reuse.
+	ctlHTTP, err := loadgen.Transport{InsecureSkipVerify: tr.InsecureSkipVerify, CAFile: tr.CAFile}.NewClient(*timeout)
+	if err != nil {
+		log.Fatalf("transport: %v", err)
+	}
+	dir := *out
+	if dir == "" {
+		dir = filepath.Join("experiments", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctl := &ctlClient{base: *server, admin: *admin, token: *token, http: ctlHTTP}
+	if _, err := ctl.get(ctx, "/health"); err != nil {
+		log.Fatalf("server not reachable: %v", err)
+	}
+
+	report := Report{Server: *server, Endpoint: *endpoint, Transport: tr, StartedAt: time.Now().UTC()}
+	if limiter != nil {
+		report.ClientLimit = &cl
+	}
+	if v, err := ctl.get(ctx, "/version"); err == nil {
+		report.Version = v
+	}
+	ctl.captureAll(ctx, dir, "0-start", boundaryCaptures)
+
+	runner := &loadgen.Runner{Client: loadClient, URL: *server + *endpoint, Limiter: limiter}
+	for i, p := range phases {
+		if ctx.Err() != nil {
+			break
+		}
+		n := i + 1
+		ctl.mark(ctx, fmt.Sprintf("phase-%d", n), fmt.Sprintf("%s at concurrency %d on %s", p.Duration, p.Concurrency, *endpoint))
+		log.Printf("phase %d: %s at concurrency %d", n, p.Duration, p.Concurrency)
+
+		res := runner.Run(ctx, p)
+		log.Printf("phase %d: %d requests, %d errors, p50 %.0fms p99 %.0fms, %d connections opened, %.0f%% reused",
+			n, res.Requests, res.Errors, res.P50Ms, res.P99Ms, res.Conns.Opened, res.Conns.ReusePct)
+		if res.Limit != nil {
+			log.Printf("phase %d: client limit %.1f (%.1f-%.1f), %d drops, mean wait %.0fms",
+				n, res.Limit.EndLimit, res.Limit.MinLimit, res.Limit.MaxLimit, res.Limit.Drops, res.Limit.WaitMeanMs)
+		}
+		report.Phases = append(report.Phases, PhaseReport{Index: n, DurationSec: p.Duration.Seconds(), Concurrency: p.Concurrency, Result: res})
+		ctl.captureAll(ctx, dir, fmt.Sprintf("%d-phase-end", n), boundaryCaptures)
+	}
+
+	// Still capture the final state when the run was interrupted.
+	endCtx := context.WithoutCancel(ctx)
+	ctl.mark(endCtx, "experiment-end", *endpoint)
+	ctl.captureAll(endCtx, dir, "final", finalCaptures)
+	report.FinishedAt = time.Now().UTC()
+
+	if err := writeJSON(filepath.Join(dir, "report.json"), report); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("report written to %s", dir)
+}
+
+// get fetches path and returns the body of a 200 response.
+func (c *ctlClient) get(ctx context.Context, path string) (json.RawMessage, error) {
+	return c.do(ctx, http.MethodGet, path)
+}
+
+func (c *ctlClient) do(ctx context.Context, method, path string) (json.RawMessage, error) {
+	base := c.base
+	if strings.HasPrefix(path, "/admin/") {
+		base = c.admin
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	return body, nil
+}
+
+// mark sets an experiment marker on the server; failures are only logged.
+func (c *ctlClient) mark(ctx context.Context, name, description string) {
+	q := url.Values{"name": {name}, "description": {description}}
+	if _, err := c.do(ctx, http.MethodPost, "/admin/annotate?"+q.Encode()); err != nil {
+		log.Printf("marker %s: %v", name, err)
+	}
+}
+
+// captureAll saves each capture as <moment>-<name>.json. A capture that
+// fails (e.g. missing admin rights) is logged and skipped.
+func (c *ctlClient) captureAll(ctx context.Context, dir, moment string, caps []capture) {
+	for _, cp := range caps {
+		body, err := c.get(ctx, cp.path)
+		if err != nil {
+			log.Printf("capture %s: %v", cp.path, err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, moment+"-"+cp.name+".json"), body, 0o644); err != nil {
+			log.Printf("capture %s: %v", cp.path, err)
+		}
+	}
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
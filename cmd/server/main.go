@@ -2,44 +2,751 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"go-routine-stress/internal/alerts"
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/audit"
+	"go-routine-stress/internal/auth"
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/balancer"
+	"go-routine-stress/internal/brownout"
+	"go-routine-stress/internal/buildinfo"
+	"go-routine-stress/internal/canary"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/coalesce"
 	"go-routine-stress/internal/config"
+	"go-routine-stress/internal/connpool"
+	"go-routine-stress/internal/cost"
+	"go-routine-stress/internal/cpuprof"
+	"go-routine-stress/internal/diag"
+	"go-routine-stress/internal/dns"
+	"go-routine-stress/internal/fairq"
+	"go-routine-stress/internal/fallback"
 	"go-routine-stress/internal/handlers"
+	"go-routine-stress/internal/health"
+	"go-routine-stress/internal/hedge"
+	"go-routine-stress/internal/inflight"
+	"go-routine-stress/internal/lifecycle"
+	"go-routine-stress/internal/markers"
+	"go-routine-stress/internal/middleware"
+	"go-routine-stress/internal/mirror"
 	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/pool"
+	"go-routine-stress/internal/preflight"
+	"go-routine-stress/internal/readiness"
+	"go-routine-stress/internal/recommend"
+	"go-routine-stress/internal/resilience"
 	"go-routine-stress/internal/routers"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/scheduler"
+	"go-routine-stress/internal/semaphore"
 	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/shed"
+	"go-routine-stress/internal/stats"
+	"go-routine-stress/internal/timeline"
+	"go-routine-stress/internal/toggles"
 )
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
 
-	// Initialize OpenTelemetry (metrics + optional traces).
-	shutdown, err := observability.SetupOTel(context.Background(), cfg.OtelEndpoint, cfg.ServiceName, cfg.DisableTraces)
+	// Runtime toggles can be flipped later through /admin/toggles.
+	rt := toggles.NewRuntime()
+	if _, err := rt.ApplySpec(cfg.RuntimeToggles); err != nil {
+		log.Fatalf("RUNTIME_TOGGLES: %v", err)
+	}
+
+	// Reach the real backends now rather than discovering a typo in an
+	// endpoint from missing data after the run.
+	preflights, err := runPreflight(cfg)
 	if err != nil {
-		log.Fatalf("otel init failed: %v", err)
+		log.Fatalf("preflight: %v", err)
 	}
-	defer func() { _ = shutdown(context.Background()) }()
 
+	// Initialize OpenTelemetry (metrics + optional traces). Exports the
+	// collector cannot take wait in memory until it is back.
+	telemetry := observability.NewExportBuffer(cfg.TelemetryBufferMB << 20)
+	shutdown, err := observability.SetupOTel(context.Background(), cfg.OtelEndpoint, cfg.ServiceName, cfg.DisableTraces,
+		func() bool { return rt.Tracing.Is(toggles.On) }, telemetry)
+	if err != nil {
+		log.Fatalf("otel init failed: %v", err)
+	}
 	m, err := observability.NewMetrics()
 	if err != nil {
 		log.Fatalf("metrics init failed: %v", err)
 	}
+	m.SampleAllocs(cfg.AllocSampleEvery)
+	m.TrackExportBuffer(telemetry.Stats)
+
+	// Background goroutines run until the group stops them during shutdown,
+	// in reverse order of start. The exporters are shut down after the last,
+	// so the shutdown's own metrics still reach the collector.
+	bg := lifecycle.New(time.Duration(cfg.WorkerStopTimeoutMs)*time.Millisecond, m)
+	bg.Export(func(ctx context.Context) error {
+		err := shutdown(ctx)
+		if n, size := telemetry.Pending(); n > 0 {
+			log.Printf("telemetry: %d buffered exports (%d KiB) never reached the collector", n, size>>10)
+		}
+		return err
+	})
+
+	// A signal during startup cuts prewarming short; the server then stops
+	// what it started instead of serving.
+	stop, stopCancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopCancel()
 
 	// Create simulated dependencies (Service A and Service B).
-	svcs := services.New()
+	clk := clock.Real{}
+	rng := services.GlobalRNG()
+	if cfg.Seed != 0 {
+		rng = services.NewSeededRNG(int64(cfg.Seed))
+	}
+	svcs := services.New(clk, rng)
+	if cfg.ChainDepth > 0 {
+		profile, err := services.ParseProfile(cfg.ChainProfile)
+		if err != nil {
+			log.Fatalf("CHAIN_PROFILE: %v", err)
+		}
+		svcs.SetChain(services.Chain{Depth: cfg.ChainDepth, Fanout: cfg.ChainFanout, Profile: profile,
+			Split: float64(cfg.ChainBudgetSplitPct) / 100})
+	}
+
+	// Per-service bulkheads applying backpressure on the async-limited endpoint.
+	bulkheads := handlers.NewBulkheads(cfg.AConcurrencyLimit, cfg.BConcurrencyLimit)
+
+	// Waiters for Service B's bulkhead are watched for starvation and ordering inversions.
+	semWatch := semaphore.NewWatch(clk, time.Duration(cfg.SemStarvationMs)*time.Millisecond)
+	m.TrackStarvingB(semWatch.Starving)
+
+	// With FAIR_QUEUE, waiters for Service B's bulkhead are served by weighted fair queuing
+	// across the TENANT_WEIGHTS tenants (hashed X-API-Key, or a local X-Tenant) instead of in arbitrary order.
+	var fairB *fairq.Queue
+	if cfg.FairQueue {
+		weights, err := fairq.ParseWeights(cfg.TenantWeights)
+		if err != nil {
+			log.Fatalf("TENANT_WEIGHTS: %v", err)
+		}
+		fairB = fairq.New(bulkheads.B.Slots(), weights)
+		m.TrackTenantSlots(fairB.InUse)
+	}
+
+	// SEM_IMPL=fifo replaces Service B's bulkhead with a ticket semaphore granting strictly in
+	// arrival order.
+	var fifoB *semaphore.FIFO
+	switch cfg.SemImpl {
+	case "channel":
+	case "fifo":
+		if fairB != nil {
+			log.Fatalf("SEM_IMPL=fifo: not combinable with FAIR_QUEUE, which orders waiters itself")
+		}
+		fifoB = semaphore.NewFIFO(cfg.BConcurrencyLimit)
+	default:
+		log.Fatalf("SEM_IMPL: unknown implementation %q, want channel or fifo", cfg.SemImpl)
+	}
+
+	agg := stats.New(clk, time.Duration(cfg.StatsTickMs)*time.Millisecond,
+		time.Duration(cfg.StatsWindowSec)*time.Second, time.Duration(cfg.StatsRetainSec)*time.Second)
+	apdexT := time.Duration(cfg.ApdexTMs) * time.Millisecond
+	apdexF := time.Duration(cfg.ApdexToleratingMs) * time.Millisecond
+	if apdexF == 0 {
+		apdexF = 4 * apdexT
+	}
+	agg.SetApdex(apdexT, max(apdexF, apdexT))
+	m.TrackApdex(agg.ApdexScores)
+	bg.Go("stats.aggregator", 0, agg.Run)
+
+	// Successful dependency calls feed timeout and limit recommendations.
+	deps := stats.New(clk, time.Duration(cfg.StatsTickMs)*time.Millisecond,
+		time.Duration(cfg.StatsWindowSec)*time.Second, time.Duration(cfg.StatsRetainSec)*time.Second)
+	bg.Go("stats.deps", 0, deps.Run)
+
+	// Every dependency call, failures included, for the /status rollup.
+	calls := stats.New(clk, time.Duration(cfg.StatsTickMs)*time.Millisecond,
+		time.Duration(cfg.StatsWindowSec)*time.Second, time.Duration(cfg.StatsWindowSec)*time.Second)
+	bg.Go("stats.calls", 0, calls.Run)
+	rec := &recommend.Recommender{Clock: clk, Deps: deps, Endpoints: agg, MarginPct: cfg.RecommendMarginPct, Started: clk.Now()}
+
+	// Experiment markers are kept for the whole run and logged again at exit.
+	marks := markers.New(clk, 1000)
+
+	// Alert rules are evaluated against the in-process stats; transitions go to
+	// an optional webhook.
+	var notifier alerts.Notifier
+	if cfg.AlertWebhookURL != "" {
+		notifier = alerts.NewWebhook(cfg.AlertWebhookURL, 5*time.Second)
+	}
+	alertEngine := alerts.New(clk, agg, []alerts.Rule{
+		{Name: "high_error_rate", Kind: alerts.KindErrorRate, Window: time.Minute, Threshold: float64(cfg.AlertErrorRatePct) / 100, MinRequests: 10},
+		{Name: "high_p99_latency", Kind: alerts.KindP99, Window: 5 * time.Minute, Threshold: float64(cfg.AlertP99Ms), MinRequests: 10},
+		{Name: "goroutine_growth", Kind: alerts.KindGoroutineGrowth, Window: 5 * time.Minute, Threshold: float64(cfg.AlertGoroutineGrowth)},
+	}, time.Duration(cfg.AlertEvalMs)*time.Millisecond, notifier)
+	bg.Go("alerts", 0, alertEngine.Run)
+
+	// The canary probes this server's own endpoints; consecutive failures flip readiness.
+	ready := readiness.New()
+	can := canary.New(clk, m, "http://127.0.0.1:"+cfg.Port,
+		[]string{"sync", "async", "async-limited", "async-timeout"},
+		cfg.CanaryFailureThreshold, time.Duration(cfg.CanaryTimeoutMs)*time.Millisecond)
+	ready.Add("canary", can.Ready)
+
+	sched := scheduler.New(clk, m)
+	if err := addScheduledTasks(sched, cfg.Schedule, agg, can, telemetry); err != nil {
+		log.Fatalf("scheduler init failed: %v", err)
+	}
+	bg.Go("scheduler", 0, sched.Run)
+
+	// Endpoints listed in SHED_POLICIES get their own concurrency limit and
+	// overload policy; degrade answers from the last good Service B result.
+	policies, err := shed.ParsePolicies(cfg.ShedPolicies)
+	if err != nil {
+		log.Fatalf("shed policies: %v", err)
+	}
+	limiters := make(map[string]*shed.Limiter, len(policies))
+	for endpoint, policy := range policies {
+		limiters[endpoint] = shed.New(clk, policy, cfg.ShedMaxInflight,
+			time.Duration(cfg.ShedQueueTimeoutMs)*time.Millisecond, time.Duration(cfg.ShedThrottleMs)*time.Millisecond,
+			time.Duration(cfg.ShedPriorityAgingMs)*time.Millisecond)
+	}
+	ceilings, err := shed.ParseCeilings(cfg.PriorityCeilings)
+	if err != nil {
+		log.Fatalf("PRIORITY_CEILINGS: %v", err)
+	}
+	// SHED_LIMIT_MODES derives the limits of listed endpoints from GOMAXPROCS
+	// (cpu) or observed latency (io) instead of SHED_MAX_INFLIGHT.
+	limitModes, err := shed.ParseLimitModes(cfg.ShedLimitModes)
+	if err != nil {
+		log.Fatalf("SHED_LIMIT_MODES: %v", err)
+	}
+	tuner := &shed.Tuner{
+		Limiters:  limiters,
+		Modes:     limitModes,
+		Agg:       agg,
+		CPUFactor: float64(cfg.ShedCPUFactor),
+		TargetRPS: float64(cfg.ShedIOTargetRPS),
+		Min:       1,
+		Max:       100000,
+		Interval:  time.Duration(cfg.ShedTuneMs) * time.Millisecond,
+	}
+	bg.Go("shed.tuner", 0, tuner.Run)
+	m.TrackShedLimits(func() map[string]int {
+		out := make(map[string]int, len(limiters))
+		for endpoint, l := range limiters {
+			out[endpoint] = l.Limit()
+		}
+		return out
+	})
+	m.TrackShedQueues(func() map[string]map[string]observability.ShedClassStats {
+		out := make(map[string]map[string]observability.ShedClassStats)
+		for endpoint, l := range limiters {
+			if l.Policy() != shed.PolicyQueue {
+				continue
+			}
+			classes := make(map[string]observability.ShedClassStats, len(shed.Priorities))
+			for p, st := range l.Classes() {
+				classes[p.String()] = observability.ShedClassStats{Promotions: st.Promotions, MaxWaitMs: st.MaxWait.Milliseconds()}
+			}
+			out[endpoint] = classes
+		}
+		return out
+	})
+	fallbackB := fallback.New[services.ServiceBData](clk)
+	m.TrackAge("B", fallbackB.Age)
+	if cfg.FallbackRefreshMs > 0 {
+		warmer := fallback.NewWarmer(fallbackB, clk, rng, m, "B",
+			time.Duration(cfg.FallbackRefreshMs)*time.Millisecond, float64(cfg.FallbackJitterPct)/100,
+			5*time.Second, svcs.ServiceB)
+		bg.Go("fallback.warmer", 0, warmer.Run)
+	}
 
-	// Semaphore used to apply backpressure on Service B (async-limited endpoint).
-	semB := make(chan struct{}, cfg.BConcurrencyLimit)
+	// Endpoints listed in ENDPOINT_COSTS hold that many tokens of the shared
+	// budget while they run; requests that do not fit are rejected.
+	costs, err := cost.ParseCosts(cfg.EndpointCosts)
+	if err != nil {
+		log.Fatalf("ENDPOINT_COSTS: %v", err)
+	}
+	budget := cost.New(int64(cfg.CostCapacity))
+	m.TrackTokens(budget.Available)
 
-	h := handlers.New(svcs, m, semB, cfg.AsyncTimeoutMs)
+	coalesceWindows, err := coalesce.ParseWindows(cfg.CoalesceWindows)
+	if err != nil {
+		log.Fatalf("coalesce windows: %v", err)
+	}
 
-	r := routers.NewRouter(m, h)
+	// Named Service B instances; /smart routes between the first two by health score.
+	instanceList, err := svcs.Instances(cfg.BInstances)
+	if err != nil {
+		log.Fatalf("B instances: %v", err)
+	}
+	if len(instanceList) < 2 {
+		log.Fatalf("B_INSTANCES: need at least a primary and a fallback instance")
+	}
+	instances := make(map[string]*services.Instance, len(instanceList))
+	scores := make(map[string]*health.Score, len(instanceList))
+	for _, in := range instanceList {
+		instances[in.Name] = in
+		scores[in.Name] = health.NewScore(0.2, time.Duration(cfg.SmartLatencyTargetMs)*time.Millisecond)
+		m.TrackHealth("B/"+in.Name, scores[in.Name].Value)
+	}
+	smart := health.NewRouter(instanceList[0].Name, instanceList[1].Name, scores,
+		float64(cfg.SmartThresholdPct)/100, 0.1, cfg.SmartProbeEvery)
+
+	// /regional calls Service B's region replicas local-first.
+	regionList, err := services.ParseRegions(cfg.Regions)
+	if err != nil {
+		log.Fatalf("REGIONS: %v", err)
+	}
+	if len(regionList) == 0 {
+		log.Fatalf("REGIONS: need at least one region")
+	}
+	regions := svcs.RegionReplicas(regionList)
+
+	// /balanced spreads Service B over all instances, ejecting outliers.
+	strategy, err := balancer.ParseStrategy(cfg.LBStrategy)
+	if err != nil {
+		log.Fatalf("LB_STRATEGY: %v", err)
+	}
+	lb := balancer.New(clk, rng, instanceList, strategy, balancer.Outlier{
+		Consecutive:   cfg.OutlierConsecutive,
+		SlowThreshold: time.Duration(cfg.OutlierSlowMs) * time.Millisecond,
+		BaseEjection:  time.Duration(cfg.OutlierBaseEjectionMs) * time.Millisecond,
+		MaxEjection:   time.Duration(cfg.OutlierMaxEjectionMs) * time.Millisecond,
+		MaxEjectedPct: cfg.OutlierMaxEjectedPct,
+	})
+
+	// Background work requests leave behind; jobs under the drain policy are
+	// waited for at shutdown, and cancelled once the drain deadline passes.
+	// The group stops the runner first, once requests have drained.
+	bgPolicies, err := background.ParsePolicies(cfg.BackgroundPolicies)
+	if err != nil {
+		log.Fatalf("BACKGROUND_POLICIES: %v", err)
+	}
+	jobsCtx, cancelJobs := context.WithCancelCause(context.Background())
+	jobs := background.NewRunner(jobsCtx, clk, bgPolicies, time.Duration(cfg.BackgroundTimeoutMs)*time.Millisecond, m)
+
+	// Service B targets are resolved through a simulated, caching DNS layer.
+	var resolver *dns.Cache
+	if cfg.DNSMaxMs > 0 {
+		sim := dns.NewSim(clk, rng, time.Duration(cfg.DNSMinMs)*time.Millisecond, time.Duration(max(cfg.DNSMaxMs, cfg.DNSMinMs))*time.Millisecond)
+		resolver = dns.NewCache(sim, clk, time.Duration(cfg.DNSTTLMs)*time.Millisecond, time.Duration(cfg.DNSRefreshAheadMs)*time.Millisecond, jobs)
+	}
+
+	// Service B targets with a client pool borrow a connection per call; warm
+	// pools dial every connection before the server starts taking traffic.
+	poolSpecs, err := connpool.ParseSpecs(cfg.ConnPools)
+	if err != nil {
+		log.Fatalf("CONN_POOLS: %v", err)
+	}
+	pools := make(map[string]*connpool.Pool, len(poolSpecs))
+	for host, spec := range poolSpecs {
+		pool := connpool.New(clk, rng, connpool.Options{
+			Size:        spec.Size,
+			MaxIdle:     cfg.ConnMaxIdle,
+			IdleTimeout: time.Duration(cfg.ConnIdleTimeoutMs) * time.Millisecond,
+			DialMin:     time.Duration(cfg.ConnDialMinMs) * time.Millisecond,
+			DialMax:     time.Duration(max(cfg.ConnDialMaxMs, cfg.ConnDialMinMs)) * time.Millisecond,
+			OnClose:     func(reason string) { m.RecordConnPoolClose(host, reason) },
+		})
+		pools[host] = pool
+		m.TrackConnPool(host, pool.Stats)
+		if spec.Warm {
+			start := time.Now()
+			n, err := pool.Prewarm(stop)
+			if stop.Err() != nil {
+				log.Printf("conn pool %s: shutting down while pre-dialing", host)
+				bg.Stop()
+				return
+			}
+			if err != nil {
+				log.Fatalf("conn pool %s: %v", host, err)
+			}
+			log.Printf("conn pool %s: pre-dialed %d connections in %s", host, n, time.Since(start).Round(time.Millisecond))
+		}
+	}
+
+	// With a p95 target set, a brownout controller sheds Service B calls
+	// progressively while the mode endpoints are too slow.
+	var bo *brownout.Controller
+	if cfg.BrownoutTargetP95Ms > 0 {
+		bo = &brownout.Controller{
+			Agg:        agg,
+			Endpoints:  brownout.ParseEndpoints(cfg.BrownoutEndpoints),
+			Target:     time.Duration(cfg.BrownoutTargetP95Ms) * time.Millisecond,
+			Hysteresis: 0.2,
+			Step:       cfg.BrownoutStepPct,
+			Interval:   time.Duration(cfg.BrownoutIntervalMs) * time.Millisecond,
+			RNG:        rng,
+		}
+		m.TrackBrownout(bo.Level)
+		bg.Go("brownout", 0, bo.Run)
+	}
+
+	// Sampled CPU profiles attribute CPU time to the endpoint label that
+	// middleware sets on request goroutines.
+	if cfg.CPUProfileWindowMs > 0 {
+		sampler := &cpuprof.Sampler{
+			Window:   time.Duration(cfg.CPUProfileWindowMs) * time.Millisecond,
+			Interval: time.Duration(max(cfg.CPUProfileIntervalMs, cfg.CPUProfileWindowMs)) * time.Millisecond,
+			Observe:  m.ObserveEndpointCPU,
+		}
+		bg.Go("cpuprof", 0, sampler.Run)
+	}
 
-	log.Printf("listening on :%s", cfg.Port)
-	log.Fatal(r.Run(":" + cfg.Port))
+	// Goroutine profiles counted by the same label estimate how many
+	// goroutines each endpoint holds.
+	if cfg.GoroutineProfileIntervalMs > 0 {
+		census := &diag.Census{
+			Label:     cpuprof.Label,
+			Unlabeled: cpuprof.Unlabeled,
+			Clock:     clk,
+			Interval:  time.Duration(cfg.GoroutineProfileIntervalMs) * time.Millisecond,
+			Observe:   m.ObserveEndpointGoroutines,
+		}
+		bg.Go("diag.census", 0, census.Run)
+	}
+
+	// Profiles saved when thresholds are crossed leave evidence of transient
+	// incidents during unattended runs.
+	captures := &diag.Capturer{
+		Dir:        cfg.ProfileCaptureDir,
+		Clock:      clk,
+		Interval:   time.Duration(cfg.ProfileCaptureIntervalMs) * time.Millisecond,
+		MinGap:     time.Duration(cfg.ProfileCaptureMinGapMs) * time.Millisecond,
+		Keep:       cfg.ProfileCaptureKeep,
+		Goroutines: cfg.ProfileCaptureGoroutines,
+		HeapBytes:  uint64(cfg.ProfileCaptureHeapMB) << 20,
+		P99Ms:      float64(cfg.ProfileCaptureP99Ms),
+		P99For:     time.Duration(cfg.ProfileCaptureP99ForMs) * time.Millisecond,
+		P99: func() (string, float64) {
+			var endpoint string
+			var worst float64
+			for _, es := range agg.Summarize(time.Duration(cfg.StatsWindowSec) * time.Second) {
+				if es.Requests >= 10 && es.P99Ms > worst {
+					endpoint, worst = es.Endpoint, es.P99Ms
+				}
+			}
+			return endpoint, worst
+		},
+		Record: m.RecordProfileCapture,
+	}
+	if captures.Enabled() {
+		bg.Go("diag.capture", 0, captures.Run)
+	}
+
+	// With MIRROR_URL set, a share of mode endpoint traffic is copied to a
+	// shadow instance; its responses are discarded.
+	var mr *mirror.Mirror
+	if cfg.MirrorURL != "" && cfg.MirrorPct > 0 {
+		mr = mirror.New(cfg.MirrorURL, float64(cfg.MirrorPct), rng, int64(cfg.MirrorMaxBodyKB)<<10, cfg.MirrorQueue, cfg.MirrorWorkers, time.Duration(cfg.MirrorTimeoutMs)*time.Millisecond, m)
+		// A copy in flight may take up to its timeout to finish.
+		bg.Go("mirror", time.Duration(cfg.MirrorTimeoutMs)*time.Millisecond, mr.Run)
+	}
+
+	// /pool runs its calls on long-lived workers instead of fresh goroutines.
+	// Warm pools have every worker through its setup before the server
+	// listens; lazy ones make the first calls wait for it.
+	workers := pool.New(clk, pool.Options{
+		Size:    cfg.PoolSize,
+		Queue:   cfg.PoolQueue,
+		Lazy:    cfg.PoolLazy,
+		Init:    time.Duration(cfg.PoolWorkerInitMs) * time.Millisecond,
+		OnStart: m.RecordWorkerPoolWait,
+	})
+	m.TrackWorkerPool(workers.Queued, workers.Started, workers.Utilization)
+	bg.Go("pool", 0, workers.Run)
+	if !cfg.PoolLazy {
+		start := time.Now()
+		select {
+		case <-workers.Ready():
+		case <-stop.Done():
+			log.Printf("worker pool: shutting down while pre-spawning")
+			bg.Stop()
+			return
+		}
+		log.Printf("worker pool: pre-spawned %d workers in %s", cfg.PoolSize, time.Since(start).Round(time.Millisecond))
+	}
+
+	hedges := hedge.NewBudget(cfg.HedgeBudgetPct, cfg.HedgeBudgetBurst)
+	m.TrackHedgeBudget(hedges.Utilization)
+
+	breakerB := resilience.NewBreaker(clk, cfg.BreakerFailures, time.Duration(cfg.BreakerOpenMs)*time.Millisecond, cfg.BreakerHalfOpenProbes)
+	m.TrackBreaker(func() int64 { return int64(breakerB.State()) })
+
+	fanIn, err := handlers.ParseFanInPolicies(cfg.FanInPolicies)
+	if err != nil {
+		log.Fatalf("FANIN_POLICIES: %v", err)
+	}
+	if _, err := handlers.ParseGather(cfg.ScatterGather); err != nil {
+		log.Fatalf("SCATTER_GATHER: %v", err)
+	}
+	if _, err := handlers.ParseOverflow(cfg.StreamOverflow); err != nil {
+		log.Fatalf("STREAM_OVERFLOW: %v", err)
+	}
+	split, err := handlers.ParseSplit(cfg.TrafficSplit, rng)
+	if err != nil {
+		log.Fatalf("TRAFFIC_SPLIT: %v", err)
+	}
+	statuses, err := handlers.ParseStatusMap(cfg.StatusMap)
+	if err != nil {
+		log.Fatalf("STATUS_MAP: %v", err)
+	}
+
+	h := handlers.New(handlers.Options{
+		Svcs:      svcs,
+		M:         m,
+		Bulkheads: bulkheads,
+		FIFOB:     fifoB,
+		SemWatchB: semWatch,
+		TimeoutMs: cfg.AsyncTimeoutMs,
+		Clock:     clk,
+		Timelines: timeline.NewRing(cfg.TimelineBuffer),
+		Agg:       agg,
+		Readiness: ready,
+		Alerts:    alertEngine,
+		Shed:      limiters,
+		FallbackB: fallbackB,
+		RetryB: handlers.RetryPolicy{
+			TryTimeout: time.Duration(cfg.BTryTimeoutMs) * time.Millisecond,
+			Retries:    cfg.BRetries,
+			Budget:     time.Duration(cfg.BBudgetMs) * time.Millisecond,
+		},
+		Instances:       instances,
+		SmartRouter:     smart,
+		Regions:         regions,
+		Balancer:        lb,
+		FairB:           fairB,
+		Budget:          budget,
+		Costs:           costs,
+		Inflight:        inflight.New(clk, cfg.RecentRequests),
+		Deps:            deps,
+		Recommender:     rec,
+		Calls:           calls,
+		FanIn:           fanIn,
+		Split:           split,
+		Statuses:        statuses,
+		Workers:         workers,
+		Settings:        cfg,
+		Preflight:       preflights,
+		Brownout:        bo,
+		HedgeBudget:     hedges,
+		BreakerB:        breakerB,
+		Background:      jobs,
+		Captures:        captures,
+		DNS:             resolver,
+		Pools:           pools,
+		Audit:           audit.New(clk, cfg.AuditBuffer),
+		Markers:         marks,
+		Toggles:         rt,
+		LogCtxBreaks:    cfg.CtxAuditLog,
+		CoalesceWindows: coalesceWindows,
+
+		PriorityCeilings: ceilings,
+	})
+
+	ready.Add("drain", h.DrainCheck)
+
+	tokens, err := auth.Load(cfg.AdminTokensFile)
+	if err != nil {
+		log.Fatalf("ADMIN_TOKENS_FILE: %v", err)
+	}
+	if !tokens.Enabled() {
+		log.Printf("admin API is unauthenticated, and profiles and dumps only open to loopback callers; set ADMIN_TOKENS_FILE outside a local lab")
+	}
+	if cfg.SimScripts {
+		log.Printf("SIM_SCRIPTS on: requests may fix their own latencies and failures with %s", services.ScriptHeader)
+	}
+	separateAdmin := cfg.AdminPort != cfg.Port
+	// Retried POST and PATCH submissions with an Idempotency-Key replay the
+	// first response instead of doing the work again.
+	var idem *middleware.Idempotency
+	if cfg.IdempotencyTTLMs > 0 {
+		idem = middleware.NewIdempotency(clk, time.Duration(cfg.IdempotencyTTLMs)*time.Millisecond, cfg.IdempotencyMaxKeys, m)
+	}
+	r := routers.NewRouter(m, agg, h, tokens, mr, idem, cfg.SimScripts, !separateAdmin)
+
+	// Request contexts derive from baseCtx so a drain that outlives the shutdown
+	// timeout can cancel in-flight work with an explicit cause.
+	baseCtx, cancelBase := context.WithCancelCause(context.Background())
+	srv := &http.Server{
+		Addr:        ":" + cfg.Port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+		ConnState:   m.ConnState,
+
+		// 0 means no limit; set it to watch /slow-write responses get cut off.
+		WriteTimeout: time.Duration(cfg.WriteTimeoutMs) * time.Millisecond,
+	}
+	if cfg.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	build := buildinfo.Get()
+	log.Printf("listening on :%s (version %s, commit %s)", cfg.Port, build.Version, build.Commit)
+	serveErr := safego.Go(baseCtx, "http.server", func(context.Context) (struct{}, error) {
+		return struct{}{}, srv.ListenAndServe()
+	})
+
+	// The admin listener has no ConnState hook, so operator connections stay
+	// out of the server connection metrics too. It is a worker of the group,
+	// stopped once requests and background jobs have drained, so the drain
+	// can be watched from /admin/inflight.
+	var adminErr chan error // nil, never ready, without one
+	if separateAdmin {
+		adminSrv := &http.Server{Addr: ":" + cfg.AdminPort, Handler: routers.NewAdminRouter(h, tokens, idem)}
+		log.Printf("admin and debug endpoints on :%s", cfg.AdminPort)
+		adminErr = make(chan error, 1)
+		bg.Go("http.admin", 0, func(ctx context.Context) {
+			defer context.AfterFunc(ctx, func() { _ = adminSrv.Close() })()
+			if err := adminSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				adminErr <- err
+			}
+		})
+	}
+
+	// The runner is stopped first: drain-policy jobs get what is left of the
+	// drain, then are cancelled. drainCtx is set before the group stops it.
+	drainTimeout := time.Duration(cfg.ShutdownTimeoutMs) * time.Millisecond
+	var drainCtx context.Context
+	bg.Go("background", drainTimeout+time.Duration(cfg.WorkerStopTimeoutMs)*time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+		if err := jobs.Wait(drainCtx); err != nil {
+			cancelJobs(apperr.ErrShutdownDrain)
+			_ = jobs.Wait(context.Background())
+		}
+		cancelJobs(nil)
+	})
+
+	select {
+	case res := <-serveErr:
+		if !errors.Is(res.Err, http.ErrServerClosed) {
+			log.Fatalf("server failed: %v", res.Err)
+		}
+	case err := <-adminErr:
+		log.Fatalf("admin server failed: %v", err)
+	case <-stop.Done():
+	}
+
+	log.Printf("shutting down, draining for up to %dms", cfg.ShutdownTimeoutMs)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer drainCancel()
+
+	if err := srv.Shutdown(drainCtx); err != nil {
+		// Drain deadline hit: cancel whatever is still running.
+		cancelBase(apperr.ErrShutdownDrain)
+		_ = srv.Close()
+	}
+	cancelBase(nil)
+
+	// Run report: the markers set during the run and, to close the loop,
+	// settings suggested for the next one.
+	if b, err := json.Marshal(marks.List()); err == nil {
+		log.Printf("markers: %s", b)
+	}
+	if b, err := json.Marshal(rec.Report(time.Duration(cfg.StatsRetainSec) * time.Second)); err == nil {
+		log.Printf("recommendations: %s", b)
+	}
+
+	bg.Stop()
+}
+
+// runPreflight checks the backends the configuration names. Unreachable ones
+// are logged; in strict mode they are an error, otherwise the server runs
+// degraded without them.
+func runPreflight(cfg config.Config) ([]preflight.Result, error) {
+	switch cfg.Preflight {
+	case "off":
+		return nil, nil
+	case "degraded", "strict":
+	default:
+		return nil, fmt.Errorf("PREFLIGHT %q: want degraded, strict or off", cfg.Preflight)
+	}
+	timeouts, err := preflight.ParseTimeouts(cfg.PreflightTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []preflight.Check{{Name: "collector", URL: cfg.OtelEndpoint}}
+	if cfg.MirrorURL != "" && cfg.MirrorPct > 0 {
+		checks = append(checks, preflight.Check{Name: "mirror", URL: cfg.MirrorURL})
+	}
+	if cfg.AlertWebhookURL != "" {
+		checks = append(checks, preflight.Check{Name: "alert_webhook", URL: cfg.AlertWebhookURL})
+	}
+	for i := range checks {
+		timeout, ok := timeouts[checks[i].Name]
+		if !ok {
+			timeout = time.Duration(cfg.PreflightTimeoutMs) * time.Millisecond
+		}
+		checks[i].Timeout = timeout
+	}
+
+	results := preflight.Run(context.Background(), checks)
+	for _, r := range results {
+		log.Printf("preflight: %s", r)
+	}
+	if failed := preflight.Failed(results); len(failed) > 0 && cfg.Preflight == "strict" {
+		return nil, fmt.Errorf("%d of %d backends unreachable", len(failed), len(results))
+	}
+	return results, nil
+}
+
+// addScheduledTasks binds the configured schedule entries to the jobs this
+// binary provides.
+func addScheduledTasks(sched *scheduler.Scheduler, spec string, agg *stats.Aggregator, can *canary.Canary, telemetry *observability.ExportBuffer) error {
+	snapshot := func(prefix string) error {
+		b, err := json.Marshal(agg.Snapshot())
+		if err != nil {
+			return err
+		}
+		log.Printf("%s: %s", prefix, b)
+		return nil
+	}
+	jobs := map[string]scheduler.Job{
+		// Periodic report snapshot of the rolling stats, one JSON log line.
+		"stats-snapshot": func(context.Context) error { return snapshot("stats snapshot") },
+		// The same, more often, while metrics cannot reach the collector, so
+		// the run's numbers survive even if the buffer overflows.
+		"stats-fallback": func(context.Context) error {
+			if !telemetry.Down() {
+				return nil
+			}
+			return snapshot("stats snapshot (collector down)")
+		},
+		// Synthetic requests against our own endpoints.
+		"canary": can.Probe,
+	}
+
+	specs, err := scheduler.ParseSpecs(spec)
+	if err != nil {
+		return err
+	}
+	for _, sp := range specs {
+		job, ok := jobs[sp.Name]
+		if !ok {
+			return fmt.Errorf("unknown scheduled task %q", sp.Name)
+		}
+		schedule, err := scheduler.Parse(sp.Expr)
+		if err != nil {
+			return err
+		}
+		sched.Add(scheduler.Task{Name: sp.Name, Schedule: schedule, Policy: sp.Policy, Job: job})
+	}
+	return nil
 }
@@ -0,0 +1,174 @@
+// Command stats reads a running server's /stats. Its diff subcommand
+// snapshots /stats, runs an action (or waits for Enter), waits for the stats
+// window to roll over and snapshots again, then prints what changed per
+// endpoint, with 95% confidence intervals where the snapshot allows one:
+//
+//	go run ./cmd/stats diff -run 'curl -XPUT "localhost:9091/admin/hedge-budget?percent=20"'
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"go-routine-stress/internal/stats"
+)
+
+// z95 is the two-sided 95% normal quantile.
+const z95 = 1.96
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 || os.Args[1] != "diff" {
+		log.Fatalf("usage: stats diff [-server URL] [-run command] [-settle duration] [-endpoint name]")
+	}
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:8080", "server base URL")
+	run := fs.String("run", "", "shell command performing the action (default: wait for Enter)")
+	settle := fs.Duration("settle", 0, "wait after the action before the second snapshot (default: the stats window, so the windows do not overlap)")
+	endpoint := fs.String("endpoint", "", "only show this endpoint")
+	_ = fs.Parse(os.Args[2:])
+
+	ctx := context.Background()
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	before, err := fetch(ctx, client, *server)
+	if err != nil {
+		log.Fatalf("before: %v", err)
+	}
+	log.Printf("before: %s, window %.0fs", before.At.Format(time.TimeOnly), before.WindowSec)
+
+	if *run != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", *run)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Fatalf("action: %v", err)
+		}
+	} else {
+		log.Printf("perform the action, then press Enter")
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+
+	wait := *settle
+	if wait == 0 {
+		wait = time.Duration(before.WindowSec * float64(time.Second))
+	}
+	log.Printf("waiting %s for the window to roll over", wait)
+	time.Sleep(wait)
+
+	after, err := fetch(ctx, client, *server)
+	if err != nil {
+		log.Fatalf("after: %v", err)
+	}
+	printDiff(os.Stdout, before, after, *endpoint)
+}
+
+func fetch(ctx context.Context, client *http.Client, server string) (stats.Snapshot, error) {
+	var snap stats.Snapshot
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"/stats", nil)
+	if err != nil {
+		return snap, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return snap, fmt.Errorf("GET /stats: %s", resp.Status)
+	}
+	return snap, json.NewDecoder(resp.Body).Decode(&snap)
+}
+
+// row is one metric of one endpoint. ci is the half-width of the 95%
+// interval of the delta, NaN when the snapshot holds too little to estimate it.
+type row struct {
+	metric        string
+	before, after float64
+	ci            float64
+	format        string
+}
+
+func printDiff(out io.Writer, before, after stats.Snapshot, only string) {
+	byName := func(s stats.Snapshot) map[string]stats.EndpointStats {
+		m := make(map[string]stats.EndpointStats, len(s.Endpoints))
+		for _, es := range s.Endpoints {
+			m[es.Endpoint] = es
+		}
+		return m
+	}
+	b, a := byName(before), byName(after)
+
+	names := make([]string, 0, len(a)+len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\tmetric\tbefore\tafter\tdelta\t95% CI\t")
+	for _, name := range names {
+		if only != "" && name != only {
+			continue
+		}
+		eb, ea := b[name], a[name]
+		for _, r := range rows(eb, ea, before.WindowSec, after.WindowSec) {
+			delta := r.after - r.before
+			ci := "n/a"
+			if !math.IsNaN(r.ci) {
+				ci = fmt.Sprintf("±"+r.format, r.ci)
+				if math.Abs(delta) > r.ci {
+					ci += " *"
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t"+r.format+"\t"+r.format+"\t%+"+r.format[1:]+"\t%s\t\n", name, r.metric, r.before, r.after, delta, ci)
+		}
+	}
+	_ = tw.Flush()
+	fmt.Fprintln(out, "* the interval excludes zero. Percentiles are histogram bucket bounds, without an interval.")
+}
+
+func rows(b, a stats.EndpointStats, winB, winA float64) []row {
+	return []row{
+		{metric: "rps", before: b.RPS, after: a.RPS, ci: rpsCI(b.Requests, winB, a.Requests, winA), format: "%.1f"},
+		{metric: "error %", before: 100 * b.ErrorRate, after: 100 * a.ErrorRate, ci: 100 * proportionCI(b.ErrorRate, b.Requests, a.ErrorRate, a.Requests), format: "%.2f"},
+		{metric: "p50 ms", before: b.P50Ms, after: a.P50Ms, ci: math.NaN(), format: "%.0f"},
+		{metric: "p95 ms", before: b.P95Ms, after: a.P95Ms, ci: math.NaN(), format: "%.0f"},
+		{metric: "p99 ms", before: b.P99Ms, after: a.P99Ms, ci: math.NaN(), format: "%.0f"},
+	}
+}
+
+// rpsCI treats each window's request count as Poisson, so a rate of n/w has
+// a standard error of sqrt(n)/w.
+func rpsCI(nB int64, winB float64, nA int64, winA float64) float64 {
+	if winB <= 0 || winA <= 0 || nB+nA == 0 {
+		return math.NaN()
+	}
+	return z95 * math.Sqrt(float64(nB)/(winB*winB)+float64(nA)/(winA*winA))
+}
+
+// proportionCI is the normal-approximation interval of a difference of two
+// proportions.
+func proportionCI(pB float64, nB int64, pA float64, nA int64) float64 {
+	if nB == 0 || nA == 0 {
+		return math.NaN()
+	}
+	return z95 * math.Sqrt(pB*(1-pB)/float64(nB)+pA*(1-pA)/float64(nA))
+}
@@ -0,0 +1,222 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/stats"
+)
+
+// Kind selects what a rule measures.
+type Kind string
+
+const (
+	// KindErrorRate fires per endpoint when the error ratio exceeds Threshold.
+	KindErrorRate Kind = "error_rate"
+	// KindP99 fires per endpoint when p99 latency (ms) exceeds Threshold.
+	KindP99 Kind = "p99_ms"
+	// KindGoroutineGrowth fires when the goroutine count grew by more than
+	// Threshold over Window.
+	KindGoroutineGrowth Kind = "goroutine_growth"
+)
+
+// Rule is one alert condition evaluated over a window.
+type Rule struct {
+	Name      string        `json:"name"`
+	Kind      Kind          `json:"kind"`
+	Window    time.Duration `json:"-"`
+	Threshold float64       `json:"threshold"`
+
+	// MinRequests suppresses per-endpoint rules on too little traffic.
+	MinRequests int64 `json:"minRequests,omitempty"`
+}
+
+// MarshalJSON reports Window in seconds, matching the /stats windowSec field.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	type plain Rule
+	return json.Marshal(struct {
+		plain
+		WindowSec float64 `json:"windowSec"`
+	}{plain(r), r.Window.Seconds()})
+}
+
+// Alert is a firing (or just resolved) instance of a rule.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`
+	Status    string    `json:"status"` // firing | resolved
+}
+
+// Notifier receives alert state changes.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+type sample struct {
+	at time.Time
+	n  int
+}
+
+// Engine evaluates rules against the in-process stats on an interval.
+type Engine struct {
+	clock    clock.Clock
+	agg      *stats.Aggregator
+	rules    []Rule
+	interval time.Duration
+	notifier Notifier // may be nil
+
+	mu      sync.Mutex
+	firing  map[string]Alert // key: rule + endpoint
+	samples []sample         // goroutine counts, oldest first
+}
+
+// New creates an engine. notifier may be nil.
+func New(clk clock.Clock, agg *stats.Aggregator, rules []Rule, interval time.Duration, notifier Notifier) *Engine {
+	return &Engine{
+		clock:    clk,
+		agg:      agg,
+		rules:    rules,
+		interval: interval,
+		notifier: notifier,
+		firing:   make(map[string]Alert),
+	}
+}
+
+// Firing returns the currently firing alerts.
+func (e *Engine) Firing() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out := make([]Alert, 0, len(e.firing))
+	for _, a := range e.firing {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Since.Before(out[j].Since) })
+	return out
+}
+
+// Rules returns the configured rules.
+func (e *Engine) Rules() []Rule { return e.rules }
+
+// Run evaluates the rules every interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.evaluateSafely(ctx)
+		}
+	}
+}
+
+func (e *Engine) evaluateSafely(ctx context.Context) {
+	var err error
+	defer safego.Recover(ctx, "alerts.evaluate", &err)
+	e.evaluate(ctx)
+}
+
+// evaluate computes every rule, then diffs the result against the firing set
+// and notifies on transitions.
+func (e *Engine) evaluate(ctx context.Context) {
+	now := e.clock.Now()
+	growth, haveGrowth := e.sampleGoroutines(now)
+
+	active := make(map[string]Alert)
+	for _, r := range e.rules {
+		switch r.Kind {
+		case KindErrorRate, KindP99:
+			for _, es := range e.agg.Summarize(r.Window) {
+				if es.Requests == 0 || es.Requests < r.MinRequests {
+					continue
+				}
+				v := es.ErrorRate
+				if r.Kind == KindP99 {
+					v = es.P99Ms
+				}
+				if v > r.Threshold {
+					active[r.Name+"/"+es.Endpoint] = Alert{Rule: r.Name, Endpoint: es.Endpoint, Value: v, Threshold: r.Threshold}
+				}
+			}
+		case KindGoroutineGrowth:
+			if g := growth(r.Window); haveGrowth && g > r.Threshold {
+				active[r.Name] = Alert{Rule: r.Name, Value: g, Threshold: r.Threshold}
+			}
+		}
+	}
+
+	var changed []Alert
+	e.mu.Lock()
+	for key, a := range active {
+		if prev, ok := e.firing[key]; ok {
+			a.Since = prev.Since
+		} else {
+			a.Since = now
+			changed = append(changed, withStatus(a, "firing"))
+		}
+		e.firing[key] = withStatus(a, "firing")
+	}
+	for key, a := range e.firing {
+		if _, ok := active[key]; !ok {
+			delete(e.firing, key)
+			changed = append(changed, withStatus(a, "resolved"))
+		}
+	}
+	e.mu.Unlock()
+
+	if e.notifier != nil {
+		for _, a := range changed {
+			if err := e.notifier.Notify(ctx, a); err != nil {
+				log.Printf("alert %s %s: notify failed: %v", a.Rule, a.Status, err)
+			}
+		}
+	}
+}
+
+// sampleGoroutines records the current goroutine count and returns a function
+// reporting growth over a window. History is trimmed to the longest rule window.
+func (e *Engine) sampleGoroutines(now time.Time) (func(time.Duration) float64, bool) {
+	var longest time.Duration
+	for _, r := range e.rules {
+		if r.Kind == KindGoroutineGrowth {
+			longest = max(longest, r.Window)
+		}
+	}
+	if longest == 0 {
+		return nil, false
+	}
+
+	n := runtime.NumGoroutine()
+	e.samples = append(e.samples, sample{at: now, n: n})
+	for len(e.samples) > 1 && now.Sub(e.samples[0].at) > longest {
+		e.samples = e.samples[1:]
+	}
+
+	samples := e.samples
+	return func(window time.Duration) float64 {
+		for _, s := range samples {
+			if now.Sub(s.at) <= window {
+				return float64(n - s.n)
+			}
+		}
+		return 0
+	}, true
+}
+
+func withStatus(a Alert, status string) Alert {
+	a.Status = status
+	return a
+}
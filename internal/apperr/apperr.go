@@ -0,0 +1,258 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Code is a stable, machine-readable identifier for a failure class.
+type Code string
+
+const (
+	CodeTimeout           Code = "timeout"
+	CodeCanceled          Code = "canceled"
+	CodeDependencyFailure Code = "dependency_failure"
+	CodeOverloaded        Code = "overloaded"
+	CodeDraining          Code = "draining"
+	CodeBadRequest        Code = "bad_request"
+	CodeInternal          Code = "internal"
+)
+
+// Retryable reports whether a client may reasonably retry a request that failed with this code.
+func (c Code) Retryable() bool {
+	switch c {
+	case CodeTimeout, CodeDependencyFailure, CodeOverloaded, CodeDraining:
+		return true
+	}
+	return false
+}
+
+// Error annotates an error with its code and the dependency that produced it.
+type Error struct {
+	Code       Code
+	Dependency string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Dependency == "" {
+		return e.Err.Error()
+	}
+	return e.Dependency + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New wraps err with an explicit code and dependency. It returns nil if err is nil.
+func New(code Code, dependency string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Dependency: dependency, Err: err}
+}
+
+// Dependency wraps err as a failure of the named dependency, deriving the code from the cause.
+func Dependency(dependency string, err error) error {
+	return New(contextCode(err, CodeDependencyFailure), dependency, err)
+}
+
+// Queued records what a request had been through in a queue when it failed
+// there: rejected, timed out or cancelled while waiting.
+type Queued struct {
+	Queue string
+	// Position is the number of requests ahead of it when it left the queue.
+	Position int
+	Waited   time.Duration
+	// EstimatedWait is how long the requests ahead would take at the current
+	// service rate, zero when the rate is unknown.
+	EstimatedWait time.Duration
+	Err           error
+}
+
+func (e *Queued) Error() string { return e.Err.Error() }
+
+func (e *Queued) Unwrap() error { return e.Err }
+
+// QueuedOf returns the queue state carried by err, if any.
+func QueuedOf(err error) (*Queued, bool) {
+	var q *Queued
+	ok := errors.As(err, &q)
+	return q, ok
+}
+
+// Progress records how far cooperative work had got when it stopped short,
+// cancelled between two of its steps.
+type Progress struct {
+	Done  int
+	Total int
+	Err   error
+}
+
+func (e *Progress) Error() string { return e.Err.Error() }
+
+func (e *Progress) Unwrap() error { return e.Err }
+
+// ProgressOf returns the progress carried by err, if any.
+func ProgressOf(err error) (*Progress, bool) {
+	var p *Progress
+	ok := errors.As(err, &p)
+	return p, ok
+}
+
+// Budgets records the share of a request's deadline each of its calls was
+// given and used, for a request failing part way through them.
+type Budgets struct {
+	Calls []CallBudget
+	Err   error
+}
+
+// CallBudget is one call's share of a deadline and the time it took of it.
+type CallBudget struct {
+	Service   string
+	Allocated time.Duration
+	Consumed  time.Duration
+}
+
+func (e *Budgets) Error() string { return e.Err.Error() }
+
+func (e *Budgets) Unwrap() error { return e.Err }
+
+// BudgetsOf returns the call budgets carried by err, if any.
+func BudgetsOf(err error) (*Budgets, bool) {
+	var b *Budgets
+	ok := errors.As(err, &b)
+	return b, ok
+}
+
+// CodeOf returns the code carried by err, falling back to context errors and then CodeInternal.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return contextCode(err, CodeInternal)
+}
+
+// DependencyOf returns the dependencies that produced err, comma-separated when
+// several failures were joined.
+func DependencyOf(err error) string {
+	var deps []string
+	walk(err, func(err error) {
+		if e, ok := err.(*Error); ok && e.Dependency != "" && !slices.Contains(deps, e.Dependency) {
+			deps = append(deps, e.Dependency)
+		}
+	})
+	return strings.Join(deps, ",")
+}
+
+// Causes flattens the wrap tree of err into messages, outermost first.
+// Joined errors are expanded depth-first; repeated messages are collapsed.
+func Causes(err error) []string {
+	var out []string
+	walk(err, func(err error) {
+		if msg := err.Error(); len(out) == 0 || out[len(out)-1] != msg {
+			out = append(out, msg)
+		}
+	})
+	return out
+}
+
+// walk visits err and every error it wraps, following both Unwrap forms.
+func walk(err error, visit func(error)) {
+	for err != nil {
+		visit(err)
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				walk(e, visit)
+			}
+			return
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+func contextCode(err error, def Code) Code {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	}
+	return def
+}
+
+// ReasonKey is the gin context key under which handlers publish the cancellation
+// reason of a failed request, so middleware can attach it to metrics.
+const ReasonKey = "apperr.reason"
+
+// CancelCause is attached to contexts via context.WithCancelCause / WithTimeoutCause
+// to record why work was cancelled. It wraps the matching context error so
+// errors.Is(err, context.DeadlineExceeded) keeps working.
+type CancelCause struct {
+	Reason string
+	Err    error
+}
+
+func (c *CancelCause) Error() string { return c.Reason + ": " + c.Err.Error() }
+
+func (c *CancelCause) Unwrap() error { return c.Err }
+
+var (
+	ErrHandlerTimeout    = &CancelCause{Reason: "handler_timeout", Err: context.DeadlineExceeded}
+	ErrClientDisconnect  = &CancelCause{Reason: "client_disconnect", Err: context.Canceled}
+	ErrShutdownDrain     = &CancelCause{Reason: "shutdown_drain", Err: context.Canceled}
+	ErrTryTimeout        = &CancelCause{Reason: "try_timeout", Err: context.DeadlineExceeded}
+	ErrBudgetExhausted   = &CancelCause{Reason: "budget_exhausted", Err: context.DeadlineExceeded}
+	ErrAdminCancel       = &CancelCause{Reason: "admin_cancel", Err: context.Canceled}
+	ErrChainBudget       = &CancelCause{Reason: "chain_budget", Err: context.DeadlineExceeded}
+	ErrSiblingFailed     = &CancelCause{Reason: "sibling_failed", Err: context.Canceled}
+	ErrQuorumReached     = &CancelCause{Reason: "quorum_reached", Err: context.Canceled}
+	ErrHedgeLost         = &CancelCause{Reason: "hedge_lost", Err: context.Canceled}
+	ErrRaceWon           = &CancelCause{Reason: "race_won", Err: context.Canceled}
+	ErrShardTimeout      = &CancelCause{Reason: "shard_timeout", Err: context.DeadlineExceeded}
+	ErrFallbackTimeout   = &CancelCause{Reason: "fallback_timeout", Err: context.DeadlineExceeded}
+	ErrBackgroundTimeout = &CancelCause{Reason: "background_timeout", Err: context.DeadlineExceeded}
+	ErrCallBudget        = &CancelCause{Reason: "call_budget", Err: context.DeadlineExceeded}
+	ErrSlowConsumer      = &CancelCause{Reason: "slow_consumer", Err: context.Canceled}
+)
+
+// Known reports whether name is a failure code or a cancellation reason.
+func Known(name string) bool {
+	switch Code(name) {
+	case CodeTimeout, CodeCanceled, CodeDependencyFailure, CodeOverloaded, CodeDraining, CodeBadRequest, CodeInternal:
+		return true
+	}
+	for _, c := range []*CancelCause{
+		ErrHandlerTimeout, ErrClientDisconnect, ErrShutdownDrain, ErrTryTimeout, ErrBudgetExhausted,
+		ErrAdminCancel, ErrChainBudget, ErrSiblingFailed, ErrQuorumReached, ErrHedgeLost,
+		ErrRaceWon, ErrShardTimeout, ErrFallbackTimeout, ErrBackgroundTimeout, ErrCallBudget,
+		ErrSlowConsumer,
+	} {
+		if c.Reason == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReasonOf returns the cancellation reason carried by err, if any.
+func ReasonOf(err error) string {
+	var c *CancelCause
+	if errors.As(err, &c) {
+		return c.Reason
+	}
+	return ""
+}
+
+// ClientDisconnected reports whether ctx was cancelled by the HTTP server because
+// the client went away: a bare context.Canceled with no server-imposed cause.
+func ClientDisconnected(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled) && context.Cause(ctx) == context.Canceled
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Entry is one admin action.
+type Entry struct {
+	At        time.Time `json:"at"`
+	Actor     string    `json:"actor"`
+	Role      string    `json:"role,omitempty"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Old       any       `json:"old,omitempty"`
+	New       any       `json:"new,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// Log keeps the most recent admin actions and writes each one as a JSON log
+// line, so the full history survives in the process logs.
+type Log struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries []Entry // ring, oldest at next once full
+	next    int
+	full    bool
+}
+
+// New creates a log keeping the last capacity entries in memory.
+func New(clk clock.Clock, capacity int) *Log {
+	return &Log{clock: clk, entries: make([]Entry, max(capacity, 1))}
+}
+
+// Record stamps e with the current time, keeps it and logs it.
+func (l *Log) Record(e Entry) Entry {
+	e.At = l.clock.Now()
+
+	l.mu.Lock()
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	l.full = l.full || l.next == 0
+	l.mu.Unlock()
+
+	if b, err := json.Marshal(e); err == nil {
+		log.Printf("audit: %s", b)
+	}
+	return e
+}
+
+// List returns the kept entries, oldest first.
+func (l *Log) List() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]Entry(nil), l.entries[:l.next]...)
+	}
+	return append(append([]Entry(nil), l.entries[l.next:]...), l.entries[:l.next]...)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Role is what a caller may do on the admin API.
+type Role int
+
+const (
+	RoleNone     Role = iota
+	RoleReader        // read-only: listings, dumps, timelines
+	RoleOperator      // may also cancel, drain and change settings
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return "reader"
+	case RoleOperator:
+		return "operator"
+	}
+	return "none"
+}
+
+// Principal is one configured caller.
+type Principal struct {
+	Name  string
+	Role  Role
+	token string
+}
+
+// Tokens maps bearer tokens to principals. An empty set disables auth.
+type Tokens struct {
+	principals []Principal
+}
+
+// Enabled reports whether any token is configured.
+func (t *Tokens) Enabled() bool { return t != nil && len(t.principals) > 0 }
+
+// Lookup returns the principal holding token. Every configured token is
+// compared in constant time, so timing does not reveal near matches.
+func (t *Tokens) Lookup(token string) (Principal, bool) {
+	var found Principal
+	ok := false
+	for _, p := range t.principals {
+		if subtle.ConstantTimeCompare([]byte(p.token), []byte(token)) == 1 {
+			found, ok = p, true
+		}
+	}
+	return found, ok
+}
+
+// Load reads a token file; an empty path yields an empty (disabled) set.
+func Load(path string) (*Tokens, error) {
+	if path == "" {
+		return &Tokens{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads one "role name token" entry per line, role being reader or
+// operator. Blank lines and lines starting with '#' are ignored.
+func Parse(r io.Reader) (*Tokens, error) {
+	t := &Tokens{}
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want \"role name token\"", n)
+		}
+		var role Role
+		switch fields[0] {
+		case "reader":
+			role = RoleReader
+		case "operator":
+			role = RoleOperator
+		default:
+			return nil, fmt.Errorf("line %d: unknown role %q (want reader or operator)", n, fields[0])
+		}
+		if seen[fields[2]] {
+			return nil, fmt.Errorf("line %d: duplicate token", n)
+		}
+		seen[fields[2]] = true
+		t.principals = append(t.principals, Principal{Name: fields[1], Role: role, token: fields[2]})
+	}
+	return t, sc.Err()
+}
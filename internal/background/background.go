@@ -0,0 +1,252 @@
+// Package background runs work a request leaves behind (a cache refresh, a
+// copy to submit) under an explicit policy for the context it inherits.
+package background
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+)
+
+// Policy decides which context background work runs under.
+type Policy string
+
+const (
+	// Inherit runs the work under the request's context: it ends with the
+	// response, whether or not it is done.
+	Inherit Policy = "inherit"
+	// Detached keeps the request's values but not its cancellation or
+	// deadline, and bounds the work by a timeout of its own.
+	Detached Policy = "detached"
+	// Drain lets the work run until the server's shutdown drain: shutdown
+	// waits for it, and cancels it only once the drain deadline has passed.
+	Drain Policy = "drain"
+)
+
+// ParsePolicy parses a policy name.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case Inherit, Detached, Drain:
+		return p, nil
+	}
+	return "", fmt.Errorf("background policy %q: want %s, %s or %s", s, Inherit, Detached, Drain)
+}
+
+// ParsePolicies parses "job=policy" entries separated by ';', e.g.
+// "fallback.refresh=drain".
+func ParsePolicies(s string) (map[string]Policy, error) {
+	out := make(map[string]Policy)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		job, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("background policy %q: want job=policy", entry)
+		}
+		p, err := ParsePolicy(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimSpace(job)] = p
+	}
+	return out, nil
+}
+
+// Runner starts background jobs and accounts for how each one ended. A job
+// cancelled by the context its policy gave it (the request ending under
+// inherit, the timeout under detached, the drain deadline under drain) is
+// counted by what cancelled it: a job often cut short this way runs under the
+// wrong policy.
+type Runner struct {
+	shutdown context.Context
+	clock    clock.Clock
+	policies map[string]Policy
+	timeout  time.Duration
+	m        *observability.Metrics
+
+	// drains tracks the jobs running under Drain, for Wait.
+	drains sync.WaitGroup
+
+	mu    sync.Mutex
+	stats map[jobKey]*Stats
+}
+
+type jobKey struct {
+	job    string
+	policy Policy
+}
+
+// Stats counts the runs of one job under one policy.
+type Stats struct {
+	Job         string           `json:"job"`
+	Policy      Policy           `json:"policy"`
+	Running     int64            `json:"running"`
+	Outcomes    map[string]int64 `json:"outcomes"`
+	CancelledBy map[string]int64 `json:"cancelledBy"`
+}
+
+// NewRunner creates a runner whose drain jobs are cancelled when shutdown
+// ends. Jobs run under their policy in policies, else Detached, and detached
+// jobs run for at most timeout on clk unless started with one of their own.
+func NewRunner(shutdown context.Context, clk clock.Clock, policies map[string]Policy, timeout time.Duration, m *observability.Metrics) *Runner {
+	return &Runner{shutdown: shutdown, clock: clk, policies: policies, timeout: timeout, m: m, stats: make(map[jobKey]*Stats)}
+}
+
+// Policy returns the configured policy of job.
+func (r *Runner) Policy(job string) Policy {
+	if p, ok := r.policies[job]; ok {
+		return p
+	}
+	return Detached
+}
+
+// Timeout returns how long detached jobs run by default.
+func (r *Runner) Timeout() time.Duration {
+	return r.timeout
+}
+
+// Go runs fn in a new goroutine under policy p, started from the request
+// context ctx. timeout bounds a detached job; 0 means the runner's default.
+// A panic in fn is contained like in safego.Go and counts as a failure.
+func (r *Runner) Go(ctx context.Context, job string, p Policy, timeout time.Duration, fn func(context.Context) error) {
+	if timeout <= 0 {
+		timeout = r.timeout
+	}
+	jctx, stop := r.context(ctx, p, timeout)
+	if p == Drain {
+		r.drains.Add(1)
+	}
+	r.started(job, p)
+
+	go func() {
+		var err error
+		defer func() {
+			r.ended(ctx, jctx, job, p, err)
+			stop()
+			if p == Drain {
+				r.drains.Done()
+			}
+		}()
+		defer safego.Recover(jctx, "background."+job, &err)
+		err = fn(jctx)
+	}()
+}
+
+// context derives the context a job runs under from the request context.
+// Detached and drain jobs leave the request's cancellation behind on purpose,
+// so the context audit does not count that as a break.
+func (r *Runner) context(ctx context.Context, p Policy, timeout time.Duration) (context.Context, context.CancelFunc) {
+	switch p {
+	case Inherit:
+		return ctx, func() {}
+	case Drain:
+		jctx, cancel := context.WithCancelCause(ctxaudit.Detach(context.WithoutCancel(ctx)))
+		stop := context.AfterFunc(r.shutdown, func() { cancel(context.Cause(r.shutdown)) })
+		return jctx, func() { stop(); cancel(nil) }
+	}
+	return clock.WithTimeoutCause(ctxaudit.Detach(context.WithoutCancel(ctx)), r.clock, timeout, apperr.ErrBackgroundTimeout)
+}
+
+// Wait waits for the jobs running under Drain, or for ctx to end.
+func (r *Runner) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.drains.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the counts per job and policy.
+func (r *Runner) Stats() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Stats, 0, len(r.stats))
+	for _, st := range r.stats {
+		s := *st
+		s.Outcomes, s.CancelledBy = maps.Clone(st.Outcomes), maps.Clone(st.CancelledBy)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Job != out[j].Job {
+			return out[i].Job < out[j].Job
+		}
+		return out[i].Policy < out[j].Policy
+	})
+	return out
+}
+
+func (r *Runner) started(job string, p Policy) {
+	r.mu.Lock()
+	r.statsLocked(job, p).Running++
+	r.mu.Unlock()
+}
+
+// ended records how a job ended: ok, failed, or cancelled, and then by what.
+func (r *Runner) ended(ctx, jctx context.Context, job string, p Policy, err error) {
+	outcome, by := "ok", ""
+	switch {
+	case err != nil && jctx.Err() != nil:
+		outcome, by = "cancelled", r.cancelledBy(jctx)
+	case err != nil:
+		outcome = "failed"
+	}
+
+	r.mu.Lock()
+	st := r.statsLocked(job, p)
+	st.Running--
+	st.Outcomes[outcome]++
+	if by != "" {
+		st.CancelledBy[by]++
+	}
+	r.mu.Unlock()
+
+	mctx := context.WithoutCancel(ctx)
+	attrs := []attribute.KeyValue{attribute.String("job", job), attribute.String("policy", string(p))}
+	r.m.BackgroundJobs.Add(mctx, 1, metric.WithAttributes(append(attrs, attribute.String("outcome", outcome))...))
+	if by != "" {
+		r.m.BackgroundCancellations.Add(mctx, 1, metric.WithAttributes(append(attrs, attribute.String("by", by))...))
+	}
+}
+
+// cancelledBy names what ended a job's context: the request, the detached
+// timeout or the shutdown drain.
+func (r *Runner) cancelledBy(jctx context.Context) string {
+	switch cause := context.Cause(jctx); {
+	case cause == apperr.ErrBackgroundTimeout:
+		return "timeout"
+	case r.shutdown.Err() != nil && cause == context.Cause(r.shutdown):
+		return "shutdown"
+	}
+	return "request"
+}
+
+func (r *Runner) statsLocked(job string, p Policy) *Stats {
+	k := jobKey{job, p}
+	st, ok := r.stats[k]
+	if !ok {
+		st = &Stats{Job: job, Policy: p, Outcomes: make(map[string]int64), CancelledBy: make(map[string]int64)}
+		r.stats[k] = st
+	}
+	return st
+}
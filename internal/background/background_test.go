@@ -0,0 +1,124 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+)
+
+type valueKey struct{}
+
+func newRunner(t *testing.T, shutdown context.Context, timeout time.Duration) *Runner {
+	t.Helper()
+	m, err := observability.NewMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRunner(shutdown, clock.Real{}, nil, timeout, m)
+}
+
+// run starts a job that waits for its context to end and reports the cause.
+func run(r *Runner, ctx context.Context, p Policy) <-chan error {
+	done := make(chan error, 1)
+	r.Go(ctx, "test", p, 0, func(jctx context.Context) error {
+		if jctx.Value(valueKey{}) != "v" {
+			done <- errors.New("request values lost")
+			return nil
+		}
+		<-jctx.Done()
+		done <- context.Cause(jctx)
+		return context.Cause(jctx)
+	})
+	return done
+}
+
+func wait(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("job still running")
+		return nil
+	}
+}
+
+// waitStats waits for the job's run to be accounted for: ended records it
+// after fn returns.
+func waitStats(t *testing.T, r *Runner, p Policy) Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, st := range r.Stats() {
+			if st.Job == "test" && st.Policy == p && st.Running == 0 {
+				return st
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no finished %s run", p)
+	return Stats{}
+}
+
+func TestInheritEndsWithRequest(t *testing.T) {
+	r := newRunner(t, context.Background(), time.Hour)
+	ctx, cancel := context.WithCancelCause(context.WithValue(context.Background(), valueKey{}, "v"))
+	done := run(r, ctx, Inherit)
+
+	cause := errors.New("client gone")
+	cancel(cause)
+	if err := wait(t, done); err != cause {
+		t.Fatalf("job ended with %v, want the request's cause", err)
+	}
+	st := waitStats(t, r, Inherit)
+	if st.Outcomes["cancelled"] != 1 || st.CancelledBy["request"] != 1 {
+		t.Fatalf("stats = %+v, want one run cancelled by the request", st)
+	}
+}
+
+func TestDetachedOutlivesRequest(t *testing.T) {
+	r := newRunner(t, context.Background(), 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), valueKey{}, "v"))
+	cancel()
+	done := run(r, ctx, Detached)
+
+	if err := wait(t, done); err != apperr.ErrBackgroundTimeout {
+		t.Fatalf("job ended with %v, want %v", err, apperr.ErrBackgroundTimeout)
+	}
+	st := waitStats(t, r, Detached)
+	if st.CancelledBy["timeout"] != 1 || st.CancelledBy["request"] != 0 {
+		t.Fatalf("stats = %+v, want one run cancelled by its timeout", st)
+	}
+}
+
+func TestDrainWaitedAtShutdown(t *testing.T) {
+	shutdown, stop := context.WithCancelCause(context.Background())
+	r := newRunner(t, shutdown, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), valueKey{}, "v"))
+	cancel()
+	done := run(r, ctx, Drain)
+
+	// Neither the request nor the detached timeout ends a drain job.
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelWait()
+	if err := r.Wait(waitCtx); err == nil {
+		t.Fatal("Wait returned while the drain job was running")
+	}
+
+	stop(apperr.ErrShutdownDrain)
+	if err := wait(t, done); err != apperr.ErrShutdownDrain {
+		t.Fatalf("job ended with %v, want %v", err, apperr.ErrShutdownDrain)
+	}
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st := waitStats(t, r, Drain)
+	if st.CancelledBy["shutdown"] != 1 {
+		t.Fatalf("stats = %+v, want one run cancelled by shutdown", st)
+	}
+}
@@ -0,0 +1,230 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/services"
+)
+
+// Strategy selects how the next replica is chosen among the healthy ones.
+type Strategy string
+
+const (
+	// RoundRobin cycles through the replicas in order.
+	RoundRobin Strategy = "round_robin"
+	// Random picks uniformly at random.
+	Random Strategy = "random"
+	// LeastInflight picks the replica with the fewest calls in flight.
+	LeastInflight Strategy = "least_inflight"
+	// P2C samples two replicas at random and keeps the less loaded one
+	// (power of two choices).
+	P2C Strategy = "p2c"
+)
+
+// ParseStrategy validates a strategy name.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(s); st {
+	case RoundRobin, Random, LeastInflight, P2C:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown balancing strategy %q (want round_robin, random, least_inflight or p2c)", s)
+}
+
+// Outlier configures Envoy-style outlier detection. A replica is ejected
+// after Consecutive failures in a row, where a failure is an error or, when
+// SlowThreshold is set, a call slower than it. The n-th ejection lasts
+// BaseEjection·2^(n-1), capped at MaxEjection; every successful call after a
+// return lowers n again. At most MaxEjectedPct of the replicas (and never the
+// last one) are ejected at a time.
+type Outlier struct {
+	Consecutive   int
+	SlowThreshold time.Duration
+	BaseEjection  time.Duration
+	MaxEjection   time.Duration
+	MaxEjectedPct int
+}
+
+// Replica is a balanced instance and its outlier state.
+type Replica struct {
+	*services.Instance
+
+	inflight atomic.Int64
+
+	// guarded by Balancer.mu
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// State is a point-in-time view of a replica for the admin API.
+type State struct {
+	Name         string    `json:"name"`
+	Profile      string    `json:"profile"`
+	Inflight     int64     `json:"inflight"`
+	Failures     int       `json:"consecutiveFailures"`
+	Ejections    int       `json:"ejections"`
+	Ejected      bool      `json:"ejected"`
+	EjectedUntil time.Time `json:"ejectedUntil,omitzero"`
+}
+
+// Balancer spreads calls over replicas, skipping the ejected ones.
+type Balancer struct {
+	clock    clock.Clock
+	rng      services.RNG
+	strategy Strategy
+	outlier  Outlier
+
+	replicas []*Replica
+	next     atomic.Uint64
+
+	mu sync.Mutex
+}
+
+// New creates a balancer over instances using strategy by default, drawing
+// random picks from rng unless the request has a seed of its own.
+func New(clk clock.Clock, rng services.RNG, instances []*services.Instance, strategy Strategy, outlier Outlier) *Balancer {
+	b := &Balancer{clock: clk, rng: rng, strategy: strategy, outlier: outlier}
+	for _, in := range instances {
+		b.replicas = append(b.replicas, &Replica{Instance: in})
+	}
+	return b
+}
+
+// Strategy returns the default strategy.
+func (b *Balancer) Strategy() Strategy { return b.strategy }
+
+// Pick chooses a healthy replica with strategy (the default if empty) and
+// marks it in flight; the caller must report the call with Done or Abandon.
+func (b *Balancer) Pick(ctx context.Context, strategy Strategy) *Replica {
+	if strategy == "" {
+		strategy = b.strategy
+	}
+	healthy := b.healthy()
+	rng := services.RNGFor(ctx, services.SaltBalancer, b.rng)
+
+	var r *Replica
+	switch strategy {
+	case Random:
+		r = healthy[rng.Intn(len(healthy))]
+	case LeastInflight:
+		r = healthy[0]
+		for _, c := range healthy[1:] {
+			if c.inflight.Load() < r.inflight.Load() {
+				r = c
+			}
+		}
+	case P2C:
+		r = healthy[rng.Intn(len(healthy))]
+		if len(healthy) > 1 {
+			i := rng.Intn(len(healthy) - 1)
+			if healthy[i] == r {
+				i = len(healthy) - 1
+			}
+			if c := healthy[i]; c.inflight.Load() < r.inflight.Load() {
+				r = c
+			}
+		}
+	default:
+		r = healthy[int(b.next.Add(1)-1)%len(healthy)]
+	}
+	r.inflight.Add(1)
+	return r
+}
+
+// healthy returns the replicas not currently ejected (all of them if every
+// replica is ejected, which the ejection cap should prevent).
+func (b *Balancer) healthy() []*Replica {
+	now := b.clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]*Replica, 0, len(b.replicas))
+	for _, r := range b.replicas {
+		if !now.Before(r.ejectedUntil) {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		return b.replicas
+	}
+	return out
+}
+
+// Done reports a finished call. It returns the ejection duration when the
+// call got the replica ejected.
+func (b *Balancer) Done(r *Replica, d time.Duration, err error) (ejectedFor time.Duration, ejected bool) {
+	r.inflight.Add(-1)
+	failed := err != nil || (b.outlier.SlowThreshold > 0 && d > b.outlier.SlowThreshold)
+
+	now := b.clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		r.failures = 0
+		if r.ejections > 0 && !now.Before(r.ejectedUntil) {
+			r.ejections--
+		}
+		return 0, false
+	}
+
+	r.failures++
+	if b.outlier.Consecutive <= 0 || r.failures < b.outlier.Consecutive || now.Before(r.ejectedUntil) || !b.mayEjectLocked(now) {
+		return 0, false
+	}
+
+	r.ejections++
+	ejectedFor = min(b.outlier.BaseEjection<<(r.ejections-1), b.outlier.MaxEjection)
+	if ejectedFor <= 0 {
+		// The shift overflowed for a replica ejected many times in a row.
+		ejectedFor = b.outlier.MaxEjection
+	}
+	r.ejectedUntil = now.Add(ejectedFor)
+	r.failures = 0
+	return ejectedFor, true
+}
+
+// Abandon reports a call the caller gave up on; it says nothing about the
+// replica and does not count for outlier detection.
+func (b *Balancer) Abandon(r *Replica) { r.inflight.Add(-1) }
+
+// mayEjectLocked reports whether one more replica may be ejected. b.mu must be held.
+func (b *Balancer) mayEjectLocked(now time.Time) bool {
+	ejected := 0
+	for _, r := range b.replicas {
+		if now.Before(r.ejectedUntil) {
+			ejected++
+		}
+	}
+	limit := len(b.replicas) * b.outlier.MaxEjectedPct / 100
+	return ejected+1 <= limit && ejected+1 < len(b.replicas)
+}
+
+// States returns the current state of every replica.
+func (b *Balancer) States() []State {
+	now := b.clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]State, 0, len(b.replicas))
+	for _, r := range b.replicas {
+		s := State{
+			Name:      r.Name,
+			Profile:   r.Profile.String(),
+			Inflight:  r.inflight.Load(),
+			Failures:  r.failures,
+			Ejections: r.ejections,
+			Ejected:   now.Before(r.ejectedUntil),
+		}
+		if s.Ejected {
+			s.EjectedUntil = r.ejectedUntil
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package brownout
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/stats"
+)
+
+// Controller is a brownout dimmer: it adjusts the share of requests that skip
+// optional work (Service B) from measured latency. While the worst p95 of the
+// controlled endpoints is above Target the level rises by Step every Interval,
+// and once it is back under Target·(1-Hysteresis) it falls again, so optional
+// work is shed progressively instead of all at once.
+type Controller struct {
+	Agg        *stats.Aggregator
+	Endpoints  map[string]bool
+	Target     time.Duration
+	Hysteresis float64
+	Step       int // percentage points per adjustment
+	Interval   time.Duration
+	// RNG decides which requests skip, unless the request has a seed.
+	RNG services.RNG
+
+	level atomic.Int64 // 0..100
+}
+
+// Level returns the current share of requests, in percent, that skip optional work.
+func (c *Controller) Level() int64 { return c.level.Load() }
+
+// Skip reports whether a request to endpoint should skip optional work now.
+func (c *Controller) Skip(ctx context.Context, endpoint string) bool {
+	if !c.Endpoints[endpoint] {
+		return false
+	}
+	level := c.level.Load()
+	return level > 0 && int64(services.RNGFor(ctx, services.SaltBrownout, c.RNG).Intn(100)) < level
+}
+
+// Run adjusts the level every Interval until ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.adjustSafely(ctx)
+		}
+	}
+}
+
+func (c *Controller) adjustSafely(ctx context.Context) {
+	var err error
+	defer safego.Recover(ctx, "brownout", &err)
+	c.Adjust()
+}
+
+// Adjust moves the level one step from the latest stats snapshot.
+func (c *Controller) Adjust() {
+	var worst, requests float64
+	for _, es := range c.Agg.Snapshot().Endpoints {
+		if c.Endpoints[es.Endpoint] {
+			worst = max(worst, es.P95Ms)
+			requests += float64(es.Requests)
+		}
+	}
+	if requests == 0 {
+		return // no traffic, no signal: hold the level
+	}
+
+	targetMs := float64(c.Target.Milliseconds())
+	old := c.level.Load()
+	level := old
+	switch {
+	case worst > targetMs:
+		level = min(old+int64(c.Step), 100)
+	case worst < targetMs*(1-c.Hysteresis):
+		level = max(old-int64(c.Step), 0)
+	}
+
+	if level != old {
+		c.level.Store(level)
+		log.Printf("brownout level %d%% -> %d%% (p95 %.0fms, target %.0fms)", old, level, worst, targetMs)
+	}
+}
+
+// ParseEndpoints parses endpoint names separated by ';'.
+func ParseEndpoints(s string) map[string]bool {
+	out := make(map[string]bool)
+	for _, ep := range strings.Split(s, ";") {
+		if ep = strings.TrimSpace(ep); ep != "" {
+			out[ep] = true
+		}
+	}
+	return out
+}
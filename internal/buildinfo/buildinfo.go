@@ -0,0 +1,56 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at link time, e.g.
+//
+//	go build -ldflags "-X go-routine-stress/internal/buildinfo.Version=v1.2.0 \
+//	  -X go-routine-stress/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X go-routine-stress/internal/buildinfo.Date=$(date -u +%FT%TZ)"
+//
+// Empty values fall back to what the Go toolchain stamped into the binary.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info identifies the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // built from a dirty tree
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the linked-in build info, completed from debug.ReadBuildInfo.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	return info
+}
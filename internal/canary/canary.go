@@ -0,0 +1,123 @@
+package canary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+)
+
+// Header marks canary traffic so it can be told apart from real load.
+const Header = "X-Canary"
+
+// Canary probes the server's own endpoints and tracks consecutive failures
+// per endpoint. It reports not-ready once any endpoint reaches the threshold.
+type Canary struct {
+	client    *http.Client
+	baseURL   string
+	endpoints []string
+	threshold int
+	clock     clock.Clock
+	m         *observability.Metrics
+
+	mu          sync.Mutex
+	consecutive map[string]int
+}
+
+// New creates a canary for baseURL (e.g. http://127.0.0.1:8080).
+func New(clk clock.Clock, m *observability.Metrics, baseURL string, endpoints []string, threshold int, timeout time.Duration) *Canary {
+	return &Canary{
+		client:      &http.Client{Timeout: timeout},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		endpoints:   endpoints,
+		threshold:   threshold,
+		clock:       clk,
+		m:           m,
+		consecutive: make(map[string]int),
+	}
+}
+
+// Probe calls every endpoint once, sequentially so a probe round adds at most
+// one request of load at a time. It returns an error if any probe failed.
+func (c *Canary) Probe(ctx context.Context) error {
+	var failed []string
+	for _, ep := range c.endpoints {
+		if err := c.probe(ctx, ep); err != nil {
+			failed = append(failed, ep+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("canary failures: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (c *Canary) probe(ctx context.Context, endpoint string) error {
+	start := c.clock.Now()
+	err := c.call(ctx, endpoint)
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	c.m.CanaryDuration.Record(ctx, float64(c.clock.Since(start).Milliseconds()),
+		metric.WithAttributes(attribute.String("endpoint", endpoint)),
+	)
+	c.m.CanaryRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("endpoint", endpoint),
+		attribute.String("outcome", outcome),
+	))
+
+	c.mu.Lock()
+	if err != nil {
+		c.consecutive[endpoint]++
+	} else {
+		c.consecutive[endpoint] = 0
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// call treats any status below 500 as healthy: 408 is a designed outcome of
+// /async-timeout, not a degradation.
+func (c *Canary) call(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/"+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(Header, "1")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("status %s", strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// Ready is a readiness check: it fails while any endpoint has failed
+// threshold or more consecutive probes.
+func (c *Canary) Ready() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ep, n := range c.consecutive {
+		if n >= c.threshold {
+			return fmt.Errorf("%s failed %d consecutive canaries", ep, n)
+		}
+	}
+	return nil
+}
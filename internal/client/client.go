@@ -0,0 +1,88 @@
+// Package client is a Go client for the server's endpoints that can limit
+// its own concurrency. With a Limiter, requests past the adaptive limit wait
+// on the client instead of queueing on the server, so client-side flow
+// control can be set against, or combined with, the server's shedding.
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client sends requests to Base. A nil Limiter sends every request at once.
+type Client struct {
+	HTTP    *http.Client
+	Base    string
+	Limiter *Limiter
+}
+
+// New returns a client for base over hc, limited by l when it is not nil.
+func New(hc *http.Client, base string, l *Limiter) *Client {
+	return &Client{HTTP: hc, Base: strings.TrimSuffix(base, "/"), Limiter: l}
+}
+
+// Get requests path, e.g. "/async-limited?timeoutMs=500".
+func (c *Client) Get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends req once a slot is free. The slot is held until the response
+// body is closed, so the RTT fed to the limit covers the whole response.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.Limiter == nil {
+		return c.HTTP.Do(req)
+	}
+	t, err := c.Limiter.Acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		t.Release(OutcomeOf(req.Context(), 0, err))
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, t: t, outcome: OutcomeOf(req.Context(), resp.StatusCode, nil)}
+	return resp, nil
+}
+
+// OutcomeOf classifies a request for the limit: a 429 or 503, or a timeout,
+// is a drop; a request the caller cancelled or failing otherwise, such as a
+// refused connection, says nothing about the server's load.
+func OutcomeOf(ctx context.Context, status int, err error) Outcome {
+	var timeout interface{ Timeout() bool }
+	switch {
+	case err != nil:
+		// A timeout of the client's own, not the caller's deadline.
+		if errors.As(err, &timeout) && timeout.Timeout() && ctx.Err() == nil {
+			return Dropped
+		}
+		return Ignored
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return Dropped
+	}
+	return Success
+}
+
+// releaseBody frees the request's slot when the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	t       *Token
+	outcome Outcome
+	closed  bool
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.closed {
+		b.closed = true
+		b.t.Release(b.outcome)
+	}
+	return err
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Sample is what one request tells an Algorithm about the server.
+type Sample struct {
+	RTT time.Duration
+	// Inflight is how many requests were out, this one included, when it
+	// was sent.
+	Inflight int
+	// Dropped reports that the server shed the request or it timed out:
+	// a sign of overload whatever its RTT.
+	Dropped bool
+}
+
+// Algorithm moves the limit after each request. Update is called under the
+// Limiter's lock, so implementations keep their state without one.
+type Algorithm interface {
+	Update(limit float64, s Sample) float64
+}
+
+// NewAlgorithm returns the named algorithm with its default settings:
+// vegas or gradient.
+func NewAlgorithm(name string) (Algorithm, error) {
+	switch name {
+	case "vegas":
+		return &Vegas{}, nil
+	case "gradient":
+		return &Gradient{}, nil
+	}
+	return nil, fmt.Errorf("unknown limit algorithm %q (want vegas or gradient)", name)
+}
+
+// appLimited reports that fewer than half the allowed requests were out, so
+// the sample says nothing about whether the server could take more: the
+// limit is left where it is instead of growing without bound.
+func appLimited(limit float64, s Sample) bool {
+	return float64(2*s.Inflight) < limit
+}
+
+// Vegas estimates the requests queued at the server from how far the RTT is
+// above the lowest seen, limit × (1 − minRTT/RTT), as TCP Vegas does for
+// packets in flight. Under Alpha queued it grows the limit by one per
+// limit's worth of requests (about one per round trip), over Beta it shrinks
+// it the same way, and a full round trip of drops halves it.
+type Vegas struct {
+	// Alpha and Beta bound the queue it aims for; 0 means 3 and 6.
+	Alpha, Beta float64
+
+	minRTT time.Duration
+}
+
+func (v *Vegas) Update(limit float64, s Sample) float64 {
+	if s.Dropped {
+		return limit - 0.5
+	}
+	if v.minRTT == 0 || s.RTT < v.minRTT {
+		v.minRTT = s.RTT
+	}
+	if appLimited(limit, s) || s.RTT <= 0 {
+		return limit
+	}
+	alpha, beta := v.Alpha, v.Beta
+	if alpha == 0 {
+		alpha = 3
+	}
+	if beta == 0 {
+		beta = 6
+	}
+	switch queue := limit * (1 - float64(v.minRTT)/float64(s.RTT)); {
+	case queue < alpha:
+		return limit + 1/limit
+	case queue > beta:
+		return limit - 1/limit
+	}
+	return limit
+}
+
+// Gradient compares a short average of the RTT with a long one: while the
+// short stays within Tolerance of the long the limit grows by √limit, and
+// past it the limit is scaled by long/short, down to half, as in Netflix's
+// gradient2. A drop counts as the steepest gradient. Changes are smoothed
+// so one slow request moves the limit by a fraction.
+type Gradient struct {
+	// Tolerance is how much slower than the long average the short one may
+	// get before the limit shrinks; 0 means 1.5.
+	Tolerance float64
+	// Smoothing is the share of each new estimate taken; 0 means 0.2.
+	Smoothing float64
+
+	short, long float64 // RTT averages over about 10 and 600 requests, in ns
+}
+
+func (g *Gradient) Update(limit float64, s Sample) float64 {
+	tolerance, smoothing := g.Tolerance, g.Smoothing
+	if tolerance == 0 {
+		tolerance = 1.5
+	}
+	if smoothing == 0 {
+		smoothing = 0.2
+	}
+	gradient := 0.5
+	if !s.Dropped {
+		rtt := float64(s.RTT)
+		if g.long == 0 {
+			g.short, g.long = rtt, rtt
+		}
+		g.short += (rtt - g.short) / 10
+		g.long += (rtt - g.long) / 600
+		if appLimited(limit, s) || g.short <= 0 {
+			return limit
+		}
+		gradient = max(0.5, min(1, tolerance*g.long/g.short))
+	}
+	estimate := limit*gradient + math.Sqrt(limit)
+	return limit*(1-smoothing) + estimate*smoothing
+}
+
+// Limiter caps a client's requests in flight at a limit its Algorithm
+// adapts from their RTTs and drops, between Min and Max. Requests past the
+// limit wait in Acquire, so a client backs off before the server has to
+// shed it.
+type Limiter struct {
+	clk      clock.Clock
+	alg      Algorithm
+	min, max float64
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	// slot is closed, and replaced, whenever a request may now fit.
+	slot chan struct{}
+
+	drops int64
+}
+
+// LimiterStats is a point-in-time view of a Limiter.
+type LimiterStats struct {
+	Limit    float64 `json:"limit"`
+	Inflight int     `json:"inflight"`
+	Drops    int64   `json:"drops"`
+}
+
+// NewLimiter starts alg at initial requests in flight, kept within
+// [min, max].
+func NewLimiter(clk clock.Clock, alg Algorithm, initial, min, max int) *Limiter {
+	l := &Limiter{clk: clk, alg: alg, min: float64(min), max: float64(max), slot: make(chan struct{})}
+	l.limit = l.clamp(float64(initial))
+	return l
+}
+
+func (l *Limiter) clamp(limit float64) float64 {
+	return max(l.min, min(l.max, limit))
+}
+
+// Token is one admitted request; Release it exactly once.
+type Token struct {
+	l        *Limiter
+	start    time.Time
+	inflight int
+}
+
+// Acquire waits until a request fits under the limit, or ctx ends.
+func (l *Limiter) Acquire(ctx context.Context) (*Token, error) {
+	for {
+		l.mu.Lock()
+		if float64(l.inflight) < math.Floor(l.limit) {
+			l.inflight++
+			t := &Token{l: l, start: l.clk.Now(), inflight: l.inflight}
+			l.mu.Unlock()
+			return t, nil
+		}
+		slot := l.slot
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-slot:
+		}
+	}
+}
+
+// Outcome is how an admitted request ended, as far as the limit goes.
+type Outcome int
+
+const (
+	// Success is a response, whatever its status, other than a shed one.
+	Success Outcome = iota
+	// Dropped is a shed response (429, 503) or a timeout.
+	Dropped
+	// Ignored says nothing about the server, e.g. the caller gave up: the
+	// slot is freed without moving the limit.
+	Ignored
+)
+
+// Release frees t's slot and feeds its RTT and outcome to the algorithm.
+func (t *Token) Release(o Outcome) {
+	l := t.l
+	rtt := l.clk.Since(t.start)
+	l.mu.Lock()
+	l.inflight--
+	if o != Ignored {
+		if o == Dropped {
+			l.drops++
+		}
+		l.limit = l.clamp(l.alg.Update(l.limit, Sample{RTT: rtt, Inflight: t.inflight, Dropped: o == Dropped}))
+	}
+	close(l.slot)
+	l.slot = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Stats returns the current limit, requests in flight and drops so far.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{Limit: l.limit, Inflight: l.inflight, Drops: l.drops}
+}
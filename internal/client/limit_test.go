@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// drive feeds n requests of rtt, each with the whole limit in flight, and
+// returns the limit they leave.
+func drive(alg Algorithm, limit float64, n int, rtt time.Duration, dropped bool) float64 {
+	for range n {
+		limit = max(1, min(1000, alg.Update(limit, Sample{RTT: rtt, Inflight: int(limit), Dropped: dropped})))
+	}
+	return limit
+}
+
+func TestAlgorithms(t *testing.T) {
+	for _, name := range []string{"vegas", "gradient"} {
+		t.Run(name, func(t *testing.T) {
+			alg, err := NewAlgorithm(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// No queue at the server: the limit grows.
+			grown := drive(alg, 10, 500, 50*time.Millisecond, false)
+			if grown <= 10 {
+				t.Fatalf("limit %.1f after steady RTTs, want above 10", grown)
+			}
+			// The server queues: RTTs triple, and the limit comes down.
+			queued := drive(alg, grown, 200, 150*time.Millisecond, false)
+			if queued >= grown {
+				t.Fatalf("limit %.1f after RTTs tripled, want below %.1f", queued, grown)
+			}
+			// The server sheds: a round trip of drops at least halves it.
+			if shed := drive(alg, queued, int(queued), 150*time.Millisecond, true); shed > queued/2+1 {
+				t.Fatalf("limit %.1f after %d drops, want at most %.1f", shed, int(queued), queued/2+1)
+			}
+			// Few requests out: nothing learnt, nothing changed.
+			if got := alg.Update(40, Sample{RTT: time.Millisecond, Inflight: 5}); got != 40 {
+				t.Fatalf("limit %.1f with 5 of 40 in flight, want 40", got)
+			}
+		})
+	}
+	if _, err := NewAlgorithm("aimd"); err == nil {
+		t.Fatal("unknown algorithm accepted")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLimiter(clk, &Vegas{}, 2, 1, 4)
+	ctx := context.Background()
+
+	a, err := l.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limit is reached: a third request waits until its caller gives up.
+	full, cancel := context.WithCancelCause(ctx)
+	stop := errors.New("gave up")
+	cancel(stop)
+	if _, err := l.Acquire(full); !errors.Is(err, stop) {
+		t.Fatalf("Acquire past the limit = %v, want the caller's cause", err)
+	}
+
+	// Or until a slot frees.
+	got := make(chan *Token, 1)
+	go func() {
+		c, err := l.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+	clk.Advance(10 * time.Millisecond)
+	a.Release(Ignored)
+	c := <-got
+	if s := l.Stats(); s.Inflight != 2 || s.Limit != 2 {
+		t.Fatalf("stats = %+v, want 2 in flight under an unchanged limit of 2", s)
+	}
+
+	// Drops never take the limit under its minimum.
+	b.Release(Dropped)
+	c.Release(Dropped)
+	for range 8 {
+		tok, err := l.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok.Release(Dropped)
+	}
+	if s := l.Stats(); s.Limit != 1 || s.Drops != 10 {
+		t.Fatalf("stats = %+v, want the minimum of 1 after 10 drops", s)
+	}
+}
@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// WithTimeoutCause is context.WithTimeoutCause with the timeout measured on
+// clk, so a deadline moves with the clock the latencies are simulated on.
+// On the real clock it is context.WithTimeoutCause itself. On any other,
+// the context reports its deadline in clk's time and ends with cause once
+// clk has moved d past now, with Err reporting context.DeadlineExceeded.
+func WithTimeoutCause(ctx context.Context, clk Clock, d time.Duration, cause error) (context.Context, context.CancelFunc) {
+	if _, ok := clk.(Real); ok {
+		return context.WithTimeoutCause(ctx, d, cause)
+	}
+	deadline := clk.Now().Add(d)
+	if parent, ok := ctx.Deadline(); ok && !parent.After(deadline) {
+		// The parent ends first, as context.WithDeadline would find.
+		return context.WithCancel(ctx)
+	}
+	inner, cancel := context.WithCancelCause(ctx)
+	tc := &timerCtx{Context: inner, deadline: deadline}
+	fire := clk.After(d)
+	go func() {
+		select {
+		case <-fire:
+			if inner.Err() == nil {
+				tc.expired.Store(true)
+				cancel(cause)
+			}
+		case <-inner.Done():
+		}
+	}()
+	return tc, func() { cancel(nil) }
+}
+
+// timerCtx is a context whose deadline is read on a clock other than the
+// real one.
+type timerCtx struct {
+	context.Context
+	deadline time.Time
+	expired  atomic.Bool
+}
+
+func (c *timerCtx) Deadline() (time.Time, bool) { return c.deadline, true }
+
+func (c *timerCtx) Err() error {
+	if c.expired.Load() {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
@@ -0,0 +1,88 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when told to, for tests: Now stays put
+// until Advance, and After fires once the clock has been advanced to its
+// deadline.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFake creates a fake clock reading now.
+func NewFake(now time.Time) *Fake { return &Fake{now: now} }
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration { return f.Now().Sub(t) }
+
+// After fires at once for d <= 0, otherwise once Advance reaches now+d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing every After due by then.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	kept := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			kept = append(kept, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	clear(f.waiters[len(kept):])
+	f.waiters = kept
+}
+
+// AdvanceToNext moves the clock to the earliest pending After deadline, if
+// any, firing every After due by then.
+func (f *Fake) AdvanceToNext() {
+	f.mu.Lock()
+	if len(f.waiters) == 0 {
+		f.mu.Unlock()
+		return
+	}
+	next := f.waiters[0].at
+	for _, w := range f.waiters[1:] {
+		if w.at.Before(next) {
+			next = w.at
+		}
+	}
+	d := next.Sub(f.now)
+	f.mu.Unlock()
+	f.Advance(d)
+}
+
+// Waiters returns how many After channels have yet to fire, so a test can
+// tell when the code under it is blocked on the clock.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
@@ -0,0 +1,100 @@
+package coalesce
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+)
+
+// Group merges identical calls that arrive within a window into one execution.
+// Unlike singleflight, which only joins calls overlapping an execution already
+// in flight, the first caller opens a window and the work starts only when it
+// closes, so near-simultaneous requests share a single result.
+type Group[T any] struct {
+	clock  clock.Clock
+	window time.Duration
+	jobs   *background.Runner
+	job    string
+
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+type call[T any] struct {
+	done    chan struct{}
+	res     safego.Result[T]
+	waiters int
+}
+
+// New creates a group with the given window, whose executions run as job on
+// jobs.
+func New[T any](clk clock.Clock, window time.Duration, jobs *background.Runner, job string) *Group[T] {
+	return &Group[T]{clock: clk, window: window, jobs: jobs, job: job, calls: make(map[string]*call[T])}
+}
+
+// Do returns the result of fn for key, shared with every caller that arrived
+// during the same window. shared reports how many callers got the result.
+// fn runs as a background job started by the caller opening the window, so
+// under the detached and drain policies it outlives any single caller's
+// cancellation; a caller whose ctx ends first gets its cause instead.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(context.Context) (T, error)) (v T, shared int, err error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if !ok {
+		c = &call[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		g.jobs.Go(ctx, g.job, g.jobs.Policy(g.job), 0, func(ctx context.Context) error {
+			g.run(ctx, key, c, fn)
+			return c.res.Err
+		})
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.res.Val, c.waiters, c.res.Err
+	case <-ctx.Done():
+		return v, 0, context.Cause(ctx)
+	}
+}
+
+func (g *Group[T]) run(ctx context.Context, key string, c *call[T], fn func(context.Context) (T, error)) {
+	<-g.clock.After(g.window)
+
+	// Closing the window: later arrivals open a new one.
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	c.res = <-safego.Go(ctx, "coalesce."+key, fn)
+	close(c.done)
+}
+
+// ParseWindows parses "endpoint=ms" entries separated by ';'.
+func ParseWindows(s string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, ms, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("coalesce window %q: want endpoint=ms", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(ms))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("coalesce window %q: want a positive number of milliseconds", entry)
+		}
+		out[strings.TrimSpace(endpoint)] = time.Duration(n) * time.Millisecond
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+// Package concurrency holds reusable goroutine patterns the modes build on.
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go-routine-stress/internal/safego"
+)
+
+// OrderedStats reports how an ordered fan-in ran.
+type OrderedStats struct {
+	// Started is how many tasks were started: fewer than n when ctx ended or
+	// emit failed first.
+	Started int
+	// MaxBuffered is the most results that had completed and were waiting
+	// to be emitted at once, never more than the window.
+	MaxBuffered int
+}
+
+// Ordered runs task(ctx, i) for i in [0, n) concurrently and hands every
+// result to emit in submission order, whatever order they complete in. At
+// most window tasks are started and not yet emitted at any time: the next
+// task starts only once the oldest result has been emitted, so memory stays
+// bounded by the window instead of n, and a slow head-of-line task stalls
+// the ones behind it rather than letting results pile up.
+//
+// Each task runs on a goroutine of its own, with the panic containment of
+// safego.Go under name. A task error is passed to emit, not returned. After
+// ctx ends no new task starts, and those started are still waited for and
+// emitted. If emit returns an error, the tasks in flight are cancelled with
+// it as cause and waited for without being emitted, and Ordered returns it.
+func Ordered[T any](ctx context.Context, name string, n, window int, task func(ctx context.Context, i int) (T, error), emit func(i int, v T, err error) error) (OrderedStats, error) {
+	if window < 1 {
+		return OrderedStats{}, fmt.Errorf("ordered fan-in window %d: want at least 1", window)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		stats   OrderedStats
+		done    atomic.Int64 // tasks completed
+		emitted atomic.Int64 // results emitted
+		peak    atomic.Int64 // most results buffered at once
+	)
+	// pending holds the result channels of the tasks started and not yet
+	// emitted, oldest first.
+	pending := make([]<-chan safego.Result[T], 0, window)
+	start := func(i int) {
+		pending = append(pending, safego.Go(ctx, name, func(ctx context.Context) (T, error) {
+			defer func() {
+				b := done.Add(1) - emitted.Load()
+				for p := peak.Load(); b > p; p = peak.Load() {
+					if peak.CompareAndSwap(p, b) {
+						break
+					}
+				}
+			}()
+			return task(ctx, i)
+		}))
+		stats.Started++
+	}
+
+	var err error
+	for next := 0; next < n || len(pending) > 0; {
+		for next < n && len(pending) < window && ctx.Err() == nil {
+			start(next)
+			next++
+		}
+		if len(pending) == 0 {
+			break
+		}
+		res := <-pending[0]
+		pending = append(pending[:0], pending[1:]...)
+		if err == nil {
+			if err = emit(int(emitted.Load()), res.Val, res.Err); err != nil {
+				cancel(err)
+				next = n
+			}
+		}
+		emitted.Add(1)
+	}
+	stats.MaxBuffered = int(peak.Load())
+	return stats, err
+}
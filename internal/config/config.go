@@ -1,30 +1,541 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"reflect"
 	"strconv"
 )
 
-// Config holds all runtime configuration for the application.
+// Config holds all runtime configuration for the application. Fields that
+// may carry a secret are tagged secret: "true" for values kept out of dumps
+// entirely, "url" for URLs of which only the scheme and host are shown (see
+// Redacted).
 type Config struct {
 	Port              string
-	OtelEndpoint      string
+	OtelEndpoint      string `secret:"url"`
 	ServiceName       string
 	AsyncTimeoutMs    int
+	AConcurrencyLimit int
 	BConcurrencyLimit int
+	FairQueue         bool
+
+	// AdminPort serves the admin and debug endpoints apart from the
+	// workload; set it to Port to serve everything on one port.
+	AdminPort string
+
+	// TenantWeights sets fair-queuing weights as "tenant=weight;..." (default 1).
+	TenantWeights     string
 	DisableTraces     bool
+	ShutdownTimeoutMs int
+	WriteTimeoutMs    int
+	TimelineBuffer    int
+	StatsTickMs       int
+	StatsWindowSec    int
+	StatsRetainSec    int
+
+	// WorkerStopTimeoutMs is how long each background worker is given to
+	// return once stopped at shutdown.
+	WorkerStopTimeoutMs int
+
+	// H2C also serves HTTP/2 over cleartext (prior knowledge) on Port, so a
+	// load client can multiplex its requests over a few connections.
+	H2C bool
+
+	// Service B semaphore implementation (SEM_IMPL "channel" or strict-FIFO
+	// "fifo"); waits longer than SEM_STARVATION_MS count as starved.
+	SemImpl         string
+	SemStarvationMs int
+
+	// Apdex thresholds: satisfied within APDEX_T_MS, tolerating within
+	// APDEX_TOLERATING_MS (0 = 4×T).
+	ApdexTMs          int
+	ApdexToleratingMs int
+
+	// RecommendMarginPct is the headroom added to recommended timeouts and limits.
+	RecommendMarginPct int
+
+	// Schedule lists recurring background tasks as "name|cron expr|policy;..."
+	Schedule string
+
+	CanaryFailureThreshold int
+	CanaryTimeoutMs        int
+
+	AlertEvalMs          int
+	AlertErrorRatePct    int
+	AlertP99Ms           int
+	AlertGoroutineGrowth int
+	AlertWebhookURL      string `secret:"url"`
+
+	// AdminTokensFile lists admin API tokens, one "role name token" per line
+	// (empty = admin API open).
+	AdminTokensFile string `secret:"true"`
+
+	// AuditBuffer is how many admin actions /admin/audit keeps.
+	AuditBuffer int
+
+	// RecentRequests is how many finished requests diagnostic snapshots list.
+	RecentRequests int
+
+	// ShedPolicies selects overload behavior per endpoint as "endpoint=policy;...".
+	ShedPolicies       string
+	ShedMaxInflight    int
+	ShedQueueTimeoutMs int
+	ShedThrottleMs     int
+	// ShedPriorityAgingMs moves a queued request up one X-Priority class per
+	// this much waiting, so low priority is not starved (0 = strict priority).
+	ShedPriorityAgingMs int
+	// PriorityCeilings lets API-key tenants ask for more than normal priority,
+	// as "key-<hash>=high|normal|low;..."; everyone else is capped at normal.
+	PriorityCeilings string
+
+	// ShedLimitModes derives guarded endpoints' limits as "endpoint=fixed|cpu|io;...".
+	ShedLimitModes  string
+	ShedCPUFactor   int
+	ShedIOTargetRPS int
+	ShedTuneMs      int
+
+	// Cost-based admission: a shared token budget (≈ CPU-ms) and per-endpoint
+	// costs as "endpoint=tokens;...".
+	CostCapacity  int
+	EndpointCosts string
+	// A POST body costs one unit per COST_BYTES_PER_UNIT bytes, or its
+	// declared complexity, up to COST_MAX_UNITS; tokens and Service B
+	// semaphore slots are taken per unit.
+	CostBytesPerUnit int
+	CostMaxUnits     int
+
+	// Service B retries: per-attempt timeout and overall budget (0 = unbounded).
+	BTryTimeoutMs int
+	BRetries      int
+	BBudgetMs     int
+
+	// Fallback cache warming for Service B (0 = only real traffic fills it).
+	FallbackRefreshMs int
+	FallbackJitterPct int
+
+	// CoalesceWindows merges identical requests per endpoint as "endpoint=ms;...".
+	CoalesceWindows string
+
+	// BInstances defines named Service B instances as "name=min-max:errorRate;...".
+	// /smart routes between the first (primary) and the second (fallback).
+	BInstances           string
+	SmartThresholdPct    int
+	SmartLatencyTargetMs int
+	SmartProbeEvery      int
+
+	// Balancing strategy and outlier detection for the /balanced replicas.
+	LBStrategy            string
+	OutlierConsecutive    int
+	OutlierSlowMs         int
+	OutlierBaseEjectionMs int
+	OutlierMaxEjectionMs  int
+	OutlierMaxEjectedPct  int
+
+	// FanInPolicies selects per fan-out endpoint how errors end the fan-in, as
+	// "endpoint=wait_all|first_error;...".
+	FanInPolicies string
+
+	// TrafficSplit spreads fan-out requests across implementations by weight,
+	// as "channels=90;errgroup=10" (empty = channels only, unlabelled).
+	TrafficSplit string
+
+	// StatusMap overrides the HTTP status of failures by cause, as
+	// "handler_timeout=504;overloaded=429" (empty = the built-in 408/503).
+	StatusMap string
+
+	// AllocSampleEvery measures heap allocations around one request in every
+	// ALLOC_SAMPLE_EVERY (0 = off).
+	AllocSampleEvery int
+
+	// CPU attribution: a CPU profile of CPU_PROFILE_WINDOW_MS (0 = off) every
+	// CPU_PROFILE_INTERVAL_MS, split by endpoint label.
+	CPUProfileWindowMs   int
+	CPUProfileIntervalMs int
+
+	// GoroutineProfileIntervalMs counts goroutines by endpoint label that
+	// often (0 = off).
+	GoroutineProfileIntervalMs int
+
+	// Profile auto-capture: heap and goroutine profiles are saved under
+	// PROFILE_CAPTURE_DIR when the goroutines, the heap or an endpoint's p99
+	// (held for PROFILE_CAPTURE_P99_FOR_MS) pass their threshold (0 = off),
+	// checked every PROFILE_CAPTURE_INTERVAL_MS. Captures are at least
+	// PROFILE_CAPTURE_MIN_GAP_MS apart; the last PROFILE_CAPTURE_KEEP are kept.
+	ProfileCaptureGoroutines int
+	ProfileCaptureHeapMB     int
+	ProfileCaptureP99Ms      int
+	ProfileCaptureP99ForMs   int
+	ProfileCaptureIntervalMs int
+	ProfileCaptureMinGapMs   int
+	ProfileCaptureKeep       int
+	ProfileCaptureDir        string
+
+	// RuntimeToggles sets runtime toggles at startup, GODEBUG-style
+	// ("tracing=off,json=streamed"); /admin/toggles flips them later.
+	RuntimeToggles string
+
+	// CtxAuditLog logs every context propagation break, not just counts it.
+	CtxAuditLog bool
+
+	// Brownout: above a p95 of BROWNOUT_TARGET_P95_MS (0 = off) on the listed
+	// endpoints ("a;b;..."), a growing share of requests skips Service B.
+	BrownoutTargetP95Ms int
+	BrownoutEndpoints   string
+	BrownoutStepPct     int
+	BrownoutIntervalMs  int
+
+	// Hedges allowed per 100 primary Service B calls, and in a row; the
+	// percentage can be changed at runtime through /admin/hedge-budget.
+	HedgeBudgetPct   int
+	HedgeBudgetBurst int
+	// HedgeDelayMs is how long /async-hedged waits on Service B before
+	// hedging; 0 uses B's p95 over the stats window.
+	HedgeDelayMs int
+
+	// /pipeline stage buffer sizes (0 = unbuffered hand-off) and the
+	// simulated work of its transform stage per item.
+	PipelineFetchBuffer     int
+	PipelineTransformBuffer int
+	PipelineTransformMs     int
+
+	// StreamBuffer is how many /stream lines wait for a slow client before
+	// StreamOverflow (drop-oldest, drop-newest or disconnect) applies.
+	StreamBuffer   int
+	StreamOverflow string
+
+	// ScatterShardTimeoutMs bounds each /scatter shard call, apart from the
+	// request's overall deadline. ScatterGather is how the shard replies are
+	// collected: shared, reflect or merge.
+	ScatterShardTimeoutMs int
+	ScatterGather         string
+
+	// FallbackBTimeoutMs is how long /async-fallback waits on Service B
+	// before serving fallback data instead.
+	FallbackBTimeoutMs int
+
+	// Regions places Service B replicas for /regional as "name=baseMs;...",
+	// the base latency each adds to B's profile; the nearest is local.
+	// RegionHedgeMs also tries the next region after this long without an
+	// answer (0 = fail over only on errors).
+	Regions       string
+	RegionHedgeMs int
+
+	// Service B circuit breaker of /async-breaker: it opens after
+	// BreakerFailures consecutive failures, stays open BreakerOpenMs, then
+	// lets BreakerHalfOpenProbes trial calls through at a time.
+	BreakerFailures       int
+	BreakerOpenMs         int
+	BreakerHalfOpenProbes int
+
+	// Background work requests leave behind runs under the policy
+	// BackgroundPolicies names for its job ("fallback.refresh=drain"), else
+	// detached, for at most BackgroundTimeoutMs.
+	BackgroundPolicies  string
+	BackgroundTimeoutMs int
+
+	// SyncBudgetAPct is the share of its deadline /sync-budget allocates to
+	// Service A; Service B gets the rest.
+	SyncBudgetAPct int
+
+	// OrderedFanInWindow is how many /ordered-fanin tasks may be started and
+	// not yet collected at once.
+	OrderedFanInWindow int
+
+	// ComputeStepIterations is how many SHA-256 rounds make one /compute
+	// step, the unit of work between two cancellation checks; at most
+	// 500000, about 50ms of CPU.
+	ComputeStepIterations int
+
+	// Simulated DNS for Service B targets (DNS_MAX_MS 0 = no DNS step). Cached
+	// answers live for DNS_TTL_MS; hits within DNS_REFRESH_AHEAD_MS of expiry
+	// renew in the background.
+	DNSMinMs          int
+	DNSMaxMs          int
+	DNSTTLMs          int
+	DNSRefreshAheadMs int
+
+	// Shadow traffic: MIRROR_PCT percent of mode endpoint requests are copied
+	// to MIRROR_URL (empty = off) by MIRROR_WORKERS workers, with up to
+	// MIRROR_QUEUE copies waiting; beyond that copies are dropped. Requests
+	// with a body over MIRROR_MAX_BODY_KB are not mirrored.
+	MirrorURL       string `secret:"url"`
+	MirrorPct       int
+	MirrorMaxBodyKB int
+	MirrorQueue     int
+	MirrorWorkers   int
+	MirrorTimeoutMs int
+
+	// POST and PATCH responses to requests with an Idempotency-Key are kept
+	// IDEMPOTENCY_TTL_MS for retries to replay, up to IDEMPOTENCY_MAX_KEYS
+	// keys; 0 turns deduplication off.
+	IdempotencyTTLMs   int
+	IdempotencyMaxKeys int
+
+	// Worker pool behind /pool: POOL_SIZE workers, with up to POOL_QUEUE calls
+	// waiting for one; callers wait beyond that. Each worker spends
+	// POOL_WORKER_INIT_MS setting up before its first call; all start before
+	// the server listens unless POOL_LAZY spawns them as calls need them.
+	PoolSize         int
+	PoolQueue        int
+	PoolLazy         bool
+	PoolWorkerInitMs int
+
+	// Simulated client connection pools for Service B targets ("host=size[:warm];...",
+	// empty = none). Opening a connection takes CONN_DIAL_MIN_MS–CONN_DIAL_MAX_MS;
+	// warm pools dial all of them at startup. Each keeps at most CONN_MAX_IDLE
+	// connections idle (0 = its size) and closes those idle for
+	// CONN_IDLE_TIMEOUT_MS (0 = never).
+	ConnPools         string
+	ConnDialMinMs     int
+	ConnDialMaxMs     int
+	ConnMaxIdle       int
+	ConnIdleTimeoutMs int
+
+	// Downstream chain behind Service B (CHAIN_DEPTH 0 = none): each node has
+	// CHAIN_PROFILE behavior and calls CHAIN_FANOUT children, handing them
+	// CHAIN_BUDGET_SPLIT_PCT of its remaining deadline (0 = all of it).
+	ChainDepth          int
+	ChainFanout         int
+	ChainProfile        string
+	ChainBudgetSplitPct int
+
+	// Seed makes simulated latencies and failures reproducible when non-zero.
+	Seed int
+
+	// SimScripts honors the X-Sim-Script header, which fixes the simulated
+	// latencies and failures of a request. For integration tests only.
+	SimScripts bool
+
+	// Preflight reaches the collector, mirror and alert webhook at startup,
+	// each within PREFLIGHT_TIMEOUT_MS or its entry in PREFLIGHT_TIMEOUTS
+	// ("name=ms;..."). PREFLIGHT is "degraded" (log and serve anyway),
+	// "strict" (exit on any failure) or "off".
+	Preflight          string
+	PreflightTimeoutMs int
+	PreflightTimeouts  string
+
+	// TelemetryBufferMB bounds the exports kept in memory while the collector
+	// is unavailable (0 = none kept).
+	TelemetryBufferMB int
 }
 
-// Load reads environment variables and returns a populated Config with defaults.
-func Load() Config {
-	return Config{
-		Port:              getEnv("PORT", "8080"),
-		OtelEndpoint:      getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://otel-collector:4318"),
-		ServiceName:       getEnv("OTEL_SERVICE_NAME", "go-goroutine-lab"),
-		AsyncTimeoutMs:    getEnvInt("ASYNC_TIMEOUT_MS", 600),
-		BConcurrencyLimit: getEnvInt("B_CONCURRENCY_LIMIT", 20),
-		DisableTraces:     getEnv("OTEL_TRACES_EXPORTER", "") == "none",
+// Load reads environment variables and returns a populated Config with
+// defaults. A numeric variable that is not an integer, or falls outside its
+// range, is an error rather than a silent fallback to the default, so a typo
+// can never go unnoticed or produce e.g. a zero-capacity semaphore; every such
+// variable is reported at once.
+func Load() (Config, error) {
+	var e env
+	cfg := Config{
+		Port:               getEnv("PORT", "8080"),
+		AdminPort:          getEnv("ADMIN_PORT", "9091"),
+		OtelEndpoint:       getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://otel-collector:4318"),
+		ServiceName:        getEnv("OTEL_SERVICE_NAME", "go-goroutine-lab"),
+		AsyncTimeoutMs:     e.intRange("ASYNC_TIMEOUT_MS", 600, 1, 60000),
+		AConcurrencyLimit:  e.intRange("A_CONCURRENCY_LIMIT", 20, 1, 100000),
+		BConcurrencyLimit:  e.intRange("B_CONCURRENCY_LIMIT", 20, 1, 100000),
+		FairQueue:          e.bool("FAIR_QUEUE", false),
+		SemImpl:            getEnv("SEM_IMPL", "channel"),
+		SemStarvationMs:    e.intRange("SEM_STARVATION_MS", 1000, 1, 600000),
+		TenantWeights:      getEnv("TENANT_WEIGHTS", ""),
+		DisableTraces:      getEnv("OTEL_TRACES_EXPORTER", "") == "none",
+		ShutdownTimeoutMs:  e.intRange("SHUTDOWN_TIMEOUT_MS", 10000, 0, 600000),
+		WriteTimeoutMs:     e.intRange("HTTP_WRITE_TIMEOUT_MS", 0, 0, 600000),
+		TimelineBuffer:     e.intRange("TIMELINE_BUFFER", 256, 0, 100000),
+		StatsTickMs:        e.intRange("STATS_TICK_MS", 1000, 100, 60000),
+		StatsWindowSec:     e.intRange("STATS_WINDOW_SEC", 60, 1, 3600),
+		RecommendMarginPct: e.intRange("RECOMMEND_MARGIN_PCT", 20, 0, 1000),
+		ApdexTMs:           e.intRange("APDEX_T_MS", 500, 1, 600000),
+		ApdexToleratingMs:  e.intRange("APDEX_TOLERATING_MS", 0, 0, 600000),
+		StatsRetainSec:     e.intRange("STATS_RETAIN_SEC", 600, 1, 86400),
+
+		WorkerStopTimeoutMs: e.intRange("WORKER_STOP_TIMEOUT_MS", 2000, 1, 600000),
+
+		H2C: e.bool("HTTP_H2C", false),
+
+		Schedule: getEnv("SCHEDULE", "stats-snapshot|@every 1m|skip;stats-fallback|@every 10s|skip;canary|@every 15s|skip"),
+
+		CanaryFailureThreshold: e.intRange("CANARY_FAILURE_THRESHOLD", 3, 1, 1000),
+		CanaryTimeoutMs:        e.intRange("CANARY_TIMEOUT_MS", 5000, 1, 60000),
+
+		AlertEvalMs:          e.intRange("ALERT_EVAL_MS", 5000, 100, 600000),
+		AlertErrorRatePct:    e.intRange("ALERT_ERROR_RATE_PCT", 10, 1, 100),
+		AlertP99Ms:           e.intRange("ALERT_P99_MS", 2000, 1, 600000),
+		AlertGoroutineGrowth: e.intRange("ALERT_GOROUTINE_GROWTH", 500, 1, 10000000),
+		AlertWebhookURL:      getEnv("ALERT_WEBHOOK_URL", ""),
+
+		AdminTokensFile: getEnv("ADMIN_TOKENS_FILE", ""),
+		AuditBuffer:     e.intRange("AUDIT_BUFFER", 1000, 1, 1000000),
+		RecentRequests:  e.intRange("RECENT_REQUESTS", 500, 0, 1000000),
+
+		ShedPolicies:        getEnv("SHED_POLICIES", ""),
+		ShedMaxInflight:     e.intRange("SHED_MAX_INFLIGHT", 100, 1, 100000),
+		ShedQueueTimeoutMs:  e.intRange("SHED_QUEUE_TIMEOUT_MS", 200, 0, 60000),
+		ShedThrottleMs:      e.intRange("SHED_THROTTLE_MS", 100, 0, 60000),
+		ShedPriorityAgingMs: e.intRange("SHED_PRIORITY_AGING_MS", 50, 0, 60000),
+
+		PriorityCeilings: getEnv("PRIORITY_CEILINGS", ""),
+
+		ShedLimitModes:  getEnv("SHED_LIMIT_MODES", ""),
+		ShedCPUFactor:   e.intRange("SHED_CPU_FACTOR", 4, 1, 10000),
+		ShedIOTargetRPS: e.intRange("SHED_IO_TARGET_RPS", 100, 1, 1000000),
+		ShedTuneMs:      e.intRange("SHED_TUNE_MS", 5000, 100, 600000),
+
+		CostCapacity:  e.intRange("COST_CAPACITY", 1000, 1, 100000000),
+		EndpointCosts: getEnv("ENDPOINT_COSTS", ""),
+
+		CostBytesPerUnit: e.intRange("COST_BYTES_PER_UNIT", 1024, 1, 1<<20),
+		CostMaxUnits:     e.intRange("COST_MAX_UNITS", 16, 1, 1000),
+
+		BTryTimeoutMs: e.intRange("B_TRY_TIMEOUT_MS", 0, 0, 60000),
+		BRetries:      e.intRange("B_RETRIES", 0, 0, 10),
+		BBudgetMs:     e.intRange("B_BUDGET_MS", 0, 0, 600000),
+
+		FallbackRefreshMs: e.intRange("FALLBACK_REFRESH_MS", 0, 0, 3600000),
+		FallbackJitterPct: e.intRange("FALLBACK_JITTER_PCT", 20, 0, 100),
+
+		CoalesceWindows: getEnv("COALESCE_WINDOWS", ""),
+
+		BInstances:           getEnv("B_INSTANCES", "primary=300-1200:0.05;fallback=400-1500:0.01"),
+		SmartThresholdPct:    e.intRange("SMART_THRESHOLD_PCT", 70, 1, 100),
+		SmartLatencyTargetMs: e.intRange("SMART_LATENCY_TARGET_MS", 1000, 1, 60000),
+		SmartProbeEvery:      e.intRange("SMART_PROBE_EVERY", 10, 1, 100000),
+
+		LBStrategy:            getEnv("LB_STRATEGY", "round_robin"),
+		OutlierConsecutive:    e.intRange("OUTLIER_CONSECUTIVE", 5, 0, 1000),
+		OutlierSlowMs:         e.intRange("OUTLIER_SLOW_MS", 0, 0, 60000),
+		OutlierBaseEjectionMs: e.intRange("OUTLIER_BASE_EJECTION_MS", 5000, 1, 3600000),
+		OutlierMaxEjectionMs:  e.intRange("OUTLIER_MAX_EJECTION_MS", 60000, 1, 3600000),
+		OutlierMaxEjectedPct:  e.intRange("OUTLIER_MAX_EJECTED_PCT", 50, 0, 100),
+
+		FanInPolicies: getEnv("FANIN_POLICIES", ""),
+		TrafficSplit:  getEnv("TRAFFIC_SPLIT", ""),
+		StatusMap:     getEnv("STATUS_MAP", ""),
+		CtxAuditLog:   e.bool("CTX_AUDIT_LOG", false),
+
+		AllocSampleEvery: e.intRange("ALLOC_SAMPLE_EVERY", 100, 0, 1000000),
+
+		CPUProfileWindowMs:   e.intRange("CPU_PROFILE_WINDOW_MS", 0, 0, 600000),
+		CPUProfileIntervalMs: e.intRange("CPU_PROFILE_INTERVAL_MS", 10000, 100, 3600000),
+
+		GoroutineProfileIntervalMs: e.intRange("GOROUTINE_PROFILE_INTERVAL_MS", 0, 0, 3600000),
+
+		ProfileCaptureGoroutines: e.intRange("PROFILE_CAPTURE_GOROUTINES", 0, 0, 100000000),
+		ProfileCaptureHeapMB:     e.intRange("PROFILE_CAPTURE_HEAP_MB", 0, 0, 1048576),
+		ProfileCaptureP99Ms:      e.intRange("PROFILE_CAPTURE_P99_MS", 0, 0, 600000),
+		ProfileCaptureP99ForMs:   e.intRange("PROFILE_CAPTURE_P99_FOR_MS", 30000, 0, 3600000),
+		ProfileCaptureIntervalMs: e.intRange("PROFILE_CAPTURE_INTERVAL_MS", 5000, 100, 3600000),
+		ProfileCaptureMinGapMs:   e.intRange("PROFILE_CAPTURE_MIN_GAP_MS", 300000, 0, 86400000),
+		ProfileCaptureKeep:       e.intRange("PROFILE_CAPTURE_KEEP", 20, 1, 10000),
+		ProfileCaptureDir:        getEnv("PROFILE_CAPTURE_DIR", "profiles"),
+
+		RuntimeToggles: getEnv("RUNTIME_TOGGLES", ""),
+
+		BrownoutTargetP95Ms: e.intRange("BROWNOUT_TARGET_P95_MS", 0, 0, 600000),
+		BrownoutEndpoints:   getEnv("BROWNOUT_ENDPOINTS", "sync;async;async-limited;async-timeout"),
+		BrownoutStepPct:     e.intRange("BROWNOUT_STEP_PCT", 10, 1, 100),
+		BrownoutIntervalMs:  e.intRange("BROWNOUT_INTERVAL_MS", 2000, 100, 600000),
+
+		HedgeBudgetPct:   e.intRange("HEDGE_BUDGET_PCT", 10, 0, 100),
+		HedgeBudgetBurst: e.intRange("HEDGE_BUDGET_BURST", 10, 1, 100000),
+		HedgeDelayMs:     e.intRange("HEDGE_DELAY_MS", 0, 0, 600000),
+
+		PipelineFetchBuffer:     e.intRange("PIPELINE_FETCH_BUFFER", 2, 0, 1000),
+		PipelineTransformBuffer: e.intRange("PIPELINE_TRANSFORM_BUFFER", 2, 0, 1000),
+		PipelineTransformMs:     e.intRange("PIPELINE_TRANSFORM_MS", 150, 0, 60000),
+
+		StreamBuffer:   e.intRange("STREAM_BUFFER", 16, 1, 1024),
+		StreamOverflow: getEnv("STREAM_OVERFLOW", "drop-oldest"),
+
+		ScatterShardTimeoutMs: e.intRange("SCATTER_SHARD_TIMEOUT_MS", 500, 1, 600000),
+		ScatterGather:         getEnv("SCATTER_GATHER", "shared"),
+
+		FallbackBTimeoutMs: e.intRange("FALLBACK_B_TIMEOUT_MS", 800, 1, 600000),
+
+		Regions:       getEnv("REGIONS", "local=0;nearby=40;remote=150"),
+		RegionHedgeMs: e.intRange("REGION_HEDGE_MS", 0, 0, 600000),
+
+		BreakerFailures:       e.intRange("BREAKER_FAILURES", 5, 1, 10000),
+		BreakerOpenMs:         e.intRange("BREAKER_OPEN_MS", 5000, 1, 3600000),
+		BreakerHalfOpenProbes: e.intRange("BREAKER_HALF_OPEN_PROBES", 1, 1, 1000),
+
+		BackgroundPolicies:  getEnv("BACKGROUND_POLICIES", ""),
+		BackgroundTimeoutMs: e.intRange("BACKGROUND_TIMEOUT_MS", 5000, 1, 600000),
+
+		SyncBudgetAPct: e.intRange("SYNC_BUDGET_A_PCT", 20, 1, 99),
+
+		OrderedFanInWindow: e.intRange("ORDERED_FANIN_WINDOW", 8, 1, 10000),
+
+		ComputeStepIterations: e.intRange("COMPUTE_STEP_ITERATIONS", 20000, 1, 500000),
+
+		DNSMinMs:          e.intRange("DNS_MIN_MS", 0, 0, 60000),
+		DNSMaxMs:          e.intRange("DNS_MAX_MS", 0, 0, 60000),
+		DNSTTLMs:          e.intRange("DNS_TTL_MS", 30000, 0, 3600000),
+		DNSRefreshAheadMs: e.intRange("DNS_REFRESH_AHEAD_MS", 0, 0, 3600000),
+
+		MirrorURL:       getEnv("MIRROR_URL", ""),
+		MirrorPct:       e.intRange("MIRROR_PCT", 10, 0, 100),
+		MirrorMaxBodyKB: e.intRange("MIRROR_MAX_BODY_KB", 64, 0, 102400),
+		MirrorQueue:     e.intRange("MIRROR_QUEUE", 100, 1, 1000000),
+		MirrorWorkers:   e.intRange("MIRROR_WORKERS", 4, 1, 1000),
+		MirrorTimeoutMs: e.intRange("MIRROR_TIMEOUT_MS", 5000, 1, 600000),
+
+		IdempotencyTTLMs:   e.intRange("IDEMPOTENCY_TTL_MS", 300000, 0, 86400000),
+		IdempotencyMaxKeys: e.intRange("IDEMPOTENCY_MAX_KEYS", 10000, 1, 1000000),
+
+		PoolSize:         e.intRange("POOL_SIZE", 20, 1, 100000),
+		PoolQueue:        e.intRange("POOL_QUEUE", 100, 1, 1000000),
+		PoolLazy:         e.bool("POOL_LAZY", false),
+		PoolWorkerInitMs: e.intRange("POOL_WORKER_INIT_MS", 0, 0, 60000),
+
+		ConnPools:         getEnv("CONN_POOLS", ""),
+		ConnDialMinMs:     e.intRange("CONN_DIAL_MIN_MS", 20, 0, 60000),
+		ConnDialMaxMs:     e.intRange("CONN_DIAL_MAX_MS", 80, 0, 60000),
+		ConnMaxIdle:       e.intRange("CONN_MAX_IDLE", 0, 0, 100000),
+		ConnIdleTimeoutMs: e.intRange("CONN_IDLE_TIMEOUT_MS", 0, 0, 3600000),
+
+		ChainDepth:          e.intRange("CHAIN_DEPTH", 0, 0, 5),
+		ChainFanout:         e.intRange("CHAIN_FANOUT", 1, 1, 10),
+		ChainProfile:        getEnv("CHAIN_PROFILE", "20-100:0.01"),
+		ChainBudgetSplitPct: e.intRange("CHAIN_BUDGET_SPLIT_PCT", 0, 0, 100),
+
+		Seed:       e.int("SIM_SEED", 0),
+		SimScripts: e.bool("SIM_SCRIPTS", false),
+
+		Preflight:          getEnv("PREFLIGHT", "degraded"),
+		PreflightTimeoutMs: e.intRange("PREFLIGHT_TIMEOUT_MS", 2000, 1, 60000),
+		PreflightTimeouts:  getEnv("PREFLIGHT_TIMEOUTS", ""),
+
+		TelemetryBufferMB: e.intRange("TELEMETRY_BUFFER_MB", 16, 0, 4096),
 	}
+	return cfg, errors.Join(e.errs...)
+}
+
+// redactedValue replaces a secret in dumps.
+const redactedValue = "(redacted)"
+
+// Redacted returns c with its secret-tagged fields masked, for dumps that
+// leave the process, such as the /admin/snapshot bundle. Webhook and mirror
+// URLs often embed a token in their path, query or user info, so a url
+// field keeps only its scheme and host.
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	for i := range v.NumField() {
+		f := v.Field(i)
+		kind := v.Type().Field(i).Tag.Get("secret")
+		if kind == "" || f.Kind() != reflect.String || f.String() == "" {
+			continue
+		}
+		masked := redactedValue
+		if u, err := url.Parse(f.String()); kind == "url" && err == nil && u.Host != "" {
+			masked = u.Scheme + "://" + u.Host + "/" + redactedValue
+		}
+		f.SetString(masked)
+	}
+	return c
 }
 
 func getEnv(key, def string) string {
@@ -35,13 +546,45 @@ func getEnv(key, def string) string {
 	return v
 }
 
-func getEnvInt(key string, def int) int {
+// env reads numeric and boolean variables, collecting what is wrong with
+// them.
+type env struct {
+	errs []error
+}
+
+func (e *env) int(key string, def int) int {
 	v := os.Getenv(key)
 	if v == "" {
 		return def
 	}
 	n, err := strconv.Atoi(v)
 	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s=%q: not an integer", key, v))
+		return def
+	}
+	return n
+}
+
+// bool reads key as strconv.ParseBool does: 1, t, true, 0, f, false in any
+// case.
+func (e *env) bool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s=%q: want true or false", key, v))
+		return def
+	}
+	return b
+}
+
+// intRange is int restricted to [min, max].
+func (e *env) intRange(key string, def, min, max int) int {
+	n := e.int(key, def)
+	if n < min || n > max {
+		e.errs = append(e.errs, fmt.Errorf("%s=%d: want a value in [%d, %d]", key, n, min, max))
 		return def
 	}
 	return n
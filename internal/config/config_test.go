@@ -0,0 +1,95 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// limits are settings that break the server when out of range: a zero bulkhead
+// deadlocks every call, a zero timeout fails every request.
+var limits = []struct {
+	key      string
+	min, max int
+	get      func(Config) int
+}{
+	{"A_CONCURRENCY_LIMIT", 1, 100000, func(c Config) int { return c.AConcurrencyLimit }},
+	{"B_CONCURRENCY_LIMIT", 1, 100000, func(c Config) int { return c.BConcurrencyLimit }},
+	{"ASYNC_TIMEOUT_MS", 1, 60000, func(c Config) int { return c.AsyncTimeoutMs }},
+	{"SHUTDOWN_TIMEOUT_MS", 0, 600000, func(c Config) int { return c.ShutdownTimeoutMs }},
+	{"SHED_MAX_INFLIGHT", 1, 100000, func(c Config) int { return c.ShedMaxInflight }},
+	{"POOL_SIZE", 1, 100000, func(c Config) int { return c.PoolSize }},
+	{"STREAM_BUFFER", 1, 1024, func(c Config) int { return c.StreamBuffer }},
+	{"B_TRY_TIMEOUT_MS", 0, 60000, func(c Config) int { return c.BTryTimeoutMs }},
+}
+
+// FuzzLoad sets one limit to arbitrary text: Load must either refuse it or
+// produce a limit in range, and must take any in-range integer as given.
+func FuzzLoad(f *testing.F) {
+	for _, seed := range []string{"", "0", "-1", "1", "600", "abc", "1e3", " 5", "99999999999999999999", "0x10"} {
+		f.Add(uint8(0), seed)
+	}
+	f.Fuzz(func(t *testing.T, which uint8, value string) {
+		if strings.ContainsRune(value, 0) {
+			t.Skip("no environment variable holds a NUL")
+		}
+		l := limits[int(which)%len(limits)]
+		t.Setenv(l.key, value)
+		cfg, err := Load()
+
+		n, parseErr := strconv.Atoi(value)
+		inRange := value == "" || (parseErr == nil && n >= l.min && n <= l.max)
+		switch got := l.get(cfg); {
+		case inRange && err != nil:
+			t.Fatalf("%s=%q: %v", l.key, value, err)
+		case !inRange && err == nil:
+			t.Fatalf("%s=%q: accepted as %d", l.key, value, got)
+		case got < l.min || got > l.max:
+			t.Fatalf("%s=%q: %d is outside [%d, %d]", l.key, value, got, l.min, l.max)
+		case value != "" && err == nil && got != n:
+			t.Fatalf("%s=%q: got %d", l.key, value, got)
+		}
+	})
+}
+
+// flags are the boolean settings, all off by default.
+var flags = []struct {
+	key string
+	get func(Config) bool
+}{
+	{"FAIR_QUEUE", func(c Config) bool { return c.FairQueue }},
+	{"HTTP_H2C", func(c Config) bool { return c.H2C }},
+	{"CTX_AUDIT_LOG", func(c Config) bool { return c.CtxAuditLog }},
+	{"POOL_LAZY", func(c Config) bool { return c.PoolLazy }},
+	{"SIM_SCRIPTS", func(c Config) bool { return c.SimScripts }},
+}
+
+// FuzzLoadBool sets one flag to arbitrary text: Load must take what
+// strconv.ParseBool takes and refuse the rest, so a typo such as "ture" is
+// not silently read as off.
+func FuzzLoadBool(f *testing.F) {
+	for _, seed := range []string{"", "true", "TRUE", "1", "t", "false", "0", "ture", "yes", " true"} {
+		f.Add(uint8(0), seed)
+	}
+	f.Fuzz(func(t *testing.T, which uint8, value string) {
+		if strings.ContainsRune(value, 0) {
+			t.Skip("no environment variable holds a NUL")
+		}
+		fl := flags[int(which)%len(flags)]
+		t.Setenv(fl.key, value)
+		cfg, err := Load()
+
+		want, parseErr := strconv.ParseBool(value)
+		valid := value == "" || parseErr == nil
+		switch got := fl.get(cfg); {
+		case valid && err != nil:
+			t.Fatalf("%s=%q: %v", fl.key, value, err)
+		case !valid && err == nil:
+			t.Fatalf("%s=%q: accepted as %v", fl.key, value, got)
+		case !valid && got:
+			t.Fatalf("%s=%q: refused but on", fl.key, value)
+		case valid && got != want:
+			t.Fatalf("%s=%q: got %v", fl.key, value, got)
+		}
+	})
+}
@@ -0,0 +1,275 @@
+package connpool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/services"
+)
+
+// Pool is a simulated client connection pool to one downstream host. Calls
+// borrow a connection for their duration; opening one costs a dial latency
+// drawn from [DialMin, DialMax], so a lazily grown pool makes its first
+// callers pay for every connection while a pre-warmed one has them ready.
+// Like net/http's Transport, it keeps at most MaxIdle connections idle and
+// closes those idle for IdleTimeout, and the next callers dial again.
+type Pool struct {
+	clock clock.Clock
+	rng   services.RNG
+	opts  Options
+
+	idle chan time.Time // one per idle connection, holding when it went idle
+
+	mu      sync.Mutex
+	open    int // idle, borrowed and dialing
+	waiting int // callers waiting for a release
+}
+
+// Options sizes a pool and sets how long its connections live.
+type Options struct {
+	// Size is the most connections open at once, like MaxConnsPerHost.
+	Size int
+	// MaxIdle is the most connections kept idle, like MaxIdleConnsPerHost;
+	// one released past it is closed unless a caller is waiting for it.
+	// 0 keeps all of them.
+	MaxIdle int
+	// IdleTimeout closes a connection left idle that long (0 = never).
+	IdleTimeout      time.Duration
+	DialMin, DialMax time.Duration
+	// OnClose, if set, is told of every connection the pool closes, with
+	// the reason: CloseIdleCap or CloseIdleTimeout.
+	OnClose func(reason string)
+}
+
+// Reasons a pool closes a connection.
+const (
+	CloseIdleCap     = "idle_cap"
+	CloseIdleTimeout = "idle_timeout"
+)
+
+// New creates an empty pool, drawing dial latencies from rng unless the
+// dialing request has a seed of its own.
+func New(clk clock.Clock, rng services.RNG, o Options) *Pool {
+	if o.MaxIdle <= 0 || o.MaxIdle > o.Size {
+		o.MaxIdle = o.Size
+	}
+	return &Pool{clock: clk, rng: rng, opts: o, idle: make(chan time.Time, o.Size)}
+}
+
+// Acquire borrows a connection: an idle one if any, otherwise a new one
+// while the pool is below size, otherwise the next one released. dialed
+// reports whether the caller paid for opening it. release must be called
+// once the call is done.
+func (p *Pool) Acquire(ctx context.Context) (release func(), dialed bool, err error) {
+	for {
+		if p.takeIdle() {
+			return p.put, false, nil
+		}
+
+		p.mu.Lock()
+		grow := p.open < p.opts.Size
+		if grow {
+			p.open++
+		} else {
+			p.waiting++
+		}
+		p.mu.Unlock()
+
+		if grow {
+			if err := p.dial(ctx); err != nil {
+				return nil, true, err
+			}
+			return p.put, true, nil
+		}
+
+		select {
+		case since := <-p.idle:
+			p.mu.Lock()
+			p.waiting--
+			p.mu.Unlock()
+			if !p.expired(since) {
+				return p.put, false, nil
+			}
+			p.close(CloseIdleTimeout)
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.waiting--
+			p.mu.Unlock()
+			return nil, false, context.Cause(ctx)
+		}
+	}
+}
+
+// takeIdle takes an idle connection, closing those found past IdleTimeout
+// on the way, and reports whether it got one.
+func (p *Pool) takeIdle() bool {
+	for {
+		select {
+		case since := <-p.idle:
+			if !p.expired(since) {
+				return true
+			}
+			p.close(CloseIdleTimeout)
+		default:
+			return false
+		}
+	}
+}
+
+func (p *Pool) expired(since time.Time) bool {
+	return p.opts.IdleTimeout > 0 && p.clock.Since(since) >= p.opts.IdleTimeout
+}
+
+// close forgets one connection taken out of the pool.
+func (p *Pool) close(reason string) {
+	p.mu.Lock()
+	p.open--
+	p.mu.Unlock()
+	if p.opts.OnClose != nil {
+		p.opts.OnClose(reason)
+	}
+}
+
+// Prewarm dials every connection the pool is missing, concurrently, and
+// leaves them idle. It returns how many were opened.
+func (p *Pool) Prewarm(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	n := p.opts.Size - p.open
+	p.open = p.opts.Size
+	p.mu.Unlock()
+
+	errs := make(chan error, n)
+	for range n {
+		go func() {
+			err := p.dial(ctx)
+			if err == nil {
+				p.idle <- p.clock.Now()
+			}
+			errs <- err
+		}()
+	}
+	var first error
+	opened := 0
+	for range n {
+		if err := <-errs; err != nil {
+			first = err
+			continue
+		}
+		opened++
+	}
+	return opened, first
+}
+
+// Size returns the most connections the pool holds.
+func (p *Pool) Size() int { return p.opts.Size }
+
+// MaxIdle returns the most connections the pool keeps idle.
+func (p *Pool) MaxIdle() int { return p.opts.MaxIdle }
+
+// Stats returns the open and idle connection counts, once the connections
+// idle past IdleTimeout are closed.
+func (p *Pool) Stats() (open, idle int) {
+	p.sweep()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.open, len(p.idle)
+}
+
+// sweep closes the idle connections past IdleTimeout and puts the others
+// back, oldest first as they were.
+func (p *Pool) sweep() {
+	if p.opts.IdleTimeout <= 0 {
+		return
+	}
+	for range len(p.idle) {
+		select {
+		case since := <-p.idle:
+			if p.expired(since) {
+				p.close(CloseIdleTimeout)
+				continue
+			}
+			p.idle <- since
+		default:
+			return
+		}
+	}
+}
+
+// dial waits out the dial latency. A dial abandoned by its caller frees its
+// slot so the pool can grow again later.
+func (p *Pool) dial(ctx context.Context) error {
+	d := p.opts.DialMin
+	if p.opts.DialMax > p.opts.DialMin {
+		d += time.Duration(services.RNGFor(ctx, services.SaltConnPool, p.rng).Intn(int(p.opts.DialMax - p.opts.DialMin + 1)))
+	}
+
+	select {
+	case <-p.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.open--
+		p.mu.Unlock()
+		return context.Cause(ctx)
+	}
+}
+
+// put returns a borrowed connection: to a waiting caller or the idle set,
+// or closed when MaxIdle are idle already.
+func (p *Pool) put() {
+	p.mu.Lock()
+	keep := p.waiting > 0 || len(p.idle) < p.opts.MaxIdle
+	if !keep {
+		p.open--
+	}
+	p.mu.Unlock()
+	if !keep {
+		if p.opts.OnClose != nil {
+			p.opts.OnClose(CloseIdleCap)
+		}
+		return
+	}
+	p.idle <- p.clock.Now()
+}
+
+// Spec configures one pool.
+type Spec struct {
+	Size int
+	Warm bool // pre-dial to Size at startup instead of growing lazily
+}
+
+// ParseSpecs parses "host=size" entries separated by ';', each optionally
+// suffixed with ":warm", e.g. "B=8:warm;B/primary=4".
+func ParseSpecs(s string) (map[string]Spec, error) {
+	out := make(map[string]Spec)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, rest, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("conn pool %q: want host=size[:warm]", entry)
+		}
+		size, mode, _ := strings.Cut(strings.TrimSpace(rest), ":")
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("conn pool %q: want a positive size", entry)
+		}
+		var warm bool
+		switch mode {
+		case "":
+		case "warm":
+			warm = true
+		default:
+			return nil, fmt.Errorf("conn pool %q: unknown mode %q, want warm", entry, mode)
+		}
+		out[strings.TrimSpace(host)] = Spec{Size: n, Warm: warm}
+	}
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package connpool
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/services"
+)
+
+func TestPoolIdle(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	var closed []string
+	p := New(clk, services.NewSeededRNG(1), Options{
+		Size:        4,
+		MaxIdle:     2,
+		IdleTimeout: time.Minute,
+		OnClose:     func(reason string) { closed = append(closed, reason) },
+	})
+	ctx := context.Background()
+
+	var releases []func()
+	for range 4 {
+		release, dialed, err := p.Acquire(ctx)
+		if err != nil || !dialed {
+			t.Fatalf("Acquire on a growing pool = dialed %v, %v", dialed, err)
+		}
+		releases = append(releases, release)
+	}
+	for _, release := range releases {
+		release()
+	}
+	// Two of the four are over MaxIdle.
+	if open, idle := p.Stats(); open != 2 || idle != 2 || !slices.Equal(closed, []string{CloseIdleCap, CloseIdleCap}) {
+		t.Fatalf("after release: open %d, idle %d, closed %v; want 2, 2, two idle_cap", open, idle, closed)
+	}
+
+	release, dialed, err := p.Acquire(ctx)
+	if err != nil || dialed {
+		t.Fatalf("Acquire with idle connections = dialed %v, %v; want reused", dialed, err)
+	}
+	release()
+
+	// Past the idle timeout, both are closed and the next caller dials.
+	clk.Advance(time.Minute)
+	if open, idle := p.Stats(); open != 0 || idle != 0 || len(closed) != 4 || closed[3] != CloseIdleTimeout {
+		t.Fatalf("after the idle timeout: open %d, idle %d, closed %v; want 0, 0, two idle_timeout", open, idle, closed)
+	}
+	if _, dialed, err := p.Acquire(ctx); err != nil || !dialed {
+		t.Fatalf("Acquire after the idle timeout = dialed %v, %v; want dialed", dialed, err)
+	}
+}
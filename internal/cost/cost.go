@@ -0,0 +1,70 @@
+package cost
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Budget is a shared pool of capacity tokens. Each request holds tokens
+// proportional to its estimated cost (e.g. CPU-ms) while it runs, so admission
+// is limited by total work in flight rather than by request count.
+type Budget struct {
+	capacity int64
+
+	mu   sync.Mutex
+	used int64
+}
+
+// New creates a budget of capacity tokens.
+func New(capacity int64) *Budget { return &Budget{capacity: capacity} }
+
+// TryAcquire takes n tokens if available, without waiting.
+func (b *Budget) TryAcquire(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.capacity {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// Release returns n tokens.
+func (b *Budget) Release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+}
+
+// Capacity returns the total number of tokens.
+func (b *Budget) Capacity() int64 { return b.capacity }
+
+// Available returns the tokens not currently held.
+func (b *Budget) Available() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.capacity - b.used
+}
+
+// ParseCosts parses "endpoint=tokens" entries separated by ';'.
+func ParseCosts(s string) (map[string]int64, error) {
+	out := make(map[string]int64)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, tokens, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("endpoint cost %q: want endpoint=tokens", entry)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(tokens), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("endpoint cost %q: want a positive token count", entry)
+		}
+		out[strings.TrimSpace(endpoint)] = n
+	}
+	return out, nil
+}
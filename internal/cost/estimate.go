@@ -0,0 +1,29 @@
+package cost
+
+import "encoding/json"
+
+// Estimator sizes a request from its body, so requests to one endpoint need
+// not all cost the same. Every BytesPerUnit bytes of body count one unit, and
+// a JSON body may declare more ({"complexity": 8}) but not fewer: a large
+// body cannot pass for a cheap one. Units are at least 1 and at most MaxUnits.
+type Estimator struct {
+	BytesPerUnit int64
+	MaxUnits     int64
+}
+
+// ReadLimit is how much of a body Units needs: anything longer costs MaxUnits.
+func (e Estimator) ReadLimit() int64 {
+	return e.BytesPerUnit*e.MaxUnits + 1
+}
+
+// Units returns the cost units of body.
+func (e Estimator) Units(body []byte) int64 {
+	units := (int64(len(body)) + e.BytesPerUnit - 1) / e.BytesPerUnit
+	var declared struct {
+		Complexity int64 `json:"complexity"`
+	}
+	if json.Unmarshal(body, &declared) == nil {
+		units = max(units, declared.Complexity)
+	}
+	return min(max(units, 1), e.MaxUnits)
+}
@@ -0,0 +1,261 @@
+package cpuprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime/pprof"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go-routine-stress/internal/safego"
+)
+
+// Label is the pprof label carrying the endpoint a goroutine works for.
+// Goroutines inherit labels when started, so fan-out goroutines are
+// attributed to the request that spawned them.
+const Label = "endpoint"
+
+// Unlabeled is reported for CPU spent outside any request: background loops,
+// the GC's own workers, the runtime.
+const Unlabeled = "other"
+
+// Do runs fn with the endpoint label set on the calling goroutine.
+func Do(ctx context.Context, endpoint string, fn func(context.Context)) {
+	pprof.Do(ctx, pprof.Labels(Label, endpoint), fn)
+}
+
+// Sampler profiles the CPU for Window out of every Interval and reports the
+// sampled time per endpoint label, scaled up by Interval/Window into an
+// estimate for the whole interval.
+type Sampler struct {
+	Window   time.Duration
+	Interval time.Duration
+	Observe  func(endpoint string, cpu time.Duration)
+}
+
+// Run samples every Interval until ctx is done.
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sampleSafely(ctx); err != nil {
+			log.Printf("cpu profile sample: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sampler) sampleSafely(ctx context.Context) (err error) {
+	defer safego.Recover(ctx, "cpuprof", &err)
+	return s.Sample(ctx)
+}
+
+// Sample takes one profile of Window (cut short if ctx ends) and reports it.
+func (s *Sampler) Sample(ctx context.Context) error {
+	var buf bytes.Buffer
+	// Fails while another CPU profile (e.g. go tool pprof) is running.
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return err
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+	case <-time.After(s.Window):
+	}
+	pprof.StopCPUProfile()
+	window := time.Since(start)
+
+	byLabel, err := Attribute(buf.Bytes(), Label)
+	if err != nil {
+		return err
+	}
+	scale := float64(s.Interval) / float64(window)
+	for endpoint, cpu := range byLabel {
+		s.Observe(endpoint, time.Duration(float64(cpu)*scale))
+	}
+	return nil
+}
+
+// Attribute sums the CPU time of a gzipped pprof CPU profile by the value of
+// label; samples without it count as Unlabeled.
+func Attribute(profile []byte, label string) (map[string]time.Duration, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(profile))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parseProfile(raw)
+	if err != nil {
+		return nil, err
+	}
+	cpuIdx := -1
+	for i, st := range p.sampleTypes {
+		if p.str(st) == "cpu" {
+			cpuIdx = i
+		}
+	}
+	if cpuIdx < 0 {
+		return nil, errors.New("profile has no cpu sample type")
+	}
+
+	out := make(map[string]time.Duration)
+	for _, smp := range p.samples {
+		if cpuIdx >= len(smp.values) {
+			continue
+		}
+		key := Unlabeled
+		for _, l := range smp.labels {
+			if p.str(l[0]) == label {
+				key = p.str(l[1])
+			}
+		}
+		out[key] += time.Duration(smp.values[cpuIdx])
+	}
+	return out, nil
+}
+
+// profile holds the parts of a pprof profile.proto that Attribute needs.
+type profile struct {
+	sampleTypes []int64 // string table index of each value's type
+	samples     []sample
+	strings     []string
+}
+
+type sample struct {
+	values []int64
+	labels [][2]int64 // key and string value, as string table indexes
+}
+
+func (p *profile) str(i int64) string {
+	if i < 0 || i >= int64(len(p.strings)) {
+		return ""
+	}
+	return p.strings[i]
+}
+
+// Field numbers from profile.proto.
+const (
+	fieldSampleType  = 1
+	fieldSample      = 2
+	fieldStringTable = 6
+
+	fieldValueTypeType = 1
+
+	fieldSampleValue = 2
+	fieldSampleLabel = 3
+
+	fieldLabelKey = 1
+	fieldLabelStr = 2
+)
+
+func parseProfile(b []byte) (*profile, error) {
+	p := &profile{}
+	err := eachField(b, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case fieldSampleType:
+			return eachField(v, func(num protowire.Number, _ protowire.Type, _ []byte, x uint64) error {
+				if num == fieldValueTypeType {
+					p.sampleTypes = append(p.sampleTypes, int64(x))
+				}
+				return nil
+			})
+		case fieldSample:
+			s, err := parseSample(v)
+			if err != nil {
+				return err
+			}
+			p.samples = append(p.samples, s)
+		case fieldStringTable:
+			p.strings = append(p.strings, string(v))
+		}
+		return nil
+	})
+	return p, err
+}
+
+func parseSample(b []byte) (sample, error) {
+	var s sample
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case fieldSampleValue:
+			if typ == protowire.VarintType {
+				s.values = append(s.values, int64(x))
+				return nil
+			}
+			// Packed repeated int64.
+			for len(v) > 0 {
+				n, l := protowire.ConsumeVarint(v)
+				if l < 0 {
+					return protowire.ParseError(l)
+				}
+				s.values = append(s.values, int64(n))
+				v = v[l:]
+			}
+		case fieldSampleLabel:
+			var l [2]int64
+			if err := eachField(v, func(num protowire.Number, _ protowire.Type, _ []byte, x uint64) error {
+				switch num {
+				case fieldLabelKey:
+					l[0] = int64(x)
+				case fieldLabelStr:
+					l[1] = int64(x)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			s.labels = append(s.labels, l)
+		}
+		return nil
+	})
+	return s, err
+}
+
+// eachField calls fn for every field of message b with either its varint
+// value x or its length-delimited bytes v.
+func eachField(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var (
+			v []byte
+			x uint64
+		)
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package ctxaudit
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Audited context properties. KeyRequest means the request's context was
+// lost altogether, e.g. a goroutine started from context.Background(); KeyCancel
+// means ctx can no longer be cancelled at all.
+const (
+	KeyRequest  = "request"
+	KeyCancel   = "cancel"
+	KeyDeadline = "deadline"
+	KeySpan     = "span"
+	KeyBaggage  = "baggage"
+	KeyTenant   = "tenant"
+)
+
+// Probe reports whether ctx still carries one property.
+type Probe func(context.Context) bool
+
+// HasCancel reports whether ctx can be cancelled.
+func HasCancel(ctx context.Context) bool { return ctx.Done() != nil }
+
+// HasDeadline reports whether ctx has a deadline.
+func HasDeadline(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return ok
+}
+
+// HasSpan reports whether ctx carries a valid trace span.
+func HasSpan(ctx context.Context) bool { return trace.SpanContextFromContext(ctx).IsValid() }
+
+// HasBaggage reports whether ctx carries OpenTelemetry baggage.
+func HasBaggage(ctx context.Context) bool { return baggage.FromContext(ctx).Len() > 0 }
+
+// state is shared by every context derived from one request, so a check deep
+// in a goroutine knows what the request established along the way.
+type state struct {
+	requestID string
+
+	mu     sync.Mutex
+	keys   []string
+	probes []Probe
+}
+
+type stateKey struct{}
+
+type detachedKey struct{}
+
+// Detach marks ctx as detached from its request on purpose, like background
+// work meant to outlive the response: checks on it or below it no longer
+// expect the request's cancellation or deadline, only what else it carried.
+func Detach(ctx context.Context) context.Context {
+	return context.WithValue(ctx, detachedKey{}, true)
+}
+
+// detachable lists the properties a deliberate detach gives up.
+var detachable = []string{KeyCancel, KeyDeadline}
+
+// Start begins auditing a request: whatever ctx already carries (cancellation,
+// deadline, span, baggage) is expected at every later check.
+func Start(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, stateKey{}, &state{requestID: requestID})
+	for key, probe := range map[string]Probe{KeyCancel: HasCancel, KeyDeadline: HasDeadline, KeySpan: HasSpan, KeyBaggage: HasBaggage} {
+		if probe(ctx) {
+			Expect(ctx, key, probe)
+		}
+	}
+	return ctx
+}
+
+// Expect records that, from now on, the request's contexts should satisfy
+// probe, e.g. after a handler attached a tenant or a deadline.
+func Expect(ctx context.Context, key string, probe Probe) {
+	st, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !slices.Contains(st.keys, key) {
+		st.keys = append(st.keys, key)
+		st.probes = append(st.probes, probe)
+	}
+}
+
+// Check returns the expected properties ctx no longer carries, and the ID of
+// the request it belongs to. A ctx without any audit state yields KeyRequest;
+// one marked by Detach is not expected to keep the request's cancellation or
+// deadline.
+func Check(ctx context.Context) (requestID string, missing []string) {
+	st, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return "", []string{KeyRequest}
+	}
+
+	st.mu.Lock()
+	keys, probes := slices.Clone(st.keys), slices.Clone(st.probes)
+	st.mu.Unlock()
+
+	detached, _ := ctx.Value(detachedKey{}).(bool)
+	for i, probe := range probes {
+		if detached && slices.Contains(detachable, keys[i]) {
+			continue
+		}
+		if !probe(ctx) {
+			missing = append(missing, keys[i])
+		}
+	}
+	return st.requestID, missing
+}
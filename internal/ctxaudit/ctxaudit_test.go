@@ -0,0 +1,43 @@
+package ctxaudit
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+type tenantKey struct{}
+
+func hasTenant(ctx context.Context) bool { return ctx.Value(tenantKey{}) != nil }
+
+func TestCheck(t *testing.T) {
+	base, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	req := Start(base, "r1")
+	tenanted := context.WithValue(req, tenantKey{}, "gold")
+	Expect(tenanted, KeyTenant, hasTenant)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"request", tenanted, nil},
+		{"lost", context.Background(), []string{KeyRequest}},
+		{"without cancel", context.WithoutCancel(tenanted), []string{KeyCancel, KeyDeadline}},
+		{"detached", Detach(context.WithoutCancel(tenanted)), nil},
+		// A detach only gives up cancellation, not the request's values.
+		{"detached from tenant", Detach(context.WithoutCancel(req)), []string{KeyTenant}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, missing := Check(tt.ctx)
+			slices.Sort(missing)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(missing, want) {
+				t.Fatalf("missing = %v, want %v", missing, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+)
+
+// Trigger is a threshold found crossed when a capture was taken.
+type Trigger struct {
+	Name      string  `json:"name"` // goroutines | heap | p99
+	Endpoint  string  `json:"endpoint,omitempty"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Capture is one set of profiles saved to disk.
+type Capture struct {
+	ID       string    `json:"id"`
+	At       time.Time `json:"at"`
+	Triggers []Trigger `json:"triggers"`
+	Files    []string  `json:"files"`
+}
+
+// captureFiles are the profiles each capture saves, with their pprof debug
+// level: the goroutine dump readable as is, the heap for go tool pprof.
+var captureFiles = []struct {
+	name, profile string
+	debug         int
+}{
+	{"goroutines.txt", "goroutine", 2},
+	{"heap.pb.gz", "heap", 0},
+}
+
+// captureMeta holds a capture's metadata next to its profiles, so captures
+// made before a restart are listed again.
+const captureMeta = "capture.json"
+
+// Capturer checks the goroutine count, the live heap and the worst endpoint
+// p99 every Interval, and saves heap and goroutine profiles under Dir when one
+// crosses its threshold (0 = not checked). The p99 must stay above P99Ms for
+// P99For to count, so a single slow tick does not fire. Captures are at least
+// MinGap apart, whatever triggered them, and only the last Keep are kept on
+// disk, so a long incident during an unattended run cannot fill it.
+type Capturer struct {
+	Dir      string
+	Clock    clock.Clock
+	Interval time.Duration
+	MinGap   time.Duration
+	Keep     int
+
+	Goroutines int
+	HeapBytes  uint64
+	P99Ms      float64
+	P99For     time.Duration
+	// P99 returns the endpoint with the worst p99 right now, and its p99.
+	P99 func() (endpoint string, ms float64)
+
+	// Record counts a check that found thresholds crossed, by trigger and
+	// result: captured, rate_limited or failed.
+	Record func(trigger, result string)
+
+	mu       sync.Mutex
+	captures []Capture // oldest first
+	last     time.Time
+	p99Since time.Time // since when p99 has been over P99Ms
+}
+
+// Enabled reports whether any threshold is set.
+func (c *Capturer) Enabled() bool {
+	return c.Goroutines > 0 || c.HeapBytes > 0 || c.P99Ms > 0
+}
+
+// Run creates Dir, lists the captures already in it and checks the
+// thresholds every Interval on Clock until ctx is done.
+func (c *Capturer) Run(ctx context.Context) {
+	if err := c.load(); err != nil {
+		log.Printf("profile capture: %v", err)
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.Clock.After(c.Interval):
+			if err := c.checkSafely(ctx); err != nil {
+				log.Printf("profile capture: %v", err)
+			}
+		}
+	}
+}
+
+// List returns the captures on disk, newest first.
+func (c *Capturer) List() []Capture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Capture, len(c.captures))
+	for i, cp := range c.captures {
+		out[len(out)-1-i] = cp
+	}
+	return out
+}
+
+// Path returns where file of capture id is stored, if both exist.
+func (c *Capturer) Path(id, file string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cp := range c.captures {
+		if cp.ID != id {
+			continue
+		}
+		for _, f := range cp.Files {
+			if f == file {
+				return filepath.Join(c.Dir, id, file), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (c *Capturer) checkSafely(ctx context.Context) (err error) {
+	defer safego.Recover(ctx, "diag.capture", &err)
+	return c.check(c.Clock.Now())
+}
+
+// check captures once if any threshold is crossed and the last capture is
+// at least MinGap old.
+func (c *Capturer) check(now time.Time) error {
+	triggers := c.crossed(now)
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	limited := !c.last.IsZero() && now.Sub(c.last) < c.MinGap
+	if !limited {
+		c.last = now
+	}
+	c.mu.Unlock()
+	if limited {
+		c.record(triggers, "rate_limited")
+		return nil
+	}
+
+	cp, err := c.capture(now, triggers)
+	if err != nil {
+		c.record(triggers, "failed")
+		return err
+	}
+	c.record(triggers, "captured")
+	log.Printf("profile capture: %s saved to %s (%s)", cp.ID, filepath.Join(c.Dir, cp.ID), describe(triggers))
+	c.add(cp)
+	return nil
+}
+
+// crossed returns the thresholds crossed at now.
+func (c *Capturer) crossed(now time.Time) []Trigger {
+	var out []Trigger
+	if c.Goroutines > 0 {
+		if n := runtime.NumGoroutine(); n > c.Goroutines {
+			out = append(out, Trigger{Name: "goroutines", Value: float64(n), Threshold: float64(c.Goroutines)})
+		}
+	}
+	if c.HeapBytes > 0 {
+		if b := heapBytes(); b > c.HeapBytes {
+			out = append(out, Trigger{Name: "heap", Value: float64(b), Threshold: float64(c.HeapBytes)})
+		}
+	}
+	if c.P99Ms > 0 && c.P99 != nil {
+		endpoint, ms := c.P99()
+		c.mu.Lock()
+		switch {
+		case ms <= c.P99Ms:
+			c.p99Since = time.Time{}
+		case c.p99Since.IsZero():
+			c.p99Since = now
+		}
+		since := c.p99Since
+		c.mu.Unlock()
+		if !since.IsZero() && now.Sub(since) >= c.P99For {
+			out = append(out, Trigger{Name: "p99", Endpoint: endpoint, Value: ms, Threshold: c.P99Ms})
+		}
+	}
+	return out
+}
+
+// heapBytes returns the bytes held by heap objects, live or not yet swept.
+func heapBytes() uint64 {
+	s := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(s)
+	return s[0].Value.Uint64()
+}
+
+// capture writes the profiles and the metadata of a new capture.
+func (c *Capturer) capture(now time.Time, triggers []Trigger) (Capture, error) {
+	cp := Capture{ID: now.UTC().Format("20060102T150405.000Z"), At: now, Triggers: triggers}
+	dir := filepath.Join(c.Dir, cp.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Capture{}, err
+	}
+	for _, f := range captureFiles {
+		var buf bytes.Buffer
+		if err := pprof.Lookup(f.profile).WriteTo(&buf, f.debug); err != nil {
+			return Capture{}, fmt.Errorf("%s: %w", f.name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.name), buf.Bytes(), 0o644); err != nil {
+			return Capture{}, err
+		}
+		cp.Files = append(cp.Files, f.name)
+	}
+	meta, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return Capture{}, err
+	}
+	return cp, os.WriteFile(filepath.Join(dir, captureMeta), meta, 0o644)
+}
+
+// add lists cp and removes the oldest captures past Keep.
+func (c *Capturer) add(cp Capture) {
+	c.mu.Lock()
+	c.captures = append(c.captures, cp)
+	c.mu.Unlock()
+	c.trim()
+}
+
+// trim removes the oldest captures past Keep, from the list and the disk.
+func (c *Capturer) trim() {
+	c.mu.Lock()
+	var drop []Capture
+	if n := len(c.captures) - c.Keep; n > 0 {
+		drop = append(drop, c.captures[:n]...)
+		c.captures = c.captures[n:]
+	}
+	c.mu.Unlock()
+
+	for _, old := range drop {
+		if err := os.RemoveAll(filepath.Join(c.Dir, old.ID)); err != nil {
+			log.Printf("profile capture: removing %s: %v", old.ID, err)
+		}
+	}
+}
+
+// load creates Dir and lists the captures a previous run left in it.
+func (c *Capturer) load() error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	var found []Capture
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.Dir, e.Name(), captureMeta))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		var cp Capture
+		if err == nil {
+			err = json.Unmarshal(data, &cp)
+		}
+		if err != nil || cp.ID != e.Name() {
+			log.Printf("profile capture: skipping %s: not a capture", e.Name())
+			continue
+		}
+		found = append(found, cp)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].At.Before(found[j].At) })
+
+	c.mu.Lock()
+	c.captures = found
+	c.mu.Unlock()
+	c.trim()
+	return nil
+}
+
+func (c *Capturer) record(triggers []Trigger, result string) {
+	if c.Record == nil {
+		return
+	}
+	for _, t := range triggers {
+		c.Record(t.Name, result)
+	}
+}
+
+func describe(triggers []Trigger) string {
+	parts := make([]string, len(triggers))
+	for i, t := range triggers {
+		parts[i] = fmt.Sprintf("%s %.0f > %.0f", t.Name, t.Value, t.Threshold)
+		if t.Endpoint != "" {
+			parts[i] = fmt.Sprintf("%s %s %.0fms > %.0fms", t.Name, t.Endpoint, t.Value, t.Threshold)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,70 @@
+package diag
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+func TestCapturer(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	var mu sync.Mutex
+	var results []string
+	c := &Capturer{
+		Dir:      t.TempDir(),
+		Clock:    clk,
+		Interval: time.Second,
+		MinGap:   5 * time.Second,
+		Keep:     2,
+		P99Ms:    100,
+		P99For:   3 * time.Second,
+		P99:      func() (string, float64) { return "/async", 500 },
+		Record: func(trigger, result string) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+
+	// One check a second for 14s. The p99 is over from the first, counts
+	// from the fourth, and captures are 5s apart.
+	for range 14 {
+		for clk.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clk.Advance(time.Second)
+	}
+	for clk.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	want := []string{
+		"captured", // 4s
+		"rate_limited", "rate_limited", "rate_limited", "rate_limited",
+		"captured", // 9s
+		"rate_limited", "rate_limited", "rate_limited", "rate_limited",
+		"captured", // 14s
+	}
+	if !slices.Equal(results, want) {
+		t.Fatalf("checks = %v, want %v", results, want)
+	}
+	list := c.List()
+	if len(list) != 2 || !list[0].At.Equal(clk.Now()) {
+		t.Fatalf("captures = %+v, want the last two, newest at %s", list, clk.Now())
+	}
+	if _, ok := c.Path(list[1].ID, "goroutines.txt"); !ok {
+		t.Fatalf("capture %s has no goroutine dump", list[1].ID)
+	}
+}
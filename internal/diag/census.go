@@ -0,0 +1,73 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"runtime/pprof"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+)
+
+// Census counts live goroutines by the value of a pprof label every
+// Interval, reading the goroutine profile. Goroutines inherit labels when
+// started, so a request's fan-out goroutines count toward its label, and so
+// do any it leaves behind.
+type Census struct {
+	Label     string
+	Unlabeled string // key for goroutines without Label
+	Clock     clock.Clock
+	Interval  time.Duration
+	Observe   func(counts map[string]int)
+}
+
+// Run takes a census, then another every Interval on Clock until ctx is
+// done.
+func (c *Census) Run(ctx context.Context) {
+	for {
+		if err := c.takeSafely(ctx); err != nil {
+			log.Printf("goroutine census: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.Clock.After(c.Interval):
+		}
+	}
+}
+
+func (c *Census) takeSafely(ctx context.Context) (err error) {
+	defer safego.Recover(ctx, "diag.census", &err)
+	counts, err := CountByLabel(c.Label, c.Unlabeled)
+	if err != nil {
+		return err
+	}
+	c.Observe(counts)
+	return nil
+}
+
+// CountByLabel counts the current goroutines by the value of label; those
+// without it count under unlabeled.
+func CountByLabel(label, unlabeled string) (map[string]int, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]int)
+	for _, g := range parseGroups(buf.Bytes()) {
+		key := unlabeled
+		// The profile prints labels as {"key":"value", ...}.
+		var labels map[string]string
+		if g.Labels != "" && json.Unmarshal([]byte(g.Labels), &labels) == nil {
+			if v, ok := labels[label]; ok {
+				key = v
+			}
+		}
+		out[key] += g.Count
+	}
+	return out, nil
+}
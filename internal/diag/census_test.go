@@ -0,0 +1,42 @@
+package diag
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+func TestCensus(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	counts := make(chan map[string]int, 1)
+	c := &Census{Label: "endpoint", Unlabeled: "other", Clock: clk, Interval: time.Second, Observe: func(m map[string]int) { counts <- m }}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	pprof.Do(ctx, pprof.Labels("endpoint", "test"), func(ctx context.Context) {
+		go func() { <-release }()
+	})
+	go c.Run(ctx)
+
+	if got := <-counts; got["test"] != 1 || got["other"] == 0 {
+		t.Fatalf("first census = %v, want one goroutine labelled test", got)
+	}
+	// The next census waits for the interval on the clock.
+	select {
+	case got := <-counts:
+		t.Fatalf("census %v before the interval", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+	for clk.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(time.Second)
+	if got := <-counts; got["test"] != 1 {
+		t.Fatalf("second census = %v, want one goroutine labelled test", got)
+	}
+}
@@ -0,0 +1,100 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StackGroup is a set of goroutines sharing an identical stack (and labels).
+type StackGroup struct {
+	Count  int      `json:"count"`
+	Labels string   `json:"labels,omitempty"`
+	Frames []string `json:"frames"`
+}
+
+// GoroutineDump is a grouped snapshot of all goroutines.
+type GoroutineDump struct {
+	Total   int          `json:"total"`
+	Matched int          `json:"matched"`
+	Groups  []StackGroup `json:"groups"`
+}
+
+// Goroutines captures the goroutine profile, grouped by identical stack.
+// Groups are kept only if they contain filter (matched against labels and
+// frames) and, when label is set, carry that pprof label (e.g. `endpoint":"async`
+// or just a key). Groups are sorted by count, largest first.
+func Goroutines(filter, label string) (GoroutineDump, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return GoroutineDump{}, err
+	}
+
+	var dump GoroutineDump
+	for _, g := range parseGroups(buf.Bytes()) {
+		dump.Total += g.Count
+		if label != "" && !strings.Contains(g.Labels, label) {
+			continue
+		}
+		if filter != "" && !strings.Contains(g.Labels, filter) && !strings.Contains(strings.Join(g.Frames, "\n"), filter) {
+			continue
+		}
+		dump.Matched += g.Count
+		dump.Groups = append(dump.Groups, g)
+	}
+
+	sort.SliceStable(dump.Groups, func(i, j int) bool { return dump.Groups[i].Count > dump.Groups[j].Count })
+	return dump, nil
+}
+
+// parseGroups parses the debug=1 goroutine profile text format:
+//
+//	goroutine profile: total N
+//	3 @ 0x... 0x...
+//	# labels: {"endpoint":"async"}
+//	#	0x...	pkg.fn+0x..	/path/file.go:42
+//	(blank line)
+func parseGroups(b []byte) []StackGroup {
+	var (
+		groups []StackGroup
+		cur    *StackGroup
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			cur = nil
+		case strings.HasPrefix(line, "# labels: "):
+			if cur != nil {
+				cur.Labels = strings.TrimPrefix(line, "# labels: ")
+			}
+		case strings.HasPrefix(line, "#\t"):
+			if cur != nil {
+				// Drop the PC column; keep "function+offset file:line".
+				fields := strings.Fields(strings.TrimPrefix(line, "#\t"))
+				if len(fields) > 1 {
+					fields = fields[1:]
+				}
+				cur.Frames = append(cur.Frames, strings.Join(fields, " "))
+			}
+		default:
+			countStr, _, ok := strings.Cut(line, " @ ")
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(countStr)
+			if err != nil {
+				continue
+			}
+			groups = append(groups, StackGroup{Count: n})
+			cur = &groups[len(groups)-1]
+		}
+	}
+	return groups
+}
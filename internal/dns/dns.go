@@ -0,0 +1,163 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+)
+
+// LookupJob is the background job running each upstream lookup.
+const LookupJob = "dns.lookup"
+
+// Resolver maps a downstream host name to an address.
+type Resolver interface {
+	Resolve(ctx context.Context, host string) (string, error)
+}
+
+// Sim is a simulated DNS server: every lookup takes a latency drawn from
+// [min, max] and returns a stable fake address for the host.
+type Sim struct {
+	clock    clock.Clock
+	rng      services.RNG
+	min, max time.Duration
+}
+
+// NewSim creates a simulated resolver drawing latencies from rng, unless the
+// lookup's request has a seed of its own.
+func NewSim(clk clock.Clock, rng services.RNG, min, max time.Duration) *Sim {
+	return &Sim{clock: clk, rng: rng, min: min, max: max}
+}
+
+// Resolve waits out the lookup latency, or until ctx is done.
+func (s *Sim) Resolve(ctx context.Context, host string) (string, error) {
+	d := s.min
+	if s.max > s.min {
+		d += time.Duration(services.RNGFor(ctx, services.SaltDNS, s.rng).Intn(int(s.max - s.min + 1)))
+	}
+
+	select {
+	case <-s.clock.After(d):
+	case <-ctx.Done():
+		return "", context.Cause(ctx)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	sum := h.Sum32()
+	return fmt.Sprintf("10.%d.%d.%d", byte(sum>>16), byte(sum>>8), byte(sum)), nil
+}
+
+// Result says how a cached lookup was answered.
+type Result string
+
+const (
+	Hit     Result = "hit"     // fresh entry
+	Refresh Result = "refresh" // fresh entry, background refresh started
+	Miss    Result = "miss"    // missing or expired, resolved inline
+	Shared  Result = "shared"  // joined another caller's inline lookup
+)
+
+// Cache is a caching resolver. Expired entries are resolved inline so the
+// first caller after expiry pays the lookup latency; with refreshAhead set, a
+// hit within refreshAhead of expiry renews the entry in the background instead.
+type Cache struct {
+	upstream     Resolver
+	clock        clock.Clock
+	ttl          time.Duration
+	refreshAhead time.Duration
+	jobs         *background.Runner
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	addr    string
+	expires time.Time
+	pending *lookup // in flight, at most one per host
+}
+
+type lookup struct {
+	done chan struct{}
+	addr string
+	err  error
+}
+
+// NewCache wraps upstream with a cache keeping answers for ttl. Lookups run
+// as LookupJob on jobs, under that job's policy.
+func NewCache(upstream Resolver, clk clock.Clock, ttl, refreshAhead time.Duration, jobs *background.Runner) *Cache {
+	return &Cache{upstream: upstream, clock: clk, ttl: ttl, refreshAhead: refreshAhead, jobs: jobs, entries: make(map[string]*entry)}
+}
+
+// TTL returns how long answers are kept.
+func (c *Cache) TTL() time.Duration { return c.ttl }
+
+// Resolve returns the address of host and how the lookup was answered.
+func (c *Cache) Resolve(ctx context.Context, host string) (string, Result, error) {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	if !ok {
+		e = &entry{}
+		c.entries[host] = e
+	}
+	if e.addr != "" && now.Before(e.expires) {
+		res := Hit
+		if c.refreshAhead > 0 && e.expires.Sub(now) <= c.refreshAhead && e.pending == nil {
+			e.pending = c.startLocked(ctx, host, e)
+			res = Refresh
+		}
+		addr := e.addr
+		c.mu.Unlock()
+		return addr, res, nil
+	}
+
+	res := Shared
+	if e.pending == nil {
+		e.pending = c.startLocked(ctx, host, e)
+		res = Miss
+	}
+	l := e.pending
+	c.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.addr, res, l.err
+	case <-ctx.Done():
+		return "", res, context.Cause(ctx)
+	}
+}
+
+// startLocked launches a lookup as a background job, so under the detached
+// and drain policies a caller giving up does not fail everyone else waiting
+// on it, and shutdown accounts for refreshes still running. c.mu must be held.
+func (c *Cache) startLocked(ctx context.Context, host string, e *entry) *lookup {
+	l := &lookup{done: make(chan struct{})}
+	c.jobs.Go(ctx, LookupJob, c.jobs.Policy(LookupJob), 0, func(ctx context.Context) (err error) {
+		var addr string
+		defer func() {
+			c.mu.Lock()
+			// A failed refresh keeps serving the old answer until it expires.
+			if err == nil {
+				e.addr, e.expires = addr, c.clock.Now().Add(c.ttl)
+			}
+			e.pending = nil
+			c.mu.Unlock()
+
+			l.addr, l.err = addr, err
+			close(l.done)
+		}()
+		defer safego.Recover(ctx, "dns."+host, &err)
+		addr, err = c.upstream.Resolve(ctx, host)
+		return err
+	})
+	return l
+}
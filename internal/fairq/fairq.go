@@ -0,0 +1,213 @@
+package fairq
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Queue orders access to a channel semaphore by weighted fair queuing across
+// tenants: each waiter gets a virtual finish tag of max(now, tenant's last tag)
+// + 1/weight, and freed slots go to the smallest tag. A tenant with weight 2
+// therefore gets twice the slots of a weight-1 tenant under contention, and one
+// aggressive tenant cannot starve the others however many requests it queues.
+type Queue struct {
+	sem     chan struct{}
+	weights map[string]float64
+
+	mu     sync.Mutex
+	vtime  float64
+	finish map[string]float64
+	wait   waitHeap
+	seq    uint64
+	inUse  map[string]int
+}
+
+// Untagged requests are queued as DefaultTenant; tenants missing from the
+// weights all share OtherTenant, so per-tenant state and metric labels stay
+// bounded by the configuration whatever clients send.
+const (
+	DefaultTenant = "default"
+	OtherTenant   = "other"
+)
+
+type waiter struct {
+	tenant  string
+	tag     float64
+	seq     uint64 // FIFO among equal tags
+	ready   chan struct{}
+	granted bool
+	index   int
+}
+
+// New creates a queue over sem. Tenants missing from weights share the
+// OtherTenant bucket, with weight 1 unless weights sets one for it.
+func New(sem chan struct{}, weights map[string]float64) *Queue {
+	return &Queue{sem: sem, weights: weights, finish: make(map[string]float64), inUse: make(map[string]int)}
+}
+
+// Tenant returns the bucket id is queued under: id itself when it has a
+// weight, DefaultTenant when it is empty, OtherTenant otherwise. A nil queue
+// knows no tenants.
+func (q *Queue) Tenant(id string) string {
+	if id == "" || id == DefaultTenant {
+		return DefaultTenant
+	}
+	if q != nil {
+		if _, ok := q.weights[id]; ok {
+			return id
+		}
+	}
+	return OtherTenant
+}
+
+// Acquire takes a slot for tenant, waiting its fair turn. On success the
+// caller must call Release(tenant).
+func (q *Queue) Acquire(ctx context.Context, tenant string) error {
+	tenant = q.Tenant(tenant)
+	q.mu.Lock()
+	if q.wait.Len() == 0 {
+		select {
+		case q.sem <- struct{}{}:
+			q.inUse[tenant]++
+			q.mu.Unlock()
+			return nil
+		default:
+		}
+	}
+
+	weight := q.weights[tenant]
+	if weight <= 0 {
+		weight = 1
+	}
+	q.seq++
+	w := &waiter{tenant: tenant, tag: max(q.vtime, q.finish[tenant]) + 1/weight, seq: q.seq, ready: make(chan struct{})}
+	q.finish[tenant] = w.tag
+	heap.Push(&q.wait, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.granted {
+			// The slot was handed over while we gave up: pass it on.
+			q.mu.Unlock()
+			q.Release(tenant)
+		} else {
+			heap.Remove(&q.wait, w.index)
+			q.mu.Unlock()
+		}
+		return context.Cause(ctx)
+	}
+}
+
+// Release frees tenant's slot, handing it straight to the next waiter if any.
+func (q *Queue) Release(tenant string) {
+	tenant = q.Tenant(tenant)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inUse[tenant]--
+	if q.wait.Len() == 0 {
+		<-q.sem
+		return
+	}
+	w := heap.Pop(&q.wait).(*waiter)
+	q.vtime = w.tag
+	q.inUse[w.tenant]++
+	w.granted = true
+	close(w.ready)
+}
+
+// InUse returns the slots currently held per tenant.
+func (q *Queue) InUse() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make(map[string]int, len(q.inUse))
+	for t, n := range q.inUse {
+		out[t] = n
+	}
+	return out
+}
+
+type waitHeap []*waiter
+
+func (h waitHeap) Len() int { return len(h) }
+func (h waitHeap) Less(i, j int) bool {
+	if h[i].tag != h[j].tag {
+		return h[i].tag < h[j].tag
+	}
+	return h[i].seq < h[j].seq
+}
+func (h waitHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *waitHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+func (h *waitHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return w
+}
+
+type tenantKey struct{}
+
+// WithTenant attaches the caller's tenant to ctx.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant attached to ctx, or DefaultTenant.
+func TenantFrom(ctx context.Context) string {
+	if t, ok := ctx.Value(tenantKey{}).(string); ok && t != "" {
+		return t
+	}
+	return DefaultTenant
+}
+
+// HasTenant reports whether a tenant was attached to ctx.
+func HasTenant(ctx context.Context) bool {
+	_, ok := ctx.Value(tenantKey{}).(string)
+	return ok
+}
+
+// KeyTenant returns the tenant name for an API key: "key-" and the first 16
+// hex digits of its SHA-256, so the key itself never reaches a map, a log or
+// a metric label. Weigh such a tenant in TENANT_WEIGHTS under that name.
+func KeyTenant(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:8])
+}
+
+// ParseWeights parses "tenant=weight" entries separated by ';'.
+func ParseWeights(s string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, weight, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("tenant weight %q: want tenant=weight", entry)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("tenant weight %q: want a positive number", entry)
+		}
+		out[strings.TrimSpace(tenant)] = w
+	}
+	return out, nil
+}
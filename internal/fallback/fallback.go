@@ -0,0 +1,48 @@
+package fallback
+
+import (
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Cache holds the last good value of a dependency so degraded responses can be
+// served without calling it.
+type Cache[T any] struct {
+	clock clock.Clock
+
+	mu    sync.RWMutex
+	val   T
+	at    time.Time
+	valid bool
+}
+
+// New creates an empty cache.
+func New[T any](clk clock.Clock) *Cache[T] {
+	return &Cache[T]{clock: clk}
+}
+
+// Store replaces the cached value.
+func (c *Cache[T]) Store(v T) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	c.val, c.at, c.valid = v, now, true
+	c.mu.Unlock()
+}
+
+// Get returns the cached value and its age, or ok=false if nothing was stored yet.
+func (c *Cache[T]) Get() (v T, age time.Duration, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.valid {
+		return v, 0, false
+	}
+	return c.val, c.clock.Since(c.at), true
+}
+
+// Age returns how long ago the cached value was stored.
+func (c *Cache[T]) Age() (time.Duration, bool) {
+	_, age, ok := c.Get()
+	return age, ok
+}
@@ -0,0 +1,80 @@
+package fallback
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+)
+
+// Warmer keeps a Cache fresh by fetching on an interval, so degraded responses
+// serve data of bounded staleness instead of whatever real traffic last left.
+type Warmer[T any] struct {
+	cache    *Cache[T]
+	clock    clock.Clock
+	rng      services.RNG
+	m        *observability.Metrics
+	name     string
+	interval time.Duration
+	jitter   float64 // fraction of interval, 0..1
+	timeout  time.Duration
+	fetch    func(context.Context) (T, error)
+}
+
+// NewWarmer creates a warmer refreshing cache every interval ± jitter·interval,
+// with the jitter drawn from rng.
+// Each fetch is bounded by timeout.
+func NewWarmer[T any](cache *Cache[T], clk clock.Clock, rng services.RNG, m *observability.Metrics, name string,
+	interval time.Duration, jitter float64, timeout time.Duration, fetch func(context.Context) (T, error)) *Warmer[T] {
+	return &Warmer[T]{cache: cache, clock: clk, rng: rng, m: m, name: name, interval: interval, jitter: jitter, timeout: timeout, fetch: fetch}
+}
+
+// Run refreshes immediately, then after every jittered interval until ctx is done.
+func (w *Warmer[T]) Run(ctx context.Context) {
+	for {
+		w.refreshSafely(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.clock.After(w.nextDelay()):
+		}
+	}
+}
+
+// nextDelay spreads refreshes so several instances do not hit the dependency in lockstep.
+func (w *Warmer[T]) nextDelay() time.Duration {
+	spread := (w.rng.Float64()*2 - 1) * w.jitter
+	return time.Duration(float64(w.interval) * (1 + spread))
+}
+
+func (w *Warmer[T]) refreshSafely(ctx context.Context) {
+	var err error
+	defer func() { w.count(ctx, err) }()
+	defer safego.Recover(ctx, "fallback.warm."+w.name, &err)
+
+	fctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var v T
+	if v, err = w.fetch(fctx); err == nil {
+		w.cache.Store(v)
+	}
+}
+
+func (w *Warmer[T]) count(ctx context.Context, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	w.m.FallbackRefreshes.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("service", w.name),
+		attribute.String("outcome", outcome),
+	))
+}
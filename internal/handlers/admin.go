@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/diag"
+)
+
+// Goroutines returns the current goroutine dump grouped by identical stacks.
+// ?filter= keeps groups containing a substring; ?label= keeps groups carrying a pprof label.
+func (h *Handlers) Goroutines(c *gin.Context) {
+	dump, err := diag.Goroutines(c.Query("filter"), c.Query("label"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dump)
+}
+
+// ListInflight returns the requests currently in flight, oldest first.
+func (h *Handlers) ListInflight(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"inflight": h.Inflight.List()})
+}
+
+// CancelInflight cancels one in-flight request's context. The request then
+// fails like any cancelled request, with cancel cause admin_cancel.
+func (h *Handlers) CancelInflight(c *gin.Context) {
+	if !h.Inflight.Cancel(c.Param("id"), apperr.ErrAdminCancel) {
+		c.String(http.StatusNotFound, "request not in flight")
+		return
+	}
+	h.recordAdmin(c, "cancel_inflight", c.Param("id"), nil, nil)
+	c.Status(http.StatusNoContent)
+}
+
+// HedgeBudgetStats returns the hedge budget's cap, utilization and counts.
+func (h *Handlers) HedgeBudgetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.HedgeBudget.Stats())
+}
+
+// BackgroundStats returns how the background jobs ended, per job and policy.
+func (h *Handlers) BackgroundStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": h.Background.Stats()})
+}
+
+// ListProfiles returns the profiles captured when thresholds were crossed,
+// newest first.
+func (h *Handlers) ListProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": h.Captures.Enabled(), "dir": h.Captures.Dir, "captures": h.Captures.List()})
+}
+
+// GetProfile downloads one file of a capture, as listed by ListProfiles.
+func (h *Handlers) GetProfile(c *gin.Context) {
+	path, ok := h.Captures.Path(c.Param("id"), c.Param("file"))
+	if !ok {
+		c.String(http.StatusNotFound, "no such capture file")
+		return
+	}
+	c.FileAttachment(path, c.Param("id")+"-"+c.Param("file"))
+}
+
+// SetHedgeBudget changes the hedge cap at runtime (?percent=0..100).
+func (h *Handlers) SetHedgeBudget(c *gin.Context) {
+	percent := queryInt(c, "percent", -1, 0, 100)
+	if percent < 0 {
+		c.String(http.StatusBadRequest, "percent must be an integer in [0, 100]")
+		return
+	}
+	h.configMu.Lock()
+	if h.setHedgeBudgetLocked(c, percent) {
+		h.configVersion++
+	}
+	h.configMu.Unlock()
+	c.JSON(http.StatusOK, h.HedgeBudget.Stats())
+}
+
+// setHedgeBudgetLocked changes and audits the hedge cap, reporting whether it
+// changed; h.configMu must be held.
+func (h *Handlers) setHedgeBudgetLocked(c *gin.Context, percent int) bool {
+	was := h.HedgeBudget.Percent()
+	if was == percent {
+		return false
+	}
+	h.HedgeBudget.SetPercent(percent)
+	h.recordAdmin(c, "set_hedge_budget", "percent", was, percent)
+	return true
+}
+
+// Recommendations derives timeouts and concurrency limits from measured
+// traffic over ?windowSec= (default: all retained history).
+func (h *Handlers) Recommendations(c *gin.Context) {
+	window := time.Duration(queryInt(c, "windowSec", math.MaxInt32, 1, math.MaxInt32)) * time.Second
+	c.JSON(http.StatusOK, h.Recommender.Report(window))
+}
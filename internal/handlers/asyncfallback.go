@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// defaultB is what /async-fallback serves for Service B before any B call
+// has succeeded, so there is nothing cached to fall back on.
+var defaultB = services.ServiceBData{Value: "default-from-B"}
+
+// refreshJob is the background job refreshing the Service B fallback cache.
+const refreshJob = "fallback.refresh"
+
+// AsyncFallback fans out like /async, but a Service B failure does not fail
+// the request: B is given ?bTimeoutMs= (default FALLBACK_B_TIMEOUT_MS) and,
+// if it fails or runs out of time, the last good B result is served instead,
+// or a default one if there is none yet, with "degraded": true. Only a
+// Service A failure or the request ending fails it.
+//
+// Once a degraded response is out, Service B is called again in the
+// background to refresh the cache, under the ?refresh= policy (default from
+// BACKGROUND_POLICIES): inherit, detached for ?refreshTimeoutMs= (default
+// BACKGROUND_TIMEOUT_MS), or drain.
+func (h *Handlers) AsyncFallback(c *gin.Context) {
+	policy := h.Background.Policy(refreshJob)
+	if q := c.Query("refresh"); q != "" {
+		p, err := background.ParsePolicy(q)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		policy = p
+	}
+	refreshTimeout := time.Duration(queryInt(c, "refreshTimeoutMs", int(h.Background.Timeout().Milliseconds()), 1, 600000)) * time.Millisecond
+	timeout := time.Duration(queryInt(c, "bTimeoutMs", h.Settings.FallbackBTimeoutMs, 1, 600000)) * time.Millisecond
+
+	// Written by the B goroutine, which the fan-in may not wait for.
+	var fellBack atomic.Bool
+	h.serve(c, "async-fallback", func(ctx context.Context) outcome {
+		o := h.fanOut(ctx, "async-fallback", func(ctx context.Context) (services.ServiceBData, error) {
+			b, fb, err := h.callServiceBOrFallback(ctx, timeout)
+			fellBack.Store(fb)
+			return b, err
+		})
+		o.degraded = o.degraded || fellBack.Load()
+		return o
+	})
+
+	if fellBack.Load() {
+		// callServiceB stores what it gets in the cache.
+		h.Background.Go(c.Request.Context(), refreshJob, policy, refreshTimeout, func(ctx context.Context) error {
+			_, err := h.callServiceB(ctx)
+			return err
+		})
+	}
+}
+
+// callServiceBOrFallback calls Service B within timeout and substitutes
+// fallback data if the call fails, reporting whether it did. It fails only
+// when ctx itself ends, since then nobody is waiting for the answer.
+func (h *Handlers) callServiceBOrFallback(ctx context.Context, timeout time.Duration) (services.ServiceBData, bool, error) {
+	bctx, cancel := clock.WithTimeoutCause(ctx, h.Clock, timeout, apperr.ErrFallbackTimeout)
+	defer cancel()
+	b, err := h.callServiceB(bctx)
+	if err == nil || ctx.Err() != nil {
+		return b, false, err
+	}
+
+	reason := "error"
+	if context.Cause(bctx) == apperr.ErrFallbackTimeout {
+		reason = "timeout"
+	}
+	source := "cache"
+	b, age, ok := h.FallbackB.Get()
+	if ok {
+		h.M.FallbackServedAge.Record(ctx, float64(age.Milliseconds()), metric.WithAttributes(attribute.String("service", "B")))
+	} else {
+		b, source = defaultB, "default"
+	}
+	reportFrom(ctx).fellBack(source)
+	h.M.FallbackActivations.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("service", "B"),
+		attribute.String("source", source),
+		attribute.String("reason", reason),
+	))
+	timeline.FromContext(ctx).Mark("fallback", fmt.Sprintf("B %s (%v), serving %s data", reason, err, source))
+	return b, true, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/audit"
+	"go-routine-stress/internal/markers"
+	"go-routine-stress/internal/middleware"
+)
+
+// recordAdmin audits an admin action by the caller of c. admin_actions_total
+// moves with every entry, which dashboards turn into "changed here" markers.
+func (h *Handlers) recordAdmin(c *gin.Context, action, target string, from, to any) {
+	e := audit.Entry{Actor: "anonymous", Action: action, Target: target, Old: from, New: to, RequestID: middleware.GetRequestID(c)}
+	if p, ok := middleware.GetPrincipal(c); ok {
+		e.Actor, e.Role = p.Name, p.Role.String()
+	}
+	h.Audit.Record(e)
+	h.M.AdminActions.Add(c.Request.Context(), 1, metric.WithAttributes(attribute.String("action", action)))
+}
+
+// ListAudit returns the recent admin actions, oldest first.
+func (h *Handlers) ListAudit(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"entries": h.Audit.List()})
+}
+
+// Annotate records an experiment marker (?name=, optional ?description=),
+// e.g. "load doubled", that lines up with metric graphs and traces.
+func (h *Handlers) Annotate(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.String(http.StatusBadRequest, "name is required")
+		return
+	}
+	m := markers.Marker{Name: name, Description: c.Query("description"), Actor: "anonymous"}
+	if p, ok := middleware.GetPrincipal(c); ok {
+		m.Actor = p.Name
+	}
+	m = h.Markers.Add(c.Request.Context(), m)
+	h.M.Markers.Add(c.Request.Context(), 1, metric.WithAttributes(attribute.String("name", name)))
+	h.recordAdmin(c, "annotate", name, nil, m.Description)
+	c.JSON(http.StatusCreated, m)
+}
+
+// ListMarkers returns the experiment markers of this run.
+func (h *Handlers) ListMarkers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"markers": h.Markers.List()})
+}
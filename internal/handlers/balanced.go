@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/balancer"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// Balanced fans out like /async, spreading Service B calls over the replicas
+// of the client-side balancer. ?strategy= overrides the configured strategy
+// so strategies can be compared side by side.
+func (h *Handlers) Balanced(c *gin.Context) {
+	var strategy balancer.Strategy
+	if q := c.Query("strategy"); q != "" {
+		st, err := balancer.ParseStrategy(q)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		strategy = st
+	}
+
+	h.serve(c, "balanced", func(ctx context.Context) outcome {
+		return h.fanOut(ctx, "balanced", func(ctx context.Context) (services.ServiceBData, error) {
+			return h.callBalancedB(ctx, strategy)
+		})
+	})
+}
+
+// Replicas returns the balancer's replicas and their outlier state.
+func (h *Handlers) Replicas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"strategy": h.Balancer.Strategy(), "replicas": h.Balancer.States()})
+}
+
+// callBalancedB calls one replica and reports the result for outlier detection.
+func (h *Handlers) callBalancedB(ctx context.Context, strategy balancer.Strategy) (services.ServiceBData, error) {
+	if strategy == "" {
+		strategy = h.Balancer.Strategy()
+	}
+	r := h.Balancer.Pick(ctx, strategy)
+
+	start := h.Clock.Now()
+	d, err := h.callB(ctx, "B/"+r.Name, r.Call)
+	h.M.BalancedCallDuration.Record(ctx, float64(h.Clock.Since(start).Milliseconds()), metric.WithAttributes(
+		attribute.String("strategy", string(strategy)),
+		attribute.String("replica", r.Name),
+	))
+	if ctx.Err() != nil {
+		h.Balancer.Abandon(r)
+		return d, err
+	}
+
+	if dur, ejected := h.Balancer.Done(r, h.Clock.Since(start), err); ejected {
+		h.M.OutlierEjections.Add(ctx, 1, metric.WithAttributes(attribute.String("replica", r.Name)))
+		timeline.FromContext(ctx).Mark("eject", r.Name+" for "+dur.String())
+	}
+	return d, err
+}
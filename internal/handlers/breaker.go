@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/markers"
+	"go-routine-stress/internal/resilience"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// AsyncBreaker fans out like /async, with Service B behind the circuit
+// breaker: while it is open, B calls fail at once with "circuit breaker
+// open" instead of waiting on a dependency known to be failing.
+func (h *Handlers) AsyncBreaker(c *gin.Context) {
+	h.serve(c, "async-breaker", func(ctx context.Context) outcome {
+		return h.fanOut(ctx, "async-breaker", h.callServiceBBreaker)
+	})
+}
+
+// callServiceBBreaker calls Service B if the breaker lets the call through,
+// and reports how it went.
+func (h *Handlers) callServiceBBreaker(ctx context.Context) (b services.ServiceBData, err error) {
+	done, err := h.BreakerB.Allow(ctx)
+	if err != nil {
+		state := h.BreakerB.State().String()
+		h.M.BreakerRejections.Add(ctx, 1, metric.WithAttributes(attribute.String("state", state)))
+		trace.SpanFromContext(ctx).AddEvent("breaker.rejected", trace.WithAttributes(attribute.String("breaker.state", state)))
+		timeline.FromContext(ctx).Mark("breaker", "B call rejected, breaker "+state)
+		return services.ServiceBData{}, apperr.Dependency("B", err)
+	}
+	// A panicking call reports as failed, recovered into err, instead of
+	// holding a half-open trial slot forever.
+	defer func() { done(err) }()
+	defer safego.Recover(ctx, "breaker.B", &err)
+	return h.callServiceB(ctx)
+}
+
+// breakerChanged records a transition of the Service B breaker, on the span
+// of the request whose call caused it and as an experiment marker.
+func (h *Handlers) breakerChanged(ctx context.Context, from, to resilience.State) {
+	h.M.BreakerTransitions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("from", from.String()),
+		attribute.String("to", to.String()),
+	))
+	trace.SpanFromContext(ctx).AddEvent("breaker.state_change", trace.WithAttributes(
+		attribute.String("breaker.from", from.String()),
+		attribute.String("breaker.to", to.String()),
+	))
+	timeline.FromContext(ctx).Mark("breaker", fmt.Sprintf("B breaker %s -> %s", from, to))
+	const name = "breaker.B"
+	h.Markers.Add(ctx, markers.Marker{Name: name, Description: fmt.Sprintf("B: %s -> %s", from, to), Actor: "breaker"})
+	h.M.Markers.Add(ctx, 1, metric.WithAttributes(attribute.String("name", name)))
+}
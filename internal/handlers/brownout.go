@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/timeline"
+)
+
+// brownoutA serves a request picked by the brownout controller: Service B is
+// skipped entirely and the response carries Service A only, marked degraded.
+// It reports false when the request should do the full work.
+func (h *Handlers) brownoutA(ctx context.Context, mode string) (outcome, bool) {
+	if h.Brownout == nil || !h.Brownout.Skip(ctx, mode) {
+		return outcome{}, false
+	}
+
+	h.M.BrownoutSkips.Add(ctx, 1, metric.WithAttributes(attribute.String("endpoint", mode)))
+	timeline.FromContext(ctx).Mark("brownout", "B skipped")
+
+	a, err := h.callServiceA(ctx)
+	if err != nil {
+		return outcome{status: http.StatusRequestTimeout, err: err}, true
+	}
+	return outcome{a: a, degraded: true}, true
+}
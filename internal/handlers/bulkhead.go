@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Bulkhead caps the calls to one service in flight from /async-limited, so a
+// slow service can hold at most its own slots.
+type Bulkhead struct {
+	slots chan struct{}
+
+	// Calls currently waiting for a slot.
+	waiting atomic.Int64
+}
+
+// NewBulkhead creates a bulkhead of limit slots.
+func NewBulkhead(limit int) *Bulkhead {
+	return &Bulkhead{slots: make(chan struct{}, limit)}
+}
+
+// Slots is the channel semaphore itself: a send takes a slot, a receive
+// frees it. Fair queuing for Service B grants its slots directly.
+func (b *Bulkhead) Slots() chan struct{} {
+	return b.slots
+}
+
+// Usage returns the held and total slots.
+func (b *Bulkhead) Usage() (inUse, limit int) {
+	return len(b.slots), cap(b.slots)
+}
+
+// Waiting returns the calls waiting for a slot.
+func (b *Bulkhead) Waiting() int {
+	return int(b.waiting.Load())
+}
+
+// acquire takes a slot, waiting until ctx ends at the latest.
+func (b *Bulkhead) acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case b.slots <- struct{}{}:
+		return func() { <-b.slots }, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// Bulkheads holds one bulkhead per service, sized by A_CONCURRENCY_LIMIT and
+// B_CONCURRENCY_LIMIT: a service using up its slots leaves the other's alone.
+type Bulkheads struct {
+	A, B *Bulkhead
+}
+
+// NewBulkheads creates bulkheads of aLimit and bLimit slots.
+func NewBulkheads(aLimit, bLimit int) Bulkheads {
+	return Bulkheads{A: NewBulkhead(aLimit), B: NewBulkhead(bLimit)}
+}
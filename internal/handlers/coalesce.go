@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/services"
+)
+
+// HeaderCoalesced reports how many requests shared one coalesced execution.
+const HeaderCoalesced = "X-Coalesced"
+
+// execute runs exec, merged with identical requests when the mode has a
+// coalescing window configured.
+func (h *Handlers) execute(c *gin.Context, ctx context.Context, mode string, exec func(context.Context) outcome) outcome {
+	g, ok := h.coalescers[mode]
+	if !ok {
+		return exec(ctx)
+	}
+
+	o, shared, err := g.Do(ctx, coalesceKey(mode, c.Request.URL.Query(), c.GetHeader(services.ScriptHeader)), func(ctx context.Context) (outcome, error) {
+		return exec(ctx), nil
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ctx.Err() != nil {
+			status = http.StatusRequestTimeout
+		}
+		return outcome{status: status, err: err}
+	}
+
+	c.Header(HeaderCoalesced, strconv.Itoa(shared))
+	if shared > 1 {
+		reportFrom(ctx).shared()
+	}
+	h.M.CoalescedRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("endpoint", mode),
+		attribute.Bool("shared", shared > 1),
+	))
+	return o
+}
+
+// coalesceKey identifies identical requests: same mode, query and simulation
+// script, ignoring debug, which only changes what is reported.
+func coalesceKey(mode string, q url.Values, script string) string {
+	q.Del("debug")
+	return mode + "?" + q.Encode() + "#" + script
+}
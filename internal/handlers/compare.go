@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/services"
+)
+
+// Compare executes the same logical request in sync, async and async-limited
+// mode back-to-back. All runs share one seed (?seed= or random), so the
+// simulated latencies and failures are identical and only the concurrency
+// strategy differs.
+func (h *Handlers) Compare(c *gin.Context) {
+	start := h.Clock.Now()
+	ctx := c.Request.Context()
+
+	seed, err := strconv.ParseInt(c.Query("seed"), 10, 64)
+	if err != nil {
+		seed = int64(h.Svcs.RNG().Intn(math.MaxInt))
+	}
+
+	modes := []struct {
+		name string
+		exec func(context.Context) outcome
+	}{
+		{"sync", h.execSync},
+		{"async", h.execAsync},
+		{"async-limited", h.execAsyncLimited},
+	}
+
+	resp := models.CompareResponse{Seed: seed}
+	for _, m := range modes {
+		runStart := h.Clock.Now()
+		o := m.exec(services.WithSeed(ctx, seed))
+
+		r := models.CompareResult{
+			Mode:         m.name,
+			Status:       http.StatusOK,
+			TotalMs:      h.Clock.Since(runStart).Milliseconds(),
+			ServiceAData: o.a,
+			ServiceBData: o.b,
+		}
+		if o.err != nil {
+			r.Status, r.Error = o.status, o.err.Error()
+		}
+		resp.Results = append(resp.Results, r)
+	}
+
+	// Speedup is relative to the sequential baseline.
+	if base := resp.Results[0].TotalMs; base > 0 {
+		for i := range resp.Results {
+			if ms := resp.Results[i].TotalMs; ms > 0 {
+				resp.Results[i].SpeedupVsSync = float64(base) / float64(ms)
+			}
+		}
+	}
+
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	c.JSON(http.StatusOK, resp)
+}
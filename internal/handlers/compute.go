@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxComputeSteps bounds ?steps=.
+const maxComputeSteps = 1000000
+
+// defaultComputeSteps is ?steps= when the request leaves it out.
+const defaultComputeSteps = 100
+
+// maxStepIterations bounds ?stepIterations= (and COMPUTE_STEP_ITERATIONS), so
+// one step, the work between two cancellation checks, stays around 50ms.
+const maxStepIterations = 500000
+
+// Compute runs a CPU-bound computation of ?steps= steps (default 100), each
+// hashing a SHA-256 digest ?stepIterations= times over (default
+// COMPUTE_STEP_ITERATIONS). Nothing in a step blocks, so no select can notice
+// a cancellation: the loop checks ctx.Err() between steps instead, and stops
+// at the first check after the request is cancelled or its ASYNC_TIMEOUT_MS
+// deadline passes (cause handler_timeout). A stopped run fails with 408 and
+// error.progress telling how many steps completed. compute_cancel_latency_ms
+// records how late the check noticed, at most about one step.
+func (h *Handlers) Compute(c *gin.Context) {
+	ctx, start := h.begin(c)
+	steps := queryInt(c, "steps", defaultComputeSteps, 1, maxComputeSteps)
+	iters := queryInt(c, "stepIterations", h.Settings.ComputeStepIterations, 1, maxStepIterations)
+
+	cctx, cancel := clock.WithTimeoutCause(ctx, h.Clock, time.Duration(h.TimeoutMs)*time.Millisecond, apperr.ErrHandlerTimeout)
+	defer cancel()
+	ctxaudit.Expect(cctx, ctxaudit.KeyDeadline, ctxaudit.HasDeadline)
+
+	// The cancellation itself is timestamped apart, to measure how long the
+	// loop kept computing after it.
+	var cancelledAt atomic.Int64
+	stop := context.AfterFunc(cctx, func() { cancelledAt.Store(h.Clock.Now().UnixNano()) })
+	defer stop()
+
+	end := beginPhase(cctx, "compute")
+	digest := sha256.Sum256([]byte("compute"))
+	done := 0
+	for done < steps && cctx.Err() == nil {
+		for range iters {
+			digest = sha256.Sum256(digest[:])
+		}
+		done++
+	}
+	end(fmt.Sprintf("%d/%d steps", done, steps))
+	h.M.ComputeSteps.Add(ctx, int64(done))
+
+	if done < steps {
+		if at := cancelledAt.Load(); at != 0 {
+			lateness := h.Clock.Since(time.Unix(0, at))
+			h.M.ComputeCancelLatency.Record(ctx, float64(lateness.Microseconds())/1000)
+		}
+		err := &apperr.Progress{Done: done, Total: steps,
+			Err: fmt.Errorf("compute stopped after %d of %d steps: %w", done, steps, context.Cause(cctx))}
+		h.respondErr(c, "compute", start, http.StatusRequestTimeout, err)
+		return
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp := models.ComputeResponse{
+		Steps:          steps,
+		StepIterations: iters,
+		Digest:         hex.EncodeToString(digest[:]),
+		TotalMs:        h.Clock.Since(start).Milliseconds(),
+		Debug:          tl.Events(),
+	}
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "compute", http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/models"
+)
+
+// HeaderIfMatch carries the config version a PATCH was based on.
+const HeaderIfMatch = "If-Match"
+
+// configETag is the strong validator of config version v.
+func configETag(v uint64) string { return fmt.Sprintf(`"%d"`, v) }
+
+// adminConfigLocked snapshots the tunables; h.configMu must be held.
+func (h *Handlers) adminConfigLocked() models.AdminConfig {
+	cfg := models.AdminConfig{Version: h.configVersion, HedgeBudgetPct: h.HedgeBudget.Percent(), Toggles: map[string]string{}}
+	for _, t := range h.Toggles.List() {
+		cfg.Toggles[t.Name] = t.Value
+	}
+	return cfg
+}
+
+// GetConfig returns the runtime tunables with their version, also as ETag.
+func (h *Handlers) GetConfig(c *gin.Context) {
+	h.configMu.Lock()
+	cfg := h.adminConfigLocked()
+	h.configMu.Unlock()
+
+	c.Header(HeaderETag, configETag(cfg.Version))
+	c.JSON(http.StatusOK, cfg)
+}
+
+// PatchConfig changes tunables given as query parameters (hedgeBudgetPct and
+// any toggle name), all or nothing. It must name the version it was based on,
+// in If-Match or ?version=: if someone changed the config since, it answers
+// 409 with the current config instead of overwriting their change.
+func (h *Handlers) PatchConfig(c *gin.Context) {
+	q := c.Request.URL.Query()
+	base := strings.Trim(strings.TrimPrefix(c.GetHeader(HeaderIfMatch), "W/"), `"`)
+	if base == "" {
+		base = q.Get("version")
+	}
+	q.Del("version")
+	if base == "" {
+		c.String(http.StatusPreconditionRequired, "give the config version the change is based on, in If-Match or ?version=")
+		return
+	}
+	version, err := strconv.ParseUint(base, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "version must be a number")
+		return
+	}
+
+	percent := -1
+	if q.Has("hedgeBudgetPct") {
+		percent = queryInt(c, "hedgeBudgetPct", -1, 0, 100)
+		if percent < 0 {
+			c.String(http.StatusBadRequest, "hedgeBudgetPct must be an integer in [0, 100]")
+			return
+		}
+		q.Del("hedgeBudgetPct")
+	}
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	pairs := make([][2]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, [2]string{name, q.Get(name)})
+	}
+	if percent < 0 && len(pairs) == 0 {
+		c.String(http.StatusBadRequest, "nothing to change")
+		return
+	}
+
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	if version != h.configVersion {
+		cfg := h.adminConfigLocked()
+		c.Header(HeaderETag, configETag(cfg.Version))
+		c.JSON(http.StatusConflict, gin.H{"error": "config changed since version " + base, "current": cfg})
+		return
+	}
+
+	changes, err := h.applyTogglesLocked(c, pairs)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	changed := len(changes) > 0
+	if percent >= 0 && h.setHedgeBudgetLocked(c, percent) {
+		changed = true
+	}
+	if changed {
+		h.configVersion++
+	}
+
+	cfg := h.adminConfigLocked()
+	c.Header(HeaderETag, configETag(cfg.Version))
+	c.JSON(http.StatusOK, cfg)
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go-routine-stress/internal/handlers"
+	"go-routine-stress/internal/models"
+)
+
+// FuzzPatchConfig sends arbitrary PATCH /admin/config queries and If-Match
+// headers: whatever comes in, the tunables must stay valid, and the version
+// must move by one on a change and not at all otherwise.
+func FuzzPatchConfig(f *testing.F) {
+	hs := newHarness(f, nil)
+	f.Add("hedgeBudgetPct=20", "0")
+	f.Add("hedgeBudgetPct=101&tracing=off", "0")
+	f.Add("hedgeBudgetPct=-5", "")
+	f.Add("json=streamed&version=0", "")
+	f.Add("tracing=sometimes", `W/"0"`)
+	f.Add("spawn=caller-runs&hedgeBudgetPct=0", `"1"`)
+	f.Add("%zz", "x")
+	f.Fuzz(func(t *testing.T, query, ifMatch string) {
+		before := hs.config(t)
+		req, err := http.NewRequest(http.MethodPatch, hs.srv.URL+"/admin/config?"+query, nil)
+		if err != nil {
+			t.Skip(err)
+		}
+		req.Header.Set(handlers.HeaderIfMatch, ifMatch)
+		resp, err := hs.srv.Client().Do(req)
+		if err != nil {
+			t.Skip(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		after := hs.config(t)
+		switch resp.StatusCode {
+		case http.StatusOK:
+			if after.Version != before.Version && after.Version != before.Version+1 {
+				t.Fatalf("version %d -> %d in one change", before.Version, after.Version)
+			}
+		case http.StatusBadRequest, http.StatusConflict, http.StatusPreconditionRequired:
+			if after.Version != before.Version {
+				t.Fatalf("status %d, yet version %d -> %d", resp.StatusCode, before.Version, after.Version)
+			}
+		default:
+			t.Fatalf("status %d for ?%s with If-Match %q", resp.StatusCode, query, ifMatch)
+		}
+		if p := hs.h.HedgeBudget.Percent(); p < 0 || p > 100 {
+			t.Fatalf("hedge budget %d%% after ?%s", p, query)
+		}
+		for _, tg := range hs.h.Toggles.List() {
+			if !slices.Contains(tg.Values, tg.Value) {
+				t.Fatalf("toggle %s = %q, want one of %v", tg.Name, tg.Value, tg.Values)
+			}
+		}
+	})
+}
+
+// config reads the tunables through GET /admin/config.
+func (hs *harness) config(t *testing.T) models.AdminConfig {
+	t.Helper()
+	resp, err := hs.srv.Client().Get(hs.srv.URL + "/admin/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var cfg models.AdminConfig
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/httpphase"
+)
+
+// connect borrows a connection to host from its client pool, if one is
+// configured. Borrowing appears as a "conn.<host>" phase on the timeline,
+// detailed with whether the caller had to dial; a dial is the call's connect
+// phase.
+func (h *Handlers) connect(ctx context.Context, host string) (release func(), err error) {
+	pool := h.Pools[host]
+	if pool == nil {
+		return func() {}, nil
+	}
+
+	end := beginPhase(ctx, "conn."+host)
+	start := h.Clock.Now()
+	release, dialed, err := pool.Acquire(ctx)
+
+	result := "reused"
+	if dialed {
+		result = "dialed"
+	}
+	attrs := metric.WithAttributes(attribute.String("pool", host), attribute.String("result", result))
+	h.M.ConnPoolAcquires.Add(ctx, 1, attrs)
+	elapsed := h.Clock.Since(start)
+	h.M.ConnPoolAcquireDuration.Record(ctx, float64(elapsed.Milliseconds()), attrs)
+
+	if err != nil {
+		end(errDetail(err))
+		return nil, err
+	}
+	if dialed {
+		h.M.RecordPhase(ctx, host, httpphase.Connect, elapsed)
+	}
+	end(result)
+	return release, nil
+}
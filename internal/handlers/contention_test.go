@@ -0,0 +1,155 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"go-routine-stress/internal/handlers"
+	"go-routine-stress/internal/semaphore"
+	"go-routine-stress/internal/services"
+)
+
+// TestAsyncLimitedContention sends concurrent /async-limited requests
+// through a Service B bulkhead of two slots, the i-th holding its slot
+// 100+i ms on the fake clock, so no two release it at once. They are granted
+// two at a time and wait about 0, 0, 100, 100, ..., 400ms whatever the
+// order: the six past 150ms count as starved. Arriving together, the channel
+// semaphore grants them in no fixed order; arriving one at a time, in
+// arrival order, with no inversions. Either way every goroutine the requests
+// started is gone once the server is closed.
+func TestAsyncLimitedContention(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(noop.NewMeterProvider()) })
+
+	const n, slots = 10, 2
+	for _, tc := range []struct {
+		name      string
+		staggered bool
+	}{
+		{"burst", false},
+		{"staggered", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hs := newHarness(t, func(o *handlers.Options) {
+				o.Bulkheads = handlers.NewBulkheads(n, slots)
+				o.SemWatchB = semaphore.NewWatch(o.Clock, 150*time.Millisecond)
+			})
+			b := hs.h.Bulkheads.B
+			starved := counter(t, reader, "serviceB_semaphore_starved_total")
+			inversions := counter(t, reader, "serviceB_semaphore_inversions_total")
+			<-hs.h.Workers.Ready()
+			baseline := runtime.NumGoroutine()
+
+			var wg sync.WaitGroup
+			var done, failed atomic.Int64
+			for i := range n {
+				wg.Go(func() {
+					defer done.Add(1)
+					req, err := http.NewRequest(http.MethodGet, hs.srv.URL+"/async-limited", nil)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					req.Header.Set(services.ScriptHeader, fmt.Sprintf("A=10;B=%d", 100+i))
+					resp, err := hs.srv.Client().Do(req)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						failed.Add(1)
+					}
+				})
+				if tc.staggered {
+					// Let this request take its place in the queue before
+					// the next one arrives.
+					waitFor(t, func() bool {
+						inUse, _ := b.Usage()
+						return inUse+b.Waiting() == i+1
+					})
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			// Every request is at the semaphore before the clock moves.
+			waitFor(t, func() bool {
+				inUse, _ := b.Usage()
+				return inUse == slots && b.Waiting() == n-slots
+			})
+			hs.settle(t, func() bool { return done.Load() == n })
+			wg.Wait()
+			if failed.Load() > 0 {
+				t.Fatalf("%d of %d requests failed", failed.Load(), n)
+			}
+
+			if got := counter(t, reader, "serviceB_semaphore_starved_total") - starved; got != 6 {
+				t.Errorf("%d starved grants, want 6", got)
+			}
+			got := counter(t, reader, "serviceB_semaphore_inversions_total") - inversions
+			if tc.staggered && got != 0 {
+				t.Errorf("%d inversions with requests arriving one at a time, want 0", got)
+			}
+			if got < 0 || got > n*(n-1)/2 {
+				t.Errorf("%d inversions, want at most one per pair of requests", got)
+			}
+
+			hs.srv.Client().CloseIdleConnections()
+			hs.srv.Close()
+			deadline := time.Now().Add(2 * time.Second)
+			for runtime.NumGoroutine() > baseline {
+				if time.Now().After(deadline) {
+					var buf strings.Builder
+					_ = pprof.Lookup("goroutine").WriteTo(&buf, 1)
+					t.Fatalf("%d goroutines after the server closed, %d before the requests:\n%s", runtime.NumGoroutine(), baseline, buf.String())
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+// waitFor polls cond in real time, without moving the fake clock.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition never held")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// counter sums the int64 counter name over its attributes.
+func counter(t *testing.T, r sdkmetric.Reader, name string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == name {
+				for _, dp := range sum.DataPoints {
+					total += dp.Value
+				}
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/cost"
+)
+
+// ErrInsufficientTokens is returned when the shared budget cannot cover a request's cost.
+var ErrInsufficientTokens = errors.New("overloaded: insufficient capacity tokens")
+
+// HeaderCostUnits reports the cost units a request was estimated at.
+const HeaderCostUnits = "X-Cost-Units"
+
+// guardCost estimates each request's cost units from its body and admits
+// requests to endpoint only while the shared budget can cover its token cost
+// times those units; the tokens are held until the request finishes. The
+// units also weigh the request's hold on the Service B semaphore.
+func (h *Handlers) guardCost(endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+	base, priced := h.Costs[endpoint]
+	priced = priced && h.Budget != nil
+	est := cost.Estimator{BytesPerUnit: int64(h.Settings.CostBytesPerUnit), MaxUnits: int64(h.Settings.CostMaxUnits)}
+
+	return func(c *gin.Context) {
+		units, err := h.estimateCost(c, est)
+		if err != nil {
+			h.respondErr(c, endpoint, h.Clock.Now(), http.StatusBadRequest,
+				apperr.New(apperr.CodeBadRequest, "", fmt.Errorf("request body: %w", err)))
+			return
+		}
+		ctx := c.Request.Context()
+		h.M.RequestCost.Record(ctx, units, metric.WithAttributes(attribute.String("endpoint", endpoint)))
+		c.Header(HeaderCostUnits, itoa64(units))
+		if !priced {
+			next(c)
+			return
+		}
+
+		n := base * units
+		if !h.Budget.TryAcquire(n) {
+			h.M.CostRejections.Add(ctx, 1, metric.WithAttributes(attribute.String("endpoint", endpoint)))
+			c.Header(HeaderRateLimitLimit, itoa64(h.Budget.Capacity()))
+			c.Header(HeaderRateLimitRemaining, itoa64(h.Budget.Available()))
+			h.respondErr(c, endpoint, h.Clock.Now(), http.StatusServiceUnavailable,
+				apperr.New(apperr.CodeOverloaded, "", ErrInsufficientTokens))
+			return
+		}
+		defer h.Budget.Release(n)
+		next(c)
+	}
+}
+
+// estimateCost sizes the request by its body and records the units on its
+// context. Only as much of the body as the estimate needs is read; the body
+// stays readable in full.
+func (h *Handlers) estimateCost(c *gin.Context, est cost.Estimator) (int64, error) {
+	units := int64(1)
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		head, err := io.ReadAll(io.LimitReader(c.Request.Body, est.ReadLimit()))
+		if err != nil {
+			return 0, err
+		}
+		c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+		units = est.Units(head)
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), costUnitsKey{}, units))
+	return units, nil
+}
+
+type costUnitsKey struct{}
+
+// costUnits returns the cost units of the request ctx belongs to; 1 when it
+// was not estimated.
+func costUnits(ctx context.Context) int64 {
+	if n, ok := ctx.Value(costUnitsKey{}).(int64); ok {
+		return n
+	}
+	return 1
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
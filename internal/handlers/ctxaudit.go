@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/timeline"
+	"go-routine-stress/internal/toggles"
+)
+
+// auditCtx checks that ctx, as handed to a dependency call, still carries what
+// the request established. Each lost property counts once per site; in debug
+// mode the break is also marked on the timeline (when it survived). Basic
+// instrumentation starts no audit state, so there is nothing to check.
+func (h *Handlers) auditCtx(ctx context.Context, site string) {
+	if h.Toggles.Instrumentation.Is(toggles.InstrumentationBasic) {
+		return
+	}
+	requestID, missing := ctxaudit.Check(ctx)
+	if len(missing) == 0 {
+		return
+	}
+
+	for _, key := range missing {
+		h.M.CtxBreaks.Add(ctx, 1, metric.WithAttributes(attribute.String("site", site), attribute.String("key", key)))
+	}
+	lost := strings.Join(missing, ",")
+	timeline.FromContext(ctx).Mark("ctx.lost", site+": "+lost)
+	if h.LogCtxBreaks {
+		log.Printf("context propagation: request %q lost %s at %s", requestID, lost, site)
+	}
+}
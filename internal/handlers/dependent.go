@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+)
+
+// SequentialDependency calls Service B with data from Service A's response.
+// The data dependency forces A and B to run one after the other: goroutines
+// cannot help, and the latency is the same as /sync.
+func (h *Handlers) SequentialDependency(c *gin.Context) {
+	h.serve(c, "sequential-dependency", h.execSequentialDependency)
+}
+
+// PipelinedDependency has the same dependency, but starts the part of the
+// Service B call that does not need A's data while A runs, so only the
+// dependent remainder waits for A.
+func (h *Handlers) PipelinedDependency(c *gin.Context) {
+	h.serve(c, "pipelined-dependency", h.execPipelinedDependency)
+}
+
+func (h *Handlers) execSequentialDependency(ctx context.Context) outcome {
+	a, errA := h.callServiceA(ctx)
+	if errA != nil {
+		return outcome{status: http.StatusRequestTimeout, err: errA}
+	}
+
+	b, errB := h.callB(ctx, "B", func(ctx context.Context) (services.ServiceBData, error) {
+		return h.Svcs.ServiceBWith(ctx, a.Value)
+	})
+	if errB != nil {
+		return outcome{status: http.StatusServiceUnavailable, err: errB}
+	}
+	return outcome{a: a, b: b}
+}
+
+func (h *Handlers) execPipelinedDependency(ctx context.Context) outcome {
+	// Overlap: B's input-independent part runs alongside A.
+	prepCtx, cancelPrep := context.WithCancelCause(ctx)
+	defer cancelPrep(nil)
+	prepCh := safego.Go(prepCtx, "pipelined-dependency.B.prepare", func(ctx context.Context) (*services.PreparedB, error) {
+		end := beginPhase(ctx, "B.prepare")
+		p, err := h.Svcs.PrepareB(ctx)
+		end(errDetail(err))
+		return p, err
+	})
+
+	a, errA := h.callServiceA(ctx)
+	if errA != nil {
+		// Without A's data the prepared call is useless; stop it and join it.
+		cancelPrep(apperr.ErrSiblingFailed)
+		<-prepCh
+		return outcome{status: http.StatusRequestTimeout, err: errA}
+	}
+	prep := <-prepCh
+
+	// The rest of B needs A's data. Its metrics cover B's time on the critical
+	// path after A, not the prepare part hidden behind A.
+	b, errB := h.callB(ctx, "B", func(ctx context.Context) (services.ServiceBData, error) {
+		if prep.Err != nil {
+			return services.ServiceBData{}, prep.Err
+		}
+		return prep.Val.Finish(ctx, a.Value)
+	})
+	if errB != nil {
+		return outcome{status: http.StatusServiceUnavailable, err: errB}
+	}
+	return outcome{a: a, b: b}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/httpphase"
+)
+
+// resolve looks up host through the DNS cache, if one is configured. Lookups
+// appear as a "dns.<host>" phase on the timeline, detailed with how the cache
+// answered, and as the call's dns phase.
+func (h *Handlers) resolve(ctx context.Context, host string) error {
+	if h.DNS == nil {
+		return nil
+	}
+
+	end := beginPhase(ctx, "dns."+host)
+	start := h.Clock.Now()
+	addr, res, err := h.DNS.Resolve(ctx, host)
+
+	attrs := metric.WithAttributes(attribute.String("host", host), attribute.String("result", string(res)))
+	h.M.DNSLookups.Add(ctx, 1, attrs)
+	elapsed := h.Clock.Since(start)
+	h.M.DNSResolveDuration.Record(ctx, float64(elapsed.Milliseconds()), attrs)
+
+	if err != nil {
+		end(errDetail(err))
+		return err
+	}
+	h.M.RecordPhase(ctx, host, httpphase.DNS, elapsed)
+	end(string(res) + " " + addr)
+	return nil
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+)
+
+// ErrDraining is returned for requests arriving while the server is draining.
+var ErrDraining = errors.New("server is draining")
+
+// Drain puts the server into draining state: readiness fails and new requests
+// to guarded endpoints get 503, while in-flight requests run to completion.
+func (h *Handlers) Drain(c *gin.Context) {
+	was := h.draining.Swap(true)
+	h.recordAdmin(c, "drain", "", was, true)
+	c.JSON(http.StatusOK, gin.H{"draining": true, "inflight": len(h.Inflight.List())})
+}
+
+// Undrain resumes normal operation.
+func (h *Handlers) Undrain(c *gin.Context) {
+	was := h.draining.Swap(false)
+	h.recordAdmin(c, "undrain", "", was, false)
+	c.JSON(http.StatusOK, gin.H{"draining": false})
+}
+
+// DrainCheck is the readiness check reporting drain state.
+func (h *Handlers) DrainCheck() error {
+	if h.draining.Load() {
+		return ErrDraining
+	}
+	return nil
+}
+
+// guardDrain rejects new requests while draining.
+func (h *Handlers) guardDrain(endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.draining.Load() {
+			h.respondErr(c, endpoint, h.Clock.Now(), http.StatusServiceUnavailable,
+				apperr.New(apperr.CodeDraining, "", ErrDraining))
+			return
+		}
+		next(c)
+	}
+}
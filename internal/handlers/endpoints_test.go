@@ -0,0 +1,229 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/config"
+	"go-routine-stress/internal/models"
+)
+
+// endpointCase is one request, the script it runs under and what must come
+// back. Every simulated call's latency and outcome is fixed by the script and
+// measured on the fake clock, so totalMs is exact.
+type endpointCase struct {
+	name   string
+	path   string
+	script string
+
+	// abandon makes the client give up mid-request instead; the case then
+	// checks that the server let go of the request too.
+	abandon bool
+
+	status     int
+	totalMs    int64
+	dependency string // error.dependency of a failed request
+	cause      string // error.cancelCause of a failed request
+	value      string // substring of serviceBData.value
+	degraded   bool   // a 200 must be marked degraded
+
+	// background is how the fallback cache refresh the request leaves
+	// behind must end: ok, failed or cancelled:<by>.
+	background string
+}
+
+func endpointCases(timeoutMs int64, instances []string) []endpointCase {
+	all := func(step string) string {
+		entries := make([]string, len(instances))
+		for i, name := range instances {
+			entries[i] = name + "=" + step
+		}
+		return strings.Join(entries, ";")
+	}
+
+	return []endpointCase{
+		{name: "bad script", path: "/sync", script: "B=soon", status: http.StatusBadRequest},
+
+		{name: "sync ok", path: "/sync", script: "A=100;B=200", status: http.StatusOK, totalMs: 300},
+		{name: "sync A fails", path: "/sync", script: "A=50:fail;B=200", status: http.StatusRequestTimeout, totalMs: 50, dependency: "A"},
+		{name: "sync B fails", path: "/sync", script: "A=50;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 150, dependency: "B"},
+		{name: "sync-budget ok", path: "/sync-budget", script: "A=50;B=100", status: http.StatusOK, totalMs: 150},
+		{name: "sync-budget cuts A at its share", path: "/sync-budget", script: "A=60000;B=100", status: http.StatusRequestTimeout, totalMs: timeoutMs / 5, dependency: "A", cause: "call_budget"},
+		{name: "sync-budget gives B the rest", path: "/sync-budget", script: fmt.Sprintf("A=50;B=%d", timeoutMs-150), status: http.StatusOK, totalMs: timeoutMs - 100},
+		{name: "sync-budget B runs out", path: "/sync-budget", script: "A=50;B=60000", status: http.StatusRequestTimeout, totalMs: timeoutMs, dependency: "B", cause: "handler_timeout"},
+		{name: "sync-budget ?aPct= widens A", path: "/sync-budget?aPct=60", script: fmt.Sprintf("A=%d;B=50", timeoutMs/2), status: http.StatusOK, totalMs: timeoutMs/2 + 50},
+
+		{name: "async overlaps", path: "/async", script: "A=200;B=300", status: http.StatusOK, totalMs: 300},
+		{name: "async B fails", path: "/async", script: "A=50;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 100, dependency: "B"},
+		{name: "async A fails, waits for B", path: "/async", script: "A=50:fail;B=400", status: http.StatusServiceUnavailable, totalMs: 400, dependency: "A"},
+
+		{name: "async-limited ok", path: "/async-limited", script: "A=50;B=200", status: http.StatusOK, totalMs: 200},
+		{name: "async-limited client gives up", path: "/async-limited", script: "A=50;B=5000", abandon: true},
+
+		{name: "async-timeout ok", path: "/async-timeout", script: "A=50;B=100", status: http.StatusOK, totalMs: 100},
+		{name: "async-timeout expires", path: "/async-timeout", script: "A=50;B=60000", status: http.StatusRequestTimeout, totalMs: timeoutMs, cause: "handler_timeout"},
+
+		{name: "async-fallback ok", path: "/async-fallback", script: "A=50;B=100", status: http.StatusOK, totalMs: 100, value: "data-from-B"},
+		{name: "async-fallback serves on B failure", path: "/async-fallback", script: "A=50;B=100:fail", status: http.StatusOK, totalMs: 100, degraded: true},
+		{name: "async-fallback serves on B timeout", path: "/async-fallback?bTimeoutMs=200", script: "A=50;B=60000", status: http.StatusOK, totalMs: 200, degraded: true},
+		{name: "async-fallback refresh inherits the request", path: "/async-fallback?bTimeoutMs=100&refresh=inherit", script: "A=50;B=300", status: http.StatusOK, totalMs: 100, degraded: true, background: "cancelled:request"},
+		{name: "async-fallback refresh detached", path: "/async-fallback?bTimeoutMs=100&refresh=detached", script: "A=50;B=300", status: http.StatusOK, totalMs: 100, degraded: true, background: "ok"},
+		{name: "async-fallback refresh detached times out", path: "/async-fallback?bTimeoutMs=100&refresh=detached&refreshTimeoutMs=150", script: "A=50;B=300", status: http.StatusOK, totalMs: 100, degraded: true, background: "cancelled:timeout"},
+		{name: "async-fallback refresh drain", path: "/async-fallback?bTimeoutMs=100&refresh=drain", script: "A=50;B=300", status: http.StatusOK, totalMs: 100, degraded: true, background: "ok"},
+		{name: "async-fallback A fails", path: "/async-fallback", script: "A=50:fail;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 100, dependency: "A"},
+
+		{name: "async-singleflight ok", path: "/async-singleflight", script: "A=50;B=200", status: http.StatusOK, totalMs: 200},
+		{name: "async-singleflight B fails", path: "/async-singleflight", script: "A=50;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 100, dependency: "B"},
+
+		{name: "async-breaker ok", path: "/async-breaker", script: "A=50;B=100", status: http.StatusOK, totalMs: 100},
+		{name: "async-breaker B fails", path: "/async-breaker", script: "A=50;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 100, dependency: "B"},
+
+		{name: "partial both ok", path: "/partial", script: "A=50;B=100", status: http.StatusOK, totalMs: 100, value: "data-from-B"},
+		{name: "partial B fails", path: "/partial", script: "A=50;B=100:fail", status: http.StatusMultiStatus, totalMs: 100},
+		{name: "partial A fails", path: "/partial", script: "A=50:fail;B=200", status: http.StatusMultiStatus, totalMs: 200},
+		{name: "partial both fail", path: "/partial", script: "A=50:fail;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 100},
+
+		{name: "async-hedged primary wins", path: "/async-hedged?delayMs=100", script: "A=50;B=300", status: http.StatusOK, totalMs: 300},
+		// The hedge fires at 50ms, so the request waits for it after the primary fails.
+		{name: "async-hedged waits for the hedge", path: "/async-hedged?delayMs=50", script: "A=50;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 150, dependency: "B"},
+
+		{name: "pool overlaps", path: "/pool", script: "A=200;B=300", status: http.StatusOK, totalMs: 300},
+		{name: "pool B fails", path: "/pool", script: "A=50;B=100:fail", status: http.StatusServiceUnavailable, totalMs: 100, dependency: "B"},
+
+		{name: "smart ok", path: "/smart", script: "A=50;" + all("100"), status: http.StatusOK, totalMs: 100, value: "data-from-B/"},
+		{name: "smart instance fails", path: "/smart", script: "A=50;" + all("50:fail"), status: http.StatusServiceUnavailable, totalMs: 50},
+
+		{name: "balanced ok", path: "/balanced", script: "A=50;" + all("100"), status: http.StatusOK, totalMs: 100, value: "data-from-B/"},
+		{name: "balanced replica fails", path: "/balanced?strategy=round_robin", script: "A=50;" + all("50:fail"), status: http.StatusServiceUnavailable, totalMs: 50},
+
+		{name: "sequential adds up", path: "/sequential-dependency", script: "A=100;B=200", status: http.StatusOK, totalMs: 300},
+		// B prepares 40% of its 200ms while A runs, so 100 + 120.
+		{name: "pipelined overlaps", path: "/pipelined-dependency", script: "A=100;B=200", status: http.StatusOK, totalMs: 220},
+		{name: "pipelined B fails", path: "/pipelined-dependency", script: "A=100;B=200:fail", status: http.StatusServiceUnavailable, totalMs: 220},
+
+		{name: "race takes the fastest", path: "/race?replicas=3", script: "A=50;replica-1=300;replica-2=100;replica-3=60000", status: http.StatusOK, totalMs: 100, value: "data-from-B/replica-2"},
+		{name: "race fails once all replicas fail", path: "/race?replicas=2", script: "A=50;replica-1=50:fail;replica-2=120:fail", status: http.StatusServiceUnavailable, totalMs: 120},
+
+		{name: "regional serves locally", path: "/regional", script: "A=50;region-local=100;region-nearby=60000", status: http.StatusOK, totalMs: 100, value: "data-from-B/region-local"},
+		{name: "regional fails over", path: "/regional?hedgeMs=0", script: "A=50;region-local=50:fail;region-nearby=100", status: http.StatusOK, totalMs: 150, value: "data-from-B/region-nearby"},
+		{name: "regional hedges a slow region", path: "/regional?hedgeMs=100", script: "A=50;region-local=60000;region-nearby=100", status: http.StatusOK, totalMs: 200, value: "data-from-B/region-nearby"},
+		{name: "regional fails once all regions fail", path: "/regional?hedgeMs=0", script: "A=50;region-local=50:fail;region-nearby=50:fail;region-remote=50:fail", status: http.StatusServiceUnavailable, totalMs: 150},
+
+		// Fetch takes 100ms an item and transform 200ms, so transform sets the pace.
+		{name: "pipeline paced by slowest stage", path: "/pipeline?items=3&transformMs=200&fetchBuffer=0&transformBuffer=0", script: "A=100", status: http.StatusOK, totalMs: 700},
+		{name: "pipeline fetch fails", path: "/pipeline?items=3", script: "A=50:fail", status: http.StatusServiceUnavailable, totalMs: 50, dependency: "A"},
+
+		{name: "fanout runs every call at once", path: "/fanout?n=200", script: "A=50;B=100", status: http.StatusOK, totalMs: 100},
+		{name: "fanout tolerates failures", path: "/fanout?n=10&bPct=50", script: "A=50;B=100:fail", status: http.StatusOK, totalMs: 100},
+		{name: "fanout fails once every call fails", path: "/fanout?n=10", script: "A=50:fail;B=80:fail", status: http.StatusServiceUnavailable, totalMs: 80},
+
+		// A, B, A, B: each call starts only once the call window before it has room.
+		{name: "ordered fan-in window of one runs in turn", path: "/ordered-fanin?n=4&window=1", script: "A=50;B=100", status: http.StatusOK, totalMs: 300},
+		{name: "ordered fan-in window refills in order", path: "/ordered-fanin?n=4&window=2", script: "A=50;B=100", status: http.StatusOK, totalMs: 200},
+		{name: "ordered fan-in wide window runs at once", path: "/ordered-fanin?n=20&window=20", script: "A=50;B=100", status: http.StatusOK, totalMs: 100},
+		{name: "ordered fan-in fails once every call fails", path: "/ordered-fanin?n=4", script: "A=50:fail;B=80:fail", status: http.StatusServiceUnavailable, totalMs: 80},
+
+		{name: "scatter gathers every shard", path: "/scatter?shards=3", script: "shard-1=50;shard-2=100;shard-3=150", status: http.StatusOK, totalMs: 150},
+		{name: "scatter cuts the slow shard", path: "/scatter?shards=3&shardTimeoutMs=200", script: "shard-1=50;shard-2=100;shard-3=60000", status: http.StatusOK, totalMs: 200},
+		{name: "scatter gathers with reflect.Select", path: "/scatter?shards=3&gather=reflect", script: "shard-1=50;shard-2=100;shard-3=150", status: http.StatusOK, totalMs: 150},
+		{name: "scatter gathers with merge goroutines", path: "/scatter?shards=3&gather=merge&shardTimeoutMs=200", script: "shard-1=50;shard-2=100:fail;shard-3=60000", status: http.StatusOK, totalMs: 200},
+		{name: "scatter fails with no shard", path: "/scatter?shards=2&shardTimeoutMs=100", script: "shard-1=60000;shard-2=50:fail", status: http.StatusServiceUnavailable, totalMs: 100},
+
+		// Compute spends no simulated time: it only ends early at the deadline,
+		// which a busy loop lets the clock reach, so the finishing run is made short.
+		{name: "compute completes", path: "/compute?steps=10&stepIterations=1", status: http.StatusOK, totalMs: 0},
+		{name: "compute stops at the deadline", path: "/compute?steps=1000000", status: http.StatusRequestTimeout, totalMs: timeoutMs, cause: "handler_timeout"},
+		{name: "compute client gives up", path: "/compute?steps=1000000", abandon: true},
+
+		{name: "v2 sync ok", path: "/v2/sync", script: "A=100;B=200", status: http.StatusOK, totalMs: 300},
+		{name: "v2 async-fallback degrades", path: "/v2/async-fallback", script: "A=50;B=100:fail", status: http.StatusOK, totalMs: 100, degraded: true},
+		{name: "v2 async-timeout expires", path: "/v2/async-timeout", script: "A=50;B=60000", status: http.StatusRequestTimeout, totalMs: timeoutMs, cause: "handler_timeout"},
+
+		{name: "quorum cuts the straggler", path: "/quorum?m=3&n=2", script: "replica-1=50;replica-2=100;replica-3=60000", status: http.StatusOK, totalMs: 100},
+		{name: "quorum tolerates a failure", path: "/quorum?m=3&n=2", script: "replica-1=50:fail;replica-2=80;replica-3=100", status: http.StatusOK, totalMs: 100},
+		{name: "quorum unreachable", path: "/quorum?m=3&n=2", script: "replica-1=50:fail;replica-2=80:fail;replica-3=60000", status: http.StatusServiceUnavailable, totalMs: 80},
+	}
+}
+
+// TestEndpoints exercises the success, error, timeout and cancellation
+// branches of every endpoint that calls the simulated services, under the
+// server's default settings.
+func TestEndpoints(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var instances []string
+	for entry := range strings.SplitSeq(cfg.BInstances, ";") {
+		name, _, _ := strings.Cut(entry, "=")
+		instances = append(instances, strings.TrimSpace(name))
+	}
+
+	for _, tc := range endpointCases(int64(cfg.AsyncTimeoutMs), instances) {
+		t.Run(tc.name, func(t *testing.T) {
+			hs := newHarness(t, nil)
+			if tc.abandon {
+				hs.abandon(t, tc.path, tc.script, 200*time.Millisecond)
+				return
+			}
+			resp, body := hs.get(t, tc.path, tc.script)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tc.status, body)
+			}
+			if tc.status == http.StatusBadRequest {
+				return
+			}
+			var got struct {
+				models.CombinedResponse
+				Error *models.ErrorDetail `json:"error"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("%v: %s", err, body)
+			}
+			if got.TotalMs != tc.totalMs {
+				t.Errorf("totalMs = %d, want %d", got.TotalMs, tc.totalMs)
+			}
+			if tc.value != "" && !strings.Contains(got.ServiceBData.Value, tc.value) {
+				t.Errorf("B value = %q, want it to contain %q", got.ServiceBData.Value, tc.value)
+			}
+			if tc.degraded && !got.Degraded {
+				t.Error("want a degraded response")
+			}
+			if tc.dependency != "" || tc.cause != "" {
+				switch {
+				case got.Error == nil:
+					t.Errorf("want an error body: %s", body)
+				case tc.dependency != "" && got.Error.Dependency != tc.dependency:
+					t.Errorf("dependency = %q, want %q", got.Error.Dependency, tc.dependency)
+				case tc.cause != "" && got.Error.CancelCause != tc.cause:
+					t.Errorf("cancel cause = %q, want %q", got.Error.CancelCause, tc.cause)
+				}
+			}
+			if tc.background != "" {
+				hs.settle(t, func() bool { return refreshes(hs.h.Background)[tc.background] > 0 })
+			}
+		})
+	}
+}
+
+// refreshes counts the ends of fallback cache refreshes so far, under every
+// policy: ok, failed, and cancelled:<by>.
+func refreshes(r *background.Runner) map[string]int64 {
+	out := make(map[string]int64)
+	for _, st := range r.Stats() {
+		if st.Job != "fallback.refresh" {
+			continue
+		}
+		out["ok"] += st.Outcomes["ok"]
+		out["failed"] += st.Outcomes["failed"]
+		for by, n := range st.CancelledBy {
+			out["cancelled:"+by] += n
+		}
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/models"
+)
+
+// Conditional request headers.
+const (
+	HeaderETag        = "ETag"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// etag is a weak validator over the body of resp as marshalled, latencies
+// included, so two responses share a tag only when their bodies are the same.
+// It is taken before the debug timeline and the /v2 report are added, which
+// is why it is weak: equal tags mean the same result, not the same bytes.
+func etag(resp models.CombinedResponse) string {
+	h := fnv.New64a()
+	resp.Debug = nil
+	// Every field of a CombinedResponse marshals, and the hash takes any write.
+	_ = json.NewEncoder(h).Encode(resp)
+	return fmt.Sprintf(`W/"%016x"`, h.Sum64())
+}
+
+// etagMatches applies the weak comparison of If-None-Match against tag.
+func etagMatches(ifNoneMatch, tag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag of resp and reports whether the client's cached
+// copy is still current, in which case it has already answered 304. The work
+// behind the response has been done either way: conditional requests save
+// bytes on the wire, not server-side concurrency.
+func (h *Handlers) notModified(c *gin.Context, resp models.CombinedResponse) bool {
+	tag := etag(resp)
+	c.Header(HeaderETag, tag)
+
+	inm := c.GetHeader(HeaderIfNoneMatch)
+	if inm == "" {
+		return false
+	}
+
+	hit := etagMatches(inm, tag)
+	result := "modified"
+	if hit {
+		result = "not_modified"
+		c.Status(http.StatusNotModified)
+	}
+	h.M.ConditionalRequests.Add(c.Request.Context(), 1, metric.WithAttributes(
+		attribute.String("endpoint", resp.Mode),
+		attribute.String("result", result),
+	))
+	return hit
+}
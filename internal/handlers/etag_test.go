@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"testing"
+
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+func TestETag(t *testing.T) {
+	base := models.CombinedResponse{
+		ServiceAData: services.ServiceAData{Value: "A", SleepMs: 120},
+		ServiceBData: services.ServiceBData{Value: "B", SleepMs: 300},
+		Mode:         "async",
+		TotalMs:      300,
+	}
+	slower := base
+	slower.ServiceBData.SleepMs = 450
+	slower.TotalMs = 450
+	split := base
+	split.Variant = "errgroup"
+
+	tag := etag(base)
+	for name, resp := range map[string]models.CombinedResponse{"sleepMs": slower, "variant": split} {
+		if etag(resp) == tag {
+			t.Errorf("%s differs, tag %s does not", name, tag)
+		}
+	}
+	// The timeline is left out: the same result keeps its tag in debug mode.
+	debug := base
+	debug.Debug = []timeline.Event{{Name: "serialize"}}
+	if etag(debug) != tag || !etagMatches(tag, etag(debug)) {
+		t.Errorf("tag changed with the debug timeline")
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FanInPolicy decides how fanOut reacts to a failed call.
+type FanInPolicy string
+
+const (
+	// FanInWaitAll waits for both calls and reports every error together.
+	FanInWaitAll FanInPolicy = "wait_all"
+	// FanInFirstError fails on the first error and cancels the sibling with
+	// cause sibling_failed, trading error detail for a shorter failure tail.
+	FanInFirstError FanInPolicy = "first_error"
+)
+
+// HeaderFanInPolicy reports the fan-in policy a fan-out response was built under.
+const HeaderFanInPolicy = "X-Fan-In-Policy"
+
+// fanInPolicy returns the policy configured for mode, waiting for all by default.
+func (h *Handlers) fanInPolicy(mode string) FanInPolicy {
+	if p, ok := h.FanIn[mode]; ok {
+		return p
+	}
+	return FanInWaitAll
+}
+
+// ParseFanInPolicies parses "endpoint=policy" entries separated by ';'.
+func ParseFanInPolicies(s string) (map[string]FanInPolicy, error) {
+	out := make(map[string]FanInPolicy)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, policy, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("fan-in policy %q: want endpoint=policy", entry)
+		}
+		p := FanInPolicy(strings.TrimSpace(policy))
+		switch p {
+		case FanInWaitAll, FanInFirstError:
+		default:
+			return nil, fmt.Errorf("fan-in policy %q: unknown policy %q", entry, p)
+		}
+		out[strings.TrimSpace(endpoint)] = p
+	}
+	return out, nil
+}
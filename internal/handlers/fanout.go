@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxFanoutWidth bounds ?n=: far past the two calls of the other modes, but
+// not unbounded.
+const maxFanoutWidth = 10000
+
+// defaultFanoutWidth is ?n= when the request leaves it out.
+const defaultFanoutWidth = 50
+
+// errFanoutFailed is returned when every call of a /fanout request failed.
+var errFanoutFailed = errors.New("every call failed")
+
+// Fanout starts ?n= calls at once (default 50), each on its own goroutine:
+// ?bPct= percent of them (default 50) to Service B, the rest to Service A.
+// It waits for all of them, tolerating failures, and reports how many
+// goroutines the request started and how many ran at once, next to the
+// outcome counts. Only when every call failed does the request fail.
+func (h *Handlers) Fanout(c *gin.Context) {
+	ctx, start := h.begin(c)
+	n := queryInt(c, "n", defaultFanoutWidth, 1, maxFanoutWidth)
+	nB := n * queryInt(c, "bPct", 50, 0, 100) / 100
+	h.M.FanoutWidth.Record(ctx, int64(n))
+
+	var running, peak atomic.Int64
+	results := make([]<-chan safego.Result[struct{}], n)
+	for i := range n {
+		call := func(ctx context.Context) error {
+			_, err := h.callServiceA(ctx)
+			return err
+		}
+		name := "fanout.A"
+		if i < nB {
+			call = func(ctx context.Context) error {
+				_, err := h.callServiceB(ctx)
+				return err
+			}
+			name = "fanout.B"
+		}
+		results[i] = safego.Go(ctx, name, func(ctx context.Context) (struct{}, error) {
+			now := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); now > p; p = peak.Load() {
+				if peak.CompareAndSwap(p, now) {
+					break
+				}
+			}
+			return struct{}{}, call(ctx)
+		})
+	}
+
+	resp := models.FanoutResponse{N: n, CallsA: n - nB, CallsB: nB, Goroutines: n, Failures: map[string]int{}}
+	// One error stands for all: thousands joined would swamp the response.
+	var firstErr error
+	for _, ch := range results {
+		res := <-ch
+		if res.Err != nil {
+			resp.Failed++
+			resp.Failures[string(apperr.CodeOf(res.Err))]++
+			if firstErr == nil {
+				firstErr = res.Err
+			}
+			continue
+		}
+		resp.Succeeded++
+	}
+	resp.PeakGoroutines = int(peak.Load())
+
+	if ctx.Err() != nil {
+		h.respondErr(c, "fanout", start, http.StatusRequestTimeout, context.Cause(ctx))
+		return
+	}
+	if resp.Succeeded == 0 {
+		h.respondErr(c, "fanout", start, http.StatusServiceUnavailable,
+			apperr.New(apperr.CodeDependencyFailure, "", fmt.Errorf("%w, %d of %d: %w", errFanoutFailed, n, n, firstErr)))
+		return
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	resp.Debug = tl.Events()
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "fanout", http.StatusOK)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Gather names how /scatter collects its shard replies.
+type Gather string
+
+const (
+	// GatherShared has every shard send on one channel, read in a loop.
+	GatherShared Gather = "shared"
+	// GatherReflect gives each shard a channel of its own and reads them
+	// with reflect.Select, the only way to select over a number of channels
+	// known only at run time. Every read scans the cases still open.
+	GatherReflect Gather = "reflect"
+	// GatherMerge gives each shard a channel of its own and one goroutine
+	// per channel forwarding its reply to a shared one: n more goroutines
+	// instead of a dynamic select.
+	GatherMerge Gather = "merge"
+)
+
+// HeaderScatterGather reports the gather strategy a /scatter response was collected with.
+const HeaderScatterGather = "X-Scatter-Gather"
+
+// ParseGather validates a gather strategy name.
+func ParseGather(s string) (Gather, error) {
+	switch g := Gather(s); g {
+	case GatherShared, GatherReflect, GatherMerge:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown gather strategy %q (want shared, reflect or merge)", s)
+}
+
+// start sets up the collection of n replies, one per shard. Shard i sends
+// its reply, once, on sinks[i]; next returns the replies in arrival order
+// and must be called exactly n times. No send blocks, whether or not next is
+// called.
+func (g Gather) start(n int) (sinks []chan<- shardReply, next func() shardReply) {
+	sinks = make([]chan<- shardReply, n)
+	switch g {
+	case GatherReflect:
+		cases := make([]reflect.SelectCase, n)
+		for i := range n {
+			ch := make(chan shardReply, 1)
+			sinks[i] = ch
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+		}
+		return sinks, func() shardReply {
+			chosen, v, _ := reflect.Select(cases)
+			// Each shard sends once, so its case is done with.
+			cases = slices.Delete(cases, chosen, chosen+1)
+			return v.Interface().(shardReply)
+		}
+
+	case GatherMerge:
+		merged := make(chan shardReply, n)
+		for i := range n {
+			ch := make(chan shardReply, 1)
+			sinks[i] = ch
+			go func() { merged <- <-ch }()
+		}
+		return sinks, func() shardReply { return <-merged }
+
+	default:
+		shared := make(chan shardReply, n)
+		for i := range n {
+			sinks[i] = shared
+		}
+		return sinks, func() shardReply { return <-shared }
+	}
+}
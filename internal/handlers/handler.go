@@ -2,34 +2,309 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
-
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+
+	"go-routine-stress/internal/alerts"
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/audit"
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/balancer"
+	"go-routine-stress/internal/brownout"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/coalesce"
+	"go-routine-stress/internal/config"
+	"go-routine-stress/internal/connpool"
+	"go-routine-stress/internal/cost"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/diag"
+	"go-routine-stress/internal/dns"
+	"go-routine-stress/internal/fairq"
+	"go-routine-stress/internal/fallback"
+	"go-routine-stress/internal/health"
+	"go-routine-stress/internal/hedge"
+	"go-routine-stress/internal/httpphase"
+	"go-routine-stress/internal/inflight"
+	"go-routine-stress/internal/markers"
+	"go-routine-stress/internal/middleware"
 	"go-routine-stress/internal/models"
 	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/pool"
+	"go-routine-stress/internal/preflight"
+	"go-routine-stress/internal/readiness"
+	"go-routine-stress/internal/recommend"
+	"go-routine-stress/internal/resilience"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/semaphore"
 	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/shed"
+	"go-routine-stress/internal/stats"
+	"go-routine-stress/internal/timeline"
+	"go-routine-stress/internal/toggles"
 )
 
+// StatusClientClosedRequest is recorded (never really delivered) when the client
+// disconnects before a response is ready, following the nginx 499 convention.
+const StatusClientClosedRequest = 499
+
 // Handlers contains all HTTP handlers and their dependencies.
 type Handlers struct {
 	Svcs *services.Services
 	M    *observability.Metrics
 
-	// Semaphore used to limit Service B concurrency (backpressure).
-	SemB chan struct{}
+	// Per-service bulkheads limiting /async-limited concurrency (backpressure).
+	// Waiters for Service B's slots are reported as X-Queue-Depth.
+	Bulkheads Bulkheads
+
+	// Optional strict-FIFO semaphore used instead of Service B's bulkhead.
+	FIFOB *semaphore.FIFO
+
+	// Follows Service B bulkhead waiters for starvation and ordering inversions.
+	SemWatchB *semaphore.Watch
 
 	// Timeout in milliseconds for /async-timeout.
 	TimeoutMs int
+
+	// Clock used for all latency measurements.
+	Clock clock.Clock
+
+	// Recently finished debug-mode timelines, served by /timeline/{requestID}.
+	Timelines *timeline.Ring
+
+	// Rolling per-endpoint stats, served by /stats.
+	Agg *stats.Aggregator
+
+	// Readiness checks served by /ready.
+	Readiness *readiness.Checker
+
+	// Alert engine whose firing alerts are served by /alerts.
+	Alerts *alerts.Engine
+
+	// Overload limiters per endpoint, applied by Guard.
+	Shed map[string]*shed.Limiter
+
+	// Highest X-Priority class per API-key tenant; others are capped at normal.
+	PriorityCeilings map[string]shed.Priority
+
+	// Last good Service B result, served by degraded responses.
+	FallbackB *fallback.Cache[services.ServiceBData]
+
+	// Per-try timeout and overall budget for Service B.
+	RetryB RetryPolicy
+
+	// Coalescing groups per mode; identical requests within a window share one execution.
+	coalescers map[string]*coalesce.Group[outcome]
+
+	// Service B calls in flight for /async-singleflight, joined by identical ones.
+	flightB singleflight.Group
+
+	// Named Service B instances, and the health-scored routing between them used by /smart.
+	Instances   map[string]*services.Instance
+	SmartRouter *health.Router
+
+	// Service B's region replicas, nearest first, called local-first by /regional.
+	Regions []*services.Instance
+
+	// Client-side balancer over the instances with outlier ejection, used by /balanced.
+	Balancer *balancer.Balancer
+
+	// Optional weighted fair queuing across tenants in front of Service B's bulkhead.
+	FairB *fairq.Queue
+
+	// Shared capacity-token budget and per-endpoint token costs, applied by Guard.
+	Budget *cost.Budget
+	Costs  map[string]int64
+
+	// In-flight requests, listed and cancelled through /admin/inflight.
+	Inflight *inflight.Registry
+
+	// Latencies of successful dependency calls, keyed by dependency, and the
+	// timeout and limit recommendations derived from them and Agg.
+	Deps        *stats.Aggregator
+	Recommender *recommend.Recommender
+
+	// Every dependency call that was not cancelled by its caller, failures
+	// included, for the /status rollup.
+	Calls *stats.Aggregator
+
+	// Fan-in policy per fan-out mode; unlisted modes wait for all results.
+	FanIn map[string]FanInPolicy
+
+	// Optional weighted split of fan-out requests between implementations.
+	Split *Split
+
+	// Overrides of the HTTP status chosen for failures, by cause.
+	Statuses StatusMap
+
+	// Long-lived workers running the fan-out calls of /pool.
+	Workers *pool.Pool
+
+	// Settings the server started with, included in diagnostic snapshots.
+	Settings config.Config
+
+	// Startup reachability of the real backends, reported by /status.
+	Preflight []preflight.Result
+
+	// Optional brownout controller deciding which requests skip Service B.
+	Brownout *brownout.Controller
+
+	// Caps speculative Service B calls to a share of primary ones.
+	HedgeBudget *hedge.Budget
+
+	// Stops Service B calls from /async-breaker while B keeps failing.
+	BreakerB *resilience.Breaker
+
+	// Runs the work requests leave behind, under its job's context policy.
+	Background *background.Runner
+
+	// Saves heap and goroutine profiles when thresholds are crossed.
+	Captures *diag.Capturer
+
+	// Optional caching resolver for Service B targets, consulted before each call.
+	DNS *dns.Cache
+
+	// Simulated client connection pools per Service B target; targets without one connect for free.
+	Pools map[string]*connpool.Pool
+
+	// Recent admin actions, served by /admin/audit.
+	Audit *audit.Log
+
+	// Experiment markers set through /admin/annotate.
+	Markers *markers.Store
+
+	// Runtime switches flipped through /admin/toggles.
+	Toggles *toggles.Runtime
+
+	// Log each context propagation break found at a dependency call, besides counting it.
+	LogCtxBreaks bool
+
+	// Guards runtime tuning changes; configVersion counts them for /admin/config.
+	configMu      sync.Mutex
+	configVersion uint64
+
+	// Set by /admin/drain: readiness fails and guarded endpoints reject new requests.
+	draining atomic.Bool
 }
 
-// New creates a new Handlers instance with dependencies injected.
-func New(svcs *services.Services, m *observability.Metrics, semB chan struct{}, timeoutMs int) *Handlers {
-	return &Handlers{Svcs: svcs, M: m, SemB: semB, TimeoutMs: timeoutMs}
+// Options holds the dependencies New wires into a Handlers; each field
+// fills the Handlers field of the same name, documented there. Optional
+// dependencies may be left nil.
+type Options struct {
+	Svcs        *services.Services
+	M           *observability.Metrics
+	Bulkheads   Bulkheads
+	FIFOB       *semaphore.FIFO
+	SemWatchB   *semaphore.Watch
+	TimeoutMs   int
+	Clock       clock.Clock
+	Timelines   *timeline.Ring
+	Agg         *stats.Aggregator
+	Readiness   *readiness.Checker
+	Alerts      *alerts.Engine
+	Shed        map[string]*shed.Limiter
+	FallbackB   *fallback.Cache[services.ServiceBData]
+	RetryB      RetryPolicy
+	Instances   map[string]*services.Instance
+	SmartRouter *health.Router
+	Regions     []*services.Instance
+	Balancer    *balancer.Balancer
+	FairB       *fairq.Queue
+	Budget      *cost.Budget
+	Costs       map[string]int64
+	Inflight    *inflight.Registry
+	Deps        *stats.Aggregator
+	Recommender *recommend.Recommender
+	Calls       *stats.Aggregator
+	FanIn       map[string]FanInPolicy
+	Split       *Split
+	Statuses    StatusMap
+	Workers     *pool.Pool
+	Settings    config.Config
+	Preflight   []preflight.Result
+	Brownout    *brownout.Controller
+	HedgeBudget *hedge.Budget
+	BreakerB    *resilience.Breaker
+	Background  *background.Runner
+	Captures    *diag.Capturer
+	DNS         *dns.Cache
+	Pools       map[string]*connpool.Pool
+	Audit       *audit.Log
+	Markers     *markers.Store
+	Toggles     *toggles.Runtime
+
+	LogCtxBreaks     bool
+	PriorityCeilings map[string]shed.Priority
+
+	// Coalescing window per mode; modes without one are not coalesced.
+	CoalesceWindows map[string]time.Duration
+}
+
+// New creates a new Handlers instance with the dependencies in o injected.
+func New(o Options) *Handlers {
+	h := &Handlers{
+		Svcs:         o.Svcs,
+		M:            o.M,
+		Bulkheads:    o.Bulkheads,
+		FIFOB:        o.FIFOB,
+		SemWatchB:    o.SemWatchB,
+		TimeoutMs:    o.TimeoutMs,
+		Clock:        o.Clock,
+		Timelines:    o.Timelines,
+		Agg:          o.Agg,
+		Readiness:    o.Readiness,
+		Alerts:       o.Alerts,
+		Shed:         o.Shed,
+		FallbackB:    o.FallbackB,
+		RetryB:       o.RetryB,
+		Instances:    o.Instances,
+		SmartRouter:  o.SmartRouter,
+		Regions:      o.Regions,
+		Balancer:     o.Balancer,
+		FairB:        o.FairB,
+		Budget:       o.Budget,
+		Costs:        o.Costs,
+		Inflight:     o.Inflight,
+		Deps:         o.Deps,
+		Recommender:  o.Recommender,
+		Calls:        o.Calls,
+		FanIn:        o.FanIn,
+		Split:        o.Split,
+		Statuses:     o.Statuses,
+		Workers:      o.Workers,
+		Settings:     o.Settings,
+		Preflight:    o.Preflight,
+		Brownout:     o.Brownout,
+		HedgeBudget:  o.HedgeBudget,
+		BreakerB:     o.BreakerB,
+		Background:   o.Background,
+		Captures:     o.Captures,
+		DNS:          o.DNS,
+		Pools:        o.Pools,
+		Audit:        o.Audit,
+		Markers:      o.Markers,
+		Toggles:      o.Toggles,
+		LogCtxBreaks: o.LogCtxBreaks,
+	}
+	if h.BreakerB != nil {
+		h.BreakerB.OnStateChange(h.breakerChanged)
+	}
+	h.coalescers = make(map[string]*coalesce.Group[outcome], len(o.CoalesceWindows))
+	for mode, window := range o.CoalesceWindows {
+		h.coalescers[mode] = coalesce.New[outcome](o.Clock, window, o.Background, "coalesce."+mode)
+	}
+	return h
 }
 
 // Health is a simple liveness endpoint.
@@ -37,243 +312,591 @@ func (h *Handlers) Health(c *gin.Context) {
 	c.String(http.StatusOK, "ok")
 }
 
+// Ready reports 200 when every readiness check passes, 503 otherwise.
+func (h *Handlers) Ready(c *gin.Context) {
+	ready, results := h.Readiness.Check()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"ready": ready, "checks": results})
+}
+
+// Stats returns the latest rolling per-endpoint stats.
+func (h *Handlers) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Agg.Snapshot())
+}
+
+// Heatmap returns per-endpoint latency histograms over time for heatmap
+// panels: the last ?minutes= (default 10, capped at the retained stats
+// history) in columns of ?stepSec= (default: about 60 columns), optionally
+// for one ?endpoint=.
+func (h *Handlers) Heatmap(c *gin.Context) {
+	window := time.Duration(queryInt(c, "minutes", 10, 1, 24*60)) * time.Minute
+	window = min(window, h.Agg.Retention())
+	step := time.Duration(queryInt(c, "stepSec", 0, 0, 24*3600)) * time.Second
+	if step == 0 {
+		step = window / 60
+	}
+	c.JSON(http.StatusOK, h.Agg.Heatmap(window, step, c.Query("endpoint")))
+}
+
+// ListAlerts returns the currently firing alerts and the rules being evaluated.
+func (h *Handlers) ListAlerts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"firing": h.Alerts.Firing(), "rules": h.Alerts.Rules()})
+}
+
 // Sync executes Service A and Service B sequentially.
-func (h *Handlers) Sync(c *gin.Context) {
-	start := time.Now()
-	ctx := c.Request.Context()
+func (h *Handlers) Sync(c *gin.Context) { h.serve(c, "sync", h.execSync) }
+
+// Async executes Service A and Service B concurrently with unbounded goroutines.
+func (h *Handlers) Async(c *gin.Context) { h.serve(c, "async", h.execAsync) }
+
+// AsyncLimited executes concurrently, but applies backpressure to Service B using a semaphore.
+func (h *Handlers) AsyncLimited(c *gin.Context) {
+	h.semBHeaders(c)
+	ctx := fairq.WithTenant(c.Request.Context(), h.tenantOf(c))
+	ctxaudit.Expect(ctx, ctxaudit.KeyTenant, fairq.HasTenant)
+	c.Request = c.Request.WithContext(ctx)
+	h.serve(c, "async-limited", h.execAsyncLimited)
+}
+
+// AsyncTimeout enforces a deadline using context cancellation.
+func (h *Handlers) AsyncTimeout(c *gin.Context) { h.serve(c, "async-timeout", h.execAsyncTimeout) }
+
+// Pool fans out like /async, but the calls run on the shared worker pool.
+func (h *Handlers) Pool(c *gin.Context) { h.serve(c, "pool", h.execPool) }
+
+// outcome is the result of one mode's logic, independent of HTTP.
+type outcome struct {
+	a services.ServiceAData
+	b services.ServiceBData
+
+	// degraded is set when optional work was skipped or fallback data served.
+	degraded bool
+
+	// fanIn is the fan-in policy applied, if the mode fanned out.
+	fanIn FanInPolicy
+
+	// variant is the fan-out implementation the traffic split picked, if any.
+	variant Variant
+
+	// status is the HTTP status to report when err is set.
+	status int
+	err    error
+}
+
+// serve runs exec for a request and writes its response.
+func (h *Handlers) serve(c *gin.Context, mode string, exec func(context.Context) outcome) {
+	ctx, start := h.begin(c)
+
+	o := h.execute(c, ctx, mode, exec)
+	if o.fanIn != "" {
+		c.Header(HeaderFanInPolicy, string(o.fanIn))
+	}
+	if o.variant != "" {
+		c.Header(HeaderVariant, string(o.variant))
+		c.Set(middleware.VariantKey, string(o.variant))
+	}
+	if o.err != nil {
+		h.respondErr(c, mode, start, o.status, o.err)
+		return
+	}
+
+	h.respondOK(c, models.CombinedResponse{
+		ServiceAData: o.a,
+		ServiceBData: o.b,
+		Mode:         mode,
+		TotalMs:      h.Clock.Since(start).Milliseconds(),
+		Degraded:     o.degraded,
+		Variant:      string(o.variant),
+	})
+}
+
+func (h *Handlers) execSync(ctx context.Context) outcome {
+	if o, ok := h.brownoutA(ctx, "sync"); ok {
+		return o
+	}
 
 	a, errA := h.callServiceA(ctx)
 	if errA != nil {
-		respondErr(c, "sync", start, http.StatusRequestTimeout, errA)
-		return
+		return outcome{status: http.StatusRequestTimeout, err: errA}
 	}
 
 	b, errB := h.callServiceB(ctx)
 	if errB != nil {
-		respondErr(c, "sync", start, http.StatusServiceUnavailable, errB)
-		return
+		return outcome{status: http.StatusServiceUnavailable, err: errB}
 	}
 
-	c.JSON(http.StatusOK, models.CombinedResponse{
-		ServiceAData: a,
-		ServiceBData: b,
-		Mode:         "sync",
-		TotalMs:      time.Since(start).Milliseconds(),
-	})
+	return outcome{a: a, b: b}
 }
 
-// Async executes Service A and Service B concurrently with unbounded goroutines.
-func (h *Handlers) Async(c *gin.Context) {
-	start := time.Now()
-	ctx := c.Request.Context()
+func (h *Handlers) execAsync(ctx context.Context) outcome {
+	return h.fanOut(ctx, "async", h.callServiceB)
+}
+
+func (h *Handlers) execAsyncLimited(ctx context.Context) outcome {
+	// Both services are protected by a bulkhead of their own (backpressure).
+	return h.fanOutWith(ctx, "async-limited", h.callServiceALimited, h.callServiceBLimited)
+}
 
-	type aRes struct {
-		d services.ServiceAData
-		e error
+func (h *Handlers) execPool(ctx context.Context) outcome {
+	if o, ok := h.brownoutA(ctx, "pool"); ok {
+		return o
 	}
-	type bRes struct {
-		d services.ServiceBData
-		e error
+	// Never split: the errgroup variant would spawn the goroutines the pool saves.
+	policy := h.fanInPolicy("pool")
+	o := h.fanOutChannels(ctx, "pool", policy, h.Workers, h.callServiceA, h.callServiceB)
+	o.fanIn = policy
+	return o
+}
+
+func (h *Handlers) execAsyncTimeout(parent context.Context) outcome {
+	ctx, cancel := clock.WithTimeoutCause(parent, h.Clock, time.Duration(h.TimeoutMs)*time.Millisecond, apperr.ErrHandlerTimeout)
+	defer cancel()
+	ctxaudit.Expect(ctx, ctxaudit.KeyDeadline, ctxaudit.HasDeadline)
+
+	return h.fanOut(ctx, "async-timeout", h.callServiceB)
+}
+
+// fanOut runs Service A and callB in parallel and waits for both results,
+// or for ctx to end. Under the first_error policy the first failure ends the
+// wait and cancels the other call. The traffic split decides which
+// implementation does the work.
+func (h *Handlers) fanOut(ctx context.Context, mode string, callB func(context.Context) (services.ServiceBData, error)) outcome {
+	return h.fanOutWith(ctx, mode, h.callServiceA, callB)
+}
+
+// fanOutWith is fanOut calling Service A through callA.
+func (h *Handlers) fanOutWith(ctx context.Context, mode string, callA func(context.Context) (services.ServiceAData, error), callB func(context.Context) (services.ServiceBData, error)) outcome {
+	if o, ok := h.brownoutA(ctx, mode); ok {
+		return o
+	}
+
+	policy := h.fanInPolicy(mode)
+	variant := h.Split.Pick(ctx)
+	var o outcome
+	if variant == VariantErrgroup {
+		o = h.fanOutErrgroup(ctx, mode, policy, callA, callB)
+	} else {
+		o = h.fanOutChannels(ctx, mode, policy, nil, callA, callB)
 	}
+	o.fanIn, o.variant = policy, variant
+	return o
+}
 
-	aCh := make(chan aRes, 1)
-	bCh := make(chan bRes, 1)
+// fanOutChannels is the channel variant of fanOut: each call delivers its
+// result on a channel and a select fans them in. With workers set, both
+// calls run on the worker pool instead of goroutines of their own.
+func (h *Handlers) fanOutChannels(ctx context.Context, mode string, policy FanInPolicy, workers *pool.Pool, callA func(context.Context) (services.ServiceAData, error), callB func(context.Context) (services.ServiceBData, error)) outcome {
+	tl := timeline.FromContext(ctx)
+	callCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-	// Fan-out: start both calls in parallel.
-	go func() { d, e := h.callServiceA(ctx); aCh <- aRes{d, e} }()
-	go func() { d, e := h.callServiceB(ctx); bCh <- bRes{d, e} }()
+	// Fan-out: start both calls in parallel. Under the caller-runs spawn
+	// strategy, A runs on this goroutine once B is started, saving a spawn.
+	var (
+		aCh <-chan safego.Result[services.ServiceAData]
+		bCh <-chan safego.Result[services.ServiceBData]
+	)
+	switch {
+	case workers != nil:
+		bCh = pool.Go(callCtx, workers, mode+".B", callB)
+		aCh = pool.Go(callCtx, workers, mode+".A", callA)
+	case h.Toggles.Spawn.Is(toggles.SpawnCallerRuns):
+		bCh = safego.Go(callCtx, mode+".B", callB)
+		aCh = safego.Inline(callCtx, mode+".A", callA)
+	default:
+		bCh = safego.Go(callCtx, mode+".B", callB)
+		aCh = safego.Go(callCtx, mode+".A", callA)
+	}
 
 	var (
 		gotA, gotB bool
-		a          services.ServiceAData
-		b          services.ServiceBData
-		eA, eB     error
+		a          safego.Result[services.ServiceAData]
+		b          safego.Result[services.ServiceBData]
 	)
 
 	// Fan-in: wait for both results or cancel if context expires.
 	for !(gotA && gotB) {
 		select {
-		case ar := <-aCh:
-			a, eA, gotA = ar.d, ar.e, true
-		case br := <-bCh:
-			b, eB, gotB = br.d, br.e, true
+		case a = <-aCh:
+			gotA = true
+			tl.Mark("select", "A result")
+			if a.Err != nil && policy == FanInFirstError {
+				cancel(apperr.ErrSiblingFailed)
+				return outcome{status: http.StatusServiceUnavailable, err: a.Err}
+			}
+		case b = <-bCh:
+			gotB = true
+			tl.Mark("select", "B result")
+			if b.Err != nil && policy == FanInFirstError {
+				cancel(apperr.ErrSiblingFailed)
+				return outcome{status: http.StatusServiceUnavailable, err: b.Err}
+			}
 		case <-ctx.Done():
-			respondErr(c, "async", start, http.StatusRequestTimeout, ctx.Err())
-			return
+			tl.Mark("select", "ctx.Done")
+			return outcome{status: http.StatusRequestTimeout, err: context.Cause(ctx)}
 		}
 	}
 
-	if eA != nil || eB != nil {
-		respondErr(c, "async", start, http.StatusServiceUnavailable, fmt.Errorf("A:%v B:%v", eA, eB))
-		return
+	if a.Err != nil || b.Err != nil {
+		return outcome{status: http.StatusServiceUnavailable, err: errors.Join(a.Err, b.Err)}
 	}
 
-	c.JSON(http.StatusOK, models.CombinedResponse{
-		ServiceAData: a,
-		ServiceBData: b,
-		Mode:         "async",
-		TotalMs:      time.Since(start).Milliseconds(),
-	})
+	return outcome{a: a.Val, b: b.Val}
 }
 
-// AsyncLimited executes concurrently, but applies backpressure to Service B using a semaphore.
-func (h *Handlers) AsyncLimited(c *gin.Context) {
-	start := time.Now()
-	ctx := c.Request.Context()
+// callServiceA wraps Service A with metrics.
+func (h *Handlers) callServiceA(ctx context.Context) (services.ServiceAData, error) {
+	h.auditCtx(ctx, "A")
+	ctx, span := observability.StartClientSpan(ctx, "A", "")
+	end := beginPhase(ctx, "A")
+	start := h.Clock.Now()
+	d, err := h.Svcs.ServiceA(ctx)
 
-	type aRes struct {
-		d services.ServiceAData
-		e error
+	elapsed := h.Clock.Since(start)
+	h.M.ServiceDuration.Record(ctx, float64(elapsed.Milliseconds()),
+		metric.WithAttributes(attribute.String("service", "A")),
+	)
+	if ctx.Err() == nil {
+		// A simulated call answers all at once: it is all time to first
+		// byte, with no body phase. Cancelled calls never got one.
+		h.M.RecordPhase(ctx, "A", httpphase.TTFB, elapsed)
 	}
-	type bRes struct {
-		d services.ServiceBData
-		e error
+	err = apperr.Dependency("A", err)
+	if err != nil {
+		h.M.ServiceErrors.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("service", "A"),
+			attribute.String("code", string(apperr.CodeOf(err))),
+		))
+	} else {
+		h.Deps.Observe("A", http.StatusOK, elapsed)
 	}
+	h.observeCall("A", elapsed, err)
+	reportFrom(ctx).call("A", elapsed, err)
+	end(errDetail(err))
+	observability.EndClientSpan(span, err, string(apperr.CodeOf(err)))
+	return d, err
+}
 
-	aCh := make(chan aRes, 1)
-	bCh := make(chan bRes, 1)
-
-	go func() { d, e := h.callServiceA(ctx); aCh <- aRes{d, e} }()
+// callServiceALimited takes a slot of Service A's bulkhead before calling it.
+func (h *Handlers) callServiceALimited(ctx context.Context) (services.ServiceAData, error) {
+	bh := h.Bulkheads.A
+	end := beginPhase(ctx, "semaphore.A")
+	start := h.Clock.Now()
+	ahead := int(bh.waiting.Add(1)) - 1
+
+	release, err := acquireLive(ctx, bh.acquire)
+	bh.waiting.Add(-1)
+	waited := h.Clock.Since(start)
+	reportFrom(ctx).waited("serviceA_semaphore", waited)
+	if err != nil {
+		h.countAbandoned(ctx, "serviceA_semaphore", "async-limited", waited)
+		end("abandoned")
+		_, slots := bh.Usage()
+		return services.ServiceAData{}, h.queued(ctx, "serviceA_semaphore", slots, h.Deps, "A", ahead, waited, err)
+	}
+	defer release()
 
-	// Service B is protected by a semaphore (backpressure).
-	go func() {
-		waitStart := time.Now()
+	h.M.SemWaitA.Record(ctx, float64(waited.Milliseconds()),
+		metric.WithAttributes(attribute.String("endpoint", "async-limited")),
+	)
+	end("acquired")
 
-		select {
-		case h.SemB <- struct{}{}:
-			// Record how long we waited to enter the limited section.
-			h.M.SemWaitB.Record(ctx, float64(time.Since(waitStart).Milliseconds()),
-				metric.WithAttributes(attribute.String("endpoint", "async-limited")),
-			)
-			defer func() { <-h.SemB }()
-		case <-ctx.Done():
-			bCh <- bRes{services.ServiceBData{}, ctx.Err()}
-			return
-		}
+	return h.callServiceA(ctx)
+}
 
-		d, e := h.callServiceB(ctx)
-		bCh <- bRes{d, e}
-	}()
+// callServiceBLimited acquires the Service B semaphore before calling it.
+func (h *Handlers) callServiceBLimited(ctx context.Context) (services.ServiceBData, error) {
+	end := beginPhase(ctx, "semaphore.B")
+	waiter := h.SemWatchB.Arrive()
+	h.Bulkheads.B.waiting.Add(1)
 
-	var (
-		gotA, gotB bool
-		a          services.ServiceAData
-		b          services.ServiceBData
-		eA, eB     error
+	release, err := acquireLive(ctx, h.takeB)
+	h.Bulkheads.B.waiting.Add(-1)
+	if err != nil {
+		ahead := waiter.Ahead()
+		waited := waiter.Abandoned()
+		h.countAbandoned(ctx, "serviceB_semaphore", "async-limited", waited)
+		reportFrom(ctx).waited("serviceB_semaphore", waited)
+		end("abandoned")
+		_, slots := h.semBUsage()
+		return services.ServiceBData{}, h.queued(ctx, "serviceB_semaphore", slots, h.Deps, "B", ahead, waited, err)
+	}
+	defer release()
+
+	// Record how long we waited to enter the limited section, and how fairly.
+	g := waiter.Acquired()
+	reportFrom(ctx).waited("serviceB_semaphore", g.Wait)
+	impl := attribute.String("impl", h.semImplB())
+	h.M.SemWaitB.Record(ctx, float64(g.Wait.Milliseconds()),
+		metric.WithAttributes(attribute.String("endpoint", "async-limited"), impl),
 	)
+	if g.Overtook > 0 {
+		h.M.SemInversionsB.Add(ctx, int64(g.Overtook), metric.WithAttributes(impl))
+	}
+	if g.Starved {
+		h.M.SemStarvedB.Add(ctx, 1, metric.WithAttributes(impl))
+	}
+	end("acquired")
 
-	for !(gotA && gotB) {
-		select {
-		case ar := <-aCh:
-			a, eA, gotA = ar.d, ar.e, true
-		case br := <-bCh:
-			b, eB, gotB = br.d, br.e, true
-		case <-ctx.Done():
-			respondErr(c, "async-limited", start, http.StatusRequestTimeout, ctx.Err())
-			return
-		}
+	return h.callServiceB(ctx)
+}
+
+// semImplB names the Service B semaphore's granting order for metrics.
+func (h *Handlers) semImplB() string {
+	switch {
+	case h.FairB != nil:
+		return "fair_queue"
+	case h.FIFOB != nil:
+		return "fifo"
 	}
+	return "channel"
+}
 
-	if eA != nil || eB != nil {
-		respondErr(c, "async-limited", start, http.StatusServiceUnavailable, fmt.Errorf("A:%v B:%v", eA, eB))
-		return
+// acquireLive takes a slot through take. A request whose context has ended
+// never gets a slot: not on arrival, and not when a slot frees up at the
+// moment it is cancelled, where the wait's select may pick either.
+func acquireLive(ctx context.Context, take func(context.Context) (func(), error)) (release func(), err error) {
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
 	}
+	release, err = take(ctx)
+	if err == nil && ctx.Err() != nil {
+		release()
+		return nil, context.Cause(ctx)
+	}
+	return release, err
+}
 
-	c.JSON(http.StatusOK, models.CombinedResponse{
-		ServiceAData: a,
-		ServiceBData: b,
-		Mode:         "async-limited",
-		TotalMs:      time.Since(start).Milliseconds(),
-	})
+// takeB takes a Service B slot, in tenant-fair order when fair queuing is
+// enabled, in arrival order with the FIFO semaphore.
+func (h *Handlers) takeB(ctx context.Context) (release func(), err error) {
+	if h.FairB != nil {
+		tenant := fairq.TenantFrom(ctx)
+		start := h.Clock.Now()
+		if err := h.FairB.Acquire(ctx, tenant); err != nil {
+			return nil, err
+		}
+		attrs := metric.WithAttributes(attribute.String("tenant", tenant))
+		h.M.FairQueueWait.Record(ctx, float64(h.Clock.Since(start).Milliseconds()), attrs)
+		h.M.FairQueueGrants.Add(ctx, 1, attrs)
+		return func() { h.FairB.Release(tenant) }, nil
+	}
+	if h.FIFOB != nil {
+		// The only weighted implementation: a request holds a slot per cost unit.
+		n := min(int(costUnits(ctx)), h.FIFOB.Cap())
+		if err := h.FIFOB.AcquireN(ctx, n); err != nil {
+			return nil, err
+		}
+		return func() { h.FIFOB.ReleaseN(n) }, nil
+	}
+
+	return h.Bulkheads.B.acquire(ctx)
 }
 
-// AsyncTimeout enforces a deadline using context cancellation.
-func (h *Handlers) AsyncTimeout(c *gin.Context) {
-	start := time.Now()
-	parent := c.Request.Context()
+// callServiceBOnce wraps a single Service B call with metrics.
+func (h *Handlers) callServiceBOnce(ctx context.Context) (services.ServiceBData, error) {
+	return h.callB(ctx, "B", h.Svcs.ServiceB)
+}
 
-	ctx, cancel := context.WithTimeout(parent, time.Duration(h.TimeoutMs)*time.Millisecond)
-	defer cancel()
+// callB wraps one call to Service B, or to one of its instances, with metrics.
+func (h *Handlers) callB(ctx context.Context, name string, call func(context.Context) (services.ServiceBData, error)) (d services.ServiceBData, err error) {
+	h.auditCtx(ctx, name)
+	// Name resolution and connecting are part of the call, as for a real client.
+	peer, instance, _ := strings.Cut(name, "/")
+	ctx, span := observability.StartClientSpan(ctx, peer, instance)
+	defer func() { observability.EndClientSpan(span, err, string(apperr.CodeOf(err))) }()
 
-	type aRes struct {
-		d services.ServiceAData
-		e error
+	if err := h.resolve(ctx, name); err != nil {
+		return services.ServiceBData{}, apperr.Dependency(name, err)
 	}
-	type bRes struct {
-		d services.ServiceBData
-		e error
+	release, err := h.connect(ctx, name)
+	if err != nil {
+		return services.ServiceBData{}, apperr.Dependency(name, err)
 	}
+	defer release()
 
-	aCh := make(chan aRes, 1)
-	bCh := make(chan bRes, 1)
+	end := beginPhase(ctx, name)
+	start := h.Clock.Now()
+	d, err = call(ctx)
 
-	go func() { d, e := h.callServiceA(ctx); aCh <- aRes{d, e} }()
-	go func() { d, e := h.callServiceB(ctx); bCh <- bRes{d, e} }()
-
-	var (
-		gotA, gotB bool
-		a          services.ServiceAData
-		b          services.ServiceBData
-		eA, eB     error
+	elapsed := h.Clock.Since(start)
+	h.M.ServiceDuration.Record(ctx, float64(elapsed.Milliseconds()),
+		metric.WithAttributes(attribute.String("service", name)),
 	)
+	if ctx.Err() == nil {
+		h.M.RecordPhase(ctx, name, httpphase.TTFB, elapsed)
+	}
+	err = apperr.Dependency(name, err)
+	if err != nil {
+		h.M.ServiceErrors.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("service", name),
+			attribute.String("code", string(apperr.CodeOf(err))),
+		))
+	} else {
+		h.FallbackB.Store(d)
+		// Only successes: failed and cancelled calls would censor the latency tail.
+		h.Deps.Observe(name, http.StatusOK, elapsed)
+	}
+	h.observeCall(name, elapsed, err)
+	reportFrom(ctx).call(name, elapsed, err)
+	end(errDetail(err))
+	return d, err
+}
 
-	for !(gotA && gotB) {
-		select {
-		case ar := <-aCh:
-			a, eA, gotA = ar.d, ar.e, true
-		case br := <-bCh:
-			b, eB, gotB = br.d, br.e, true
-		case <-ctx.Done():
-			respondErr(c, "async-timeout", start, http.StatusRequestTimeout, ctx.Err())
-			return
+// respondErr writes a structured error response derived from err, with
+// status unless the status map overrides it. If the client already
+// disconnected, no body is built.
+func (h *Handlers) respondErr(c *gin.Context, mode string, start time.Time, status int, err error) {
+	if apperr.ClientDisconnected(c.Request.Context()) {
+		c.Set(apperr.ReasonKey, apperr.ErrClientDisconnect.Reason)
+		c.Status(StatusClientClosedRequest)
+		return
+	}
+
+	status = h.Statuses.status(err, status)
+	reason := apperr.ReasonOf(err)
+	detail := errorDetail(err)
+	detail.CorrelationID = middleware.GetRequestID(c)
+
+	span := trace.SpanFromContext(c.Request.Context())
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		detail.TraceID = sc.TraceID().String()
+	}
+	span.RecordError(err)
+	if reason != "" {
+		span.SetAttributes(attribute.String("cancel.cause", reason))
+		c.Set(apperr.ReasonKey, reason)
+	}
+
+	q, queued := queuedOf(c.Request.Context(), err)
+	if queued {
+		detail.Queue = &models.QueueDetail{
+			Name:            q.Queue,
+			Position:        q.Position,
+			WaitedMs:        q.Waited.Milliseconds(),
+			EstimatedWaitMs: q.EstimatedWait.Milliseconds(),
 		}
 	}
 
-	if eA != nil || eB != nil {
-		respondErr(c, "async-timeout", start, http.StatusServiceUnavailable, fmt.Errorf("A:%v B:%v", eA, eB))
-		return
+	if detail.Retryable {
+		c.Header(HeaderRetryAfter, strconv.Itoa(retryAfter(q)))
 	}
 
-	c.JSON(http.StatusOK, models.CombinedResponse{
-		ServiceAData: a,
-		ServiceBData: b,
-		Mode:         "async-timeout",
-		TotalMs:      time.Since(start).Milliseconds(),
-	})
+	tl := timeline.FromContext(c.Request.Context())
+	end := tl.Begin("serialize")
+	resp := models.ErrorResponse{
+		Mode:    mode,
+		TotalMs: h.Clock.Since(start).Milliseconds(),
+		Error:   detail,
+		Debug:   tl.Events(),
+	}
+	var body any = resp
+	if r := reportFrom(c.Request.Context()); r != nil {
+		body = models.ErrorResponseV2{ErrorResponse: resp, Report: r.snapshot(err)}
+	}
+	h.writeJSON(c, status, body)
+	end("")
+	h.record(c, tl, mode, status)
 }
 
-// callServiceA wraps Service A with metrics.
-func (h *Handlers) callServiceA(ctx context.Context) (services.ServiceAData, error) {
-	start := time.Now()
-	d, err := h.Svcs.ServiceA(ctx)
+// errorDetail describes err in machine-readable form.
+func errorDetail(err error) models.ErrorDetail {
+	code := apperr.CodeOf(err)
+	detail := models.ErrorDetail{
+		Code:        string(code),
+		Message:     err.Error(),
+		Retryable:   code.Retryable(),
+		Dependency:  apperr.DependencyOf(err),
+		Causes:      apperr.Causes(err),
+		CancelCause: apperr.ReasonOf(err),
+	}
+	if p, ok := apperr.ProgressOf(err); ok {
+		detail.Progress = &models.ProgressDetail{Done: p.Done, Total: p.Total}
+	}
+	if b, ok := apperr.BudgetsOf(err); ok {
+		detail.Budgets = callBudgets(b.Calls)
+	}
+	return detail
+}
 
-	h.M.ServiceDuration.Record(ctx, float64(time.Since(start).Milliseconds()),
-		metric.WithAttributes(attribute.String("service", "A")),
-	)
-	if err != nil {
-		h.M.ServiceErrors.Add(ctx, 1, metric.WithAttributes(attribute.String("service", "A")))
+// begin starts timing a request and gives its queues a place to report their
+// state. In debug mode (?debug=true or an X-Debug header) it also attaches an
+// execution timeline to the request context.
+func (h *Handlers) begin(c *gin.Context) (context.Context, time.Time) {
+	start := h.Clock.Now()
+	c.Request = c.Request.WithContext(withQueued(c.Request.Context()))
+	if c.Query("debug") == "true" || c.GetHeader("X-Debug") != "" {
+		c.Request = c.Request.WithContext(timeline.WithTimeline(c.Request.Context(), timeline.New(h.Clock)))
 	}
-	return d, err
+	return c.Request.Context(), start
 }
 
-// callServiceB wraps Service B with metrics.
-func (h *Handlers) callServiceB(ctx context.Context) (services.ServiceBData, error) {
-	start := time.Now()
-	d, err := h.Svcs.ServiceB(ctx)
+// respondOK writes a successful response, including the timeline in debug mode,
+// or 304 when the client's If-None-Match still matches.
+func (h *Handlers) respondOK(c *gin.Context, resp models.CombinedResponse) {
+	tl := timeline.FromContext(c.Request.Context())
+	if h.notModified(c, resp) {
+		h.record(c, tl, resp.Mode, http.StatusNotModified)
+		return
+	}
 
-	h.M.ServiceDuration.Record(ctx, float64(time.Since(start).Milliseconds()),
-		metric.WithAttributes(attribute.String("service", "B")),
-	)
-	if err != nil {
-		h.M.ServiceErrors.Add(ctx, 1, metric.WithAttributes(attribute.String("service", "B")))
+	end := tl.Begin("serialize")
+	resp.Debug = tl.Events()
+	var body any = resp
+	if r := reportFrom(c.Request.Context()); r != nil {
+		body = models.CombinedResponseV2{CombinedResponse: resp, Report: r.snapshot(nil)}
 	}
-	return d, err
+	h.writeJSON(c, http.StatusOK, body)
+	end("")
+	h.record(c, tl, resp.Mode, http.StatusOK)
 }
 
-func respondErr(c *gin.Context, mode string, start time.Time, status int, err error) {
-	c.JSON(status, models.ErrorResponse{
-		Mode:    mode,
-		TotalMs: time.Since(start).Milliseconds(),
-		Error:   err.Error(),
+// writeJSON writes a mode endpoint's body the way the json toggle says:
+// marshalled whole first, as gin does, or encoded straight onto the connection.
+func (h *Handlers) writeJSON(c *gin.Context, status int, v any) {
+	if h.Toggles.JSON.Is(toggles.JSONBuffered) {
+		c.JSON(status, v)
+		return
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(status)
+	if err := json.NewEncoder(c.Writer).Encode(v); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// record keeps a finished debug-mode timeline for /timeline/{requestID}.
+func (h *Handlers) record(c *gin.Context, tl *timeline.Timeline, mode string, status int) {
+	if tl == nil {
+		return
+	}
+	h.Timelines.Add(timeline.Record{
+		RequestID: middleware.GetRequestID(c),
+		Mode:      mode,
+		Status:    status,
+		StartedAt: tl.Start(),
+		Events:    tl.Events(),
 	})
 }
+
+// beginPhase starts a named phase on both the debug timeline and the in-flight
+// registry, and returns the function that ends it.
+func beginPhase(ctx context.Context, name string) func(detail string) {
+	endTimeline := timeline.FromContext(ctx).Begin(name)
+	endPhase := inflight.Phase(ctx, name)
+	return func(detail string) {
+		endPhase()
+		endTimeline(detail)
+	}
+}
+
+// errDetail summarizes a call outcome for timeline events.
+func errDetail(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
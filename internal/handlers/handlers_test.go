@@ -0,0 +1,290 @@
+package handlers_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/audit"
+	"go-routine-stress/internal/auth"
+	"go-routine-stress/internal/background"
+	"go-routine-stress/internal/balancer"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/config"
+	"go-routine-stress/internal/fallback"
+	"go-routine-stress/internal/handlers"
+	"go-routine-stress/internal/health"
+	"go-routine-stress/internal/hedge"
+	"go-routine-stress/internal/inflight"
+	"go-routine-stress/internal/markers"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/pool"
+	"go-routine-stress/internal/readiness"
+	"go-routine-stress/internal/resilience"
+	"go-routine-stress/internal/routers"
+	"go-routine-stress/internal/semaphore"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/shed"
+	"go-routine-stress/internal/stats"
+	"go-routine-stress/internal/timeline"
+	"go-routine-stress/internal/toggles"
+)
+
+func init() { gin.SetMode(gin.TestMode) }
+
+// harness serves the mode and admin endpoints over a fake clock, with
+// scripts enabled so each case fixes its own latencies and failures.
+type harness struct {
+	clk *clock.Fake
+	srv *httptest.Server
+	h   *handlers.Handlers
+}
+
+func newHarness(t testing.TB, configure func(*handlers.Options)) *harness {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := observability.NewMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	rng := services.NewSeededRNG(1)
+	svcs := services.New(clk, rng)
+	jobs, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+
+	instanceList, err := svcs.Instances(cfg.BInstances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := make(map[string]*services.Instance, len(instanceList))
+	scores := make(map[string]*health.Score, len(instanceList))
+	for _, in := range instanceList {
+		instances[in.Name] = in
+		scores[in.Name] = health.NewScore(0.2, time.Duration(cfg.SmartLatencyTargetMs)*time.Millisecond)
+	}
+	regionList, err := services.ParseRegions(cfg.Regions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strategy, err := balancer.ParseStrategy(cfg.LBStrategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := pool.New(clk, pool.Options{Size: cfg.PoolSize, Queue: cfg.PoolQueue})
+	go workers.Run(jobs)
+
+	o := handlers.Options{
+		Svcs:        svcs,
+		M:           m,
+		Bulkheads:   handlers.NewBulkheads(cfg.AConcurrencyLimit, cfg.BConcurrencyLimit),
+		SemWatchB:   semaphore.NewWatch(clk, time.Duration(cfg.SemStarvationMs)*time.Millisecond),
+		TimeoutMs:   cfg.AsyncTimeoutMs,
+		Clock:       clk,
+		Timelines:   timeline.NewRing(10),
+		Agg:         stats.New(clk, time.Second, time.Minute, time.Minute),
+		Readiness:   readiness.New(),
+		Shed:        map[string]*shed.Limiter{},
+		FallbackB:   fallback.New[services.ServiceBData](clk),
+		Instances:   instances,
+		SmartRouter: health.NewRouter(instanceList[0].Name, instanceList[1].Name, scores, float64(cfg.SmartThresholdPct)/100, 0.1, cfg.SmartProbeEvery),
+		Regions:     svcs.RegionReplicas(regionList),
+		Balancer:    balancer.New(clk, rng, instanceList, strategy, balancer.Outlier{Consecutive: cfg.OutlierConsecutive}),
+		Inflight:    inflight.New(clk, 10),
+		Deps:        stats.New(clk, time.Second, time.Minute, time.Minute),
+		Calls:       stats.New(clk, time.Second, time.Minute, time.Minute),
+		Workers:     workers,
+		Settings:    cfg,
+		HedgeBudget: hedge.NewBudget(cfg.HedgeBudgetPct, cfg.HedgeBudgetBurst),
+		BreakerB:    resilience.NewBreaker(clk, cfg.BreakerFailures, time.Duration(cfg.BreakerOpenMs)*time.Millisecond, cfg.BreakerHalfOpenProbes),
+		Background:  background.NewRunner(jobs, clk, nil, time.Duration(cfg.BackgroundTimeoutMs)*time.Millisecond, m),
+		Audit:       audit.New(clk, 10),
+		Markers:     markers.New(clk, 10),
+		Toggles:     toggles.NewRuntime(),
+
+		CoalesceWindows: map[string]time.Duration{},
+	}
+	if configure != nil {
+		configure(&o)
+	}
+	h := handlers.New(o)
+	srv := httptest.NewServer(routers.NewRouter(m, o.Agg, h, &auth.Tokens{}, nil, nil, true, true))
+	t.Cleanup(srv.Close)
+	return &harness{clk: clk, srv: srv, h: h}
+}
+
+// do sends req and moves the fake clock until the response is in.
+func (hs *harness) do(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	type result struct {
+		resp *http.Response
+		body []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := hs.srv.Client().Do(req)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		done <- result{resp, body, err}
+	}()
+
+	var r result
+	hs.settle(t, func() bool {
+		select {
+		case r = <-done:
+			return true
+		default:
+			return false
+		}
+	})
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	return r.resp, r.body
+}
+
+// settle moves the fake clock until cond holds. Whenever the server has gone
+// quiet, with the same calls waiting on the clock and the same goroutines
+// running over a few polls, it jumps to the earliest of their deadlines.
+func (hs *harness) settle(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	var last [2]int
+	quiet := 0
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("never settled")
+		}
+		time.Sleep(time.Millisecond)
+		if now := [2]int{hs.clk.Waiters(), runtime.NumGoroutine()}; now != last {
+			last, quiet = now, 0
+			continue
+		}
+		if quiet++; quiet >= 3 {
+			hs.clk.AdvanceToNext()
+			quiet = 0
+		}
+	}
+}
+
+// abandon sends a request that gives up after after of real time, with the
+// fake clock held so that nothing the server waits on can finish first, and
+// then waits for the server to let go of it.
+func (hs *harness) abandon(t *testing.T, path, script string, after time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), after)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hs.srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(services.ScriptHeader, script)
+	if resp, err := hs.srv.Client().Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		if err == nil {
+			resp.Body.Close()
+		}
+		t.Fatalf("want the client to time out, got %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(hs.h.Inflight.List()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the server kept the request in flight after the client left")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (hs *harness) get(t *testing.T, path, script string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, hs.srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if script != "" {
+		req.Header.Set(services.ScriptHeader, script)
+	}
+	return hs.do(t, req)
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		script   string
+		services []string
+		firstMs  int64
+		totalMs  int64
+	}{
+		{"async sends the faster first", "mode=async", "A=50;B=200", []string{"A", "B"}, 50, 200},
+		{"sync waits for A", "mode=sync", "A=50;B=200", []string{"A", "B"}, 50, 250},
+		{"async sends B first when faster", "mode=async", "A=300;B=100", []string{"B", "A"}, 100, 300},
+		{"async-limited", "mode=async-limited", "A=100;B=200", []string{"A", "B"}, 100, 200},
+		{"sync stops at a failed A", "mode=sync", "A=50:fail;B=200", []string{"A"}, 50, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := newHarness(t, nil)
+			resp, body := hs.get(t, "/stream?"+tt.query, tt.script)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.StatusCode, body)
+			}
+			var services []string
+			var summary struct {
+				Done        bool  `json:"done"`
+				FirstByteMs int64 `json:"firstByteMs"`
+				TotalMs     int64 `json:"totalMs"`
+				Dropped     int   `json:"dropped"`
+			}
+			sc := bufio.NewScanner(bytes.NewReader(body))
+			for sc.Scan() {
+				var line struct {
+					Service string `json:"service"`
+				}
+				if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+					t.Fatal(err)
+				}
+				if line.Service == "" {
+					if err := json.Unmarshal(sc.Bytes(), &summary); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				services = append(services, line.Service)
+			}
+			if !slices.Equal(services, tt.services) {
+				t.Fatalf("lines = %v, want %v", services, tt.services)
+			}
+			if !summary.Done || summary.FirstByteMs != tt.firstMs || summary.TotalMs != tt.totalMs || summary.Dropped != 0 {
+				t.Fatalf("summary = %+v, want first %dms, total %dms, nothing dropped", summary, tt.firstMs, tt.totalMs)
+			}
+		})
+	}
+
+	for _, query := range []string{"mode=bogus", "overflow=block"} {
+		t.Run(query, func(t *testing.T) {
+			hs := newHarness(t, nil)
+			if resp, body := hs.get(t, "/stream?"+query, ""); resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", resp.StatusCode, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxHedgeDelayMs bounds ?delayMs=.
+const maxHedgeDelayMs = 60000
+
+// AsyncHedged fans out like /async, but hedges Service B: if the call has not
+// answered after the hedge delay, a second one races it and the first success
+// wins, cancelling the other with cause hedge_lost. The delay is
+// HEDGE_DELAY_MS, or else Service B's p95; ?delayMs= overrides it. Hedges
+// spend the hedge budget and are suppressed once it is exhausted.
+func (h *Handlers) AsyncHedged(c *gin.Context) {
+	delay, ok := h.hedgeDelay()
+	if ms := queryInt(c, "delayMs", -1, 0, maxHedgeDelayMs); ms >= 0 {
+		delay, ok = time.Duration(ms)*time.Millisecond, true
+	}
+
+	h.serve(c, "async-hedged", func(ctx context.Context) outcome {
+		return h.fanOut(ctx, "async-hedged", func(ctx context.Context) (services.ServiceBData, error) {
+			return h.callHedgedB(ctx, delay, ok)
+		})
+	})
+}
+
+// hedgeDelay returns how long to wait on Service B before hedging. Without
+// HEDGE_DELAY_MS it is B's p95 over the stats window, and there is none until
+// B has succeeded in the window.
+func (h *Handlers) hedgeDelay() (time.Duration, bool) {
+	if ms := h.Settings.HedgeDelayMs; ms > 0 {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	b, ok := h.Deps.Snapshot().Endpoint("B")
+	if !ok || b.P95Ms == 0 {
+		return 0, false
+	}
+	return time.Duration(b.P95Ms) * time.Millisecond, true
+}
+
+// callHedgedB calls Service B and, unless hedge is false, calls it again once
+// delay has passed without an answer, budget permitting. The first success is
+// returned and the other call cancelled; if the primary fails before the
+// hedge fires, no hedge is sent and its error is returned.
+func (h *Handlers) callHedgedB(ctx context.Context, delay time.Duration, hedge bool) (services.ServiceBData, error) {
+	h.HedgeBudget.Primary()
+	tl := timeline.FromContext(ctx)
+	attrs := metric.WithAttributes(attribute.String("service", "B"))
+
+	hctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Each call is an attempt span of its own; the hedge links the primary.
+	attempt := func(name string, n int, follows trace.SpanContext) (<-chan safego.Result[services.ServiceBData], trace.SpanContext) {
+		actx, span := observability.StartAttemptSpan(hctx, "B", observability.AttemptHedge, n, follows)
+		return safego.Go(actx, name, func(ctx context.Context) (d services.ServiceBData, err error) {
+			defer func() { endAttempt(ctx, span, err) }()
+			// Recovered here too, for the span to end with the panic.
+			defer safego.Recover(ctx, name, &err)
+			return h.callServiceB(ctx)
+		}), span.SpanContext()
+	}
+
+	primary, primarySpan := attempt("async-hedged.B", 1, trace.SpanContext{})
+	var (
+		hedged  <-chan safego.Result[services.ServiceBData]
+		timer   <-chan time.Time
+		pending = 1
+		errs    error
+	)
+	if hedge {
+		timer = h.Clock.After(delay)
+	}
+
+	for {
+		var (
+			r       safego.Result[services.ServiceBData]
+			isHedge bool
+		)
+		select {
+		case <-timer:
+			timer = nil
+			if ctx.Err() != nil {
+				continue
+			}
+			if !h.HedgeBudget.TryHedge() {
+				h.M.HedgesSuppressed.Add(ctx, 1, attrs)
+				tl.Mark("hedge", "suppressed")
+				continue
+			}
+			h.M.HedgesFired.Add(ctx, 1, attrs)
+			tl.Mark("hedge", "fired after "+delay.String())
+			hedged, _ = attempt("async-hedged.B.hedge", 2, primarySpan)
+			pending++
+			continue
+		case r = <-primary:
+			primary = nil
+		case r = <-hedged:
+			hedged, isHedge = nil, true
+		}
+
+		if r.Err == nil {
+			if pending > 1 {
+				cancel(apperr.ErrHedgeLost)
+			}
+			if isHedge {
+				h.M.HedgesWon.Add(ctx, 1, attrs)
+				tl.Mark("hedge", "won")
+			}
+			return r.Val, nil
+		}
+		errs = errors.Join(errs, r.Err)
+		if pending--; pending == 0 {
+			return services.ServiceBData{}, errs
+		}
+	}
+}
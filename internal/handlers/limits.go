@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/fairq"
+	"go-routine-stress/internal/shed"
+	"go-routine-stress/internal/stats"
+)
+
+// Flow-control headers. RateLimit-* follow the IETF RateLimit header fields
+// draft; the X- headers expose the concurrency limiter directly.
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderConcurrencyLimit   = "X-Concurrency-Limit"
+	HeaderQueueDepth         = "X-Queue-Depth"
+
+	// HeaderTenant names the tenant of a local caller without an API key;
+	// otherwise the tenant is a hash of X-API-Key.
+	HeaderTenant = "X-Tenant"
+	HeaderAPIKey = "X-API-Key"
+
+	// HeaderPriority ranks a request (high, normal, low) in a shed queue, up
+	// to the ceiling of its API key.
+	HeaderPriority = "X-Priority"
+)
+
+// retryAfterSec is advertised on retryable failures so clients back off
+// instead of hammering a saturated server.
+const retryAfterSec = 1
+
+// setLimitHeaders reports the state of a concurrency limiter at admission time.
+func setLimitHeaders(c *gin.Context, limit, inUse, queued int) {
+	c.Header(HeaderRateLimitLimit, strconv.Itoa(limit))
+	c.Header(HeaderRateLimitRemaining, strconv.Itoa(max(limit-inUse, 0)))
+	c.Header(HeaderConcurrencyLimit, strconv.Itoa(limit))
+	c.Header(HeaderQueueDepth, strconv.Itoa(queued))
+}
+
+// semBHeaders reports the Service B semaphore used by /async-limited.
+func (h *Handlers) semBHeaders(c *gin.Context) {
+	inUse, limit := h.semBUsage()
+	setLimitHeaders(c, limit, inUse, h.Bulkheads.B.Waiting())
+}
+
+// semBUsage returns the held and total slots of whichever Service B
+// semaphore is in use.
+func (h *Handlers) semBUsage() (inUse, limit int) {
+	if h.FIFOB != nil {
+		return h.FIFOB.InUse(), h.FIFOB.Cap()
+	}
+	return h.Bulkheads.B.Usage()
+}
+
+// countAbandoned records a wait in an endpoint's queue given up after waited
+// because ctx ended, labelled with why it ended.
+func (h *Handlers) countAbandoned(ctx context.Context, queue, endpoint string, waited time.Duration) {
+	cause := apperr.ReasonOf(context.Cause(ctx))
+	switch {
+	case cause != "":
+	case apperr.ClientDisconnected(ctx):
+		cause = apperr.ErrClientDisconnect.Reason
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		cause = "deadline"
+	default:
+		cause = "canceled"
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("queue", queue),
+		attribute.String("endpoint", endpoint),
+		attribute.String("cause", cause),
+	)
+	h.M.AbandonedWaits.Add(ctx, 1, attrs)
+	h.M.AbandonedWaitDuration.Record(ctx, float64(waited.Milliseconds()), attrs)
+}
+
+// queued wraps err, a failure while waiting in queue, with where the request
+// stood. The estimate takes the queue's service rate to be its slots over the
+// mean latency of the work holding them, that of key in st. The state is also
+// kept on ctx, for when the request fails on its own context before err
+// reaches the response.
+func (h *Handlers) queued(ctx context.Context, queue string, slots int, st *stats.Aggregator, key string, ahead int, waited time.Duration, err error) error {
+	var est time.Duration
+	if es, ok := st.Snapshot().Endpoint(key); ok && slots > 0 {
+		est = time.Duration(float64(ahead+1) * es.MeanMs / float64(slots) * float64(time.Millisecond))
+	}
+	q := &apperr.Queued{Queue: queue, Position: ahead, Waited: waited, EstimatedWait: est, Err: err}
+	if p, ok := ctx.Value(queuedKey{}).(*atomic.Pointer[apperr.Queued]); ok {
+		p.Store(q)
+	}
+	return q
+}
+
+type queuedKey struct{}
+
+// withQueued gives queues waited on under ctx a place to leave their state.
+func withQueued(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queuedKey{}, new(atomic.Pointer[apperr.Queued]))
+}
+
+// queuedOf returns the queue state of a failed request: carried by err, or
+// left on ctx by a queue it gave up on. When the fan-in sees the context end
+// first, the wait may not have left its state yet and none is reported.
+func queuedOf(ctx context.Context, err error) (*apperr.Queued, bool) {
+	if q, ok := apperr.QueuedOf(err); ok {
+		return q, true
+	}
+	if p, ok := ctx.Value(queuedKey{}).(*atomic.Pointer[apperr.Queued]); ok {
+		if q := p.Load(); q != nil {
+			return q, true
+		}
+	}
+	return nil, false
+}
+
+// retryAfter is the Retry-After, in seconds, for a retryable failure: the
+// estimated queue wait when there is one, else retryAfterSec.
+func retryAfter(q *apperr.Queued) int {
+	if q != nil && q.EstimatedWait > 0 {
+		return max(int(math.Ceil(q.EstimatedWait.Seconds())), 1)
+	}
+	return retryAfterSec
+}
+
+// tenantOf returns the tenant a request is queued under: one configured in
+// TENANT_WEIGHTS, or the shared default and other buckets. An API key names
+// its tenant by its hash. X-Tenant is chosen by the client, so it is only
+// taken from callers without a key connecting over loopback, such as a load
+// tool next to the server: anyone else could claim the heaviest weight.
+// Unknown values never become tenants of their own.
+func (h *Handlers) tenantOf(c *gin.Context) string {
+	var id string
+	if key := c.GetHeader(HeaderAPIKey); key != "" {
+		id = fairq.KeyTenant(key)
+	} else if ip := net.ParseIP(c.RemoteIP()); ip != nil && ip.IsLoopback() {
+		id = c.GetHeader(HeaderTenant)
+	}
+	return h.FairB.Tenant(id)
+}
+
+// priorityOf returns the class a request queues in: its X-Priority, capped
+// at the PRIORITY_CEILINGS entry of its hashed API key, or at normal without
+// one. X-Tenant is self-declared, so it never raises a ceiling.
+func (h *Handlers) priorityOf(c *gin.Context) shed.Priority {
+	ceiling := shed.PriorityNormal
+	if key := c.GetHeader(HeaderAPIKey); key != "" {
+		if p, ok := h.PriorityCeilings[fairq.KeyTenant(key)]; ok {
+			ceiling = p
+		}
+	}
+	return max(shed.ParsePriority(c.GetHeader(HeaderPriority)), ceiling)
+}
+
+func itoa64(n int64) string { return strconv.FormatInt(n, 10) }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/fairq"
+	"go-routine-stress/internal/shed"
+)
+
+// FuzzHeaderOverrides feeds arbitrary X-Priority, X-Tenant and X-API-Key
+// headers: a request must land in a configured tenant or a shared bucket,
+// and only an API key with a ceiling may queue above normal.
+func FuzzHeaderOverrides(f *testing.F) {
+	const vip = "vip-key"
+	h := &Handlers{
+		FairB:            fairq.New(make(chan struct{}, 1), map[string]float64{"gold": 3, "silver": 1}),
+		PriorityCeilings: map[string]shed.Priority{fairq.KeyTenant(vip): shed.PriorityHigh},
+	}
+	f.Add("high", "", "")
+	f.Add("high", "gold", vip)
+	f.Add(" HIGH ", "other", "key-0123456789abcdef")
+	f.Add("low", "default", "")
+	f.Add("urgent", fairq.KeyTenant(vip), "")
+	f.Fuzz(func(t *testing.T, priority, tenant, key string) {
+		req := httptest.NewRequest(http.MethodGet, "/async-limited", nil)
+		req.Header.Set(HeaderPriority, priority)
+		req.Header.Set(HeaderTenant, tenant)
+		req.Header.Set(HeaderAPIKey, key)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = req
+
+		switch got := h.tenantOf(c); got {
+		case "gold", "silver", fairq.DefaultTenant, fairq.OtherTenant:
+		default:
+			t.Fatalf("tenant %q from X-Tenant %q, X-API-Key %q", got, tenant, key)
+		}
+		p := h.priorityOf(c)
+		if p < shed.PriorityHigh || p > shed.PriorityLow {
+			t.Fatalf("priority %d from X-Priority %q", p, priority)
+		}
+		// Header values are trimmed on the wire, as net/http would.
+		if p < shed.PriorityNormal && req.Header.Get(HeaderAPIKey) != vip {
+			t.Fatalf("priority %s for X-API-Key %q without a ceiling", p, key)
+		}
+	})
+}
+
+// TestTenantSpoofing checks that X-Tenant cannot claim another tenant's
+// weight: it is ignored next to an API key and from remote callers.
+func TestTenantSpoofing(t *testing.T) {
+	const key = "silver-key"
+	h := &Handlers{FairB: fairq.New(make(chan struct{}, 1), map[string]float64{"gold": 4, fairq.KeyTenant(key): 1})}
+	for _, tc := range []struct {
+		name, remote, tenant, key, want string
+	}{
+		{"remote spoofing", "203.0.113.7:4000", "gold", "", fairq.DefaultTenant},
+		{"key holder spoofing", "203.0.113.7:4000", "gold", key, fairq.KeyTenant(key)},
+		{"local key holder spoofing", "127.0.0.1:4000", "gold", key, fairq.KeyTenant(key)},
+		{"local load tool", "127.0.0.1:4000", "gold", "", "gold"},
+		{"unknown key", "203.0.113.7:4000", "", "other-key", fairq.OtherTenant},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/async-limited", nil)
+		req.RemoteAddr = tc.remote
+		req.Header.Set(HeaderTenant, tc.tenant)
+		req.Header.Set(HeaderAPIKey, tc.key)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = req
+		if got := h.tenantOf(c); got != tc.want {
+			t.Errorf("%s: tenant %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/concurrency"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/timeline"
+)
+
+// orderedCall is what one /ordered-fanin task hands back.
+type orderedCall struct {
+	completed int
+	latency   time.Duration
+}
+
+// OrderedFanIn makes ?n= calls (default 50) concurrently, ?bPct= percent of
+// them (default 50) to Service B spread among those to Service A, and
+// collects the results in submission order with concurrency.Ordered: at most
+// ?window= calls (default ORDERED_FANIN_WINDOW, 8) are started and not yet
+// collected, so a slow call holds back the ones after it instead of letting
+// their results pile up. The response lists the results in order with the
+// rank each completed in, how many completed before an earlier one and the
+// most results held at once. Like /fanout it tolerates failures and fails
+// only when every call failed.
+func (h *Handlers) OrderedFanIn(c *gin.Context) {
+	ctx, start := h.begin(c)
+	n := queryInt(c, "n", defaultFanoutWidth, 1, maxFanoutWidth)
+	bPct := queryInt(c, "bPct", 50, 0, 100)
+	window := queryInt(c, "window", h.Settings.OrderedFanInWindow, 1, maxFanoutWidth)
+
+	resp := models.OrderedFanInResponse{N: n, Window: window, Results: make([]models.OrderedResult, 0, n), Failures: map[string]int{}}
+	// Call i goes to B when it carries the running B share past a whole
+	// call, which spreads the B calls evenly among the A ones.
+	toB := func(i int) bool { return (i+1)*bPct/100 > i*bPct/100 }
+
+	var completed atomic.Int64
+	var firstErr error
+	latest := -1 // the latest completion rank among the results collected
+	// emit never fails, and window is at least 1: Ordered cannot either.
+	stats, _ := concurrency.Ordered(ctx, "ordered-fanin", n, window,
+		func(ctx context.Context, i int) (orderedCall, error) {
+			t0 := h.Clock.Now()
+			var err error
+			if toB(i) {
+				_, err = h.callServiceB(ctx)
+			} else {
+				_, err = h.callServiceA(ctx)
+			}
+			return orderedCall{completed: int(completed.Add(1) - 1), latency: h.Clock.Since(t0)}, err
+		},
+		func(i int, call orderedCall, err error) error {
+			r := models.OrderedResult{Index: i, Service: "A", Completed: call.completed, LatencyMs: call.latency.Milliseconds()}
+			if toB(i) {
+				r.Service = "B"
+				resp.CallsB++
+			} else {
+				resp.CallsA++
+			}
+			if call.completed < latest {
+				resp.Reordered++
+			}
+			latest = max(latest, call.completed)
+			if err != nil {
+				r.Error = string(apperr.CodeOf(err))
+				resp.Failed++
+				resp.Failures[r.Error]++
+				if firstErr == nil {
+					firstErr = err
+				}
+			} else {
+				resp.Succeeded++
+			}
+			resp.Results = append(resp.Results, r)
+			return nil
+		})
+	resp.MaxBuffered = stats.MaxBuffered
+	h.M.OrderedFanInBuffered.Record(ctx, int64(stats.MaxBuffered))
+
+	if ctx.Err() != nil {
+		h.respondErr(c, "ordered-fanin", start, http.StatusRequestTimeout, context.Cause(ctx))
+		return
+	}
+	if resp.Succeeded == 0 {
+		h.respondErr(c, "ordered-fanin", start, http.StatusServiceUnavailable,
+			apperr.New(apperr.CodeDependencyFailure, "", fmt.Errorf("%w, %d of %d: %w", errFanoutFailed, n, n, firstErr)))
+		return
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	resp.Debug = tl.Events()
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "ordered-fanin", http.StatusOK)
+}
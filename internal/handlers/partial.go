@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/timeline"
+)
+
+// Partial calls Service A and Service B concurrently like /async, but one
+// failing does not fail the request: it answers 207 with whichever service
+// succeeded and the other's error under errors, keyed by service. Both
+// succeeding is a 200; only both failing is a 503.
+func (h *Handlers) Partial(c *gin.Context) {
+	ctx, start := h.begin(c)
+
+	// Each call runs to its own end: neither failure cancels the other.
+	aCh := safego.Go(ctx, "partial.A", h.callServiceA)
+	bCh := safego.Go(ctx, "partial.B", h.callServiceB)
+	a, b := <-aCh, <-bCh
+
+	if ctx.Err() != nil {
+		h.respondErr(c, "partial", start, http.StatusRequestTimeout, context.Cause(ctx))
+		return
+	}
+	result, failed := "complete", "none"
+	switch {
+	case a.Err != nil && b.Err != nil:
+		result, failed = "none", "both"
+	case a.Err != nil:
+		result, failed = "partial", "A"
+	case b.Err != nil:
+		result, failed = "partial", "B"
+	}
+	h.M.PartialResults.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result), attribute.String("failed", failed)))
+	if result == "none" {
+		h.respondErr(c, "partial", start, http.StatusServiceUnavailable, apperr.New(apperr.CodeDependencyFailure, "", errors.Join(a.Err, b.Err)))
+		return
+	}
+
+	resp := models.CombinedResponse{ServiceAData: a.Val, ServiceBData: b.Val, Mode: "partial"}
+	status := http.StatusOK
+	if result == "partial" {
+		status = http.StatusMultiStatus
+		resp.Partial = true
+		resp.Errors = make(map[string]models.ErrorDetail, 1)
+		if a.Err != nil {
+			resp.Errors["A"] = errorDetail(a.Err)
+		}
+		if b.Err != nil {
+			resp.Errors["B"] = errorDetail(b.Err)
+		}
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	resp.Debug = tl.Events()
+	c.JSON(status, resp)
+	h.record(c, tl, "partial", status)
+}
@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"go-routine-stress/internal/connpool"
+	"go-routine-stress/internal/dns"
+	"go-routine-stress/internal/handlers"
+	"go-routine-stress/internal/httpphase"
+	"go-routine-stress/internal/services"
+)
+
+// spans records every span the tests end. The client spans come from a
+// tracer taken at init, which only follows the first provider set.
+var (
+	spans     = tracetest.NewSpanRecorder()
+	setTracer sync.Once
+)
+
+// TestCallPhases checks a Service B call behind simulated DNS and a lazy
+// connection pool records its dns, connect and ttfb phases, with the
+// scripted latencies, and that a second call reuses both: a cache hit taking
+// no time and no dial.
+func TestCallPhases(t *testing.T) {
+	setTracer.Do(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))) })
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(noop.NewMeterProvider()) })
+
+	hs := newHarness(t, func(o *handlers.Options) {
+		rng := services.NewSeededRNG(1)
+		o.DNS = dns.NewCache(dns.NewSim(o.Clock, rng, 20*time.Millisecond, 20*time.Millisecond), o.Clock, time.Minute, 0, o.Background)
+		o.Pools = map[string]*connpool.Pool{"B": connpool.New(o.Clock, rng, connpool.Options{
+			Size: 1, DialMin: 30 * time.Millisecond, DialMax: 30 * time.Millisecond,
+		})}
+	})
+	ended := len(spans.Ended())
+	for range 2 {
+		if resp, body := hs.get(t, "/sync", "A=40;B=60"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("/sync = %d %s", resp.StatusCode, body)
+		}
+	}
+
+	got := phaseDurations(t, reader)
+	for _, want := range []struct {
+		peer, phase string
+		count       uint64
+		sumMs       float64
+	}{
+		{"A", httpphase.TTFB, 2, 80},
+		{"B", httpphase.DNS, 2, 20},
+		{"B", httpphase.Connect, 1, 30},
+		{"B", httpphase.TTFB, 2, 120},
+	} {
+		dp, ok := got[[2]string{want.peer, want.phase}]
+		if !ok || dp.Count != want.count || dp.Sum != want.sumMs {
+			t.Errorf("%s %s phase: %d recorded, %vms in all; want %d, %vms", want.peer, want.phase, dp.Count, dp.Sum, want.count, want.sumMs)
+		}
+	}
+	if _, ok := got[[2]string{"B", httpphase.Body}]; ok {
+		t.Error("a simulated call recorded a body phase")
+	}
+
+	// The first B call span carries each phase as an event, in order.
+	for _, s := range spans.Ended()[ended:] {
+		if s.Name() != "B/Call" {
+			continue
+		}
+		var names []string
+		for _, e := range s.Events() {
+			names = append(names, e.Name)
+		}
+		if want := []string{httpphase.DNS, httpphase.Connect, httpphase.TTFB}; !slices.Equal(names, want) {
+			t.Errorf("B/Call span events %q, want %q", names, want)
+		}
+		return
+	}
+	t.Error("no B/Call span ended")
+}
+
+// phaseDurations returns downstream_phase_duration_ms by peer and phase.
+func phaseDurations(t *testing.T, r sdkmetric.Reader) map[[2]string]metricdata.HistogramDataPoint[float64] {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[[2]string]metricdata.HistogramDataPoint[float64])
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			h, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok || m.Name != "downstream_phase_duration_ms" {
+				continue
+			}
+			for _, dp := range h.DataPoints {
+				peer, _ := dp.Attributes.Value(attribute.Key("peer"))
+				phase, _ := dp.Attributes.Value(attribute.Key("phase"))
+				out[[2]string{peer.AsString(), phase.AsString()}] = dp
+			}
+		}
+	}
+	return out
+}
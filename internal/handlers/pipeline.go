@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxPipelineItems bounds ?items= so the Service A calls of one request stay bounded.
+const maxPipelineItems = 100
+
+// defaultPipelineItems is ?items= when the request leaves it out.
+const defaultPipelineItems = 10
+
+// maxPipelineBuffer bounds ?fetchBuffer= and ?transformBuffer=.
+const maxPipelineBuffer = 1000
+
+type pipelineItem struct {
+	index int
+	value string
+}
+
+// pipelineStage is one stage's view of a /pipeline run, written only by the
+// goroutine running the stage.
+type pipelineStage struct {
+	name                string
+	items               int
+	buffer              int
+	busy, wait, blocked time.Duration
+}
+
+// Pipeline runs ?items= items (default 10) through three stages, each its own
+// goroutine: fetch calls Service A once per item, transform spends
+// ?transformMs= on each, aggregate collects them. Stages hand items over
+// through channels of ?fetchBuffer= and ?transformBuffer= slots, so a stage
+// faster than the next fills the buffer in between and then blocks: the
+// response shows, per stage, the time spent working, waiting for input and
+// blocked on a full buffer. A failed fetch cancels the other stages with
+// cause sibling_failed.
+func (h *Handlers) Pipeline(c *gin.Context) {
+	ctx, start := h.begin(c)
+	items := queryInt(c, "items", defaultPipelineItems, 1, maxPipelineItems)
+	fetchBuf := queryInt(c, "fetchBuffer", h.Settings.PipelineFetchBuffer, 0, maxPipelineBuffer)
+	transformBuf := queryInt(c, "transformBuffer", h.Settings.PipelineTransformBuffer, 0, maxPipelineBuffer)
+	work := time.Duration(queryInt(c, "transformMs", h.Settings.PipelineTransformMs, 0, 60000)) * time.Millisecond
+
+	pctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	fetched := make(chan pipelineItem, fetchBuf)
+	transformed := make(chan pipelineItem, transformBuf)
+	fetch := &pipelineStage{name: "fetch"}
+	transform := &pipelineStage{name: "transform", buffer: fetchBuf}
+	aggregate := &pipelineStage{name: "aggregate", buffer: transformBuf}
+
+	// Buffered for both stages so neither blocks once the request is answered.
+	errs := make(chan error, 2)
+	go func() { errs <- h.pipelineFetch(pctx, cancel, fetch, items, fetched, transform.name) }()
+	go func() {
+		errs <- h.pipelineTransform(pctx, cancel, transform, work, fetched, transformed, aggregate.name)
+	}()
+
+	resp := models.PipelineResponse{Values: make([]string, 0, items)}
+	for {
+		item, ok := h.pipelineRecv(pctx, aggregate, transformed)
+		if !ok {
+			break
+		}
+		t0 := h.Clock.Now()
+		resp.Values = append(resp.Values, item.value)
+		h.pipelineDone(pctx, aggregate, t0)
+	}
+
+	// The stage that failed first wins over those it cancelled.
+	var err error
+	for range 2 {
+		if e := <-errs; e != nil && (err == nil || errors.Is(err, apperr.ErrSiblingFailed)) {
+			err = e
+		}
+	}
+	// Items still buffered after an aborted run leave the queue gauge.
+	for range fetched {
+		h.M.AddPipelineQueued(transform.name, -1)
+	}
+	for range transformed {
+		h.M.AddPipelineQueued(aggregate.name, -1)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		h.respondErr(c, "pipeline", start, http.StatusRequestTimeout, context.Cause(ctx))
+		return
+	case err != nil:
+		h.respondErr(c, "pipeline", start, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	tl := timeline.FromContext(ctx)
+	for _, st := range []*pipelineStage{fetch, transform, aggregate} {
+		stage := models.PipelineStage{
+			Name:      st.name,
+			Items:     st.items,
+			BusyMs:    st.busy.Milliseconds(),
+			WaitMs:    st.wait.Milliseconds(),
+			BlockedMs: st.blocked.Milliseconds(),
+		}
+		if st != fetch {
+			stage.Buffer = &st.buffer
+		}
+		resp.Stages = append(resp.Stages, stage)
+	}
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	resp.Debug = tl.Events()
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "pipeline", http.StatusOK)
+}
+
+// pipelineFetch calls Service A for each item and hands the results to next.
+func (h *Handlers) pipelineFetch(ctx context.Context, cancel context.CancelCauseFunc, st *pipelineStage, items int, out chan<- pipelineItem, next string) (err error) {
+	defer abortPipeline(ctx, cancel, &err)
+	defer safego.Recover(ctx, "pipeline."+st.name, &err)
+	defer close(out)
+
+	for i := range items {
+		t0 := h.Clock.Now()
+		a, callErr := h.callServiceA(ctx)
+		if callErr != nil {
+			return callErr
+		}
+		h.pipelineDone(ctx, st, t0)
+		if !h.pipelineSend(ctx, st, out, pipelineItem{index: i, value: a.Value}, next) {
+			return context.Cause(ctx)
+		}
+	}
+	return nil
+}
+
+// pipelineTransform spends work on each item from in and hands it to next.
+func (h *Handlers) pipelineTransform(ctx context.Context, cancel context.CancelCauseFunc, st *pipelineStage, work time.Duration, in <-chan pipelineItem, out chan<- pipelineItem, next string) (err error) {
+	defer abortPipeline(ctx, cancel, &err)
+	defer safego.Recover(ctx, "pipeline."+st.name, &err)
+	defer close(out)
+
+	for {
+		item, ok := h.pipelineRecv(ctx, st, in)
+		if !ok {
+			return context.Cause(ctx)
+		}
+		t0 := h.Clock.Now()
+		end := beginPhase(ctx, "transform")
+		if work > 0 {
+			select {
+			case <-h.Clock.After(work):
+			case <-ctx.Done():
+				end("cancelled")
+				return context.Cause(ctx)
+			}
+		}
+		item.value = fmt.Sprintf("%d:%s", item.index, item.value)
+		end("")
+		h.pipelineDone(ctx, st, t0)
+		if !h.pipelineSend(ctx, st, out, item, next) {
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// abortPipeline cancels the other stages when a stage fails on its own.
+func abortPipeline(ctx context.Context, cancel context.CancelCauseFunc, err *error) {
+	if *err != nil && ctx.Err() == nil {
+		cancel(apperr.ErrSiblingFailed)
+	}
+}
+
+// pipelineRecv takes the next item from in, counting the time waited for it.
+// It reports false once in is closed or ctx is done.
+func (h *Handlers) pipelineRecv(ctx context.Context, st *pipelineStage, in <-chan pipelineItem) (pipelineItem, bool) {
+	t0 := h.Clock.Now()
+	defer func() { st.wait += h.Clock.Since(t0) }()
+	select {
+	case item, ok := <-in:
+		if ok {
+			h.M.AddPipelineQueued(st.name, -1)
+		}
+		return item, ok
+	case <-ctx.Done():
+		return pipelineItem{}, false
+	}
+}
+
+// pipelineSend hands item to the next stage, counting the time blocked on a
+// full buffer. The item counts as queued for next while blocked, so the queue
+// depth gauge going past the buffer size shows backpressure on the sender.
+// It reports false if ctx is done first.
+func (h *Handlers) pipelineSend(ctx context.Context, st *pipelineStage, out chan<- pipelineItem, item pipelineItem, next string) bool {
+	t0 := h.Clock.Now()
+	defer func() { st.blocked += h.Clock.Since(t0) }()
+	h.M.AddPipelineQueued(next, 1)
+	select {
+	case out <- item:
+		return true
+	case <-ctx.Done():
+		h.M.AddPipelineQueued(next, -1)
+		return false
+	}
+}
+
+// pipelineDone records one item processed by st, from t0.
+func (h *Handlers) pipelineDone(ctx context.Context, st *pipelineStage, t0 time.Time) {
+	d := h.Clock.Since(t0)
+	st.items++
+	st.busy += d
+	h.M.PipelineStageDuration.Record(ctx, float64(d.Microseconds())/1000,
+		metric.WithAttributes(attribute.String("stage", st.name)),
+	)
+}
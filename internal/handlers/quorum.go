@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxQuorumReplicas bounds ?m= so one request cannot spawn unbounded goroutines.
+const maxQuorumReplicas = 16
+
+// defaultQuorumReplicas is ?m= when the request leaves it out.
+const defaultQuorumReplicas = 5
+
+// errQuorumUnreachable is returned once too many replicas failed for N to succeed.
+var errQuorumUnreachable = errors.New("quorum unreachable")
+
+type replicaReply struct {
+	replica string
+	b       services.ServiceBData
+	err     error
+}
+
+// quorumJob is the background job running each replica call of a quorum
+// whose outstanding replicas outlive the response.
+const quorumJob = "quorum.replica"
+
+// Quorum fans out to ?m= replicas of Service B (default 5) and answers as soon
+// as ?n= of them succeed (default a majority), cancelling the rest with cause
+// quorum_reached. Failures are tolerated while n can still be reached, and
+// listed in the response. With ?cancel=false the outstanding replicas instead run to
+// completion as background jobs, so quorum_duration_ms{kind="all"} shows what
+// waiting for every reply would have cost.
+func (h *Handlers) Quorum(c *gin.Context) {
+	ctx, start := h.begin(c)
+	m := queryInt(c, "m", defaultQuorumReplicas, 1, maxQuorumReplicas)
+	n := queryInt(c, "n", m/2+1, 1, m)
+	cancelLosers := c.Query("cancel") != "false"
+
+	// cancel stops the replicas still out. Those that may outlive the
+	// response run as quorumJob, whose policy gives them their context: only
+	// their cancellation is tied to stop, detached from the request.
+	stop, cancel := context.WithCancelCause(ctx)
+	if !cancelLosers {
+		stop, cancel = context.WithCancelCause(context.Background())
+	}
+
+	// Buffered for every replica so none blocks once nobody is listening.
+	replies := make(chan replicaReply, m)
+	for i := range m {
+		r := h.Svcs.Replica(i)
+		call := func(qctx context.Context) (err error) {
+			rep := replicaReply{replica: r.Name}
+			defer func() {
+				rep.err = err
+				replies <- rep
+			}()
+			defer safego.Recover(qctx, "quorum."+r.Name, &err)
+			rep.b, err = h.callB(qctx, "B/"+r.Name, r.Call)
+			return err
+		}
+		if cancelLosers {
+			go call(stop)
+			continue
+		}
+		h.Background.Go(ctx, quorumJob, h.Background.Policy(quorumJob), time.Duration(h.TimeoutMs)*time.Millisecond, func(jctx context.Context) error {
+			qctx, cancelQ := context.WithCancelCause(jctx)
+			defer cancelQ(nil)
+			defer context.AfterFunc(stop, func() { cancelQ(context.Cause(stop)) })()
+			return call(qctx)
+		})
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp := models.QuorumResponse{M: m, N: n, Failures: []models.QuorumFailure{}}
+	var errs error
+	for len(resp.Replies) < n {
+		select {
+		case rep := <-replies:
+			if rep.err != nil {
+				resp.Failed++
+				resp.Failures = append(resp.Failures, models.QuorumFailure{
+					Replica: rep.replica,
+					Code:    string(apperr.CodeOf(rep.err)),
+					Error:   rep.err.Error(),
+					Ms:      h.Clock.Since(start).Milliseconds(),
+				})
+				errs = errors.Join(errs, rep.err)
+				if resp.Failed > m-n {
+					cancel(apperr.ErrSiblingFailed)
+					h.recordQuorum(ctx, "quorum", "unreachable", start)
+					h.respondErr(c, "quorum", start, http.StatusServiceUnavailable,
+						apperr.New(apperr.CodeDependencyFailure, "", fmt.Errorf("%w: %d of %d replicas failed, need %d: %w", errQuorumUnreachable, resp.Failed, m, n, errs)))
+					return
+				}
+				continue
+			}
+			resp.Replies = append(resp.Replies, rep.b)
+			tl.Mark("quorum", fmt.Sprintf("%d/%d", len(resp.Replies), n))
+		case <-ctx.Done():
+			cancel(context.Cause(ctx))
+			h.respondErr(c, "quorum", start, http.StatusRequestTimeout, context.Cause(ctx))
+			return
+		}
+	}
+	h.recordQuorum(ctx, "quorum", "ok", start)
+
+	resp.Outstanding = m - len(resp.Replies) - resp.Failed
+	if cancelLosers {
+		cancel(apperr.ErrQuorumReached)
+	} else {
+		go h.awaitQuorumStragglers(context.WithoutCancel(ctx), replies, resp.Outstanding, start, cancel)
+	}
+
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	resp.Debug = tl.Events()
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "quorum", http.StatusOK)
+}
+
+// awaitQuorumStragglers collects the replies still outstanding after the
+// response went out and records how long the full fan-in took.
+func (h *Handlers) awaitQuorumStragglers(ctx context.Context, replies <-chan replicaReply, outstanding int, start time.Time, cancel context.CancelCauseFunc) {
+	defer cancel(nil)
+	outcome := "ok"
+	for range outstanding {
+		if rep := <-replies; rep.err != nil {
+			outcome = "error"
+		}
+	}
+	h.recordQuorum(ctx, "all", outcome, start)
+}
+
+func (h *Handlers) recordQuorum(ctx context.Context, kind, outcome string, start time.Time) {
+	h.M.QuorumDuration.Record(ctx, float64(h.Clock.Since(start).Milliseconds()), metric.WithAttributes(
+		attribute.String("kind", kind),
+		attribute.String("outcome", outcome),
+	))
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxRaceReplicas bounds ?replicas= so one request cannot spawn unbounded goroutines.
+const maxRaceReplicas = 16
+
+// defaultRaceReplicas is ?replicas= when the request leaves it out.
+const defaultRaceReplicas = 3
+
+// Race fans out like /async, but calls ?replicas= replicas of Service B at
+// once (default 3) and takes the first success, cancelling the rest with
+// cause race_won. The work the losers had done is counted as wasted.
+func (h *Handlers) Race(c *gin.Context) {
+	n := queryInt(c, "replicas", defaultRaceReplicas, 1, maxRaceReplicas)
+	h.serve(c, "race", func(ctx context.Context) outcome {
+		return h.fanOut(ctx, "race", func(ctx context.Context) (services.ServiceBData, error) {
+			return h.callRaceB(ctx, n)
+		})
+	})
+}
+
+// callRaceB calls n replicas concurrently and returns the first success, or
+// every failure once all have failed. Replicas cancelled because another won
+// count in race_wasted_calls_total and race_wasted_ms.
+func (h *Handlers) callRaceB(ctx context.Context, n int) (services.ServiceBData, error) {
+	rctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Buffered for every replica so none blocks once the race is decided.
+	replies := make(chan replicaReply, n)
+	for i := range n {
+		r := h.Svcs.Replica(i)
+		go func() {
+			rep := replicaReply{replica: r.Name}
+			defer func() { replies <- rep }()
+			defer safego.Recover(rctx, "race."+r.Name, &rep.err)
+			start := h.Clock.Now()
+			rep.b, rep.err = h.callB(rctx, "B/"+r.Name, r.Call)
+			if rep.err != nil && context.Cause(rctx) == apperr.ErrRaceWon {
+				attrs := metric.WithAttributes(attribute.String("replica", r.Name))
+				h.M.RaceWastedCalls.Add(rctx, 1, attrs)
+				h.M.RaceWastedDuration.Record(rctx, float64(h.Clock.Since(start).Milliseconds()), attrs)
+			}
+		}()
+	}
+
+	var errs error
+	for range n {
+		rep := <-replies
+		if rep.err == nil {
+			cancel(apperr.ErrRaceWon)
+			timeline.FromContext(ctx).Mark("race", "won by "+rep.replica)
+			return rep.b, nil
+		}
+		errs = errors.Join(errs, rep.err)
+	}
+	return services.ServiceBData{}, errs
+}
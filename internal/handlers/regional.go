@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+type regionReply struct {
+	region string
+	b      services.ServiceBData
+	err    error
+}
+
+// Regional fans out like /async, with Service B served local-first: the
+// replica of the nearest region in REGIONS is called, and a failure fails
+// over to the next nearest. With ?hedgeMs= (default REGION_HEDGE_MS, 0 = off)
+// the next region is also tried when the current one has not answered by
+// then; the first success wins and the others are cancelled with cause
+// hedge_lost. The request fails only once every region has failed.
+func (h *Handlers) Regional(c *gin.Context) {
+	hedge := time.Duration(queryInt(c, "hedgeMs", h.Settings.RegionHedgeMs, 0, 600000)) * time.Millisecond
+	h.serve(c, "regional", func(ctx context.Context) outcome {
+		return h.fanOut(ctx, "regional", func(ctx context.Context) (services.ServiceBData, error) {
+			return h.callRegionalB(ctx, hedge)
+		})
+	})
+}
+
+// callRegionalB calls the regions in order, one more on each failure or,
+// with hedge set, each time hedge passes without an answer.
+func (h *Handlers) callRegionalB(ctx context.Context, hedge time.Duration) (services.ServiceBData, error) {
+	rctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	tl := timeline.FromContext(ctx)
+
+	// Buffered for every region so none blocks once one has answered.
+	replies := make(chan regionReply, len(h.Regions))
+	var (
+		started, pending int
+		hedgeAt          <-chan time.Time
+		prev             trace.SpanContext // the last region attempt started
+	)
+	next := func() {
+		r := h.Regions[started]
+		started++
+		pending++
+		hedgeAt = nil
+		if hedge > 0 && started < len(h.Regions) {
+			hedgeAt = h.Clock.After(hedge)
+		}
+		actx, span := observability.StartAttemptSpan(rctx, "B", observability.AttemptFailover, started, prev)
+		span.SetAttributes(attribute.String("region", r.Region))
+		prev = span.SpanContext()
+		go func() {
+			rep := regionReply{region: r.Region}
+			defer func() { replies <- rep }()
+			defer func() { endAttempt(actx, span, rep.err) }()
+			defer safego.Recover(actx, "regional."+r.Name, &rep.err)
+			start := h.Clock.Now()
+			rep.b, rep.err = h.callB(actx, "B/"+r.Name, r.Call)
+			h.observeRegion(rctx, r.Region, h.Clock.Since(start), rep.err)
+		}()
+	}
+	failover := func(from, reason string) {
+		to := h.Regions[started].Region
+		h.M.RegionFailovers.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("from", from),
+			attribute.String("to", to),
+			attribute.String("reason", reason),
+		))
+		tl.Mark("regional", reason+": "+from+" → "+to)
+		next()
+	}
+
+	next()
+	var errs error
+	for pending > 0 {
+		select {
+		case rep := <-replies:
+			pending--
+			if rep.err == nil {
+				cancel(apperr.ErrHedgeLost)
+				return rep.b, nil
+			}
+			errs = errors.Join(errs, rep.err)
+			if ctx.Err() == nil && started < len(h.Regions) {
+				failover(rep.region, "error")
+			}
+		case <-hedgeAt:
+			failover(h.Regions[started-1].Region, "hedge")
+		}
+	}
+	return services.ServiceBData{}, errs
+}
+
+// observeRegion records one call to the replica in region.
+func (h *Handlers) observeRegion(ctx context.Context, region string, elapsed time.Duration, err error) {
+	outcome := "ok"
+	switch {
+	case err == nil:
+	case context.Cause(ctx) == apperr.ErrHedgeLost:
+		outcome = "hedge_lost"
+	case ctx.Err() != nil:
+		outcome = "canceled"
+	default:
+		outcome = "error"
+	}
+	attr := attribute.String("region", region)
+	h.M.RegionCalls.Add(ctx, 1, metric.WithAttributes(attr, attribute.String("outcome", outcome)))
+	if err == nil {
+		h.M.RegionCallDuration.Record(ctx, float64(elapsed.Milliseconds()), metric.WithAttributes(attr))
+	}
+}
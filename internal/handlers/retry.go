@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// RetryPolicy splits the time allowed for Service B into a per-attempt timeout
+// and an overall budget shared by all attempts. The zero value makes a single
+// attempt bounded only by the request context.
+type RetryPolicy struct {
+	TryTimeout time.Duration
+	Retries    int
+	Budget     time.Duration
+}
+
+func (p RetryPolicy) enabled() bool {
+	return p.TryTimeout > 0 || p.Retries > 0 || p.Budget > 0
+}
+
+// callServiceB calls Service B under the retry policy: each attempt gets at
+// most TryTimeout, and attempts stop once the overall Budget is spent.
+func (h *Handlers) callServiceB(ctx context.Context) (services.ServiceBData, error) {
+	p := h.RetryB
+	if !p.enabled() {
+		return h.callServiceBOnce(ctx)
+	}
+
+	budgetCtx := ctx
+	if p.Budget > 0 {
+		var cancel context.CancelFunc
+		budgetCtx, cancel = clock.WithTimeoutCause(ctx, h.Clock, p.Budget, apperr.ErrBudgetExhausted)
+		defer cancel()
+	}
+
+	var (
+		d    services.ServiceBData
+		err  error
+		prev trace.SpanContext
+	)
+	for attempt := 1; attempt <= p.Retries+1; attempt++ {
+		actx, span := observability.StartAttemptSpan(budgetCtx, "B", observability.AttemptRetry, attempt, prev)
+		d, err = h.tryServiceB(actx, attempt)
+		endAttempt(actx, span, err)
+		prev = span.SpanContext()
+		outcome := "ok"
+		switch {
+		case err == nil:
+		case errors.Is(err, apperr.ErrTryTimeout):
+			outcome = "try_timeout"
+		default:
+			outcome = "error"
+		}
+		h.M.RetryAttempts.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("service", "B"),
+			attribute.String("outcome", outcome),
+		))
+
+		if err == nil || !apperr.CodeOf(err).Retryable() || budgetCtx.Err() != nil {
+			break
+		}
+	}
+
+	if err != nil && ctx.Err() == nil && context.Cause(budgetCtx) == apperr.ErrBudgetExhausted {
+		h.M.RetryBudgetExhausted.Add(ctx, 1, metric.WithAttributes(attribute.String("service", "B")))
+	}
+	return d, err
+}
+
+// endAttempt ends the span of an attempt run under ctx with how it went: a
+// try timeout, cancelled (by what) once ctx ended, or its error.
+func endAttempt(ctx context.Context, span trace.Span, err error) {
+	outcome, cause := "ok", ""
+	switch {
+	case err == nil:
+	case errors.Is(err, apperr.ErrTryTimeout):
+		outcome = "try_timeout"
+	case ctx.Err() != nil:
+		outcome, cause = "cancelled", apperr.ReasonOf(context.Cause(ctx))
+		if cause == "" {
+			cause = "canceled"
+		}
+	default:
+		outcome = "error"
+	}
+	observability.EndAttemptSpan(span, err, outcome, cause)
+}
+
+// tryServiceB runs one attempt under the per-try timeout.
+func (h *Handlers) tryServiceB(ctx context.Context, attempt int) (services.ServiceBData, error) {
+	timeline.FromContext(ctx).Mark("attempt.B", strconv.Itoa(attempt))
+	if h.RetryB.TryTimeout <= 0 {
+		return h.callServiceBOnce(ctx)
+	}
+	tryCtx, cancel := clock.WithTimeoutCause(ctx, h.Clock, h.RetryB.TryTimeout, apperr.ErrTryTimeout)
+	defer cancel()
+	return h.callServiceBOnce(tryCtx)
+}
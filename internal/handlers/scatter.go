@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxScatterShards bounds ?shards= so one request cannot spawn unbounded
+// goroutines, while leaving room to compare gather strategies at high fan-out.
+const maxScatterShards = 256
+
+// defaultScatterShards is ?shards= when the request leaves it out.
+const defaultScatterShards = 4
+
+// errNoShards is returned when not one shard answered.
+var errNoShards = errors.New("no shard answered")
+
+type shardReply struct {
+	shard string
+	b     services.ServiceBData
+	err   error
+	// cause is why the shard's context ended, if it did.
+	cause error
+	ms    int64
+	// sent is when the reply was handed to the gather strategy.
+	sent time.Time
+}
+
+// Scatter queries ?shards= Service B shards at once (default 4) and answers
+// with whatever arrived, collected by the ?gather= strategy (default
+// SCATTER_GATHER). Each shard call is cut off after ?shardTimeoutMs=
+// (default SCATTER_SHARD_TIMEOUT_MS) with cause shard_timeout, and the whole
+// request after ASYNC_TIMEOUT_MS with cause handler_timeout, whichever comes
+// first for that shard. Timed-out and failed shards are listed apart, and the
+// response is partial unless every shard answered; only when none did does
+// the request fail.
+func (h *Handlers) Scatter(c *gin.Context) {
+	gather := Gather(h.Settings.ScatterGather)
+	if q := c.Query("gather"); q != "" {
+		g, err := ParseGather(q)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		gather = g
+	}
+	c.Header(HeaderScatterGather, string(gather))
+
+	ctx, start := h.begin(c)
+	n := queryInt(c, "shards", defaultScatterShards, 1, maxScatterShards)
+	shardTimeout := time.Duration(queryInt(c, "shardTimeoutMs", h.Settings.ScatterShardTimeoutMs, 1, 600000)) * time.Millisecond
+
+	sctx, cancel := clock.WithTimeoutCause(ctx, h.Clock, time.Duration(h.TimeoutMs)*time.Millisecond, apperr.ErrHandlerTimeout)
+	defer cancel()
+	ctxaudit.Expect(sctx, ctxaudit.KeyDeadline, ctxaudit.HasDeadline)
+
+	// Every shard ends by the deadline, so all replies are waited for.
+	sinks, next := gather.start(n)
+	for i := range n {
+		s, sink := h.Svcs.Shard(i), sinks[i]
+		go func() {
+			rep := shardReply{shard: s.Name}
+			defer func() {
+				rep.sent = h.Clock.Now()
+				sink <- rep
+			}()
+			defer safego.Recover(sctx, "scatter."+s.Name, &rep.err)
+			shardCtx, cancel := clock.WithTimeoutCause(sctx, h.Clock, shardTimeout, apperr.ErrShardTimeout)
+			defer cancel()
+			rep.b, rep.err = h.callB(shardCtx, "B/"+s.Name, s.Call)
+			rep.ms = h.Clock.Since(start).Milliseconds()
+			if shardCtx.Err() != nil {
+				rep.cause = context.Cause(shardCtx)
+			}
+		}()
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp := models.ScatterResponse{Shards: n, Gather: string(gather), Results: []models.ShardResult{}, TimedOut: []models.ShardMiss{}, Failed: []models.ShardMiss{}}
+	var errs error
+	strategy := metric.WithAttributes(attribute.String("strategy", string(gather)))
+	for range n {
+		rep := next()
+		h.M.ScatterGatherDelay.Record(ctx, float64(h.Clock.Since(rep.sent).Microseconds())/1000, strategy)
+		switch {
+		case rep.err == nil:
+			resp.Results = append(resp.Results, models.ShardResult{Shard: rep.shard, Value: rep.b.Value, Ms: rep.ms})
+		case rep.cause == apperr.ErrShardTimeout || rep.cause == apperr.ErrHandlerTimeout:
+			reason := apperr.ReasonOf(rep.cause)
+			resp.TimedOut = append(resp.TimedOut, models.ShardMiss{Shard: rep.shard, Cause: reason, Error: rep.err.Error(), Ms: rep.ms})
+			h.M.ScatterShardTimeouts.Add(ctx, 1, metric.WithAttributes(attribute.String("cause", reason)))
+			tl.Mark("scatter", rep.shard+" timed out: "+reason)
+			errs = errors.Join(errs, rep.err)
+		default:
+			resp.Failed = append(resp.Failed, models.ShardMiss{Shard: rep.shard, Code: string(apperr.CodeOf(rep.err)), Error: rep.err.Error(), Ms: rep.ms})
+			errs = errors.Join(errs, rep.err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		h.respondErr(c, "scatter", start, http.StatusRequestTimeout, context.Cause(ctx))
+		return
+	}
+	result := "complete"
+	switch {
+	case len(resp.Results) == 0:
+		result = "none"
+	case len(resp.Results) < n:
+		result = "partial"
+		resp.Partial = true
+	}
+	h.M.ScatterResults.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	if len(resp.Results) == 0 {
+		h.respondErr(c, "scatter", start, http.StatusServiceUnavailable,
+			apperr.New(apperr.CodeDependencyFailure, "", fmt.Errorf("%w of %d: %w", errNoShards, n, errs)))
+		return
+	}
+
+	resp.TotalMs = h.Clock.Since(start).Milliseconds()
+	resp.Debug = tl.Events()
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "scatter", http.StatusOK)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Overflow names what a /stream client's send buffer does when a result
+// arrives and the buffer is full because the client reads slower than
+// results come in.
+type Overflow string
+
+const (
+	// OverflowDropOldest discards the oldest line waiting to be sent, so
+	// the client always gets the latest results.
+	OverflowDropOldest Overflow = "drop-oldest"
+	// OverflowDropNewest discards the line that did not fit, so the client
+	// gets the results in order, with a gap at the end.
+	OverflowDropNewest Overflow = "drop-newest"
+	// OverflowDisconnect gives up on the client: the connection is closed
+	// and the calls still running are cancelled with cause slow_consumer.
+	OverflowDisconnect Overflow = "disconnect"
+)
+
+// ParseOverflow validates an overflow policy name.
+func ParseOverflow(s string) (Overflow, error) {
+	switch o := Overflow(s); o {
+	case OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q (want drop-oldest, drop-newest or disconnect)", s)
+}
+
+// sendBuffer holds the lines waiting for one client, at most size of them,
+// between the goroutine producing them and the one writing them out. A
+// producer never waits for the client: when the buffer is full, overflow
+// decides what gives.
+type sendBuffer[T any] struct {
+	size     int
+	overflow Overflow
+	// ready wakes the writer after a push or close.
+	ready chan struct{}
+
+	mu     sync.Mutex
+	items  []T
+	closed bool
+	broken bool
+}
+
+func newSendBuffer[T any](size int, overflow Overflow) *sendBuffer[T] {
+	return &sendBuffer[T]{size: size, overflow: overflow, ready: make(chan struct{}, 1)}
+}
+
+// push queues v. dropped reports that a line was discarded to stay within
+// size, v or an older one; ok is false once the client is to be
+// disconnected, after which nothing more is queued or sent.
+func (b *sendBuffer[T]) push(v T) (dropped, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.broken {
+		return false, false
+	}
+	defer b.wake()
+	if len(b.items) < b.size {
+		b.items = append(b.items, v)
+		return false, true
+	}
+	switch b.overflow {
+	case OverflowDropOldest:
+		clear(b.items[:1])
+		b.items = append(b.items[1:], v)
+	case OverflowDisconnect:
+		b.broken = true
+		b.items = nil
+		return true, false
+	}
+	return true, true
+}
+
+// close tells the writer no more lines are coming; it sends those queued.
+func (b *sendBuffer[T]) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.wake()
+}
+
+// pop returns the next line to send, waiting for one. ok is false once the
+// buffer is closed and empty, or the client is to be disconnected.
+func (b *sendBuffer[T]) pop() (v T, ok bool) {
+	for {
+		b.mu.Lock()
+		switch {
+		case b.broken:
+			b.mu.Unlock()
+			return v, false
+		case len(b.items) > 0:
+			v = b.items[0]
+			clear(b.items[:1])
+			b.items = b.items[1:]
+			b.mu.Unlock()
+			return v, true
+		case b.closed:
+			b.mu.Unlock()
+			return v, false
+		}
+		b.mu.Unlock()
+		<-b.ready
+	}
+}
+
+func (b *sendBuffer[T]) wake() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSendBuffer(t *testing.T) {
+	tests := []struct {
+		overflow Overflow
+		dropped  int
+		ok       bool
+		sent     []int
+	}{
+		{OverflowDropOldest, 2, true, []int{3, 4}},
+		{OverflowDropNewest, 2, true, []int{1, 2}},
+		{OverflowDisconnect, 1, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.overflow), func(t *testing.T) {
+			// Nothing is popped while pushing: the client is stuck.
+			b := newSendBuffer[int](2, tt.overflow)
+			dropped, ok := 0, true
+			for v := 1; v <= 4; v++ {
+				d, k := b.push(v)
+				if d {
+					dropped++
+				}
+				ok = ok && k
+			}
+			b.close()
+			var sent []int
+			for v, more := b.pop(); more; v, more = b.pop() {
+				sent = append(sent, v)
+			}
+			if dropped != tt.dropped || ok != tt.ok || !slices.Equal(sent, tt.sent) {
+				t.Fatalf("dropped %d, ok %v, sent %v; want %d, %v, %v", dropped, ok, sent, tt.dropped, tt.ok, tt.sent)
+			}
+		})
+	}
+}
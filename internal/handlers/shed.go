@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/shed"
+)
+
+// Guard applies the endpoint's admission controls before next: drain state,
+// then the token cost check, since it never waits, then the shed policy.
+func (h *Handlers) Guard(endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+	return h.guardDrain(endpoint, h.guardCost(endpoint, h.guardShed(endpoint, next)))
+}
+
+// guardShed applies the endpoint's shed policy.
+func (h *Handlers) guardShed(endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+	l, ok := h.Shed[endpoint]
+	if !ok {
+		return next
+	}
+
+	return func(c *gin.Context) {
+		start := h.Clock.Now()
+		ctx := c.Request.Context()
+
+		release, saturated, err := l.Acquire(ctx, h.priorityOf(c))
+		if err != nil && ctx.Err() != nil {
+			h.countAbandoned(ctx, "shed", endpoint, h.Clock.Since(start))
+		}
+		setLimitHeaders(c, l.Limit(), l.InUse(), l.Queued())
+		if saturated {
+			reportFrom(ctx).waited("shed", h.Clock.Since(start))
+			h.M.ShedRequests.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("endpoint", endpoint),
+				attribute.String("policy", string(l.Policy())),
+				attribute.String("outcome", shedOutcome(l.Policy(), err)),
+			))
+		}
+
+		if err == nil {
+			defer release()
+			next(c)
+			return
+		}
+
+		if errors.Is(err, shed.ErrOverloaded) || errors.Is(err, shed.ErrQueueTimeout) {
+			if l.Policy() == shed.PolicyDegrade && h.degrade(c, endpoint, start) {
+				return
+			}
+			// Aging keeps reordering the shed queue: everyone still waiting counts as ahead.
+			err = h.queued(ctx, "shed", l.Limit(), h.Agg, endpoint, l.Queued(), h.Clock.Since(start), apperr.New(apperr.CodeOverloaded, "", err))
+		}
+		h.respondErr(c, endpoint, start, http.StatusServiceUnavailable, err)
+	}
+}
+
+// degrade answers with a live Service A call and the cached Service B result,
+// skipping the expensive dependency. It reports false when there is nothing cached.
+func (h *Handlers) degrade(c *gin.Context, mode string, start time.Time) bool {
+	b, age, ok := h.FallbackB.Get()
+	if !ok {
+		return false
+	}
+
+	ctx, _ := h.begin(c)
+	h.M.FallbackServedAge.Record(ctx, float64(age.Milliseconds()), metric.WithAttributes(attribute.String("service", "B")))
+	reportFrom(ctx).fellBack("cache")
+	a, err := h.callServiceA(ctx)
+	if err != nil {
+		h.respondErr(c, mode, start, http.StatusRequestTimeout, err)
+		return true
+	}
+
+	h.respondOK(c, models.CombinedResponse{
+		ServiceAData: a,
+		ServiceBData: b,
+		Mode:         mode,
+		TotalMs:      h.Clock.Since(start).Milliseconds(),
+		Degraded:     true,
+	})
+	return true
+}
+
+// shedOutcome labels what happened to a request that found its endpoint saturated.
+func shedOutcome(p shed.Policy, err error) string {
+	switch {
+	case err == nil && p == shed.PolicyThrottle:
+		return "throttled"
+	case err == nil:
+		return "queued"
+	case errors.Is(err, shed.ErrQueueTimeout):
+		return "queue_timeout"
+	case p == shed.PolicyDegrade:
+		return "degraded"
+	case errors.Is(err, shed.ErrOverloaded):
+		return "rejected"
+	}
+	return "abandoned"
+}
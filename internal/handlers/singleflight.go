@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// AsyncSingleflight fans out like /async, but concurrent identical Service B
+// calls collapse into one: a call arriving while an identical one is in
+// flight waits for that call's result instead of making its own. Calls are
+// identical when their requests run under the same simulation script.
+func (h *Handlers) AsyncSingleflight(c *gin.Context) {
+	key := "B#" + c.GetHeader(services.ScriptHeader)
+	h.serve(c, "async-singleflight", func(ctx context.Context) outcome {
+		return h.fanOut(ctx, "async-singleflight", func(ctx context.Context) (services.ServiceBData, error) {
+			return h.callServiceBShared(ctx, key)
+		})
+	})
+}
+
+// singleflightJob is the background job making the shared Service B call.
+const singleflightJob = "singleflight.B"
+
+// callServiceBShared calls Service B through flightB. Unlike coalescing,
+// only calls overlapping one already in flight join it, so nothing waits for
+// a window to close. The shared call runs as singleflightJob, so under the
+// detached and drain policies it outlives any single caller's cancellation,
+// and only the caller that made it reports the call; a caller whose ctx ends
+// first gets its cause instead.
+func (h *Handlers) callServiceBShared(ctx context.Context, key string) (services.ServiceBData, error) {
+	// Set by the call when this caller made it; the send on ch orders it
+	// before the read.
+	var led bool
+	ch := h.flightB.DoChan(key, func() (v any, err error) {
+		led = true
+		done := make(chan struct{})
+		h.Background.Go(ctx, singleflightJob, h.Background.Policy(singleflightJob), 0, func(ctx context.Context) error {
+			defer close(done)
+			// singleflight re-panics on a goroutine of its own, out of reach
+			// of any recovery, so the panic is turned into an error before.
+			defer safego.Recover(ctx, "singleflight."+key, &err)
+			v, err = h.callServiceB(ctx)
+			return err
+		})
+		<-done
+		return v, err
+	})
+
+	select {
+	case res := <-ch:
+		result := "leader"
+		if !led {
+			result = "joined"
+			reportFrom(ctx).shared()
+			timeline.FromContext(ctx).Mark("singleflight", "joined the Service B call in flight")
+		}
+		h.M.SingleflightCalls.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+		if res.Err != nil {
+			return services.ServiceBData{}, res.Err
+		}
+		return res.Val.(services.ServiceBData), nil
+	case <-ctx.Done():
+		return services.ServiceBData{}, context.Cause(ctx)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// SlowWrite streams a large body in throttled chunks, like a server feeding a
+// slow client through a small socket buffer. The handler goroutine blocks on
+// each write, so a server WriteTimeout shorter than the whole transfer cuts
+// the response off mid-body.
+//
+// ?bytes= total size (default 1 MiB), ?chunk= bytes per write (default 4 KiB),
+// ?delayMs= pause between chunks (default 10).
+func (h *Handlers) SlowWrite(c *gin.Context) {
+	total := queryInt(c, "bytes", 1<<20, 1, 1<<30)
+	chunk := queryInt(c, "chunk", 4<<10, 1, 1<<20)
+	delay := time.Duration(queryInt(c, "delayMs", 10, 0, 60000)) * time.Millisecond
+
+	ctx := c.Request.Context()
+	start := h.Clock.Now()
+	written, err := h.writeThrottled(ctx, c.Writer, total, chunk, delay)
+
+	outcome := "complete"
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		outcome = "canceled"
+	default:
+		outcome = "write_error"
+	}
+	attrs := metric.WithAttributes(attribute.String("outcome", outcome))
+	h.M.SlowWriteBytes.Add(ctx, int64(written), attrs)
+	h.M.SlowWriteDuration.Record(ctx, float64(h.Clock.Since(start).Milliseconds()), attrs)
+}
+
+// writeThrottled writes total bytes in chunks, flushing each one and pausing
+// delay in between. It stops at the first write error or when ctx ends.
+func (h *Handlers) writeThrottled(ctx context.Context, w gin.ResponseWriter, total, chunk int, delay time.Duration) (int, error) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+
+	buf := bytes.Repeat([]byte{'x'}, chunk)
+	written := 0
+	for written < total {
+		n, err := w.Write(buf[:min(chunk, total-written)])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		w.Flush()
+
+		if written < total && delay > 0 {
+			select {
+			case <-h.Clock.After(delay):
+			case <-ctx.Done():
+				return written, context.Cause(ctx)
+			}
+		}
+	}
+	return written, nil
+}
+
+// queryInt reads an integer query parameter, falling back to def when it is
+// missing or outside [lo, hi].
+func queryInt(c *gin.Context, key string, def, lo, hi int) int {
+	n, err := strconv.Atoi(c.Query(key))
+	if err != nil || n < lo || n > hi {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// Smart fans out like /async, but routes Service B to the primary or the
+// fallback instance depending on their health scores.
+func (h *Handlers) Smart(c *gin.Context) { h.serve(c, "smart", h.execSmart) }
+
+func (h *Handlers) execSmart(ctx context.Context) outcome {
+	return h.fanOut(ctx, "smart", h.callSmartB)
+}
+
+// callSmartB calls the instance the router currently prefers and feeds the
+// result back into its score. Calls ended by the caller's own context say
+// nothing about the instance and are not scored.
+func (h *Handlers) callSmartB(ctx context.Context) (services.ServiceBData, error) {
+	r := h.SmartRouter
+	name := r.Pick()
+
+	start := h.Clock.Now()
+	d, err := h.callB(ctx, "B/"+name, h.Instances[name].Call)
+	if ctx.Err() != nil {
+		return d, err
+	}
+
+	if to, switched := r.Observe(name, h.Clock.Since(start), err); switched {
+		from := r.Primary
+		if to == r.Primary {
+			from = r.Fallback
+		}
+		h.M.SmartSwitches.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("from", from),
+			attribute.String("to", to),
+		))
+		timeline.FromContext(ctx).Mark("switch", to)
+		log.Printf("smart routing: %s -> %s (scores %v)", from, to, r.Scores())
+	}
+	return d, err
+}
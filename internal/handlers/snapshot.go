@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/diag"
+)
+
+// Snapshot captures a diagnostic bundle of the moment as a tar.gz download,
+// so an interesting point of a stress run can be analyzed offline:
+//
+//	goroutines.txt  every goroutine with its full stack (pprof debug=2)
+//	goroutines.json the dump grouped by stack, as /admin/goroutines
+//	heap.pb.gz      heap profile, for go tool pprof
+//	requests.json   requests in flight and the most recently finished ones
+//	config.json     startup settings, runtime tunables, enabled features
+//	stats.json      endpoint and dependency stats, the /status rollup and topology
+//	events.json     admin actions and experiment markers
+func (h *Handlers) Snapshot(c *gin.Context) {
+	at := h.Clock.Now()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: at}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	addJSON := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, data)
+	}
+	addProfile := func(name, profile string, debug int) error {
+		var p bytes.Buffer
+		if err := pprof.Lookup(profile).WriteTo(&p, debug); err != nil {
+			return err
+		}
+		return add(name, p.Bytes())
+	}
+
+	h.configMu.Lock()
+	tunables := h.adminConfigLocked()
+	h.configMu.Unlock()
+	settings := h.Settings.Redacted()
+
+	err := addProfile("goroutines.txt", "goroutine", 2)
+	if err == nil {
+		var dump diag.GoroutineDump
+		if dump, err = diag.Goroutines("", ""); err == nil {
+			err = addJSON("goroutines.json", dump)
+		}
+	}
+	if err == nil {
+		err = addProfile("heap.pb.gz", "heap", 0)
+	}
+	if err == nil {
+		err = addJSON("requests.json", gin.H{"inflight": h.Inflight.List(), "recent": h.Inflight.Recent()})
+	}
+	if err == nil {
+		err = addJSON("config.json", gin.H{"settings": settings, "runtime": tunables, "version": h.version()})
+	}
+	if err == nil {
+		err = addJSON("stats.json", gin.H{
+			"endpoints":    h.Agg.Snapshot(),
+			"dependencies": h.Calls.Snapshot(),
+			"status":       h.statusRollup(),
+			"topology":     h.topology(),
+		})
+	}
+	if err == nil {
+		err = addJSON("events.json", gin.H{"audit": h.Audit.List(), "markers": h.Markers.List()})
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.recordAdmin(c, "snapshot", "", nil, nil)
+	name := fmt.Sprintf("snapshot-%s.tar.gz", at.UTC().Format("20060102T150405Z"))
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
+
+// Variant names one implementation of the fan-out.
+type Variant string
+
+const (
+	// VariantChannels starts each call with safego.Go and fans in with a select.
+	VariantChannels Variant = "channels"
+	// VariantErrgroup runs both calls in an errgroup.Group and fans in with Wait.
+	VariantErrgroup Variant = "errgroup"
+)
+
+// HeaderVariant reports the fan-out implementation a response was built by.
+const HeaderVariant = "X-Variant"
+
+// Split assigns each fan-out request a variant at random, in proportion to
+// the variants' weights, so implementations can be compared under the same
+// mixed load. A nil Split always picks the default implementation and leaves
+// responses and metrics unlabelled.
+type Split struct {
+	variants []Variant
+	cum      []int // running weight totals, parallel to variants
+	rng      services.RNG
+}
+
+// Pick draws the variant for one request, or "" for a nil Split.
+func (s *Split) Pick(ctx context.Context) Variant {
+	if s == nil {
+		return ""
+	}
+	n := services.RNGFor(ctx, services.SaltSplit, s.rng).Intn(s.cum[len(s.cum)-1])
+	for i, c := range s.cum {
+		if n < c {
+			return s.variants[i]
+		}
+	}
+	return s.variants[len(s.variants)-1]
+}
+
+// ParseSplit parses "variant=weight" entries separated by ';', for a Split
+// drawing from rng. An empty spec returns a nil Split.
+func ParseSplit(s string, rng services.RNG) (*Split, error) {
+	split := &Split{rng: rng}
+	total := 0
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("split %q: want variant=weight", entry)
+		}
+		v := Variant(strings.TrimSpace(name))
+		switch v {
+		case VariantChannels, VariantErrgroup:
+		default:
+			return nil, fmt.Errorf("split %q: unknown variant %q", entry, v)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("split %q: weight must be a non-negative integer", entry)
+		}
+		total += w
+		split.variants = append(split.variants, v)
+		split.cum = append(split.cum, total)
+	}
+	if len(split.variants) == 0 {
+		return nil, nil
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("split %q: weights sum to zero", s)
+	}
+	return split, nil
+}
+
+// fanOutErrgroup is the errgroup variant of fanOut. Wait is the fan-in, so
+// under first_error the request returns once the cancelled sibling has
+// unwound rather than at the first failure, and a request deadline is seen
+// through the calls returning rather than by a select. Both calls always get
+// a goroutine of their own, whatever the spawn toggle says.
+func (h *Handlers) fanOutErrgroup(ctx context.Context, mode string, policy FanInPolicy, callA func(context.Context) (services.ServiceAData, error), callB func(context.Context) (services.ServiceBData, error)) outcome {
+	tl := timeline.FromContext(ctx)
+	callCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		g          errgroup.Group
+		a          services.ServiceAData
+		b          services.ServiceBData
+		errA, errB error
+	)
+
+	// run adapts a call to errgroup, with the same panic containment as
+	// safego.Go and the first_error cancellation of the channel fan-in.
+	run := func(name string, errp *error, call func(context.Context) error) func() error {
+		return func() (err error) {
+			defer func() {
+				*errp = err
+				tl.Mark("errgroup", name+" result")
+				if err != nil && policy == FanInFirstError {
+					cancel(apperr.ErrSiblingFailed)
+				}
+			}()
+			defer safego.Recover(callCtx, mode+"."+name, &err)
+			return call(callCtx)
+		}
+	}
+
+	g.Go(run("B", &errB, func(ctx context.Context) (err error) {
+		b, err = callB(ctx)
+		return err
+	}))
+	g.Go(run("A", &errA, func(ctx context.Context) (err error) {
+		a, err = callA(ctx)
+		return err
+	}))
+	first := g.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return outcome{status: http.StatusRequestTimeout, err: context.Cause(ctx)}
+	case first != nil && policy == FanInFirstError:
+		// Wait reports the first failure; the sibling's is only sibling_failed.
+		return outcome{status: http.StatusServiceUnavailable, err: first}
+	case errA != nil || errB != nil:
+		return outcome{status: http.StatusServiceUnavailable, err: errors.Join(errA, errB)}
+	}
+	return outcome{a: a, b: b}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/buildinfo"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/preflight"
+)
+
+// observeCall feeds Calls with one dependency call. Calls the caller
+// cancelled say nothing about the dependency and are skipped.
+func (h *Handlers) observeCall(name string, elapsed time.Duration, err error) {
+	status := http.StatusOK
+	switch {
+	case err == nil:
+	case apperr.CodeOf(err) == apperr.CodeCanceled:
+		return
+	case apperr.CodeOf(err) == apperr.CodeTimeout:
+		status = http.StatusGatewayTimeout
+	default:
+		status = http.StatusBadGateway
+	}
+	h.Calls.Observe(name, status, elapsed)
+}
+
+// Status returns a rollup of dependency health, saturation, brownout and
+// alerts, plus what binary is running.
+func (h *Handlers) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, h.statusRollup())
+}
+
+func (h *Handlers) statusRollup() models.StatusResponse {
+	now := h.Clock.Now()
+	resp := models.StatusResponse{
+		Status:       "ok",
+		At:           now,
+		UptimeSec:    now.Sub(h.Recommender.Started).Seconds(),
+		Build:        buildinfo.Get(),
+		Dependencies: h.dependencyStatus(),
+		Saturation:   h.saturation(),
+		FiringAlerts: []string{},
+		Preflight:    h.Preflight,
+	}
+	if h.Brownout != nil {
+		resp.BrownoutLevel = h.Brownout.Level()
+	}
+	for _, a := range h.Alerts.Firing() {
+		resp.FiringAlerts = append(resp.FiringAlerts, a.Rule)
+	}
+
+	saturated := false
+	for _, s := range resp.Saturation {
+		saturated = saturated || s.Utilization >= 1
+	}
+	switch {
+	case h.draining.Load():
+		resp.Status = "draining"
+	case len(resp.FiringAlerts) > 0 || resp.BrownoutLevel > 0 || saturated || len(preflight.Failed(h.Preflight)) > 0:
+		resp.Status = "degraded"
+	}
+	return resp
+}
+
+func (h *Handlers) dependencyStatus() []models.DependencyStatus {
+	scores := h.SmartRouter.Scores()
+	ejected := make(map[string]bool)
+	for _, st := range h.Balancer.States() {
+		ejected[st.Name] = st.Ejected
+	}
+
+	var out []models.DependencyStatus
+	for _, es := range h.Calls.Snapshot().Endpoints {
+		if es.Requests == 0 {
+			continue
+		}
+		ds := models.DependencyStatus{Name: es.Endpoint, Calls: es.Requests, ErrorRate: es.ErrorRate, P99Ms: es.P99Ms}
+		if instance, ok := strings.CutPrefix(es.Endpoint, "B/"); ok {
+			if score, ok := scores[instance]; ok {
+				ds.HealthScore = &score
+			}
+			if e, ok := ejected[instance]; ok {
+				ds.Ejected = &e
+			}
+		}
+		out = append(out, ds)
+	}
+	return out
+}
+
+func (h *Handlers) saturation() []models.Saturation {
+	aInUse, aLimit := h.Bulkheads.A.Usage()
+	semInUse, semLimit := h.semBUsage()
+	out := []models.Saturation{
+		newSaturation("semaphore.A", aInUse, aLimit, h.Bulkheads.A.Waiting()),
+		newSaturation("semaphore.B", semInUse, semLimit, h.Bulkheads.B.Waiting()),
+		newSaturation("cost.tokens", int(h.Budget.Capacity()-h.Budget.Available()), int(h.Budget.Capacity()), 0),
+	}
+
+	endpoints := make([]string, 0, len(h.Shed))
+	for ep := range h.Shed {
+		endpoints = append(endpoints, ep)
+	}
+	sort.Strings(endpoints)
+	for _, ep := range endpoints {
+		l := h.Shed[ep]
+		out = append(out, newSaturation("shed."+ep, l.InUse(), l.Limit(), l.Queued()))
+	}
+	return out
+}
+
+func newSaturation(name string, inUse, capacity, waiting int) models.Saturation {
+	s := models.Saturation{Name: name, InUse: int64(inUse), Capacity: int64(capacity), Waiting: int64(waiting)}
+	if capacity > 0 {
+		s.Utilization = float64(inUse) / float64(capacity)
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-routine-stress/internal/apperr"
+)
+
+// StatusMap overrides the HTTP status of failed requests, keyed by cancel
+// reason (handler_timeout) or error code (overloaded). A reason match wins
+// over a code match; failures matching neither keep the status their handler
+// chose.
+type StatusMap map[string]int
+
+// status returns the status to send for err, def if nothing overrides it.
+func (m StatusMap) status(err error, def int) int {
+	if s, ok := m[apperr.ReasonOf(err)]; ok {
+		return s
+	}
+	if s, ok := m[string(apperr.CodeOf(err))]; ok {
+		return s
+	}
+	return def
+}
+
+// ParseStatusMap parses "cause=status" entries separated by ';', e.g.
+// "handler_timeout=504;overloaded=429".
+func ParseStatusMap(s string) (StatusMap, error) {
+	out := make(StatusMap)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cause, status, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("status mapping %q: want cause=status", entry)
+		}
+		cause = strings.TrimSpace(cause)
+		if !apperr.Known(cause) {
+			return nil, fmt.Errorf("status mapping %q: unknown cause %q, want an error code or cancel reason", entry, cause)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(status))
+		if err != nil || n < 400 || n > 599 {
+			return nil, fmt.Errorf("status mapping %q: status must be 400-599", entry)
+		}
+		out[cause] = n
+	}
+	return out, nil
+}
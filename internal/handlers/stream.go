@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/timeline"
+)
+
+// maxStreamBuffer caps ?buffer=.
+const maxStreamBuffer = 1024
+
+// Stream calls Service A and Service B the way ?mode= says (default async)
+// and writes each result as an NDJSON line the moment it arrives, flushing
+// after every line, then a summary line with the time to the first line and
+// to the last. Modes:
+//
+//	sync           A, then B once A succeeded
+//	async          both at once
+//	async-timeout  both at once, within ASYNC_TIMEOUT_MS
+//	async-limited  both at once, each through its service's bulkhead
+//
+// The status line goes out with the first result, so it is 200 even when a
+// call fails: failures are reported in their line and counted in the summary.
+//
+// Lines reach the client through a buffer of ?buffer= lines (default
+// STREAM_BUFFER), written out by a goroutine of their own, so a client that
+// reads slowly never holds up the calls. When it fills, ?overflow= (default
+// STREAM_OVERFLOW) drops the oldest line, drops the newest or disconnects
+// the client; the summary still goes out after a drop, with the count.
+func (h *Handlers) Stream(c *gin.Context) {
+	ctx, start := h.begin(c)
+	mode := c.DefaultQuery("mode", "async")
+	size := queryInt(c, "buffer", h.Settings.StreamBuffer, 1, maxStreamBuffer)
+	overflow := Overflow(h.Settings.StreamOverflow)
+	if q := c.Query("overflow"); q != "" {
+		var err error
+		if overflow, err = ParseOverflow(q); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	callA, callB := h.callServiceA, h.callServiceB
+	switch mode {
+	case "sync", "async":
+	case "async-timeout":
+		var cancel context.CancelFunc
+		ctx, cancel = clock.WithTimeoutCause(ctx, h.Clock, time.Duration(h.TimeoutMs)*time.Millisecond, apperr.ErrHandlerTimeout)
+		defer cancel()
+		ctxaudit.Expect(ctx, ctxaudit.KeyDeadline, ctxaudit.HasDeadline)
+	case "async-limited":
+		callA, callB = h.callServiceALimited, h.callServiceBLimited
+	default:
+		c.String(http.StatusBadRequest, fmt.Sprintf("stream mode %q: want sync, async, async-timeout or async-limited", mode))
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("mode", mode))
+	dropAttrs := metric.WithAttributes(attribute.String("mode", mode), attribute.String("overflow", string(overflow)))
+	tl := timeline.FromContext(ctx)
+
+	summary := models.StreamSummary{Done: true, Mode: mode}
+	enc := json.NewEncoder(c.Writer)
+	var (
+		wrote    bool
+		writeErr error
+	)
+	write := func(v any) {
+		if writeErr != nil {
+			// The client is gone; the calls still finish or see ctx end.
+			return
+		}
+		if !wrote {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		if writeErr = enc.Encode(v); writeErr != nil {
+			return
+		}
+		c.Writer.Flush()
+		if !wrote {
+			wrote = true
+			summary.FirstByteMs = h.Clock.Since(start).Milliseconds()
+			h.M.StreamFirstByte.Record(ctx, float64(summary.FirstByteMs), attrs)
+		}
+	}
+	buf := newSendBuffer[models.StreamLine](size, overflow)
+	writer := safego.Go(ctx, "stream.write", func(context.Context) (struct{}, error) {
+		for {
+			line, ok := buf.pop()
+			if !ok {
+				return struct{}{}, nil
+			}
+			write(line)
+		}
+	})
+	disconnected := false
+	emit := func(service string, data any, err error) {
+		line := models.StreamLine{Service: service, ElapsedMs: h.Clock.Since(start).Milliseconds()}
+		if err != nil {
+			detail := errorDetail(err)
+			line.Error = &detail
+			summary.Failed++
+		} else {
+			line.Data = data
+			summary.Succeeded++
+		}
+		dropped, ok := buf.push(line)
+		if dropped {
+			summary.Dropped++
+			h.M.StreamDropped.Add(ctx, 1, dropAttrs)
+		}
+		switch {
+		case ok:
+			tl.Mark("stream", fmt.Sprintf("%s queued, %s", service, errDetail(err)))
+		case !disconnected:
+			disconnected = true
+			tl.Mark("stream", service+" overflowed the buffer, disconnecting")
+			cancel(apperr.ErrSlowConsumer)
+			// A write blocked on the client fails at once, and net/http
+			// closes the connection. The socket runs on the wall clock.
+			_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now())
+		}
+	}
+
+	if mode == "sync" {
+		a, err := callA(ctx)
+		emit("A", a, err)
+		if err == nil {
+			b, err := callB(ctx)
+			emit("B", b, err)
+		}
+	} else {
+		chA := safego.Go(ctx, "stream.A", callA)
+		chB := safego.Go(ctx, "stream.B", callB)
+		for chA != nil || chB != nil {
+			select {
+			case res := <-chA:
+				emit("A", res.Val, res.Err)
+				chA = nil
+			case res := <-chB:
+				emit("B", res.Val, res.Err)
+				chB = nil
+			}
+		}
+	}
+
+	buf.close()
+	<-writer
+	if disconnected {
+		c.Set(apperr.ReasonKey, apperr.ErrSlowConsumer.Reason)
+		h.M.StreamDuration.Record(ctx, float64(h.Clock.Since(start).Milliseconds()), attrs)
+		h.record(c, tl, "stream", http.StatusOK)
+		return
+	}
+	total := h.Clock.Since(start)
+	summary.TotalMs = total.Milliseconds()
+	summary.Debug = tl.Events()
+	write(summary)
+	h.M.StreamDuration.Record(ctx, float64(total.Milliseconds()), attrs)
+	h.record(c, tl, "stream", http.StatusOK)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/timeline"
+)
+
+// SyncBudget calls Service A, then Service B, like /sync, but splits the
+// ASYNC_TIMEOUT_MS deadline between them instead of letting A eat all of it:
+// A is allocated ?aPct= percent of it (default SYNC_BUDGET_A_PCT, 20) and cut
+// off past that with cause call_budget, and B gets whatever remains, so at
+// least the other 80%. The response reports the budget allocated to each
+// call and the time it consumed; a failed request does too, in
+// error.budgets.
+func (h *Handlers) SyncBudget(c *gin.Context) {
+	ctx, start := h.begin(c)
+	aPct := queryInt(c, "aPct", h.Settings.SyncBudgetAPct, 1, 99)
+
+	sctx, cancel := clock.WithTimeoutCause(ctx, h.Clock, time.Duration(h.TimeoutMs)*time.Millisecond, apperr.ErrHandlerTimeout)
+	defer cancel()
+	ctxaudit.Expect(sctx, ctxaudit.KeyDeadline, ctxaudit.HasDeadline)
+	deadline, _ := sctx.Deadline()
+	remaining := func() time.Duration { return max(deadline.Sub(h.Clock.Now()), 0) }
+
+	total := remaining()
+	var calls []apperr.CallBudget
+	fail := func(cctx context.Context, err error) {
+		status := http.StatusServiceUnavailable
+		if cctx.Err() != nil {
+			status = http.StatusRequestTimeout
+		}
+		h.respondErr(c, "sync-budget", start, status, &apperr.Budgets{Calls: calls, Err: err})
+	}
+
+	budgetA := total * time.Duration(aPct) / 100
+	actx, cancelA := clock.WithTimeoutCause(sctx, h.Clock, budgetA, apperr.ErrCallBudget)
+	t0 := h.Clock.Now()
+	a, err := h.callServiceA(actx)
+	cancelA()
+	calls = h.spend(ctx, calls, "A", budgetA, t0)
+	if err != nil {
+		fail(actx, err)
+		return
+	}
+
+	// B's share is the rest of the deadline, A's unused time included.
+	budgetB := remaining()
+	t0 = h.Clock.Now()
+	b, err := h.callServiceB(sctx)
+	calls = h.spend(ctx, calls, "B", budgetB, t0)
+	if err != nil {
+		fail(sctx, err)
+		return
+	}
+
+	tl := timeline.FromContext(ctx)
+	resp := models.SyncBudgetResponse{
+		ServiceAData: a,
+		ServiceBData: b,
+		DeadlineMs:   total.Milliseconds(),
+		APct:         aPct,
+		Budgets:      callBudgets(calls),
+		TotalMs:      h.Clock.Since(start).Milliseconds(),
+		Debug:        tl.Events(),
+	}
+	c.JSON(http.StatusOK, resp)
+	h.record(c, tl, "sync-budget", http.StatusOK)
+}
+
+// spend adds a call started at t0 with budget allocated to calls, and
+// records the share of it the call used.
+func (h *Handlers) spend(ctx context.Context, calls []apperr.CallBudget, service string, budget time.Duration, t0 time.Time) []apperr.CallBudget {
+	used := h.Clock.Since(t0)
+	if budget > 0 {
+		h.M.SyncBudgetUsed.Record(ctx, float64(used)/float64(budget),
+			metric.WithAttributes(attribute.String("service", service)),
+		)
+	}
+	timeline.FromContext(ctx).Mark("budget", fmt.Sprintf("%s used %v of %v", service, used.Round(time.Millisecond), budget.Round(time.Millisecond)))
+	return append(calls, apperr.CallBudget{Service: service, Allocated: budget, Consumed: used})
+}
+
+func callBudgets(calls []apperr.CallBudget) []models.CallBudget {
+	out := make([]models.CallBudget, len(calls))
+	for i, cb := range calls {
+		out[i] = models.CallBudget{Service: cb.Service, AllocatedMs: cb.Allocated.Milliseconds(), ConsumedMs: cb.Consumed.Milliseconds()}
+	}
+	return out
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/timeline"
+)
+
+// Timeline renders the recorded timeline of a debug-mode request as an HTML/SVG
+// Gantt chart, or as JSON with ?format=json.
+func (h *Handlers) Timeline(c *gin.Context) {
+	rec, ok := h.Timelines.Get(c.Param("requestID"))
+	if !ok {
+		c.String(http.StatusNotFound, "timeline not found (only ?debug=true requests are recorded)")
+		return
+	}
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, rec)
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = timeline.RenderHTML(c.Writer, rec)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/markers"
+	"go-routine-stress/internal/middleware"
+	"go-routine-stress/internal/toggles"
+)
+
+// ListToggles returns the runtime toggles with their current and allowed values.
+func (h *Handlers) ListToggles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"toggles": h.Toggles.List()})
+}
+
+// SetToggles flips runtime toggles given as query parameters, e.g.
+// ?tracing=off&json=streamed, all or nothing. Every flip is audited and set
+// as an experiment marker, so graphs show where behavior changed.
+func (h *Handlers) SetToggles(c *gin.Context) {
+	q := c.Request.URL.Query()
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		c.String(http.StatusBadRequest, "give at least one toggle as name=value")
+		return
+	}
+	slices.Sort(names)
+
+	pairs := make([][2]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, [2]string{name, q.Get(name)})
+	}
+	h.configMu.Lock()
+	changes, err := h.applyTogglesLocked(c, pairs)
+	if len(changes) > 0 {
+		h.configVersion++
+	}
+	h.configMu.Unlock()
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": changes, "toggles": h.Toggles.List()})
+}
+
+// applyTogglesLocked applies pairs, audits each flip and sets it as an
+// experiment marker; h.configMu must be held.
+func (h *Handlers) applyTogglesLocked(c *gin.Context, pairs [][2]string) ([]toggles.Change, error) {
+	changes, err := h.Toggles.Apply(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := "anonymous"
+	if p, ok := middleware.GetPrincipal(c); ok {
+		actor = p.Name
+	}
+	for _, ch := range changes {
+		h.recordAdmin(c, "set_toggle", ch.Name, ch.Old, ch.New)
+		name := "toggle." + ch.Name
+		h.Markers.Add(c.Request.Context(), markers.Marker{Name: name, Description: fmt.Sprintf("%s: %s -> %s", ch.Name, ch.Old, ch.New), Actor: actor})
+		h.M.Markers.Add(c.Request.Context(), 1, metric.WithAttributes(attribute.String("name", name)))
+	}
+	return changes, nil
+}
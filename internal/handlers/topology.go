@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/models"
+	"go-routine-stress/internal/services"
+)
+
+// topologyServer is the ID of the node standing for this server.
+const topologyServer = "server"
+
+// maxTopologyChainNodes bounds how many chain nodes /topology lists one by
+// one; larger chains are drawn one node per level.
+const maxTopologyChainNodes = 64
+
+// Endpoints making each kind of dependency call, as the handlers do.
+var (
+	topologyModesA         = []string{"sync", "sync-budget", "async", "async-limited", "async-timeout", "async-hedged", "async-fallback", "async-singleflight", "async-breaker", "partial", "smart", "balanced", "sequential-dependency", "pipelined-dependency", "pool", "race", "regional", "pipeline", "fanout", "ordered-fanin", "stream"}
+	topologyModesB         = []string{"sync", "sync-budget", "async", "async-limited", "async-timeout", "async-hedged", "async-fallback", "async-singleflight", "async-breaker", "partial", "sequential-dependency", "pipelined-dependency", "pool", "fanout", "ordered-fanin", "stream"}
+	topologyModesInstances = []string{"smart", "balanced"}
+	topologyModesReplicas  = []string{"quorum", "race"}
+	topologyModesShards    = []string{"scatter"}
+	topologyModesRegions   = []string{"regional"}
+)
+
+// Topology describes the dependency graph the server is configured with:
+// services, instances, quorum replicas, scatter shards, region replicas and
+// the downstream chain, with the limits applied along the way. ?format=dot renders it for Graphviz.
+func (h *Handlers) Topology(c *gin.Context) {
+	t := h.topology()
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, t)
+	case "dot":
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(topologyDOT(t)))
+	default:
+		c.String(http.StatusBadRequest, "format must be json or dot")
+	}
+}
+
+func (h *Handlers) topology() models.TopologyResponse {
+	t := models.TopologyResponse{
+		Nodes: []models.TopologyNode{
+			{ID: topologyServer, Kind: "server", Limits: h.serverLimits()},
+			{ID: "A", Kind: "service", Profile: services.DefaultAProfile.String()},
+			{ID: "B", Kind: "service", Profile: services.DefaultBProfile.String()},
+		},
+		Edges: []models.TopologyEdge{
+			{From: topologyServer, To: "A", Modes: topologyModesA, Limits: h.aLimits()},
+			{From: topologyServer, To: "B", Modes: topologyModesB, Limits: h.bLimits()},
+		},
+	}
+	parents := []string{"B"}
+
+	for _, st := range h.Balancer.States() {
+		id := "B/" + st.Name
+		t.Nodes = append(t.Nodes, models.TopologyNode{ID: id, Kind: "instance", Profile: st.Profile})
+		t.Edges = append(t.Edges, models.TopologyEdge{From: topologyServer, To: id, Modes: topologyModesInstances, Limits: h.targetLimits(id)})
+		parents = append(parents, id)
+	}
+
+	// Quorum replicas are created per request, ?m= of them.
+	t.Nodes = append(t.Nodes, models.TopologyNode{ID: "B/replica-*", Kind: "replica", Profile: services.DefaultBProfile.String(), Count: defaultQuorumReplicas})
+	replicaLimits := append([]models.TopologyLimit{{Name: "replicas", Max: maxQuorumReplicas, Detail: fmt.Sprintf("per request: quorum ?m= (default %d), race ?replicas= (default %d)", defaultQuorumReplicas, defaultRaceReplicas)}}, h.targetLimits("")...)
+	t.Edges = append(t.Edges, models.TopologyEdge{From: topologyServer, To: "B/replica-*", Modes: topologyModesReplicas, Limits: replicaLimits})
+	parents = append(parents, "B/replica-*")
+
+	// Scatter shards too, ?shards= of them, each call under its own timeout.
+	t.Nodes = append(t.Nodes, models.TopologyNode{ID: "B/shard-*", Kind: "shard", Profile: services.DefaultBProfile.String(), Count: defaultScatterShards})
+	shardLimits := append([]models.TopologyLimit{
+		{Name: "shards", Max: maxScatterShards, Detail: fmt.Sprintf("per request: ?shards= (default %d)", defaultScatterShards)},
+		{Name: "shard_timeout", Ms: int64(h.Settings.ScatterShardTimeoutMs), Detail: "per shard call; ?shardTimeoutMs= overrides"},
+	}, h.targetLimits("")...)
+	t.Edges = append(t.Edges, models.TopologyEdge{From: topologyServer, To: "B/shard-*", Modes: topologyModesShards, Limits: shardLimits})
+	parents = append(parents, "B/shard-*")
+
+	// Region replicas, nearest first.
+	for _, r := range h.Regions {
+		id := "B/" + r.Name
+		t.Nodes = append(t.Nodes, models.TopologyNode{ID: id, Kind: "region", Profile: r.Profile.String()})
+		t.Edges = append(t.Edges, models.TopologyEdge{From: topologyServer, To: id, Modes: topologyModesRegions, Limits: h.targetLimits(id)})
+		parents = append(parents, id)
+	}
+
+	if chain := h.Svcs.Chain(); chain.Depth > 0 {
+		addChain(&t, chain, parents)
+	}
+	return t
+}
+
+// serverLimits lists the limits applied to requests as they come in.
+func (h *Handlers) serverLimits() []models.TopologyLimit {
+	var out []models.TopologyLimit
+	endpoints := make([]string, 0, len(h.Shed))
+	for ep := range h.Shed {
+		endpoints = append(endpoints, ep)
+	}
+	sort.Strings(endpoints)
+	for _, ep := range endpoints {
+		l := h.Shed[ep]
+		out = append(out, models.TopologyLimit{Name: "shed." + ep, Max: int64(l.Limit()), Detail: string(l.Policy())})
+	}
+	if len(h.Costs) > 0 {
+		out = append(out, models.TopologyLimit{Name: "cost.tokens", Max: h.Budget.Capacity()})
+	}
+	out = append(out, models.TopologyLimit{Name: "worker_pool", Max: int64(h.Workers.Size()), Detail: "pool"})
+	return append(out, models.TopologyLimit{Name: "timeout", Ms: int64(h.TimeoutMs), Detail: "async-timeout"})
+}
+
+// aLimits lists the limits on Service A calls.
+func (h *Handlers) aLimits() []models.TopologyLimit {
+	_, slots := h.Bulkheads.A.Usage()
+	return []models.TopologyLimit{{Name: "semaphore", Max: int64(slots), Detail: "channel, async-limited"}}
+}
+
+// bLimits lists the limits on plain Service B calls.
+func (h *Handlers) bLimits() []models.TopologyLimit {
+	_, slots := h.semBUsage()
+	out := []models.TopologyLimit{{Name: "semaphore", Max: int64(slots), Detail: h.semImplB() + ", async-limited"}}
+	if p := h.RetryB; p.enabled() {
+		l := models.TopologyLimit{Name: "retries", Max: int64(p.Retries), Ms: p.TryTimeout.Milliseconds()}
+		if p.Budget > 0 {
+			l.Detail = fmt.Sprintf("budget %dms", p.Budget.Milliseconds())
+		}
+		out = append(out, l)
+	}
+	hb := h.HedgeBudget.Stats()
+	out = append(out, models.TopologyLimit{Name: "breaker", Max: int64(h.Settings.BreakerFailures), Ms: int64(h.Settings.BreakerOpenMs), Detail: "failures in a row to open, open time; async-breaker"})
+	out = append(out, models.TopologyLimit{Name: "hedge_budget", Max: int64(hb.Burst), Detail: fmt.Sprintf("%d%% of primaries, async-hedged", hb.Percent)})
+	return append(out, h.targetLimits("B")...)
+}
+
+// targetLimits lists what every call to the Service B target host goes
+// through before reaching it: name resolution and its connection pool.
+func (h *Handlers) targetLimits(host string) []models.TopologyLimit {
+	var out []models.TopologyLimit
+	if h.DNS != nil {
+		out = append(out, models.TopologyLimit{Name: "dns", Ms: h.DNS.TTL().Milliseconds(), Detail: "ttl"})
+	}
+	if pool := h.Pools[host]; pool != nil {
+		out = append(out, models.TopologyLimit{Name: "conn_pool", Max: int64(pool.Size())})
+	}
+	return out
+}
+
+// addChain adds the downstream chain under each of parents, all of which
+// share it. Chains too large to list are drawn one node per level.
+func addChain(t *models.TopologyResponse, chain services.Chain, parents []string) {
+	total, width := 0, 1
+	for range chain.Depth {
+		width *= chain.Fanout
+		total += width
+		if total > maxTopologyChainNodes {
+			break
+		}
+	}
+	var limits []models.TopologyLimit
+	if chain.Split > 0 {
+		limits = []models.TopologyLimit{{Name: "budget_split", Detail: fmt.Sprintf("%g%% of the remaining deadline", chain.Split*100)}}
+	}
+	node := func(id string, count int) {
+		t.Nodes = append(t.Nodes, models.TopologyNode{ID: id, Kind: "chain", Profile: chain.Profile.String(), Count: count})
+	}
+	edge := func(from, to string) {
+		t.Edges = append(t.Edges, models.TopologyEdge{From: from, To: to, Limits: limits})
+	}
+
+	// Node paths start at "B" whichever instance made the call.
+	if total > maxTopologyChainNodes {
+		parent, count := "B", 1
+		for level := 1; level <= chain.Depth; level++ {
+			id := fmt.Sprintf("%s>%c*", parent, 'C'+level-1)
+			count *= chain.Fanout
+			node(id, count)
+			if level == 1 {
+				for _, p := range parents {
+					edge(p, id)
+				}
+			} else {
+				edge(parent, id)
+			}
+			parent = id
+		}
+		return
+	}
+
+	var expand func(parent string, level int)
+	expand = func(parent string, level int) {
+		if level > chain.Depth {
+			return
+		}
+		for i := range chain.Fanout {
+			id := fmt.Sprintf("%s>%c%d", parent, 'C'+level-1, i+1)
+			node(id, 0)
+			if level == 1 {
+				for _, p := range parents {
+					edge(p, id)
+				}
+			} else {
+				edge(parent, id)
+			}
+			expand(id, level+1)
+		}
+	}
+	expand("B", 1)
+}
+
+// topologyDOT renders t as a Graphviz digraph, limits in the labels.
+func topologyDOT(t models.TopologyResponse) string {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, n := range t.Nodes {
+		label := []string{n.ID}
+		if n.Count > 1 {
+			label[0] += fmt.Sprintf(" ×%d", n.Count)
+		}
+		if n.Profile != "" {
+			label = append(label, n.Profile)
+		}
+		label = append(label, limitLabels(n.Limits)...)
+		shape := "box"
+		if n.Kind == "server" {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q, shape=%s];\n", n.ID, strings.Join(label, "\n"), shape)
+	}
+	for _, e := range t.Edges {
+		label := limitLabels(e.Limits)
+		if len(e.Modes) > 0 {
+			label = append([]string{strings.Join(e.Modes, ", ")}, label...)
+		}
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", e.From, e.To, strings.Join(label, "\n"))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func limitLabels(limits []models.TopologyLimit) []string {
+	out := make([]string, 0, len(limits))
+	for _, l := range limits {
+		var parts []string
+		if l.Max > 0 {
+			parts = append(parts, fmt.Sprintf("max %d", l.Max))
+		}
+		if l.Ms > 0 {
+			parts = append(parts, fmt.Sprintf("%dms", l.Ms))
+		}
+		if l.Detail != "" {
+			parts = append(parts, l.Detail)
+		}
+		out = append(out, l.Name+": "+strings.Join(parts, " "))
+	}
+	return out
+}
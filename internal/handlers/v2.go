@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/models"
+)
+
+// Route is the handler of one mode.
+type Route struct {
+	Mode   string
+	Handle gin.HandlerFunc
+}
+
+// V2Routes lists the modes also served under /v2: those answering with
+// CombinedResponse. The router registers exactly these, and /version lists
+// their names.
+func (h *Handlers) V2Routes() []Route {
+	return []Route{
+		{"sync", h.Sync}, {"async", h.Async}, {"async-limited", h.AsyncLimited}, {"async-timeout", h.AsyncTimeout},
+		{"async-hedged", h.AsyncHedged}, {"async-fallback", h.AsyncFallback}, {"async-singleflight", h.AsyncSingleflight}, {"async-breaker", h.AsyncBreaker}, {"smart", h.Smart}, {"balanced", h.Balanced},
+		{"sequential-dependency", h.SequentialDependency}, {"pipelined-dependency", h.PipelinedDependency},
+		{"race", h.Race}, {"regional", h.Regional}, {"pool", h.Pool},
+	}
+}
+
+// V2 serves next under the /v2 schema: the request carries a report that the
+// dependency calls, queues and fallbacks it goes through fill in, and its
+// response, success or error, includes it. Wrap it around Guard so the shed
+// queue's wait is reported too.
+func (h *Handlers) V2(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := &report{services: make(map[string]*models.ServiceReport), queues: make(map[string]time.Duration)}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), reportKey{}, r))
+		next(c)
+	}
+}
+
+// report collects how one /v2 request was served. Calls run on several
+// goroutines, so it is locked. A nil report, outside /v2, ignores everything.
+type report struct {
+	mu             sync.Mutex
+	services       map[string]*models.ServiceReport
+	queues         map[string]time.Duration
+	coalesced      bool
+	fallback       string
+	budgetExceeded bool
+}
+
+type reportKey struct{}
+
+func reportFrom(ctx context.Context) *report {
+	r, _ := ctx.Value(reportKey{}).(*report)
+	return r
+}
+
+// call records one attempt at the dependency name. An attempt cut off by a
+// time budget (Service B's retry budget, the chain's or the handler
+// deadline) marks the budget exceeded.
+func (r *report) call(name string, elapsed time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.services[name]
+	if !ok {
+		s = &models.ServiceReport{}
+		r.services[name] = s
+	}
+	s.Attempts++
+	s.Ms += elapsed.Milliseconds()
+	if err != nil {
+		s.Failures++
+	}
+	if budgetExceeded(err) {
+		r.budgetExceeded = true
+	}
+}
+
+func budgetExceeded(err error) bool {
+	return errors.Is(err, apperr.ErrBudgetExhausted) || errors.Is(err, apperr.ErrChainBudget) || errors.Is(err, apperr.ErrHandlerTimeout)
+}
+
+// waited records time spent waiting in queue, admitted or not.
+func (r *report) waited(queue string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.queues[queue] += d
+	r.mu.Unlock()
+}
+
+// fellBack records that fallback data from source ("cache", "default") was served.
+func (r *report) fellBack(source string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.fallback = source
+	r.mu.Unlock()
+}
+
+// shared records that the result came from a coalesced execution.
+func (r *report) shared() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.coalesced = true
+	r.mu.Unlock()
+}
+
+// snapshot returns the report as served, with err the request's failure, if any.
+func (r *report) snapshot(err error) models.RequestReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := models.RequestReport{
+		Services:       make(map[string]models.ServiceReport, len(r.services)),
+		Queues:         make(map[string]int64, len(r.queues)),
+		Coalesced:      r.coalesced,
+		Fallback:       r.fallback,
+		BudgetExceeded: r.budgetExceeded || budgetExceeded(err),
+	}
+	for name, s := range r.services {
+		out.Services[name] = *s
+	}
+	var wait time.Duration
+	for queue, d := range r.queues {
+		out.Queues[queue] = d.Milliseconds()
+		wait += d
+	}
+	out.QueueWaitMs = wait.Milliseconds()
+	return out
+}
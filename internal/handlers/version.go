@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/buildinfo"
+	"go-routine-stress/internal/models"
+)
+
+// Modes lists the concurrency mode endpoints, as registered by the router.
+var Modes = []string{
+	"sync", "sync-budget", "async", "async-limited", "async-timeout", "async-hedged", "async-fallback", "async-singleflight", "async-breaker", "partial", "smart", "balanced",
+	"sequential-dependency", "pipelined-dependency", "quorum", "race", "regional", "scatter", "fanout", "ordered-fanin", "pipeline", "pool", "compute", "compare", "slow-write", "stream",
+}
+
+// Version reports the build, the modes served and which optional features
+// this run has enabled, so experiment reports can record what produced them.
+func (h *Handlers) Version(c *gin.Context) {
+	c.JSON(http.StatusOK, h.version())
+}
+
+func (h *Handlers) version() models.VersionResponse {
+	var v2 []string
+	for _, r := range h.V2Routes() {
+		v2 = append(v2, r.Mode)
+	}
+	return models.VersionResponse{
+		Build:   buildinfo.Get(),
+		Modes:   Modes,
+		V2Modes: v2,
+		Features: map[string]bool{
+			"fair_queue":     h.FairB != nil,
+			"dns":            h.DNS != nil,
+			"conn_pools":     len(h.Pools) > 0,
+			"fifo_semaphore": h.FIFOB != nil,
+			"brownout":       h.Brownout != nil,
+			"coalescing":     len(h.coalescers) > 0,
+			"retries":        h.RetryB.Retries > 0,
+			"load_shed":      len(h.Shed) > 0,
+			"fan_in":         len(h.FanIn) > 0,
+			"traffic_split":  h.Split != nil,
+			"status_map":     len(h.Statuses) > 0,
+			"chain":          h.Svcs.Chain().Depth > 0,
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Score tracks an EWMA of the error ratio and latency of one dependency and
+// folds them into a single health value in [0, 1].
+type Score struct {
+	alpha    float64
+	targetMs float64
+
+	mu    sync.Mutex
+	errs  float64
+	latMs float64
+	seen  bool
+}
+
+// NewScore creates a score with smoothing factor alpha (0..1, higher reacts
+// faster) and a latency target; latencies above target lower the score.
+func NewScore(alpha float64, target time.Duration) *Score {
+	return &Score{alpha: alpha, targetMs: float64(target.Milliseconds())}
+}
+
+// Observe folds one call into the averages.
+func (s *Score) Observe(d time.Duration, err error) {
+	e := 0.0
+	if err != nil {
+		e = 1
+	}
+	ms := float64(d.Microseconds()) / 1000
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen {
+		s.errs, s.latMs, s.seen = e, ms, true
+		return
+	}
+	s.errs += s.alpha * (e - s.errs)
+	s.latMs += s.alpha * (ms - s.latMs)
+}
+
+// Value is (1 - error EWMA) scaled down by how far latency exceeds the target.
+// A dependency that was never called is considered healthy.
+func (s *Score) Value() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen {
+		return 1
+	}
+	v := 1 - s.errs
+	if s.latMs > s.targetMs && s.latMs > 0 {
+		v *= s.targetMs / s.latMs
+	}
+	return v
+}
+
+// Router picks between a primary and a fallback dependency from their scores.
+// It leaves the primary when its score drops below Threshold and returns once
+// it recovers above Threshold+Hysteresis. While on the fallback, every
+// ProbeEvery-th call still goes to the primary so its score keeps updating.
+type Router struct {
+	Primary, Fallback string
+	Threshold         float64
+	Hysteresis        float64
+	ProbeEvery        int
+
+	scores map[string]*Score
+
+	mu         sync.Mutex
+	onFallback bool
+	calls      int
+}
+
+// NewRouter creates a router over two named dependencies with the given scores.
+func NewRouter(primary, fallback string, scores map[string]*Score, threshold, hysteresis float64, probeEvery int) *Router {
+	return &Router{
+		Primary:    primary,
+		Fallback:   fallback,
+		Threshold:  threshold,
+		Hysteresis: hysteresis,
+		ProbeEvery: probeEvery,
+		scores:     scores,
+	}
+}
+
+// Pick returns the dependency to call next.
+func (r *Router) Pick() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.onFallback {
+		return r.Primary
+	}
+	r.calls++
+	if r.ProbeEvery > 0 && r.calls%r.ProbeEvery == 0 {
+		return r.Primary
+	}
+	return r.Fallback
+}
+
+// Observe records a call to name and re-evaluates the route. It returns the
+// new target when the route switched.
+func (r *Router) Observe(name string, d time.Duration, err error) (switchedTo string, switched bool) {
+	r.scores[name].Observe(d, err)
+	primary := r.scores[r.Primary].Value()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case !r.onFallback && primary < r.Threshold && r.scores[r.Fallback].Value() > primary:
+		r.onFallback = true
+		return r.Fallback, true
+	case r.onFallback && primary >= r.Threshold+r.Hysteresis:
+		r.onFallback = false
+		return r.Primary, true
+	}
+	return "", false
+}
+
+// Current returns the dependency currently preferred.
+func (r *Router) Current() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.onFallback {
+		return r.Fallback
+	}
+	return r.Primary
+}
+
+// Scores returns the current score of every dependency.
+func (r *Router) Scores() map[string]float64 {
+	out := make(map[string]float64, len(r.scores))
+	for name, s := range r.scores {
+		out[name] = s.Value()
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package hedge
+
+import "sync"
+
+// Budget caps hedged calls to a percentage of primary calls, so hedging cannot
+// multiply load on a dependency that is already slow. Every primary call
+// deposits percent/100 of a token, up to burst; every hedge spends one. During
+// a brownout hedges drain the budget and further hedges are suppressed until
+// primary traffic refills it.
+type Budget struct {
+	mu      sync.Mutex
+	percent int
+	burst   float64
+	tokens  float64
+
+	primaries  int64
+	hedges     int64
+	suppressed int64
+}
+
+// Stats is a point-in-time view of a Budget.
+type Stats struct {
+	Percent     int     `json:"percent"`
+	Burst       float64 `json:"burst"`
+	Tokens      float64 `json:"tokens"`
+	Utilization float64 `json:"utilization"`
+	Primaries   int64   `json:"primaries"`
+	Hedges      int64   `json:"hedges"`
+	Suppressed  int64   `json:"suppressed"`
+}
+
+// NewBudget creates a full budget allowing percent hedges per 100 primaries,
+// and at most burst hedges in a row.
+func NewBudget(percent, burst int) *Budget {
+	return &Budget{percent: percent, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Primary records one primary call.
+func (b *Budget) Primary() {
+	b.mu.Lock()
+	b.primaries++
+	b.tokens = min(b.tokens+float64(b.percent)/100, b.burst)
+	b.mu.Unlock()
+}
+
+// TryHedge spends a token for one hedge, or reports false if the budget is
+// exhausted and the hedge must be suppressed.
+func (b *Budget) TryHedge() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.percent == 0 || b.tokens < 1 {
+		b.suppressed++
+		return false
+	}
+	b.tokens--
+	b.hedges++
+	return true
+}
+
+// Percent returns the current cap.
+func (b *Budget) Percent() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.percent
+}
+
+// SetPercent changes the cap at runtime; 0 disables hedging.
+func (b *Budget) SetPercent(percent int) {
+	b.mu.Lock()
+	b.percent = percent
+	b.mu.Unlock()
+}
+
+// Utilization is the spent share of the budget: 0 when full, 1 when exhausted.
+func (b *Budget) Utilization() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.utilizationLocked()
+}
+
+func (b *Budget) utilizationLocked() float64 {
+	if b.burst == 0 {
+		return 1
+	}
+	return 1 - b.tokens/b.burst
+}
+
+// Stats returns the current state and lifetime counts.
+func (b *Budget) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Stats{
+		Percent:     b.percent,
+		Burst:       b.burst,
+		Tokens:      b.tokens,
+		Utilization: b.utilizationLocked(),
+		Primaries:   b.primaries,
+		Hedges:      b.hedges,
+		Suppressed:  b.suppressed,
+	}
+}
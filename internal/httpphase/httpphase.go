@@ -0,0 +1,95 @@
+// Package httpphase times the phases of an HTTP client request, DNS,
+// connect, time to first byte and body, so a slow downstream call can be told
+// apart from a slow dial or lookup on its way.
+package httpphase
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// The phases of one downstream call, in the order they happen.
+const (
+	DNS     = "dns"     // resolving the host
+	Connect = "connect" // dialing a new connection, TLS included
+	TTFB    = "ttfb"    // from having a connection to the first response byte
+	Body    = "body"    // from the first response byte to the end of the body
+)
+
+// Phase is how long one phase of a call took.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Timer measures the phases of one HTTP request from net/http/httptrace
+// hooks. A request on a reused connection has no dns or connect phase, and
+// one on an IP address no dns phase.
+type Timer struct {
+	mu      sync.Mutex
+	started map[string]time.Time
+	phases  []Phase
+	done    bool
+}
+
+// Start returns ctx with a trace feeding a new Timer; send the request with
+// it, then call Done once its body has been read.
+func Start(ctx context.Context) (context.Context, *Timer) {
+	t := &Timer{started: make(map[string]time.Time)}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.begin(DNS) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.end(DNS) },
+		// With several addresses the dial may be tried on more than one:
+		// the phase runs from the first attempt.
+		ConnectStart: func(string, string) { t.begin(Connect) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				t.end(Connect)
+			}
+			t.begin(TTFB)
+		},
+		GotFirstResponseByte: func() {
+			t.end(TTFB)
+			t.begin(Body)
+		},
+	}), t
+}
+
+// Done ends the body phase, and with it the request: phases ending later
+// are not recorded.
+func (t *Timer) Done() {
+	t.end(Body)
+	t.mu.Lock()
+	t.done = true
+	t.mu.Unlock()
+}
+
+// Phases returns the phases that ended, in the order they did.
+func (t *Timer) Phases() []Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Phase(nil), t.phases...)
+}
+
+func (t *Timer) begin(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.started[name]; !ok {
+		t.started[name] = time.Now()
+	}
+}
+
+// end records name once, if it began. The hooks of a dial the request did
+// not wait for, having got an idle connection first, can run after Done.
+func (t *Timer) end(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start := t.started[name]
+	if t.done || start.IsZero() {
+		return
+	}
+	t.phases = append(t.phases, Phase{Name: name, Duration: time.Since(start)})
+	t.started[name] = time.Time{}
+}
@@ -0,0 +1,53 @@
+package httpphase
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTimer sends two requests over one connection, by host name so that it
+// is resolved: the first goes through every phase, the second, on the
+// connection the first left idle, only ttfb and body. The server holds the
+// body back, so the body phase takes at least that long.
+func TestTimer(t *testing.T) {
+	const hold = 20 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "head ")
+		w.(http.Flusher).Flush()
+		time.Sleep(hold)
+		_, _ = io.WriteString(w, "tail")
+	}))
+	defer srv.Close()
+	url := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	for i, want := range [][]string{{DNS, Connect, TTFB, Body}, {TTFB, Body}} {
+		ctx, timer := Start(t.Context())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		timer.Done()
+
+		var names []string
+		for _, p := range timer.Phases() {
+			names = append(names, p.Name)
+			if p.Name == Body && p.Duration < hold {
+				t.Errorf("request %d: body phase %v, want at least %v", i, p.Duration, hold)
+			}
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("request %d: phases %q, want %q", i, names, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package inflight
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Registry tracks in-flight requests so they can be listed and cancelled
+// individually through the admin API, and keeps the most recently finished
+// ones for diagnostic snapshots.
+type Registry struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	recent  []Finished // ring of finished requests
+	next    int
+	full    bool
+}
+
+type entry struct {
+	id       string
+	endpoint string
+	start    time.Time
+	cancel   context.CancelCauseFunc
+
+	mu     sync.Mutex
+	phases []string
+	status int
+	reason string
+}
+
+// Info describes one in-flight request.
+type Info struct {
+	ID        string    `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	StartedAt time.Time `json:"startedAt"`
+	AgeMs     int64     `json:"ageMs"`
+	Phases    []string  `json:"phases"`
+}
+
+// Finished describes one finished request.
+type Finished struct {
+	ID         string    `json:"id"`
+	Endpoint   string    `json:"endpoint"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs float64   `json:"durationMs"`
+	Status     int       `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// New creates an empty registry remembering up to recent finished requests.
+func New(clk clock.Clock, recent int) *Registry {
+	return &Registry{clock: clk, entries: make(map[string]*entry), recent: make([]Finished, recent)}
+}
+
+type ctxKey struct{}
+
+// Start registers a request and returns its cancellable context and a
+// function that unregisters it. A duplicate id gets a numeric suffix.
+func (r *Registry) Start(ctx context.Context, id, endpoint string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	e := &entry{endpoint: endpoint, start: r.clock.Now(), cancel: cancel}
+
+	r.mu.Lock()
+	e.id = id
+	for n := 2; r.entries[e.id] != nil; n++ {
+		e.id = id + "#" + strconv.Itoa(n)
+	}
+	r.entries[e.id] = e
+	r.mu.Unlock()
+
+	return context.WithValue(ctx, ctxKey{}, e), func() {
+		end := r.clock.Now()
+		e.mu.Lock()
+		f := Finished{ID: e.id, Endpoint: endpoint, StartedAt: e.start, DurationMs: float64(end.Sub(e.start).Microseconds()) / 1000, Status: e.status, Reason: e.reason}
+		e.mu.Unlock()
+
+		r.mu.Lock()
+		delete(r.entries, e.id)
+		if len(r.recent) > 0 {
+			r.recent[r.next] = f
+			r.next = (r.next + 1) % len(r.recent)
+			r.full = r.full || r.next == 0
+		}
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Finish records the outcome of the request in ctx, reported once it is
+// unregistered: its HTTP status and the cancellation reason, if any. It is
+// a no-op for requests that are not registered.
+func Finish(ctx context.Context, status int, reason string) {
+	e, ok := ctx.Value(ctxKey{}).(*entry)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	e.status, e.reason = status, reason
+	e.mu.Unlock()
+}
+
+// Recent returns the most recently finished requests, oldest first.
+func (r *Registry) Recent() []Finished {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return slices.Clone(r.recent[:r.next])
+	}
+	return append(slices.Clone(r.recent[r.next:]), r.recent[:r.next]...)
+}
+
+// Cancel cancels the request with the given id and cause. It reports whether
+// the request was found.
+func (r *Registry) Cancel(id string, cause error) bool {
+	r.mu.Lock()
+	e, ok := r.entries[id]
+	r.mu.Unlock()
+	if ok {
+		e.cancel(cause)
+	}
+	return ok
+}
+
+// List returns the in-flight requests, oldest first.
+func (r *Registry) List() []Info {
+	now := r.clock.Now()
+	r.mu.Lock()
+	out := make([]Info, 0, len(r.entries))
+	for _, e := range r.entries {
+		e.mu.Lock()
+		out = append(out, Info{
+			ID:        e.id,
+			Endpoint:  e.endpoint,
+			StartedAt: e.start,
+			AgeMs:     now.Sub(e.start).Milliseconds(),
+			Phases:    slices.Clone(e.phases),
+		})
+		e.mu.Unlock()
+	}
+	r.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// Phase marks name as active for the request in ctx until the returned
+// function is called. Concurrent phases (e.g. A and B during a fan-out) are
+// all listed. It is a no-op for requests that are not registered.
+func Phase(ctx context.Context, name string) func() {
+	e, ok := ctx.Value(ctxKey{}).(*entry)
+	if !ok {
+		return func() {}
+	}
+	e.mu.Lock()
+	e.phases = append(e.phases, name)
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		if i := slices.Index(e.phases, name); i >= 0 {
+			e.phases = slices.Delete(e.phases, i, i+1)
+		}
+		e.mu.Unlock()
+	}
+}
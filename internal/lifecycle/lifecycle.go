@@ -0,0 +1,116 @@
+// Package lifecycle owns the server's long-lived background goroutines:
+// stats tickers, watchdogs, worker pools, queue consumers, the admin
+// listener and the telemetry exporters.
+package lifecycle
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+)
+
+// Group is a run-group of background workers. Each runs under a context of
+// its own with the panic containment of safego.Background. Workers are
+// started after what they depend on, so Stop stops them in reverse order of
+// start: no worker is stopped while one still running relies on it.
+type Group struct {
+	stopTimeout time.Duration
+	m           *observability.Metrics
+
+	mu      sync.Mutex
+	workers []*worker
+	stopped bool
+	export  func(context.Context) error
+}
+
+type worker struct {
+	name    string
+	timeout time.Duration
+	cancel  context.CancelFunc
+	done    <-chan struct{}
+}
+
+// New creates a group giving each worker stopTimeout to return once
+// stopped, unless it was started with a timeout of its own.
+func New(stopTimeout time.Duration, m *observability.Metrics) *Group {
+	return &Group{stopTimeout: stopTimeout, m: m}
+}
+
+// Go starts fn as the worker name, to run until its context is cancelled by
+// Stop. stopTimeout bounds how long Stop waits for it to return; 0 means the
+// group's. Starting a worker on a stopped group is a no-op.
+func (g *Group) Go(name string, stopTimeout time.Duration, fn func(context.Context)) {
+	if stopTimeout <= 0 {
+		stopTimeout = g.stopTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		log.Printf("lifecycle: %s not started, shutting down", name)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.workers = append(g.workers, &worker{name: name, timeout: stopTimeout, cancel: cancel, done: safego.Background(ctx, name, fn)})
+}
+
+// Export hands the group the shutdown of the telemetry exporters. Stop calls
+// it once every worker is stopped and its own metrics are recorded, so they
+// are flushed with the rest, and gives it the group's stop timeout.
+func (g *Group) Export(shutdown func(context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.export = shutdown
+}
+
+// Stop stops the workers one at a time, newest first: each has its context
+// cancelled and is given its stop timeout to return. One that overruns is
+// logged and left behind, and the next one stopped. Stop records how long
+// each worker took in lifecycle_stop_duration_ms{worker,result} and the
+// whole in lifecycle_shutdown_duration_ms, then shuts the exporters down,
+// and returns the workers that overran.
+func (g *Group) Stop() (overran []string) {
+	g.mu.Lock()
+	g.stopped = true
+	workers, export := g.workers, g.export
+	g.workers, g.export = nil, nil
+	g.mu.Unlock()
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := len(workers) - 1; i >= 0; i-- {
+		w := workers[i]
+		t0 := time.Now()
+		w.cancel()
+		result := "stopped"
+		timer := time.NewTimer(w.timeout)
+		select {
+		case <-w.done:
+		case <-timer.C:
+			result = "timeout"
+			overran = append(overran, w.name)
+			log.Printf("lifecycle: %s still running %s after stop, leaving it behind", w.name, w.timeout)
+		}
+		timer.Stop()
+		g.m.LifecycleStopDuration.Record(ctx, float64(time.Since(t0).Milliseconds()), metric.WithAttributes(
+			attribute.String("worker", w.name),
+			attribute.String("result", result),
+		))
+	}
+	g.m.LifecycleShutdownDuration.Record(ctx, float64(time.Since(start).Milliseconds()))
+
+	if export != nil {
+		flushCtx, cancel := context.WithTimeout(ctx, g.stopTimeout)
+		defer cancel()
+		if err := export(flushCtx); err != nil {
+			log.Printf("lifecycle: telemetry shutdown: %v", err)
+		}
+	}
+	return overran
+}
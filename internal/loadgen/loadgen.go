@@ -0,0 +1,285 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-routine-stress/internal/client"
+	"go-routine-stress/internal/httpphase"
+)
+
+// Phase is one stage of a run: Concurrency closed-loop workers, each sending
+// its next request as soon as the previous one finished, for Duration.
+type Phase struct {
+	Duration    time.Duration `json:"-"`
+	Concurrency int           `json:"concurrency"`
+}
+
+// ParsePhases parses "duration@concurrency" stages separated by ';', e.g.
+// "30s@10;1m@50;30s@10".
+func ParsePhases(s string) ([]Phase, error) {
+	var out []Phase
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		dur, conc, ok := strings.Cut(part, "@")
+		if !ok {
+			return nil, fmt.Errorf("phase %q: want duration@concurrency", part)
+		}
+		d, err := time.ParseDuration(dur)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("phase %q: bad duration", part)
+		}
+		n, err := strconv.Atoi(conc)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("phase %q: concurrency must be a positive integer", part)
+		}
+		out = append(out, Phase{Duration: d, Concurrency: n})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no phases in %q", s)
+	}
+	return out, nil
+}
+
+// Result summarizes the requests of one phase, as seen by the client.
+type Result struct {
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"` // transport errors and non-2xx/304
+	Statuses map[int]int64 `json:"statuses"`
+	RPS      float64       `json:"rps"`
+	MeanMs   float64       `json:"meanMs"`
+	P50Ms    float64       `json:"p50Ms"`
+	P95Ms    float64       `json:"p95Ms"`
+	P99Ms    float64       `json:"p99Ms"`
+	MaxMs    float64       `json:"maxMs"`
+	Conns    ConnStats     `json:"connections"`
+	// Phases breaks the latencies down by phase: dns, connect, ttfb, body.
+	Phases map[string]PhaseStats `json:"phases"`
+	// Limit is set when the client limited its own concurrency.
+	Limit *LimitStats `json:"clientLimit,omitempty"`
+}
+
+// LimitStats is what the client-side limit did during a phase.
+type LimitStats struct {
+	// MeanLimit, MinLimit and MaxLimit are over the limit as it stood after
+	// each request; EndLimit is where the phase left it.
+	MeanLimit float64 `json:"meanLimit"`
+	MinLimit  float64 `json:"minLimit"`
+	MaxLimit  float64 `json:"maxLimit"`
+	EndLimit  float64 `json:"endLimit"`
+	// Drops are the requests that cut the limit: shed (429, 503) or timed out.
+	Drops int64 `json:"drops"`
+	// WaitMeanMs and WaitMaxMs are how long workers waited on the limit
+	// before sending; the latencies above leave that wait out.
+	WaitMeanMs float64 `json:"waitMeanMs"`
+	WaitMaxMs  float64 `json:"waitMaxMs"`
+}
+
+// PhaseStats is how long one phase of the requests took. Count is the
+// requests that went through it: only those that dialed have a dns and a
+// connect phase.
+type PhaseStats struct {
+	Count  int64   `json:"count"`
+	MeanMs float64 `json:"meanMs"`
+	P95Ms  float64 `json:"p95Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// ConnStats counts how the requests of a phase got their connection.
+type ConnStats struct {
+	Opened int64 `json:"opened"` // requests that dialed a new connection
+	Reused int64 `json:"reused"` // requests on a connection already used
+	// Idle is how many of the reused were taken idle from the pool; under
+	// HTTP/2 the others shared the connection with requests in flight.
+	Idle     int64   `json:"idle"`
+	ReusePct float64 `json:"reusePct"`
+	// ConnectMs is the mean time to dial a new connection, TLS included.
+	ConnectMs float64 `json:"connectMs"`
+	// Protocols counts the responses by protocol, e.g. HTTP/1.1 or HTTP/2.0.
+	Protocols map[string]int64 `json:"protocols"`
+}
+
+// conn is how one request got its connection.
+type conn struct {
+	got          bool // false when the request failed before it had one
+	reused, idle bool
+	connect      time.Duration // 0 when reused
+	proto        string
+}
+
+// Runner sends GET requests to URL. With a Limiter, a phase's workers send
+// only as many requests at once as its limit allows, and the others wait;
+// the limit carries over from one phase to the next.
+type Runner struct {
+	Client  *http.Client
+	URL     string
+	Limiter *client.Limiter
+}
+
+// Run drives one phase and returns what the client measured. Requests still
+// in flight when the phase ends are cancelled and not counted.
+func (r *Runner) Run(ctx context.Context, p Phase) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.Duration)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		latencies []float64
+		connect   time.Duration
+		limits    []float64
+		waits     []float64
+		drops     int64
+		phases    = make(map[string][]float64)
+		res       = Result{Statuses: make(map[int]int64), Conns: ConnStats{Protocols: make(map[string]int64)}}
+	)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for range p.Concurrency {
+		wg.Go(func() {
+			for ctx.Err() == nil {
+				var tok *client.Token
+				var wait time.Duration
+				if r.Limiter != nil {
+					w0 := time.Now()
+					var err error
+					if tok, err = r.Limiter.Acquire(ctx); err != nil {
+						return
+					}
+					wait = time.Since(w0)
+				}
+				t0 := time.Now()
+				status, cn, ph, err := r.once(ctx)
+				ms := float64(time.Since(t0).Microseconds()) / 1000
+				outcome := client.OutcomeOf(ctx, status, err)
+				if tok != nil {
+					tok.Release(outcome)
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				mu.Lock()
+				if tok != nil {
+					limits = append(limits, r.Limiter.Limit())
+					waits = append(waits, float64(wait.Microseconds())/1000)
+					if outcome == client.Dropped {
+						drops++
+					}
+				}
+				res.Requests++
+				res.Statuses[status]++
+				if err != nil || (status >= 300 && status != http.StatusNotModified) {
+					res.Errors++
+				}
+				latencies = append(latencies, ms)
+				for _, p := range ph {
+					phases[p.Name] = append(phases[p.Name], float64(p.Duration.Microseconds())/1000)
+				}
+				switch {
+				case !cn.got:
+					// Failed before it had a connection: counted in neither.
+				case cn.reused:
+					res.Conns.Reused++
+					if cn.idle {
+						res.Conns.Idle++
+					}
+				default:
+					res.Conns.Opened++
+					connect += cn.connect
+				}
+				if cn.proto != "" {
+					res.Conns.Protocols[cn.proto]++
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	res.RPS = float64(res.Requests) / time.Since(start).Seconds()
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		res.MeanMs = mean(latencies)
+		res.P50Ms, res.P95Ms, res.P99Ms = quantile(latencies, 0.50), quantile(latencies, 0.95), quantile(latencies, 0.99)
+		res.MaxMs = latencies[len(latencies)-1]
+	}
+	res.Phases = make(map[string]PhaseStats, len(phases))
+	for name, ms := range phases {
+		slices.Sort(ms)
+		res.Phases[name] = PhaseStats{Count: int64(len(ms)), MeanMs: mean(ms), P95Ms: quantile(ms, 0.95), MaxMs: ms[len(ms)-1]}
+	}
+	if n := res.Conns.Opened + res.Conns.Reused; n > 0 {
+		res.Conns.ReusePct = 100 * float64(res.Conns.Reused) / float64(n)
+	}
+	if res.Conns.Opened > 0 {
+		res.Conns.ConnectMs = float64(connect.Microseconds()) / 1000 / float64(res.Conns.Opened)
+	}
+	if r.Limiter != nil {
+		res.Limit = &LimitStats{EndLimit: r.Limiter.Limit(), Drops: drops}
+		if len(limits) > 0 {
+			res.Limit.MeanLimit, res.Limit.MinLimit, res.Limit.MaxLimit = mean(limits), slices.Min(limits), slices.Max(limits)
+			res.Limit.WaitMeanMs, res.Limit.WaitMaxMs = mean(waits), slices.Max(waits)
+		}
+	}
+	return res
+}
+
+// once sends one request and reports the connection it went out on and the
+// phases it went through; status is 0 on transport errors.
+func (r *Runner) once(ctx context.Context) (int, conn, []httpphase.Phase, error) {
+	var (
+		cn conn
+		// The dial runs on a goroutine of the transport's.
+		connectStart atomic.Int64
+	)
+	ctx, phases := httpphase.Start(ctx)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connectStart.CompareAndSwap(0, time.Now().UnixNano()) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			cn.got, cn.reused, cn.idle = true, info.Reused, info.WasIdle
+			if t := connectStart.Load(); !info.Reused && t != 0 {
+				cn.connect = time.Since(time.Unix(0, t))
+			}
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return 0, cn, nil, err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		phases.Done()
+		return 0, cn, phases.Phases(), err
+	}
+	defer resp.Body.Close()
+	cn.proto = resp.Proto
+	// Drain the body so the connection is reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	phases.Done()
+	return resp.StatusCode, cn, phases.Phases(), nil
+}
+
+func mean(xs []float64) float64 {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	return sorted[min(int(q*float64(len(sorted))), len(sorted)-1)]
+}
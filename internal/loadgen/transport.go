@@ -0,0 +1,83 @@
+package loadgen
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Transport sets how the load generator's client connects. How many
+// connections it opens and whether it reuses them changes what the server
+// sees: HTTP/1.1 without keep-alive opens a connection per request, while
+// HTTP/2 multiplexes every worker over one.
+type Transport struct {
+	// Protocol is auto (HTTP/2 over TLS when the server offers it, HTTP/1.1
+	// otherwise), http1, http2 (over TLS only) or h2c (HTTP/2 over
+	// cleartext, with prior knowledge; the server needs HTTP_H2C=true).
+	Protocol string `json:"protocol"`
+	// MaxConnsPerHost caps the connections per host (0 = no cap); workers
+	// past it wait for one.
+	MaxConnsPerHost int `json:"maxConnsPerHost"`
+	// MaxIdleConnsPerHost is how many idle connections are kept per host
+	// (0 = net/http's default, 2): workers past it close their connection
+	// after each request, and the next one dials again.
+	MaxIdleConnsPerHost int           `json:"maxIdleConnsPerHost"`
+	IdleConnTimeout     time.Duration `json:"idleConnTimeoutNs"`
+	// DisableKeepAlives closes every connection after one request.
+	DisableKeepAlives bool `json:"disableKeepAlives"`
+	// InsecureSkipVerify accepts any server certificate; CAFile adds a PEM
+	// bundle to the trusted roots instead.
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	CAFile             string `json:"caFile,omitempty"`
+}
+
+// NewClient returns a client that connects the way t says, with timeout
+// per request.
+func (t Transport) NewClient(timeout time.Duration) (*http.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxConnsPerHost = t.MaxConnsPerHost
+	tr.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+	// The default caps idle connections over all hosts at 100, which would
+	// silently undercut a larger per-host setting.
+	tr.MaxIdleConns = 0
+	if t.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = t.IdleConnTimeout
+	}
+	tr.DisableKeepAlives = t.DisableKeepAlives
+
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates", t.CAFile)
+		}
+		tr.TLSClientConfig.RootCAs = roots
+	}
+
+	var p http.Protocols
+	switch t.Protocol {
+	case "", "auto":
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	case "http1":
+		p.SetHTTP1(true)
+	case "http2":
+		p.SetHTTP2(true)
+	case "h2c":
+		p.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("protocol %q: want auto, http1, http2 or h2c", t.Protocol)
+	}
+	tr.Protocols = &p
+	return &http.Client{Transport: tr, Timeout: timeout}, nil
+}
@@ -0,0 +1,73 @@
+package markers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Marker flags a moment of an experiment, e.g. "chaos started".
+type Marker struct {
+	At          time.Time `json:"at"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+}
+
+// Store keeps every marker of the run, up to a limit, and exports each one
+// as a log line and as a trace span of its own, so trace backends show the
+// marker next to the requests around it.
+type Store struct {
+	clock clock.Clock
+	limit int
+
+	mu      sync.Mutex
+	markers []Marker
+}
+
+// New creates a store keeping the first limit markers of the run.
+func New(clk clock.Clock, limit int) *Store {
+	return &Store{clock: clk, limit: max(limit, 1)}
+}
+
+// Add stamps m with the current time and records it. Markers past the limit
+// are still logged and traced, just not kept.
+func (s *Store) Add(ctx context.Context, m Marker) Marker {
+	m.At = s.clock.Now()
+
+	s.mu.Lock()
+	if len(s.markers) < s.limit {
+		s.markers = append(s.markers, m)
+	}
+	s.mu.Unlock()
+
+	if b, err := json.Marshal(m); err == nil {
+		log.Printf("marker: %s", b)
+	}
+
+	// A root span: the marker belongs to the run, not to the admin request.
+	_, span := otel.Tracer("go-goroutine-lab/markers").Start(ctx, "marker "+m.Name,
+		trace.WithNewRoot(), trace.WithTimestamp(m.At))
+	span.AddEvent(m.Name, trace.WithTimestamp(m.At), trace.WithAttributes(
+		attribute.String("marker.name", m.Name),
+		attribute.String("marker.description", m.Description),
+		attribute.String("marker.actor", m.Actor),
+	))
+	span.End(trace.WithTimestamp(m.At))
+	return m
+}
+
+// List returns the markers in the order they were added.
+func (s *Store) List() []Marker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Marker(nil), s.markers...)
+}
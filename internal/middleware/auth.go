@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/auth"
+)
+
+const principalKey = "principal"
+
+// RequireRole admits requests bearing a token (Authorization: Bearer) of at
+// least role: 401 without a known token, 403 with too weak a role. With no
+// tokens configured everything is admitted, as an anonymous operator.
+func RequireRole(tokens *auth.Tokens, role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tokens.Enabled() {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		p, known := tokens.Lookup(token)
+		if !ok || !known {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.String(http.StatusUnauthorized, "missing or unknown admin token")
+			c.Abort()
+			return
+		}
+		if p.Role < role {
+			c.String(http.StatusForbidden, "role %s required, %s has %s", role, p.Name, p.Role)
+			c.Abort()
+			return
+		}
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+// RequireRoleOrLocal is RequireRole failing closed, for endpoints exposing
+// process internals (profiles, stacks, command line): with no tokens
+// configured it admits only callers connecting over loopback, instead of
+// everyone. The peer address is checked, not X-Forwarded-For.
+func RequireRoleOrLocal(tokens *auth.Tokens, role auth.Role) gin.HandlerFunc {
+	withTokens := RequireRole(tokens, role)
+	return func(c *gin.Context) {
+		if tokens.Enabled() {
+			withTokens(c)
+			return
+		}
+		if ip := net.ParseIP(c.RemoteIP()); ip == nil || !ip.IsLoopback() {
+			c.String(http.StatusForbidden, "only loopback callers may use this endpoint without ADMIN_TOKENS_FILE")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetPrincipal returns the caller admitted by RequireRole, if auth is enabled.
+func GetPrincipal(c *gin.Context) (auth.Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return auth.Principal{}, false
+	}
+	p, ok := v.(auth.Principal)
+	return p, ok
+}
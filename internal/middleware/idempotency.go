@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/fairq"
+	"go-routine-stress/internal/observability"
+)
+
+// Idempotency headers: the client's key, and the mark on a response replayed
+// from the store instead of served.
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// Limits on what is deduplicated: longer keys are refused, larger request
+// bodies too, and larger responses are served but not kept.
+const (
+	maxIdempotencyKey  = 255
+	maxIdempotentBody  = 1 << 20
+	maxIdempotentReply = 1 << 20
+)
+
+// Idempotency outcomes, as idempotency_requests_total{result}.
+const (
+	idemStored   = "stored"   // served and kept for replay
+	idemReplayed = "replayed" // answered from the store
+	idemUnstored = "unstored" // served but not kept: retryable, or too large
+	idemMismatch = "mismatch" // the key was used for another request
+	idemFull     = "full"     // served without a key, the store being full
+)
+
+// Idempotency keeps the responses to POST and PATCH requests sent with an
+// Idempotency-Key, for ttl after they complete, so a retried submission gets
+// the first response instead of doing the work again. Keys are scoped to the
+// caller: its admin principal, else its hashed X-API-Key, else its address.
+type Idempotency struct {
+	clock   clock.Clock
+	ttl     time.Duration
+	maxKeys int
+	m       *observability.Metrics
+
+	mu        sync.Mutex
+	entries   map[string]*idemEntry
+	nextSweep time.Time
+}
+
+// idemEntry is one key: in flight until done is closed, then replayable
+// until expires if stored.
+type idemEntry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+
+	stored  bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// NewIdempotency creates a store keeping up to maxKeys responses for ttl,
+// measured on clk.
+func NewIdempotency(clk clock.Clock, ttl time.Duration, maxKeys int, m *observability.Metrics) *Idempotency {
+	return &Idempotency{clock: clk, ttl: ttl, maxKeys: maxKeys, m: m, entries: make(map[string]*idemEntry)}
+}
+
+// Idempotent deduplicates POST and PATCH requests carrying an
+// Idempotency-Key through s; a nil s, other methods and requests without a
+// key pass through. A retry arriving while the first request runs waits for
+// it. A key reused for a different method, URL or body is refused with 422.
+// Responses that ask for a retry, 5xx and 429, are not kept, so the next
+// attempt runs again.
+func Idempotent(s *Idempotency) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if s == nil || key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key longer than 255 bytes"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBody))
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": "reading the request body: " + err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		id := idempotencyScope(c) + "\x00" + key
+		h := sha256.New()
+		_, _ = io.WriteString(h, c.Request.Method+" "+c.Request.URL.RequestURI()+"\x00")
+		h.Write(body)
+		var fp [sha256.Size]byte
+		h.Sum(fp[:0])
+
+		for {
+			e, leader, ok := s.claim(id, fp)
+			switch {
+			case !ok:
+				s.record(c, idemFull)
+				c.Next()
+				return
+			case leader:
+				s.serve(c, id, e)
+				return
+			case e.fingerprint != fp:
+				s.record(c, idemMismatch)
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key already used for a different request"})
+				return
+			}
+			select {
+			case <-e.done:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			if e.stored {
+				s.replay(c, e)
+				return
+			}
+			// The first attempt was not kept: this one runs, or waits on
+			// whichever claimed the key first.
+		}
+	}
+}
+
+// idempotencyScope names the caller a key belongs to, so two clients picking
+// the same key never see each other's responses.
+func idempotencyScope(c *gin.Context) string {
+	if p, ok := GetPrincipal(c); ok {
+		return "principal:" + p.Name
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return fairq.KeyTenant(key)
+	}
+	return "addr:" + c.RemoteIP()
+}
+
+// claim returns the entry for id, creating it when there is none or it has
+// expired, in which case the caller is its leader and serves the request.
+// ok is false when the store is full.
+func (s *Idempotency) claim(id string, fp [sha256.Size]byte) (e *idemEntry, leader, ok bool) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !now.Before(s.nextSweep) {
+		for k, old := range s.entries {
+			if old.stored && !now.Before(old.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(s.ttl)
+	}
+	if e := s.entries[id]; e != nil && (!e.stored || now.Before(e.expires)) {
+		return e, false, true
+	}
+	if len(s.entries) >= s.maxKeys {
+		return nil, false, false
+	}
+	e = &idemEntry{fingerprint: fp, done: make(chan struct{})}
+	s.entries[id] = e
+	return e, true, true
+}
+
+// serve runs the request for the entry it leads, keeping the response when
+// it is final. The waiters are released even if the handler panics.
+func (s *Idempotency) serve(c *gin.Context, id string, e *idemEntry) {
+	rw := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = rw
+	keep := false
+	defer func() {
+		s.mu.Lock()
+		if keep {
+			e.status, e.header, e.body = rw.Status(), rw.Header().Clone(), bytes.Clone(rw.body.Bytes())
+			e.expires = s.clock.Now().Add(s.ttl)
+			e.stored = true
+		} else {
+			delete(s.entries, id)
+		}
+		s.mu.Unlock()
+		close(e.done)
+	}()
+
+	c.Next()
+
+	status := rw.Status()
+	keep = status < http.StatusInternalServerError && status != http.StatusTooManyRequests && rw.body.Len() <= maxIdempotentReply
+	if keep {
+		s.record(c, idemStored)
+	} else {
+		s.record(c, idemUnstored)
+	}
+}
+
+// replay answers c with e's response, keeping c's own request ID.
+func (s *Idempotency) replay(c *gin.Context, e *idemEntry) {
+	s.record(c, idemReplayed)
+	for k, v := range e.header {
+		if k != RequestIDHeader {
+			c.Writer.Header()[k] = v
+		}
+	}
+	c.Header(IdempotencyReplayedHeader, "true")
+	c.Writer.WriteHeader(e.status)
+	_, _ = c.Writer.Write(e.body)
+	c.Abort()
+}
+
+func (s *Idempotency) record(c *gin.Context, result string) {
+	s.m.IdempotencyRequests.Add(c.Request.Context(), 1, metric.WithAttributes(
+		attribute.String("route", c.FullPath()),
+		attribute.String("result", result),
+	))
+}
+
+// recordingWriter keeps a copy of the body it writes.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.body.Len() <= maxIdempotentReply {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	if w.body.Len() <= maxIdempotentReply {
+		w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
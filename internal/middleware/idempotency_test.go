@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+)
+
+func TestIdempotent(t *testing.T) {
+	m, err := observability.NewMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewIdempotency(clk, time.Minute, 100, m)
+
+	var runs atomic.Int64
+	release := make(chan struct{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/jobs", Idempotent(s), func(c *gin.Context) {
+		n := runs.Add(1)
+		if c.Query("wait") != "" {
+			<-release
+		}
+		c.String(http.StatusCreated, "job %d", n)
+	})
+	r.POST("/shed", Idempotent(s), func(c *gin.Context) {
+		runs.Add(1)
+		c.Status(http.StatusTooManyRequests)
+	})
+	send := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("/jobs", "k1", "a")
+	retry := send("/jobs", "k1", "a")
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated || retry.Body.String() != "job 1" ||
+		retry.Header().Get(IdempotencyReplayedHeader) != "true" || runs.Load() != 1 {
+		t.Fatalf("retry = %d %q replayed=%q after %d runs; want the first job replayed",
+			retry.Code, retry.Body, retry.Header().Get(IdempotencyReplayedHeader), runs.Load())
+	}
+	if w := send("/jobs", "k1", "b"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("key reused for another body = %d, want 422", w.Code)
+	}
+	if w := send("/jobs", "k2", "a"); w.Body.String() != "job 2" {
+		t.Fatalf("another key = %q, want a new job", w.Body)
+	}
+
+	// Retryable answers are not kept: the retry runs again.
+	send("/shed", "k3", "")
+	send("/shed", "k3", "")
+	if runs.Load() != 4 {
+		t.Fatalf("%d runs after two shed attempts, want 4", runs.Load())
+	}
+
+	// Past the TTL, the key is free again.
+	clk.Advance(time.Minute)
+	if w := send("/jobs", "k1", "a"); w.Body.String() != "job 5" || w.Header().Get(IdempotencyReplayedHeader) != "" {
+		t.Fatalf("after the TTL = %q, want a new job", w.Body)
+	}
+
+	// Retries of a request still running wait for it and share its response.
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Go(func() { bodies[i] = send("/jobs?wait=1", "k4", "").Body.String() })
+	}
+	for runs.Load() < 6 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	for _, b := range bodies {
+		if b != "job 6" {
+			t.Fatalf("concurrent retries got %q, want all job 6", bodies)
+		}
+	}
+	if runs.Load() != 6 {
+		t.Fatalf("%d runs, want one for the concurrent retries", runs.Load())
+	}
+}
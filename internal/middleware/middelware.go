@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"strconv"
 	"time"
 
@@ -9,14 +10,36 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/canary"
+	"go-routine-stress/internal/cpuprof"
+	"go-routine-stress/internal/ctxaudit"
+	"go-routine-stress/internal/inflight"
 	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/stats"
+	"go-routine-stress/internal/toggles"
 )
 
+// VariantKey is the gin context key under which handlers publish the
+// implementation variant that served a request, so request metrics can be
+// compared per variant.
+const VariantKey = "handlers.variant"
+
 // Instrument wraps a handler with basic observability:
-// - in-flight tracking
+// - rolling in-process stats (served by /stats)
+// - in-flight tracking, including the registry behind /admin/inflight
+// - context propagation audit state (see ctxaudit)
 // - request counter
 // - latency histogram
-func Instrument(m *observability.Metrics, endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+// - allocation histograms on sampled requests
+// - the pprof "endpoint" label, for CPU attribution (see cpuprof)
+// - client disconnect counter
+// - the variant label, when traffic splitting picked one
+//
+// The audit state and allocation sampling are skipped while the
+// instrumentation toggle is basic. The pprof label is not: the goroutine
+// census and CPU attribution count by it whatever the toggle says.
+func Instrument(m *observability.Metrics, st *stats.Aggregator, reg *inflight.Registry, rt *toggles.Runtime, endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
@@ -29,21 +52,66 @@ func Instrument(m *observability.Metrics, endpoint string, next gin.HandlerFunc)
 		ctx, span := tr.Start(ctx, "HTTP "+endpoint)
 		defer span.End()
 
-		c.Request = c.Request.WithContext(ctx)
+		// Handlers run under a context the admin API can cancel; ctx itself
+		// stays the server's, so client disconnects are still told apart below.
+		reqCtx, done := reg.Start(ctx, GetRequestID(c), endpoint)
+		defer done()
+		full := rt.Instrumentation.Is(toggles.InstrumentationFull)
+		if full {
+			reqCtx = ctxaudit.Start(reqCtx, GetRequestID(c))
+		}
+		c.Request = c.Request.WithContext(reqCtx)
 
 		start := time.Now()
-		next(c)
-		elapsedMs := float64(time.Since(start).Milliseconds())
+		var endAllocs func(context.Context, string)
+		if full {
+			endAllocs = m.BeginAllocs()
+		}
+		// The labelled context goes to the handler too, so calls handed to
+		// long-lived goroutines (the /pool workers) can take the label along.
+		cpuprof.Do(reqCtx, endpoint, func(lctx context.Context) {
+			c.Request = c.Request.WithContext(lctx)
+			next(c)
+		})
+		if endAllocs != nil {
+			endAllocs(ctx, endpoint)
+		}
+		elapsed := time.Since(start)
+		elapsedMs := float64(elapsed.Milliseconds())
+
+		// Canary probes stay out of the in-process stats so /stats reflects real load.
+		canaryReq := c.GetHeader(canary.Header) != ""
+		if !canaryReq {
+			st.Observe(endpoint, c.Writer.Status(), elapsed)
+		}
 
+		inflight.Finish(reqCtx, c.Writer.Status(), c.GetString(apperr.ReasonKey))
 		status := strconv.Itoa(c.Writer.Status())
 
-		// Attach endpoint and status labels to metrics.
-		attrs := metric.WithAttributes(
+		// Attach endpoint and status labels to metrics, plus the cancellation
+		// reason when the handler reported one.
+		kv := []attribute.KeyValue{
 			attribute.String("endpoint", endpoint),
 			attribute.String("status", status),
-		)
+		}
+		if reason := c.GetString(apperr.ReasonKey); reason != "" {
+			kv = append(kv, attribute.String("cancel_cause", reason))
+		}
+		if variant := c.GetString(VariantKey); variant != "" {
+			kv = append(kv, attribute.String("variant", variant))
+			span.SetAttributes(attribute.String("variant", variant))
+		}
+		if canaryReq {
+			kv = append(kv, attribute.Bool("canary", true))
+		}
+		attrs := metric.WithAttributes(kv...)
 
 		m.HTTPRequestsTotal.Add(ctx, 1, attrs)
 		m.HTTPRequestDuration.Record(ctx, elapsedMs, attrs)
+
+		// Client disconnects are counted apart from server-side timeouts.
+		if apperr.ClientDisconnected(ctx) {
+			m.ClientDisconnects.Add(ctx, 1, metric.WithAttributes(attribute.String("endpoint", endpoint)))
+		}
 	}
 }
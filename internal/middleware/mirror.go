@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/mirror"
+)
+
+// Mirror offers every request to mr for shadowing before handling it.
+// Queueing a copy never blocks the request.
+func Mirror(mr *mirror.Mirror) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mr.Offer(c.Request, GetRequestID(c))
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-routine-stress/internal/services"
+)
+
+// SimScript applies the X-Sim-Script header, when present, to the request's
+// simulated service calls; a malformed script is rejected with 400.
+func SimScript() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spec := c.GetHeader(services.ScriptHeader)
+		if spec == "" {
+			c.Next()
+			return
+		}
+		s, err := services.ParseScript(spec)
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s: %v", services.ScriptHeader, err)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(services.WithScript(c.Request.Context(), s))
+		c.Next()
+	}
+}
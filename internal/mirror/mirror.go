@@ -0,0 +1,163 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/httpphase"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/services"
+)
+
+// Header marks mirrored requests with the ID of the original. A mirror never
+// copies a request carrying it, so two instances shadowing each other cannot
+// loop.
+const Header = "X-Mirrored-From"
+
+// credentialHeaders are never copied: the shadow is a different, often less
+// trusted deployment, and must not receive the client's credentials.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key"}
+
+// Mirror copies a share of incoming requests to a shadow target. Copies go
+// through a bounded queue drained by a fixed set of workers and their
+// responses are discarded: when the shadow falls behind, copies are dropped
+// instead of holding up real traffic or growing without bound.
+type Mirror struct {
+	target  string // base URL the request path is appended to
+	pct     float64
+	rng     services.RNG
+	maxBody int64
+	workers int
+	client  *http.Client
+	m       *observability.Metrics
+	queue   chan *http.Request
+}
+
+// New creates a mirror sending pct percent of requests to target, with up to
+// queue copies waiting for one of workers. Requests with a body larger than
+// maxBody bytes are not mirrored. Requests are sampled with rng.
+func New(target string, pct float64, rng services.RNG, maxBody int64, queue, workers int, timeout time.Duration, m *observability.Metrics) *Mirror {
+	mr := &Mirror{
+		target:  strings.TrimSuffix(target, "/"),
+		pct:     pct,
+		rng:     rng,
+		maxBody: maxBody,
+		workers: workers,
+		client:  &http.Client{Timeout: timeout},
+		m:       m,
+		queue:   make(chan *http.Request, queue),
+	}
+	m.TrackMirrorQueue(func() int { return len(mr.queue) })
+	return mr
+}
+
+// Offer samples r and, if picked, queues a copy of it. The method, URL,
+// headers without credentials and body are copied; the copy gets an ID of its
+// own on the shadow. The body is read into memory, up to maxBody, and r is
+// left reading it in full as if untouched; a request with a larger body is
+// not mirrored (too_large), a copy without it would not be faithful.
+func (mr *Mirror) Offer(r *http.Request, requestID string) {
+	if r.Header.Get(Header) != "" || services.RNGFor(r.Context(), services.SaltMirror, mr.rng).Float64()*100 >= mr.pct {
+		return
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, mr.maxBody+1))
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err != nil {
+			mr.count(r.Context(), "failed")
+			return
+		}
+		if int64(len(body)) > mr.maxBody {
+			mr.count(r.Context(), "too_large")
+			return
+		}
+	}
+
+	// Detached from the original: the copy must not die with its request.
+	req, err := http.NewRequestWithContext(context.Background(), r.Method, mr.target+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		mr.count(r.Context(), "failed")
+		return
+	}
+	req.Header = r.Header.Clone()
+	for _, h := range credentialHeaders {
+		req.Header.Del(h)
+	}
+	req.Header.Del("X-Request-ID")
+	req.Header.Set(Header, requestID)
+
+	select {
+	case mr.queue <- req:
+	default:
+		mr.count(r.Context(), "dropped")
+	}
+}
+
+// Run starts the workers and returns once ctx is done and each has finished
+// its current copy. Copies still queued are abandoned.
+func (mr *Mirror) Run(ctx context.Context) {
+	done := make([]<-chan struct{}, mr.workers)
+	for i := range done {
+		done[i] = safego.Background(ctx, "mirror.worker", mr.work)
+	}
+	for _, d := range done {
+		<-d
+	}
+}
+
+func (mr *Mirror) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-mr.queue:
+			mr.send(ctx, req)
+		}
+	}
+}
+
+func (mr *Mirror) send(ctx context.Context, req *http.Request) {
+	spanCtx, span := observability.StartHTTPClientSpan(ctx, "shadow", req)
+	traceCtx, phases := httpphase.Start(spanCtx)
+	start := time.Now()
+	resp, err := mr.client.Do(req.WithContext(traceCtx))
+	result := "sent"
+	status := 0
+	if err != nil {
+		result = "failed"
+	} else {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status >= 500 {
+			result = "failed"
+		}
+	}
+	phases.Done()
+	for _, p := range phases.Phases() {
+		mr.m.RecordPhase(spanCtx, "shadow", p.Name, p.Duration)
+	}
+	observability.EndHTTPClientSpan(span, status, err)
+	mr.m.MirrorDuration.Record(ctx, float64(time.Since(start).Milliseconds()), metric.WithAttributes(attribute.String("result", result)))
+	mr.count(ctx, result)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (mr *Mirror) count(ctx context.Context, result string) {
+	mr.m.MirrorRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
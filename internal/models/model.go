@@ -1,6 +1,13 @@
 package models
 
-import "go-routine-stress/internal/services"
+import (
+	"time"
+
+	"go-routine-stress/internal/buildinfo"
+	"go-routine-stress/internal/preflight"
+	"go-routine-stress/internal/services"
+	"go-routine-stress/internal/timeline"
+)
 
 // CombinedResponse is returned by all endpoints on success.
 type CombinedResponse struct {
@@ -8,11 +15,446 @@ type CombinedResponse struct {
 	ServiceBData services.ServiceBData `json:"serviceBData"`
 	Mode         string                `json:"mode"`
 	TotalMs      int64                 `json:"totalMs"`
+
+	// Degraded is set when part of the response came from fallback data.
+	Degraded bool `json:"degraded,omitempty"`
+
+	// Variant is the fan-out implementation that served the request, when
+	// traffic splitting is on.
+	Variant string `json:"variant,omitempty"`
+
+	// Partial is set by /partial when one service failed: its data is then
+	// empty, and Errors holds the failure, by service ("A", "B").
+	Partial bool                   `json:"partial,omitempty"`
+	Errors  map[string]ErrorDetail `json:"errors,omitempty"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// CombinedResponseV2 is CombinedResponse as served under /v2: the same
+// result, plus how each mechanism took part in it.
+type CombinedResponseV2 struct {
+	CombinedResponse
+	Report RequestReport `json:"report"`
+}
+
+// ErrorResponseV2 is ErrorResponse as served under /v2.
+type ErrorResponseV2 struct {
+	ErrorResponse
+	Report RequestReport `json:"report"`
+}
+
+// RequestReport tells how a /v2 request was served, so outcomes can be
+// sliced by mechanism and not only by total latency.
+type RequestReport struct {
+	// Services holds each dependency called, by the name calls are known by
+	// in metrics and traces ("A", "B", "B/replica-1"). Losing hedges or race
+	// calls still winding down when the response is written are left out.
+	Services map[string]ServiceReport `json:"services"`
+
+	// QueueWaitMs is the time spent waiting in queues, admitted or not;
+	// Queues splits it by queue ("shed", "serviceB_semaphore").
+	QueueWaitMs int64            `json:"queueWaitMs"`
+	Queues      map[string]int64 `json:"queues"`
+
+	// Coalesced is set when the result was shared with identical requests;
+	// only the request whose execution produced it reports the calls.
+	Coalesced bool `json:"coalesced"`
+	// Fallback is where fallback data came from ("cache", "default"), if any was served.
+	Fallback string `json:"fallback,omitempty"`
+	// BudgetExceeded is set when a time budget ran out: Service B's retry
+	// budget, the chain's, or the handler deadline.
+	BudgetExceeded bool `json:"budgetExceeded"`
+}
+
+// ServiceReport sums up the calls made to one dependency.
+type ServiceReport struct {
+	// Attempts counts calls, retries and hedges included; Ms adds up their
+	// durations, so overlapping attempts can exceed the wall time.
+	Attempts int   `json:"attempts"`
+	Failures int   `json:"failures"`
+	Ms       int64 `json:"ms"`
 }
 
 // ErrorResponse is returned by all endpoints on failure.
 type ErrorResponse struct {
-	Mode    string `json:"mode"`
-	TotalMs int64  `json:"totalMs"`
+	Mode    string      `json:"mode"`
+	TotalMs int64       `json:"totalMs"`
+	Error   ErrorDetail `json:"error"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// ErrorDetail describes a failure in machine-readable form.
+type ErrorDetail struct {
+	Code          string   `json:"code"`
+	Message       string   `json:"message"`
+	Retryable     bool     `json:"retryable"`
+	Dependency    string   `json:"dependency,omitempty"`
+	Causes        []string `json:"causes,omitempty"`
+	CancelCause   string   `json:"cancelCause,omitempty"`
+	TraceID       string   `json:"traceId,omitempty"`
+	CorrelationID string   `json:"correlationId,omitempty"`
+
+	// Queue is set when the request failed while waiting in a queue.
+	Queue *QueueDetail `json:"queue,omitempty"`
+	// Progress is set when stepped work was cancelled part way through.
+	Progress *ProgressDetail `json:"progress,omitempty"`
+	// Budgets is set when a request splitting its deadline between calls
+	// failed: the calls made so far, the failed one last.
+	Budgets []CallBudget `json:"budgets,omitempty"`
+}
+
+// CallBudget is the share of a request's deadline one call was allocated,
+// and how much of it the call consumed.
+type CallBudget struct {
+	Service     string `json:"service"`
+	AllocatedMs int64  `json:"allocatedMs"`
+	ConsumedMs  int64  `json:"consumedMs"`
+}
+
+// ProgressDetail is how many steps of a stepped computation completed before
+// it was cancelled.
+type ProgressDetail struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// QueueDetail tells a client where it stood in the queue it failed in, so it
+// can back off by real signals.
+type QueueDetail struct {
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	WaitedMs int64  `json:"waitedMs"`
+	// EstimatedWaitMs is how long the requests ahead would take at the
+	// current service rate; 0 when the rate is unknown.
+	EstimatedWaitMs int64 `json:"estimatedWaitMs"`
+}
+
+// CompareResponse is returned by /compare.
+type CompareResponse struct {
+	Seed    int64           `json:"seed"`
+	Results []CompareResult `json:"results"`
+	TotalMs int64           `json:"totalMs"`
+}
+
+// CompareResult is one mode's run within a /compare request.
+type CompareResult struct {
+	Mode          string                `json:"mode"`
+	Status        int                   `json:"status"`
+	TotalMs       int64                 `json:"totalMs"`
+	SpeedupVsSync float64               `json:"speedupVsSync,omitempty"`
+	ServiceAData  services.ServiceAData `json:"serviceAData"`
+	ServiceBData  services.ServiceBData `json:"serviceBData"`
+	Error         string                `json:"error,omitempty"`
+}
+
+// QuorumResponse is returned by /quorum.
+type QuorumResponse struct {
+	M int `json:"m"`
+	N int `json:"n"`
+
+	// Replies are the first N successful replies, in arrival order.
+	Replies []services.ServiceBData `json:"replies"`
+	Failed  int                     `json:"failed"`
+
+	// Failures are the replicas that failed before the quorum was reached,
+	// tolerated as long as N could still succeed.
+	Failures []QuorumFailure `json:"failures"`
+
+	// Outstanding replicas when the quorum was reached, cancelled unless ?cancel=false.
+	Outstanding int   `json:"outstanding"`
+	TotalMs     int64 `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// QuorumFailure is one failed replica call of a /quorum request.
+type QuorumFailure struct {
+	Replica string `json:"replica"`
+	Code    string `json:"code"`
 	Error   string `json:"error"`
+	// Ms is when the failure arrived, from the start of the request.
+	Ms int64 `json:"ms"`
+}
+
+// FanoutResponse is returned by /fanout.
+type FanoutResponse struct {
+	N      int `json:"n"`
+	CallsA int `json:"callsA"`
+	CallsB int `json:"callsB"`
+
+	// Goroutines is how many the request started, one per call;
+	// PeakGoroutines how many of them were running at once.
+	Goroutines     int `json:"goroutines"`
+	PeakGoroutines int `json:"peakGoroutines"`
+
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Failures counts the failed calls by error code.
+	Failures map[string]int `json:"failures"`
+	TotalMs  int64          `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// StreamLine is one /stream NDJSON line: a service result, written the
+// moment it arrived.
+type StreamLine struct {
+	Service   string       `json:"service"`
+	Data      any          `json:"data,omitempty"`
+	Error     *ErrorDetail `json:"error,omitempty"`
+	ElapsedMs int64        `json:"elapsedMs"`
+}
+
+// StreamSummary is the last /stream NDJSON line.
+type StreamSummary struct {
+	Done        bool   `json:"done"`
+	Mode        string `json:"mode"`
+	FirstByteMs int64  `json:"firstByteMs"`
+	TotalMs     int64  `json:"totalMs"`
+	Succeeded   int    `json:"succeeded"`
+	Failed      int    `json:"failed"`
+	// Dropped counts the result lines the client's send buffer discarded.
+	Dropped int `json:"dropped"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// OrderedFanInResponse is returned by /ordered-fanin.
+type OrderedFanInResponse struct {
+	N      int `json:"n"`
+	Window int `json:"window"`
+	CallsA int `json:"callsA"`
+	CallsB int `json:"callsB"`
+
+	// Results are in submission order, whatever order the calls completed in.
+	Results []OrderedResult `json:"results"`
+	// Reordered counts the calls that completed before an earlier one;
+	// MaxBuffered is the most completed results held at once, waiting for
+	// their turn.
+	Reordered   int `json:"reordered"`
+	MaxBuffered int `json:"maxBuffered"`
+
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Failures counts the failed calls by error code.
+	Failures map[string]int `json:"failures"`
+	TotalMs  int64          `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// OrderedResult is one /ordered-fanin call.
+type OrderedResult struct {
+	Index   int    `json:"index"`
+	Service string `json:"service"`
+	// Completed is the call's rank in completion order, from 0.
+	Completed int    `json:"completed"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ScatterResponse is returned by /scatter.
+type ScatterResponse struct {
+	Shards int `json:"shards"`
+	// Gather is the strategy the replies were collected with.
+	Gather string `json:"gather"`
+
+	// Results are the shards that answered, in arrival order.
+	Results []ShardResult `json:"results"`
+	// Partial is set when any shard is missing from Results.
+	Partial bool `json:"partial"`
+
+	// TimedOut are the shards cut off by the per-shard timeout (shard_timeout)
+	// or by the overall deadline (handler_timeout); Failed those that failed.
+	TimedOut []ShardMiss `json:"timedOut"`
+	Failed   []ShardMiss `json:"failed"`
+	TotalMs  int64       `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// ShardResult is one shard's answer to a /scatter request.
+type ShardResult struct {
+	Shard string `json:"shard"`
+	Value string `json:"value"`
+	Ms    int64  `json:"ms"`
+}
+
+// ShardMiss is a shard missing from a /scatter response.
+type ShardMiss struct {
+	Shard string `json:"shard"`
+	// Cause is the cancel cause of a timed-out shard, Code the error code of a failed one.
+	Cause string `json:"cause,omitempty"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+	Ms    int64  `json:"ms"`
+}
+
+// PipelineResponse is returned by /pipeline.
+type PipelineResponse struct {
+	// Values are the aggregated items, in the order they were fetched.
+	Values  []string        `json:"values"`
+	Stages  []PipelineStage `json:"stages"`
+	TotalMs int64           `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// PipelineStage is how one /pipeline stage spent the request. A stage slower
+// than the next one shows up as WaitMs downstream; one faster than the next
+// fills the buffer in between and shows up as its own BlockedMs.
+type PipelineStage struct {
+	Name  string `json:"name"`
+	Items int    `json:"items"`
+	// Buffer is the capacity of the channel feeding the stage; absent for fetch.
+	Buffer *int  `json:"buffer,omitempty"`
+	BusyMs int64 `json:"busyMs"`
+	// WaitMs is time spent waiting for input, BlockedMs waiting for room downstream.
+	WaitMs    int64 `json:"waitMs"`
+	BlockedMs int64 `json:"blockedMs"`
+}
+
+// ComputeResponse is returned by /compute when every step completed.
+type ComputeResponse struct {
+	Steps          int `json:"steps"`
+	StepIterations int `json:"stepIterations"`
+	// Digest is the hex SHA-256 the last step produced.
+	Digest  string `json:"digest"`
+	TotalMs int64  `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// SyncBudgetResponse is returned by /sync-budget when both calls succeeded.
+type SyncBudgetResponse struct {
+	ServiceAData services.ServiceAData `json:"serviceAData"`
+	ServiceBData services.ServiceBData `json:"serviceBData"`
+	// DeadlineMs is the request deadline split between the calls, and APct
+	// the share of it Service A was allocated.
+	DeadlineMs int64        `json:"deadlineMs"`
+	APct       int          `json:"aPct"`
+	Budgets    []CallBudget `json:"budgets"`
+	TotalMs    int64        `json:"totalMs"`
+
+	// Debug is the execution timeline, present only in debug mode.
+	Debug []timeline.Event `json:"debug,omitempty"`
+}
+
+// StatusResponse is returned by /status: the one-stop rollup to check first
+// during an incident.
+type StatusResponse struct {
+	// Status is "ok", "degraded" (alerts firing, brownout active, a limiter
+	// saturated or a backend unreachable at startup) or "draining".
+	Status    string         `json:"status"`
+	At        time.Time      `json:"at"`
+	UptimeSec float64        `json:"uptimeSec"`
+	Build     buildinfo.Info `json:"build"`
+
+	Dependencies  []DependencyStatus `json:"dependencies"`
+	Saturation    []Saturation       `json:"saturation"`
+	BrownoutLevel int64              `json:"brownoutLevel"`
+	FiringAlerts  []string           `json:"firingAlerts"`
+
+	// Preflight is how the real backends answered at startup.
+	Preflight []preflight.Result `json:"preflight,omitempty"`
+}
+
+// DependencyStatus is one dependency's health over the stats window. Calls
+// cancelled by the caller are left out of the error rate.
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Calls     int64   `json:"calls"`
+	ErrorRate float64 `json:"errorRate"`
+	P99Ms     float64 `json:"p99Ms"`
+
+	// Health score from /smart routing and outlier ejection from /balanced,
+	// for the instances those modes use.
+	HealthScore *float64 `json:"healthScore,omitempty"`
+	Ejected     *bool    `json:"ejected,omitempty"`
+}
+
+// Saturation is the occupancy of one queue, semaphore or budget.
+type Saturation struct {
+	Name        string  `json:"name"`
+	InUse       int64   `json:"inUse"`
+	Capacity    int64   `json:"capacity"`
+	Waiting     int64   `json:"waiting"`
+	Utilization float64 `json:"utilization"`
+}
+
+// VersionResponse is returned by /version.
+type VersionResponse struct {
+	Build buildinfo.Info `json:"build"`
+
+	// Modes lists the concurrency mode endpoints this binary serves.
+	Modes []string `json:"modes"`
+	// V2Modes lists those also served under /v2, with a per-request report.
+	V2Modes []string `json:"v2Modes"`
+
+	// Features reports which optional subsystems are enabled in this run.
+	Features map[string]bool `json:"features"`
+}
+
+// TopologyResponse is returned by /topology: the dependency graph the server
+// is currently configured with, from the server itself down to the deepest
+// chain node.
+type TopologyResponse struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode is one service in the graph. IDs are the names calls are
+// known by in metrics and traces: "A", "B", "B/primary", "B>C1".
+type TopologyNode struct {
+	ID string `json:"id"`
+
+	// Kind is "server", "service", "instance", "replica", "shard", "region" or "chain".
+	Kind string `json:"kind"`
+
+	// Profile is the simulated behavior, as "min-max:errorRate".
+	Profile string `json:"profile,omitempty"`
+
+	// Count is how many identical nodes this one stands for, when more than one.
+	Count int `json:"count,omitempty"`
+
+	Limits []TopologyLimit `json:"limits,omitempty"`
+}
+
+// TopologyEdge is a call from one node to another.
+type TopologyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Modes lists the server endpoints making the call; empty for calls
+	// between downstream services.
+	Modes []string `json:"modes,omitempty"`
+
+	Limits []TopologyLimit `json:"limits,omitempty"`
+}
+
+// TopologyLimit is one limit applied to a node or to the calls on an edge.
+type TopologyLimit struct {
+	Name   string `json:"name"`
+	Max    int64  `json:"max,omitempty"`
+	Ms     int64  `json:"ms,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// AdminConfig is the runtime-tunable configuration served by /admin/config.
+// Version moves with every change, whichever endpoint made it.
+type AdminConfig struct {
+	Version        uint64            `json:"version"`
+	HedgeBudgetPct int               `json:"hedgeBudgetPct"`
+	Toggles        map[string]string `json:"toggles"`
 }
@@ -0,0 +1,68 @@
+package observability
+
+import (
+	"context"
+	"runtime/metrics"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// allocSampler measures heap allocation deltas around a sampled subset of
+// requests. The runtime only counts allocations process-wide, so a delta also
+// includes whatever concurrent requests allocated; samples taken while the
+// request ran alone are marked exclusive.
+type allocSampler struct {
+	every   atomic.Int64 // sample one request in every; 0 = off
+	seq     atomic.Int64
+	started atomic.Int64 // requests begun, to detect overlap
+	active  atomic.Int64
+}
+
+var allocSamples = []string{"/gc/heap/allocs:bytes", "/gc/heap/allocs:objects"}
+
+func readAllocs() (bytes, objects uint64) {
+	s := []metrics.Sample{{Name: allocSamples[0]}, {Name: allocSamples[1]}}
+	metrics.Read(s)
+	return s[0].Value.Uint64(), s[1].Value.Uint64()
+}
+
+func (m *Metrics) registerAllocMetrics(meter metric.Meter) error {
+	var err error
+	m.RequestAllocBytes, err = meter.Int64Histogram("request_alloc_bytes")
+	if err != nil {
+		return err
+	}
+	m.RequestAllocObjects, err = meter.Int64Histogram("request_alloc_objects")
+	return err
+}
+
+// SampleAllocs measures one request in every n (0 disables sampling).
+func (m *Metrics) SampleAllocs(every int) {
+	m.allocs.every.Store(int64(every))
+}
+
+// BeginAllocs starts measuring a request's allocations if it is sampled. The
+// returned func records the deltas for endpoint; it is a no-op otherwise.
+func (m *Metrics) BeginAllocs() func(ctx context.Context, endpoint string) {
+	s := m.allocs
+	started := s.started.Add(1)
+	active := s.active.Add(1)
+
+	every := s.every.Load()
+	if every <= 0 || s.seq.Add(1)%every != 0 {
+		return func(context.Context, string) { s.active.Add(-1) }
+	}
+
+	bytes0, objects0 := readAllocs()
+	return func(ctx context.Context, endpoint string) {
+		bytes1, objects1 := readAllocs()
+		exclusive := active == 1 && s.started.Load() == started
+		s.active.Add(-1)
+
+		attrs := metric.WithAttributes(attribute.String("endpoint", endpoint), attribute.Bool("exclusive", exclusive))
+		m.RequestAllocBytes.Record(ctx, int64(bytes1-bytes0), attrs)
+		m.RequestAllocObjects.Record(ctx, int64(objects1-objects0), attrs)
+	}
+}
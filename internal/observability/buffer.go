@@ -0,0 +1,301 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExportBuffer is the transport of the OTLP exporters. When the collector
+// cannot take an export (unreachable, or answering 429/502/503/504), the
+// export is kept in a bounded in-memory queue instead of being lost, and the
+// exporter is told it succeeded so it does not retry on its own. Queued
+// exports go out, oldest first, ahead of the next export once the collector
+// answers again. Past the byte limit the oldest are dropped.
+//
+// No lock is held while an export is on the network, so one slow collector
+// round trip does not hold up the other exporters: one export at a time
+// replays the queue, and those arriving meanwhile join its back.
+type ExportBuffer struct {
+	next     http.RoundTripper
+	maxBytes int
+
+	mu       sync.Mutex
+	queue    []bufferedExport
+	bytes    int
+	down     bool
+	flushing bool // an export is replaying the queue
+	signals  map[string]*ExportBufferStats
+}
+
+// ExportBufferStats counts one signal's exports through an ExportBuffer.
+type ExportBufferStats struct {
+	Queued  int   `json:"queued"`
+	Flushed int64 `json:"flushed"`
+	Dropped int64 `json:"dropped"`
+}
+
+type bufferedExport struct {
+	signal string
+	req    *http.Request // without body
+	body   []byte
+}
+
+// exportTimeout bounds each attempt at sending one export.
+const exportTimeout = 10 * time.Second
+
+// NewExportBuffer creates a buffer keeping up to maxBytes of exports.
+func NewExportBuffer(maxBytes int) *ExportBuffer {
+	return &ExportBuffer{next: http.DefaultTransport, maxBytes: maxBytes, signals: make(map[string]*ExportBufferStats)}
+}
+
+// Client returns the HTTP client exporters send through the buffer.
+func (b *ExportBuffer) Client() *http.Client {
+	return &http.Client{Transport: b}
+}
+
+// RoundTrip sends req, or queues it behind the exports already queued and
+// replays them. An export the collector cannot take is queued and answered
+// with 200.
+func (b *ExportBuffer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	exp := bufferedExport{signal: signalOf(req.URL.Path), req: req, body: body}
+
+	b.mu.Lock()
+	if len(b.queue) > 0 || b.flushing {
+		// Sending it now would overtake older exports.
+		b.enqueueLocked(exp)
+		replay := !b.flushing
+		b.flushing = true
+		b.mu.Unlock()
+		if replay {
+			b.flush(req.Context())
+		}
+		return b.ok(req), nil
+	}
+	b.mu.Unlock()
+
+	resp, err := b.send(req, body)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !answered(resp, err) {
+		b.enqueueLocked(exp)
+		b.downLocked(resp, err)
+		return b.ok(req), nil
+	}
+	if len(b.queue) == 0 {
+		// Otherwise exports queued while this one was out go with the next.
+		b.upLocked()
+	}
+	return resp, nil
+}
+
+// flush sends the queued exports oldest first, until the queue is empty or
+// the collector fails to take one, which goes back to the front. The caller
+// must have set b.flushing, which flush clears.
+func (b *ExportBuffer) flush(ctx context.Context) {
+	for {
+		b.mu.Lock()
+		if len(b.queue) == 0 {
+			b.flushing = false
+			b.upLocked()
+			b.mu.Unlock()
+			return
+		}
+		queued := b.queue[0]
+		b.queue = b.queue[1:]
+		b.bytes -= len(queued.body)
+		b.statsLocked(queued.signal).Queued--
+		b.mu.Unlock()
+
+		resp, err := b.send(queued.req.WithContext(ctx), queued.body)
+		if answered(resp, err) {
+			drain(resp)
+		}
+
+		b.mu.Lock()
+		if !answered(resp, err) {
+			b.requeueLocked(queued)
+			b.downLocked(resp, err)
+			b.flushing = false
+			b.mu.Unlock()
+			return
+		}
+		st := b.statsLocked(queued.signal)
+		if resp.StatusCode <= 299 {
+			st.Flushed++
+		} else {
+			st.Dropped++
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Down reports whether the last export failed to reach the collector.
+func (b *ExportBuffer) Down() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.down
+}
+
+// Stats returns the counts per signal ("metrics", "traces").
+func (b *ExportBuffer) Stats() map[string]ExportBufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]ExportBufferStats, len(b.signals))
+	for signal, st := range b.signals {
+		out[signal] = *st
+	}
+	return out
+}
+
+// Pending returns the exports still queued and their size.
+func (b *ExportBuffer) Pending() (exports, bytes int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue), b.bytes
+}
+
+// send makes one attempt at an export, within exportTimeout.
+func (b *ExportBuffer) send(req *http.Request, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), exportTimeout)
+	req = req.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	resp, err := b.next.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+// answered reports whether the collector dealt with an export: it accepted
+// it, or rejected its content, which sending it again would not fix.
+func answered(resp *http.Response, err error) bool {
+	return err == nil && !retryable(resp.StatusCode)
+}
+
+// enqueueLocked queues exp, dropping the oldest exports to stay within
+// maxBytes; an export larger than maxBytes is dropped itself.
+func (b *ExportBuffer) enqueueLocked(exp bufferedExport) {
+	if len(exp.body) > b.maxBytes {
+		b.statsLocked(exp.signal).Dropped++
+		return
+	}
+	exp.req = exp.req.Clone(context.Background())
+	exp.req.Body = nil
+	b.queue = append(b.queue, exp)
+	b.bytes += len(exp.body)
+	b.statsLocked(exp.signal).Queued++
+	b.trimLocked()
+}
+
+// requeueLocked puts back at the front an export taken out to be replayed.
+// Exports queued while it was on the network may have filled its room, in
+// which case it is the oldest and the one dropped.
+func (b *ExportBuffer) requeueLocked(exp bufferedExport) {
+	b.queue = append([]bufferedExport{exp}, b.queue...)
+	b.bytes += len(exp.body)
+	b.statsLocked(exp.signal).Queued++
+	b.trimLocked()
+}
+
+// trimLocked drops the oldest exports past maxBytes.
+func (b *ExportBuffer) trimLocked() {
+	for b.bytes > b.maxBytes {
+		oldest := b.queue[0]
+		b.queue = b.queue[1:]
+		b.bytes -= len(oldest.body)
+		st := b.statsLocked(oldest.signal)
+		st.Queued--
+		st.Dropped++
+	}
+}
+
+func (b *ExportBuffer) downLocked(resp *http.Response, err error) {
+	if err == nil {
+		drain(resp)
+	}
+	if b.down {
+		return
+	}
+	b.down = true
+	reason := "connection failed"
+	switch {
+	case err != nil:
+		reason = err.Error()
+	case resp != nil:
+		reason = resp.Status
+	}
+	log.Printf("telemetry: collector unavailable (%s), buffering up to %d KiB of exports", reason, b.maxBytes>>10)
+}
+
+func (b *ExportBuffer) upLocked() {
+	if b.down {
+		b.down = false
+		log.Printf("telemetry: collector reachable again, buffered exports flushed")
+	}
+}
+
+func (b *ExportBuffer) statsLocked(signal string) *ExportBufferStats {
+	st, ok := b.signals[signal]
+	if !ok {
+		st = &ExportBufferStats{}
+		b.signals[signal] = st
+	}
+	return st
+}
+
+// ok is the response for an export that was queued.
+func (b *ExportBuffer) ok(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// retryable reports whether the OTLP spec lets a client retry after code.
+func retryable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// signalOf names the signal an OTLP path carries: /v1/metrics → "metrics".
+func signalOf(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,99 @@
+package observability
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// connTracker follows every server connection through its ConnState
+// transitions so open/active/idle/hijacked counts and lifetimes can be exported.
+type connTracker struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]connInfo
+	counts map[http.ConnState]int64
+}
+
+type connInfo struct {
+	state  http.ConnState
+	opened time.Time
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]connInfo), counts: make(map[http.ConnState]int64)}
+}
+
+func (m *Metrics) registerConnMetrics(meter metric.Meter) error {
+	var err error
+	m.ConnsOpened, err = meter.Int64Counter("http_connections_opened_total")
+	if err != nil {
+		return err
+	}
+	m.ConnLifetime, err = meter.Float64Histogram("http_connection_lifetime_ms")
+	if err != nil {
+		return err
+	}
+
+	// http_connections gauge reports the open connections per state. Hijacked
+	// connections are no longer visible to the server, so that state is the
+	// running total handed off.
+	_, err = meter.Int64ObservableGauge("http_connections",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			m.conns.mu.Lock()
+			defer m.conns.mu.Unlock()
+			for _, st := range []http.ConnState{http.StateNew, http.StateActive, http.StateIdle, http.StateHijacked} {
+				obs.Observe(m.conns.counts[st], metric.WithAttributes(attribute.String("state", st.String())))
+			}
+			return nil
+		}),
+	)
+	return err
+}
+
+// ConnState is an http.Server ConnState hook. Connections leave the tracked
+// set when closed or hijacked (e.g. by a WebSocket upgrade); their lifetime
+// is recorded with the final state.
+func (m *Metrics) ConnState(c net.Conn, state http.ConnState) {
+	now := time.Now()
+	t := m.conns
+
+	t.mu.Lock()
+	info, known := t.conns[c]
+	if known {
+		t.counts[info.state]--
+	}
+
+	var lifetime time.Duration
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.conns, c)
+		if state == http.StateHijacked {
+			t.counts[state]++
+		}
+		if known {
+			lifetime = now.Sub(info.opened)
+		}
+	default:
+		if !known {
+			info.opened = now
+		}
+		info.state = state
+		t.conns[c] = info
+		t.counts[state]++
+	}
+	t.mu.Unlock()
+
+	ctx := context.Background()
+	switch {
+	case state == http.StateNew:
+		m.ConnsOpened.Add(ctx, 1)
+	case lifetime > 0:
+		m.ConnLifetime.Record(ctx, float64(lifetime.Milliseconds()),
+			metric.WithAttributes(attribute.String("end", state.String())))
+	}
+}
@@ -4,52 +4,881 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Metrics groups all metric instruments in one place.
 type Metrics struct {
 	HTTPRequestsTotal   metric.Int64Counter
 	HTTPRequestDuration metric.Float64Histogram
+	ClientDisconnects   metric.Int64Counter
 
 	ServiceDuration metric.Float64Histogram
 	ServiceErrors   metric.Int64Counter
+	PhaseDuration   metric.Float64Histogram
 
-	SemWaitB metric.Float64Histogram
+	SemWaitA       metric.Float64Histogram
+	SemWaitB       metric.Float64Histogram
+	SemStarvedB    metric.Int64Counter
+	SemInversionsB metric.Int64Counter
+
+	AbandonedWaits        metric.Int64Counter
+	AbandonedWaitDuration metric.Float64Histogram
+
+	SchedulerTaskRuns     metric.Int64Counter
+	SchedulerTaskDuration metric.Float64Histogram
+
+	CanaryRequests metric.Int64Counter
+	CanaryDuration metric.Float64Histogram
+
+	ShedRequests metric.Int64Counter
+
+	RetryAttempts        metric.Int64Counter
+	RetryBudgetExhausted metric.Int64Counter
+
+	FallbackRefreshes metric.Int64Counter
+	FallbackServedAge metric.Float64Histogram
+	// FallbackActivations counts failed calls answered with fallback data instead.
+	FallbackActivations metric.Int64Counter
+
+	CoalescedRequests metric.Int64Counter
+
+	SmartSwitches metric.Int64Counter
+
+	OutlierEjections     metric.Int64Counter
+	BalancedCallDuration metric.Float64Histogram
+
+	FairQueueWait   metric.Float64Histogram
+	FairQueueGrants metric.Int64Counter
+
+	CostRejections metric.Int64Counter
+	RequestCost    metric.Int64Histogram
+
+	SlowWriteBytes    metric.Int64Counter
+	SlowWriteDuration metric.Float64Histogram
+
+	StreamFirstByte metric.Float64Histogram
+	StreamDuration  metric.Float64Histogram
+	StreamDropped   metric.Int64Counter
+
+	ConditionalRequests metric.Int64Counter
+
+	HedgesSuppressed metric.Int64Counter
+	HedgesFired      metric.Int64Counter
+	HedgesWon        metric.Int64Counter
+
+	BrownoutSkips metric.Int64Counter
+
+	QuorumDuration metric.Float64Histogram
+
+	RaceWastedCalls    metric.Int64Counter
+	RaceWastedDuration metric.Float64Histogram
+
+	PipelineStageDuration metric.Float64Histogram
+
+	FanoutWidth metric.Int64Histogram
+
+	OrderedFanInBuffered metric.Int64Histogram
+
+	ScatterResults       metric.Int64Counter
+	ScatterShardTimeouts metric.Int64Counter
+	ScatterGatherDelay   metric.Float64Histogram
+
+	PartialResults metric.Int64Counter
+
+	RegionCalls        metric.Int64Counter
+	RegionCallDuration metric.Float64Histogram
+	RegionFailovers    metric.Int64Counter
+
+	SingleflightCalls metric.Int64Counter
+
+	BreakerTransitions metric.Int64Counter
+	BreakerRejections  metric.Int64Counter
+
+	LifecycleStopDuration     metric.Float64Histogram
+	LifecycleShutdownDuration metric.Float64Histogram
+
+	BackgroundJobs          metric.Int64Counter
+	BackgroundCancellations metric.Int64Counter
+
+	SyncBudgetUsed metric.Float64Histogram
+
+	ComputeSteps         metric.Int64Counter
+	ComputeCancelLatency metric.Float64Histogram
+
+	CtxBreaks metric.Int64Counter
+
+	AdminActions metric.Int64Counter
+	Markers      metric.Int64Counter
+
+	DNSLookups         metric.Int64Counter
+	DNSResolveDuration metric.Float64Histogram
+
+	MirrorRequests metric.Int64Counter
+	MirrorDuration metric.Float64Histogram
+
+	IdempotencyRequests metric.Int64Counter
+
+	WorkerPoolWait metric.Float64Histogram
+
+	ConnPoolAcquires        metric.Int64Counter
+	ConnPoolAcquireDuration metric.Float64Histogram
+	ConnPoolClosed          metric.Int64Counter
+
+	ConnsOpened  metric.Int64Counter
+	ConnLifetime metric.Float64Histogram
+
+	EndpointCPU metric.Float64Counter
+
+	ProfileCaptures metric.Int64Counter
+
+	RequestAllocBytes   metric.Int64Histogram
+	RequestAllocObjects metric.Int64Histogram
+
+	// Heap allocation sampling around requests.
+	allocs *allocSampler
+
+	// Server connections by state, fed by the ConnState hook.
+	conns *connTracker
 
 	// Inflight is exported as an observable gauge per endpoint.
 	inflight sync.Map // map[string]*atomic.Int64
+
+	// Items waiting in /pipeline stage buffers, exported as an observable gauge per stage.
+	pipelineQueued sync.Map // map[string]*atomic.Int64
+
+	// Fallback cache ages are exported as an observable gauge per service.
+	ages sync.Map // map[string]func() (time.Duration, bool)
+
+	// Dependency health scores are exported as an observable gauge per service.
+	scores sync.Map // map[string]func() float64
+
+	// Mirror copies waiting to be sent, exported as an observable gauge.
+	mirrorQueue atomic.Pointer[func() int]
+
+	// Worker pool queue depth and busy share, exported as observable gauges.
+	poolQueue       atomic.Pointer[func() int]
+	poolWorkers     atomic.Pointer[func() int]
+	poolUtilization atomic.Pointer[func() float64]
+
+	// Telemetry export buffer counts per signal.
+	exportBuffer atomic.Pointer[func() map[string]ExportBufferStats]
+
+	// Client connection pool sizes are exported as an observable gauge per pool.
+	connPools sync.Map // map[string]func() (open, idle int)
+
+	// Slots held per tenant in the fair queue, exported as an observable gauge.
+	tenantSlots atomic.Pointer[func() map[string]int]
+
+	// Service B semaphore waiters past the starvation threshold, exported as an observable gauge.
+	starvingB atomic.Pointer[func() int]
+
+	// Token budget availability, exported as an observable gauge.
+	tokens atomic.Pointer[func() int64]
+
+	// Current shed limiter limits per endpoint, exported as an observable gauge.
+	shedLimits atomic.Pointer[func() map[string]int]
+
+	// Shed queue priority aging per endpoint and class.
+	shedQueues atomic.Pointer[func() map[string]map[string]ShedClassStats]
+
+	// Current brownout level, exported as an observable gauge.
+	brownout atomic.Pointer[func() int64]
+
+	// Service B breaker state, exported as an observable gauge.
+	breaker atomic.Pointer[func() int64]
+
+	// Spent share of the hedge budget, exported as an observable gauge.
+	hedgeBudget atomic.Pointer[func() float64]
+
+	// Apdex score per endpoint over the stats window, exported as an observable gauge.
+	apdex atomic.Pointer[func() map[string]float64]
+
+	// Latest goroutine census per endpoint label, exported as observable gauges.
+	goroutines atomic.Pointer[goroutineCensus]
+}
+
+// goroutineCensus is one goroutine count per endpoint, with the in-flight
+// requests of each endpoint at the time it was taken.
+type goroutineCensus struct {
+	counts   map[string]int
+	inflight map[string]int64
 }
 
-// NewMetrics creates all instruments and registers callbacks.
-func NewMetrics() (*Metrics, error) {
-	m := &Metrics{}
-	meter := otel.Meter("go-goroutine-lab/metrics")
+// NewMetrics creates all instruments and registers callbacks.
+func NewMetrics() (*Metrics, error) {
+	m := &Metrics{conns: newConnTracker(), allocs: &allocSampler{}}
+	meter := otel.Meter("go-goroutine-lab/metrics")
+
+	var err error
+
+	m.HTTPRequestsTotal, err = meter.Int64Counter("http_requests_total")
+	if err != nil {
+		return nil, err
+	}
+	m.HTTPRequestDuration, err = meter.Float64Histogram("http_request_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ClientDisconnects, err = meter.Int64Counter("client_disconnects_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ServiceDuration, err = meter.Float64Histogram("service_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.ServiceErrors, err = meter.Int64Counter("service_errors_total")
+	if err != nil {
+		return nil, err
+	}
+	m.PhaseDuration, err = meter.Float64Histogram("downstream_phase_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.SemWaitA, err = meter.Float64Histogram("serviceA_semaphore_wait_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.SemWaitB, err = meter.Float64Histogram("serviceB_semaphore_wait_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.SemStarvedB, err = meter.Int64Counter("serviceB_semaphore_starved_total")
+	if err != nil {
+		return nil, err
+	}
+	m.SemInversionsB, err = meter.Int64Counter("serviceB_semaphore_inversions_total")
+	if err != nil {
+		return nil, err
+	}
+
+	// Waits given up because the request's context ended, and the time they wasted.
+	m.AbandonedWaits, err = meter.Int64Counter("abandoned_waits_total")
+	if err != nil {
+		return nil, err
+	}
+	m.AbandonedWaitDuration, err = meter.Float64Histogram("abandoned_wait_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	// serviceB_semaphore_starving gauge reports waiters already past the starvation threshold.
+	_, err = meter.Int64ObservableGauge("serviceB_semaphore_starving",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.starvingB.Load(); fn != nil {
+				obs.Observe(int64((*fn)()))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.SchedulerTaskRuns, err = meter.Int64Counter("scheduler_task_runs_total")
+	if err != nil {
+		return nil, err
+	}
+	m.SchedulerTaskDuration, err = meter.Float64Histogram("scheduler_task_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.CanaryRequests, err = meter.Int64Counter("canary_requests_total")
+	if err != nil {
+		return nil, err
+	}
+	m.CanaryDuration, err = meter.Float64Histogram("canary_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ShedRequests, err = meter.Int64Counter("shed_requests_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.RetryAttempts, err = meter.Int64Counter("retry_attempts_total")
+	if err != nil {
+		return nil, err
+	}
+	m.RetryBudgetExhausted, err = meter.Int64Counter("retry_budget_exhausted_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.FallbackRefreshes, err = meter.Int64Counter("fallback_refresh_total")
+	if err != nil {
+		return nil, err
+	}
+	m.FallbackServedAge, err = meter.Float64Histogram("fallback_served_age_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.FallbackActivations, err = meter.Int64Counter("fallback_activations_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.CoalescedRequests, err = meter.Int64Counter("coalesced_requests_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.SmartSwitches, err = meter.Int64Counter("smart_switches_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.OutlierEjections, err = meter.Int64Counter("outlier_ejections_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.BalancedCallDuration, err = meter.Float64Histogram("balanced_call_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.FairQueueWait, err = meter.Float64Histogram("fair_queue_wait_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.FairQueueGrants, err = meter.Int64Counter("fair_queue_grants_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.CostRejections, err = meter.Int64Counter("cost_rejections_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.RequestCost, err = meter.Int64Histogram("request_cost_units")
+	if err != nil {
+		return nil, err
+	}
+
+	m.SlowWriteBytes, err = meter.Int64Counter("slow_write_bytes_total")
+	if err != nil {
+		return nil, err
+	}
+	m.SlowWriteDuration, err = meter.Float64Histogram("slow_write_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.StreamFirstByte, err = meter.Float64Histogram("stream_first_byte_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.StreamDuration, err = meter.Float64Histogram("stream_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.StreamDropped, err = meter.Int64Counter("stream_dropped_lines_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ConditionalRequests, err = meter.Int64Counter("conditional_requests_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.HedgesSuppressed, err = meter.Int64Counter("hedges_suppressed_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.HedgesFired, err = meter.Int64Counter("hedges_fired_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.HedgesWon, err = meter.Int64Counter("hedges_won_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.BrownoutSkips, err = meter.Int64Counter("brownout_skipped_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.QuorumDuration, err = meter.Float64Histogram("quorum_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.RaceWastedCalls, err = meter.Int64Counter("race_wasted_calls_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.RaceWastedDuration, err = meter.Float64Histogram("race_wasted_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.PipelineStageDuration, err = meter.Float64Histogram("pipeline_stage_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.FanoutWidth, err = meter.Int64Histogram("fanout_width")
+	if err != nil {
+		return nil, err
+	}
+
+	m.OrderedFanInBuffered, err = meter.Int64Histogram("ordered_fanin_max_buffered")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ScatterResults, err = meter.Int64Counter("scatter_results_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ScatterShardTimeouts, err = meter.Int64Counter("scatter_shard_timeouts_total")
+	if err != nil {
+		return nil, err
+	}
+	m.ScatterGatherDelay, err = meter.Float64Histogram("scatter_gather_delay_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.PartialResults, err = meter.Int64Counter("partial_results_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.RegionCalls, err = meter.Int64Counter("region_calls_total")
+	if err != nil {
+		return nil, err
+	}
+	m.RegionCallDuration, err = meter.Float64Histogram("region_call_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.RegionFailovers, err = meter.Int64Counter("region_failovers_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.SingleflightCalls, err = meter.Int64Counter("singleflight_calls_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.BreakerTransitions, err = meter.Int64Counter("breaker_transitions_total")
+	if err != nil {
+		return nil, err
+	}
+	m.BreakerRejections, err = meter.Int64Counter("breaker_rejections_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.LifecycleStopDuration, err = meter.Float64Histogram("lifecycle_stop_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.LifecycleShutdownDuration, err = meter.Float64Histogram("lifecycle_shutdown_duration_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.BackgroundJobs, err = meter.Int64Counter("background_jobs_total")
+	if err != nil {
+		return nil, err
+	}
+	m.BackgroundCancellations, err = meter.Int64Counter("background_policy_cancellations_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.SyncBudgetUsed, err = meter.Float64Histogram("sync_budget_used_ratio")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ComputeSteps, err = meter.Int64Counter("compute_steps_total")
+	if err != nil {
+		return nil, err
+	}
+	m.ComputeCancelLatency, err = meter.Float64Histogram("compute_cancel_latency_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	m.EndpointCPU, err = meter.Float64Counter("endpoint_cpu_seconds_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.ProfileCaptures, err = meter.Int64Counter("profile_captures_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.AdminActions, err = meter.Int64Counter("admin_actions_total")
+	if err != nil {
+		return nil, err
+	}
+
+	m.Markers, err = meter.Int64Counter("experiment_markers_total")
+	if err != nil {
+		return nil, err
+	}
 
-	var err error
+	m.CtxBreaks, err = meter.Int64Counter("ctx_propagation_breaks_total")
+	if err != nil {
+		return nil, err
+	}
 
-	m.HTTPRequestsTotal, err = meter.Int64Counter("http_requests_total")
+	m.DNSLookups, err = meter.Int64Counter("dns_lookups_total")
 	if err != nil {
 		return nil, err
 	}
-	m.HTTPRequestDuration, err = meter.Float64Histogram("http_request_duration_ms")
+	m.DNSResolveDuration, err = meter.Float64Histogram("dns_resolve_duration_ms")
 	if err != nil {
 		return nil, err
 	}
 
-	m.ServiceDuration, err = meter.Float64Histogram("service_duration_ms")
+	m.MirrorRequests, err = meter.Int64Counter("mirror_requests_total")
 	if err != nil {
 		return nil, err
 	}
-	m.ServiceErrors, err = meter.Int64Counter("service_errors_total")
+	m.MirrorDuration, err = meter.Float64Histogram("mirror_duration_ms")
 	if err != nil {
 		return nil, err
 	}
 
-	m.SemWaitB, err = meter.Float64Histogram("serviceB_semaphore_wait_ms")
+	m.IdempotencyRequests, err = meter.Int64Counter("idempotency_requests_total")
+	if err != nil {
+		return nil, err
+	}
+
+	// mirror_queue_depth gauge reports mirrored copies waiting for a worker.
+	_, err = meter.Int64ObservableGauge("mirror_queue_depth",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.mirrorQueue.Load(); fn != nil {
+				obs.Observe(int64((*fn)()))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// worker_pool_queue_depth gauge reports calls waiting for a pool worker.
+	_, err = meter.Int64ObservableGauge("worker_pool_queue_depth",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.poolQueue.Load(); fn != nil {
+				obs.Observe(int64((*fn)()))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// worker_pool_workers gauge reports the pool workers started so far.
+	_, err = meter.Int64ObservableGauge("worker_pool_workers",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.poolWorkers.Load(); fn != nil {
+				obs.Observe(int64((*fn)()))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.WorkerPoolWait, err = meter.Float64Histogram("worker_pool_wait_ms")
+	if err != nil {
+		return nil, err
+	}
+
+	// worker_pool_utilization gauge reports the share of pool workers busy.
+	_, err = meter.Float64ObservableGauge("worker_pool_utilization",
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			if fn := m.poolUtilization.Load(); fn != nil {
+				obs.Observe((*fn)())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// telemetry_buffered_exports gauge reports exports waiting for the
+	// collector; telemetry_dropped_exports_total counts those lost to the
+	// buffer limit. They reach the collector late, with the buffered data.
+	_, err = meter.Int64ObservableGauge("telemetry_buffered_exports",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.exportBuffer.Load(); fn != nil {
+				for signal, st := range (*fn)() {
+					obs.Observe(int64(st.Queued), metric.WithAttributes(attribute.String("signal", signal)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableCounter("telemetry_dropped_exports_total",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.exportBuffer.Load(); fn != nil {
+				for signal, st := range (*fn)() {
+					obs.Observe(st.Dropped, metric.WithAttributes(attribute.String("signal", signal)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.ConnPoolAcquires, err = meter.Int64Counter("conn_pool_acquires_total")
+	if err != nil {
+		return nil, err
+	}
+	m.ConnPoolAcquireDuration, err = meter.Float64Histogram("conn_pool_acquire_ms")
+	if err != nil {
+		return nil, err
+	}
+	m.ConnPoolClosed, err = meter.Int64Counter("conn_pool_closed_total")
+	if err != nil {
+		return nil, err
+	}
+
+	// conn_pool_connections gauge reports each client pool's open and idle connections.
+	_, err = meter.Int64ObservableGauge("conn_pool_connections",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			m.connPools.Range(func(k, v any) bool {
+				open, idle := v.(func() (int, int))()
+				pool := attribute.String("pool", k.(string))
+				obs.Observe(int64(open), metric.WithAttributes(pool, attribute.String("state", "open")))
+				obs.Observe(int64(idle), metric.WithAttributes(pool, attribute.String("state", "idle")))
+				return true
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.registerConnMetrics(meter); err != nil {
+		return nil, err
+	}
+	if err := m.registerAllocMetrics(meter); err != nil {
+		return nil, err
+	}
+
+	// brownout_level gauge reports the percentage of requests skipping optional work.
+	_, err = meter.Int64ObservableGauge("brownout_level",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.brownout.Load(); fn != nil {
+				obs.Observe((*fn)())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// breaker_state gauge reports the Service B breaker: 0 closed, 1 half-open, 2 open.
+	_, err = meter.Int64ObservableGauge("breaker_state",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.breaker.Load(); fn != nil {
+				obs.Observe((*fn)())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// hedge_budget_utilization gauge reports how much of the hedge budget is spent.
+	_, err = meter.Float64ObservableGauge("hedge_budget_utilization",
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			if fn := m.hedgeBudget.Load(); fn != nil {
+				obs.Observe((*fn)())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// shed_limit gauge reports each guarded endpoint's current concurrency limit.
+	_, err = meter.Int64ObservableGauge("shed_limit",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.shedLimits.Load(); fn != nil {
+				for endpoint, n := range (*fn)() {
+					obs.Observe(int64(n), metric.WithAttributes(attribute.String("endpoint", endpoint)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// shed_priority_promotions_total counts the classes queued requests gained
+	// by aging, and shed_queue_max_wait_ms the longest wait, per endpoint and class.
+	_, err = meter.Int64ObservableCounter("shed_priority_promotions_total",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			m.observeShedQueues(func(st ShedClassStats, attrs metric.MeasurementOption) {
+				obs.Observe(st.Promotions, attrs)
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.Int64ObservableGauge("shed_queue_max_wait_ms",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			m.observeShedQueues(func(st ShedClassStats, attrs metric.MeasurementOption) {
+				obs.Observe(st.MaxWaitMs, attrs)
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// apdex_score gauge reports each endpoint's Apdex over the stats window.
+	_, err = meter.Float64ObservableGauge("apdex_score",
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			if fn := m.apdex.Load(); fn != nil {
+				for endpoint, score := range (*fn)() {
+					obs.Observe(score, metric.WithAttributes(attribute.String("endpoint", endpoint)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// endpoint_goroutines gauge reports the goroutines carrying each endpoint
+	// label at the latest census.
+	_, err = meter.Int64ObservableGauge("endpoint_goroutines",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if c := m.goroutines.Load(); c != nil {
+				for endpoint, n := range c.counts {
+					obs.Observe(int64(n), metric.WithAttributes(attribute.String("endpoint", endpoint)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// endpoint_goroutines_per_request gauge divides them by the endpoint's
+	// in-flight requests at the census, for endpoints with any.
+	_, err = meter.Float64ObservableGauge("endpoint_goroutines_per_request",
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			if c := m.goroutines.Load(); c != nil {
+				for endpoint, n := range c.counts {
+					if reqs := c.inflight[endpoint]; reqs > 0 {
+						obs.Observe(float64(n)/float64(reqs), metric.WithAttributes(attribute.String("endpoint", endpoint)))
+					}
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// cost_tokens_available gauge reports the unused capacity tokens.
+	_, err = meter.Int64ObservableGauge("cost_tokens_available",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.tokens.Load(); fn != nil {
+				obs.Observe((*fn)())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// fair_queue_slots_in_use gauge reports each tenant's share of Service B capacity.
+	_, err = meter.Int64ObservableGauge("fair_queue_slots_in_use",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			if fn := m.tenantSlots.Load(); fn != nil {
+				for tenant, n := range (*fn)() {
+					obs.Observe(int64(n), metric.WithAttributes(attribute.String("tenant", tenant)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// dependency_health_score gauge reports the EWMA health of each scored dependency.
+	_, err = meter.Float64ObservableGauge("dependency_health_score",
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			m.scores.Range(func(k, v any) bool {
+				obs.Observe(v.(func() float64)(), metric.WithAttributes(attribute.String("service", k.(string))))
+				return true
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// fallback_age_ms gauge reports how stale each fallback cache currently is.
+	_, err = meter.Float64ObservableGauge("fallback_age_ms",
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			m.ages.Range(func(k, v any) bool {
+				if age, ok := v.(func() (time.Duration, bool))(); ok {
+					obs.Observe(float64(age.Milliseconds()), metric.WithAttributes(attribute.String("service", k.(string))))
+				}
+				return true
+			})
+			return nil
+		}),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -70,9 +899,29 @@ func NewMetrics() (*Metrics, error) {
 		return nil, err
 	}
 
+	// pipeline_queue_depth gauge reports items buffered ahead of each /pipeline stage.
+	_, err = meter.Int64ObservableGauge("pipeline_queue_depth",
+		metric.WithInt64Callback(func(ctx context.Context, obs metric.Int64Observer) error {
+			m.pipelineQueued.Range(func(k, v any) bool {
+				obs.Observe(v.(*atomic.Int64).Load(), metric.WithAttributes(attribute.String("stage", k.(string))))
+				return true
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
+// AddPipelineQueued adds delta to the items buffered ahead of a /pipeline stage.
+func (m *Metrics) AddPipelineQueued(stage string, delta int64) {
+	v, _ := m.pipelineQueued.LoadOrStore(stage, &atomic.Int64{})
+	v.(*atomic.Int64).Add(delta)
+}
+
 // IncInflight increments the in-flight counter for an endpoint.
 func (m *Metrics) IncInflight(endpoint string) {
 	v, _ := m.inflight.LoadOrStore(endpoint, &atomic.Int64{})
@@ -85,3 +934,159 @@ func (m *Metrics) DecInflight(endpoint string) {
 		v.(*atomic.Int64).Add(-1)
 	}
 }
+
+// ObserveEndpointCPU adds cpu to endpoint_cpu_seconds_total for endpoint.
+func (m *Metrics) ObserveEndpointCPU(endpoint string, cpu time.Duration) {
+	m.EndpointCPU.Add(context.Background(), cpu.Seconds(), metric.WithAttributes(attribute.String("endpoint", endpoint)))
+}
+
+// RecordProfileCapture counts a check of the profile capture thresholds
+// that found trigger crossed, by result.
+func (m *Metrics) RecordProfileCapture(trigger, result string) {
+	m.ProfileCaptures.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("trigger", trigger),
+		attribute.String("result", result),
+	))
+}
+
+// ObserveEndpointGoroutines replaces the goroutine counts per endpoint label
+// behind endpoint_goroutines, pairing them with the current in-flight
+// requests for endpoint_goroutines_per_request.
+func (m *Metrics) ObserveEndpointGoroutines(counts map[string]int) {
+	c := &goroutineCensus{counts: counts, inflight: make(map[string]int64)}
+	m.inflight.Range(func(k, v any) bool {
+		c.inflight[k.(string)] = v.(*atomic.Int64).Load()
+		return true
+	})
+	m.goroutines.Store(c)
+}
+
+// TrackAge exports the age reported by fn as fallback_age_ms for service.
+// fn returns false while there is nothing cached.
+func (m *Metrics) TrackAge(service string, fn func() (time.Duration, bool)) {
+	m.ages.Store(service, fn)
+}
+
+// TrackHealth exports the score reported by fn as dependency_health_score for service.
+func (m *Metrics) TrackHealth(service string, fn func() float64) {
+	m.scores.Store(service, fn)
+}
+
+// TrackMirrorQueue exports the depth reported by fn as mirror_queue_depth.
+func (m *Metrics) TrackMirrorQueue(fn func() int) {
+	m.mirrorQueue.Store(&fn)
+}
+
+// TrackWorkerPool exports the depth, worker count and utilization reported
+// by queued, workers and utilization as worker_pool_queue_depth,
+// worker_pool_workers and worker_pool_utilization.
+func (m *Metrics) TrackWorkerPool(queued, workers func() int, utilization func() float64) {
+	m.poolQueue.Store(&queued)
+	m.poolWorkers.Store(&workers)
+	m.poolUtilization.Store(&utilization)
+}
+
+// RecordWorkerPoolWait records how long a call waited for a pool worker,
+// by whether the worker was spawned for it.
+func (m *Metrics) RecordWorkerPoolWait(wait time.Duration, spawned bool) {
+	worker := "running"
+	if spawned {
+		worker = "spawned"
+	}
+	m.WorkerPoolWait.Record(context.Background(), float64(wait.Microseconds())/1000, metric.WithAttributes(attribute.String("worker", worker)))
+}
+
+// TrackExportBuffer exports the counts reported by fn as
+// telemetry_buffered_exports and telemetry_dropped_exports_total.
+func (m *Metrics) TrackExportBuffer(fn func() map[string]ExportBufferStats) {
+	m.exportBuffer.Store(&fn)
+}
+
+// RecordPhase records how long one phase of a call to peer took (see package
+// httpphase for the phases) in downstream_phase_duration_ms, and adds it as
+// an event on the call's span in ctx.
+func (m *Metrics) RecordPhase(ctx context.Context, peer, phase string, d time.Duration) {
+	ms := float64(d.Microseconds()) / 1000
+	m.PhaseDuration.Record(ctx, ms, metric.WithAttributes(
+		attribute.String("peer", peer),
+		attribute.String("phase", phase),
+	))
+	trace.SpanFromContext(ctx).AddEvent(phase, trace.WithAttributes(attribute.Float64("phase.duration_ms", ms)))
+}
+
+// TrackConnPool exports the counts reported by fn as conn_pool_connections for pool.
+func (m *Metrics) TrackConnPool(pool string, fn func() (open, idle int)) {
+	m.connPools.Store(pool, fn)
+}
+
+// RecordConnPoolClose counts a connection closed by a client pool, by reason.
+func (m *Metrics) RecordConnPoolClose(pool, reason string) {
+	m.ConnPoolClosed.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("pool", pool),
+		attribute.String("reason", reason),
+	))
+}
+
+// TrackTenantSlots exports the per-tenant slot counts reported by fn as fair_queue_slots_in_use.
+func (m *Metrics) TrackTenantSlots(fn func() map[string]int) {
+	m.tenantSlots.Store(&fn)
+}
+
+// TrackStarvingB exports the count reported by fn as serviceB_semaphore_starving.
+func (m *Metrics) TrackStarvingB(fn func() int) {
+	m.starvingB.Store(&fn)
+}
+
+// TrackTokens exports the token count reported by fn as cost_tokens_available.
+func (m *Metrics) TrackTokens(fn func() int64) {
+	m.tokens.Store(&fn)
+}
+
+// TrackApdex exports the per-endpoint scores reported by fn as apdex_score.
+func (m *Metrics) TrackApdex(fn func() map[string]float64) {
+	m.apdex.Store(&fn)
+}
+
+// TrackShedLimits exports the per-endpoint limits reported by fn as shed_limit.
+func (m *Metrics) TrackShedLimits(fn func() map[string]int) {
+	m.shedLimits.Store(&fn)
+}
+
+// ShedClassStats is one priority class of a shed queue.
+type ShedClassStats struct {
+	Promotions int64
+	MaxWaitMs  int64
+}
+
+// TrackShedQueues exports the per-endpoint, per-class stats reported by fn as
+// shed_priority_promotions_total and shed_queue_max_wait_ms.
+func (m *Metrics) TrackShedQueues(fn func() map[string]map[string]ShedClassStats) {
+	m.shedQueues.Store(&fn)
+}
+
+func (m *Metrics) observeShedQueues(observe func(ShedClassStats, metric.MeasurementOption)) {
+	fn := m.shedQueues.Load()
+	if fn == nil {
+		return
+	}
+	for endpoint, classes := range (*fn)() {
+		for class, st := range classes {
+			observe(st, metric.WithAttributes(attribute.String("endpoint", endpoint), attribute.String("class", class)))
+		}
+	}
+}
+
+// TrackHedgeBudget exports the utilization reported by fn as hedge_budget_utilization.
+func (m *Metrics) TrackHedgeBudget(fn func() float64) {
+	m.hedgeBudget.Store(&fn)
+}
+
+// TrackBrownout exports the level reported by fn as brownout_level.
+func (m *Metrics) TrackBrownout(fn func() int64) {
+	m.brownout.Store(&fn)
+}
+
+// TrackBreaker exports the state reported by fn as breaker_state.
+func (m *Metrics) TrackBreaker(fn func() int64) {
+	m.breaker.Store(&fn)
+}
@@ -6,26 +6,38 @@ import (
 
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-routine-stress/internal/buildinfo"
 )
 
 // SetupOTel initializes OpenTelemetry providers.
-// Metrics are always enabled. Traces are optional.
-func SetupOTel(ctx context.Context, endpoint, serviceName string, disableTraces bool) (func(context.Context) error, error) {
+// Metrics are always enabled. Traces are optional; when enabled, new traces
+// are only sampled while tracing reports true. Both are exported through buf.
+func SetupOTel(ctx context.Context, endpoint, serviceName string, disableTraces bool, tracing func() bool, buf *ExportBuffer) (func(context.Context) error, error) {
+	build := buildinfo.Get()
 	res, err := resource.New(ctx,
-		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(build.Version),
+			attribute.String("build.commit", build.Commit),
+			attribute.String("build.date", build.Date),
+			attribute.String("build.go_version", build.GoVersion),
+		),
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	// Metrics exporter (OTLP HTTP → Collector).
-	metricExp, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint))
+	metricExp, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint), otlpmetrichttp.WithHTTPClient(buf.Client()))
 	if err != nil {
 		return nil, err
 	}
@@ -38,13 +50,14 @@ func SetupOTel(ctx context.Context, endpoint, serviceName string, disableTraces
 	// Traces exporter (optional).
 	var tp *sdktrace.TracerProvider
 	if !disableTraces {
-		traceExp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+		traceExp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint), otlptracehttp.WithHTTPClient(buf.Client()))
 		if err != nil {
 			return nil, err
 		}
 		tp = sdktrace.NewTracerProvider(
 			sdktrace.WithResource(res),
 			sdktrace.WithBatcher(traceExp),
+			sdktrace.WithSampler(toggledSampler{on: tracing, base: sdktrace.ParentBased(sdktrace.AlwaysSample())}),
 		)
 		otel.SetTracerProvider(tp)
 	}
@@ -62,3 +75,19 @@ func SetupOTel(ctx context.Context, endpoint, serviceName string, disableTraces
 		return nil
 	}, nil
 }
+
+// toggledSampler defers to base while on reports true and drops every span
+// otherwise, so tracing can be switched off without a restart.
+type toggledSampler struct {
+	on   func() bool
+	base sdktrace.Sampler
+}
+
+func (s toggledSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if !s.on() {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState()}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s toggledSampler) Description() string { return "Toggled{" + s.base.Description() + "}" }
@@ -0,0 +1,130 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var clientTracer = otel.Tracer("go-goroutine-lab/client")
+
+// rpcSystem names the simulated transport in rpc.system.
+const rpcSystem = "simulated"
+
+// StartClientSpan starts a client-kind span for one call to the downstream
+// service peer, attributed per the RPC semantic conventions so trace backends
+// draw it as an edge to peer in their service maps. instance is the replica
+// or chain path called, the call's server.address; empty means peer itself.
+func StartClientSpan(ctx context.Context, peer, instance string) (context.Context, trace.Span) {
+	if instance == "" {
+		instance = peer
+	}
+	return clientTracer.Start(ctx, peer+"/Call", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.RPCSystemKey.String(rpcSystem),
+		semconv.RPCService(peer),
+		semconv.RPCMethod("Call"),
+		semconv.PeerService(peer),
+		semconv.ServerAddress(instance),
+	))
+}
+
+// EndClientSpan ends a span started by StartClientSpan with the call's
+// outcome: errType (an error code) in error.type and an error status, or OK.
+func EndClientSpan(span trace.Span, err error, errType string) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(semconv.ErrorTypeKey.String(errType))
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
+
+// Attempt kinds, the resilience policy that made an attempt, in attempt.kind.
+const (
+	AttemptRetry    = "retry"
+	AttemptHedge    = "hedge"
+	AttemptFailover = "failover"
+)
+
+// StartAttemptSpan starts a span for attempt n (from 1) of a call to peer
+// made under the policy kind, named "<peer>/<kind>"; the attempt's client
+// span becomes its child, so a trace shows every downstream call a request
+// made and why. follows is the attempt this one retries or races, linked
+// so that one cancelled or failed stays tied to the attempt that took over.
+func StartAttemptSpan(ctx context.Context, peer, kind string, n int, follows trace.SpanContext) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{trace.WithAttributes(
+		semconv.PeerService(peer),
+		attribute.String("attempt.kind", kind),
+		attribute.Int("attempt.number", n),
+	)}
+	if follows.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: follows,
+			Attributes:  []attribute.KeyValue{attribute.String("attempt.link", "follows")},
+		}))
+	}
+	return clientTracer.Start(ctx, peer+"/"+kind, opts...)
+}
+
+// EndAttemptSpan ends a span started by StartAttemptSpan with the attempt's
+// outcome in attempt.outcome (ok, error, try_timeout, cancelled) and, for a
+// cancelled one, what cancelled it in attempt.cancel_cause. Only errors get
+// an error status: a cancelled attempt lost a race, it did not fail.
+func EndAttemptSpan(span trace.Span, err error, outcome, cause string) {
+	span.SetAttributes(attribute.String("attempt.outcome", outcome))
+	switch {
+	case err == nil:
+		span.SetStatus(codes.Ok, "")
+	case cause != "":
+		span.SetAttributes(attribute.String("attempt.cancel_cause", cause))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartHTTPClientSpan starts a client-kind span for sending req to the
+// service peer, attributed per the HTTP semantic conventions, and injects
+// its context into req's headers so the receiving server joins the trace.
+func StartHTTPClientSpan(ctx context.Context, peer string, req *http.Request) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.String()),
+		semconv.ServerAddress(req.URL.Hostname()),
+		semconv.PeerService(peer),
+	}
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+	ctx, span := clientTracer.Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return ctx, span
+}
+
+// EndHTTPClientSpan ends a span started by StartHTTPClientSpan with the
+// response status, or with err if no response came back. Like the HTTP
+// conventions, it counts only 5xx responses as client errors.
+func EndHTTPClientSpan(span trace.Span, status int, err error) {
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetAttributes(semconv.ErrorTypeKey.String("transport"))
+		span.SetStatus(codes.Error, err.Error())
+	case status >= 500:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status), semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+		span.SetStatus(codes.Error, http.StatusText(status))
+	default:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	}
+	span.End()
+}
@@ -0,0 +1,187 @@
+package pool
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+)
+
+// Pool runs calls on a fixed set of long-lived workers fed by a bounded
+// queue: the alternative to starting a goroutine per call, trading spawn
+// cost and an unbounded goroutine count for queueing once every worker is
+// busy. A warm pool starts every worker with Run; a lazy one starts them as
+// calls find none free, up to size, so its first callers pay for the spawn.
+type Pool struct {
+	clock clock.Clock
+	opts  Options
+	jobs  chan job
+	// spawn asks Run for one more worker of a lazy pool.
+	spawn chan struct{}
+
+	busy    atomic.Int64
+	idle    atomic.Int64 // workers waiting for a call
+	started atomic.Int64 // workers running or asked for
+	warming atomic.Int64 // warm workers still in Init
+	ready   chan struct{}
+}
+
+// Options sizes a pool and sets how its workers start.
+type Options struct {
+	Size  int
+	Queue int // calls waiting for a worker before callers block
+	// Lazy starts workers on demand instead of all of them in Run.
+	Lazy bool
+	// Init is the setup a worker does before its first call, such as
+	// opening a client of its own, waited out on the pool's clock.
+	Init time.Duration
+	// OnStart, if set, is told how long each call waited for a worker,
+	// and whether that worker was just spawned for it.
+	OnStart func(wait time.Duration, spawned bool)
+}
+
+type job struct {
+	run    func(worker context.Context)
+	queued time.Time
+}
+
+// New creates a pool of workers. They start with Run.
+func New(clk clock.Clock, o Options) *Pool {
+	p := &Pool{clock: clk, opts: o, jobs: make(chan job, o.Queue), spawn: make(chan struct{}, o.Size), ready: make(chan struct{})}
+	if o.Lazy {
+		close(p.ready)
+	} else {
+		p.warming.Store(int64(o.Size))
+	}
+	return p
+}
+
+// Size returns the number of workers.
+func (p *Pool) Size() int { return p.opts.Size }
+
+// Lazy reports whether workers start on demand.
+func (p *Pool) Lazy() bool { return p.opts.Lazy }
+
+// Started returns the workers running, or starting.
+func (p *Pool) Started() int { return int(p.started.Load()) }
+
+// Queued returns the calls waiting for a worker.
+func (p *Pool) Queued() int { return len(p.jobs) }
+
+// Utilization returns the share of workers running a call, from 0 to 1.
+func (p *Pool) Utilization() float64 {
+	return float64(p.busy.Load()) / float64(p.opts.Size)
+}
+
+// Ready is closed once a warm pool's workers are through Init, or have
+// stopped, and at once for a lazy pool.
+func (p *Pool) Ready() <-chan struct{} { return p.ready }
+
+// Run starts the workers, all of them unless the pool is lazy, and returns
+// once ctx is done and each has finished its current call. Calls still
+// queued never run.
+func (p *Pool) Run(ctx context.Context) {
+	var done []<-chan struct{}
+	start := func(spawned bool) {
+		done = append(done, safego.Background(ctx, "pool.worker", func(ctx context.Context) { p.work(ctx, spawned) }))
+	}
+	if !p.opts.Lazy {
+		p.started.Store(int64(p.opts.Size))
+		for range p.opts.Size {
+			start(false)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			for _, d := range done {
+				<-d
+			}
+			return
+		case <-p.spawn:
+			start(true)
+		}
+	}
+}
+
+func (p *Pool) work(ctx context.Context, spawned bool) {
+	var ok bool
+	select {
+	case <-p.clock.After(p.opts.Init):
+		ok = true
+	case <-ctx.Done():
+	}
+	if !spawned && p.warming.Add(-1) == 0 {
+		close(p.ready)
+	}
+	if !ok {
+		return
+	}
+	for {
+		p.idle.Add(1)
+		select {
+		case <-ctx.Done():
+			p.idle.Add(-1)
+			return
+		case j := <-p.jobs:
+			p.idle.Add(-1)
+			if p.opts.OnStart != nil {
+				p.opts.OnStart(p.clock.Since(j.queued), spawned)
+			}
+			spawned = false
+			p.busy.Add(1)
+			j.run(ctx)
+			p.busy.Add(-1)
+		}
+	}
+}
+
+// grow asks for one more worker of a lazy pool when none is free for the
+// call about to be queued.
+func (p *Pool) grow() {
+	if !p.opts.Lazy || p.idle.Load() > int64(len(p.jobs)) {
+		return
+	}
+	if p.started.Add(1) > int64(p.opts.Size) {
+		p.started.Add(-1)
+		return
+	}
+	p.spawn <- struct{}{}
+}
+
+// Go queues fn to run on a worker and returns a buffered channel that
+// receives exactly one Result, like safego.Go. It waits for room in the
+// queue while ctx allows. If ctx ends first, or before a worker picks the
+// call up, fn never runs and the Result carries ctx's cause. A panic in fn
+// is contained as by safego.Go and leaves the worker running.
+func Go[T any](ctx context.Context, p *Pool, name string, fn func(context.Context) (T, error)) <-chan safego.Result[T] {
+	ch := make(chan safego.Result[T], 1)
+	run := func(worker context.Context) {
+		var res safego.Result[T]
+		defer func() {
+			// Drop the labels the call brought along before it is seen done.
+			pprof.SetGoroutineLabels(worker)
+			ch <- res
+		}()
+		if ctx.Err() != nil {
+			res.Err = context.Cause(ctx)
+			return
+		}
+		// The worker takes the caller's pprof labels for the call, so the
+		// goroutine census and CPU profiles count it toward the request.
+		pprof.SetGoroutineLabels(ctx)
+		defer safego.Recover(ctx, name, &res.Err)
+		res.Val, res.Err = fn(ctx)
+	}
+
+	p.grow()
+	select {
+	case p.jobs <- job{run: run, queued: p.clock.Now()}:
+	case <-ctx.Done():
+		ch <- safego.Result[T]{Err: context.Cause(ctx)}
+	}
+	return ch
+}
@@ -0,0 +1,96 @@
+package pool
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/diag"
+)
+
+func TestPoolStart(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		name := "warm"
+		if lazy {
+			name = "lazy"
+		}
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+			var mu sync.Mutex
+			var spawned int
+			p := New(clk, Options{Size: 4, Queue: 4, Lazy: lazy, Init: 50 * time.Millisecond, OnStart: func(_ time.Duration, s bool) {
+				mu.Lock()
+				defer mu.Unlock()
+				if s {
+					spawned++
+				}
+			}})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go p.Run(ctx)
+
+			if !lazy {
+				// Every worker is in its setup before any call arrives.
+				for clk.Waiters() < 4 {
+					time.Sleep(time.Millisecond)
+				}
+				clk.Advance(50 * time.Millisecond)
+				<-p.Ready()
+			}
+			ch := Go(ctx, p, "test", func(context.Context) (int, error) { return 1, nil })
+			if lazy {
+				// A worker is spawned for the call, which waits out its setup.
+				for clk.Waiters() < 1 {
+					time.Sleep(time.Millisecond)
+				}
+				clk.Advance(50 * time.Millisecond)
+			}
+			if res := <-ch; res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			want, wantSpawned := 4, 0
+			if lazy {
+				want, wantSpawned = 1, 1
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if p.Started() != want || spawned != wantSpawned {
+				t.Fatalf("%d workers, %d calls on a spawned one; want %d, %d", p.Started(), spawned, want, wantSpawned)
+			}
+		})
+	}
+}
+
+// TestPoolLabels checks that a call counts toward its caller's pprof label
+// while it runs on a worker, and the idle worker toward none.
+func TestPoolLabels(t *testing.T) {
+	p := New(clock.Real{}, Options{Size: 1, Queue: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	var during map[string]int
+	pprof.Do(ctx, pprof.Labels("endpoint", "pool"), func(ctx context.Context) {
+		res := <-Go(ctx, p, "test", func(context.Context) (map[string]int, error) {
+			return diag.CountByLabel("endpoint", "other")
+		})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		during = res.Val
+	})
+	// This goroutine and the worker running the call.
+	if during["pool"] != 2 {
+		t.Fatalf("during the call: %v, want 2 goroutines labelled pool", during)
+	}
+	after, err := diag.CountByLabel("endpoint", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after["pool"] != 0 {
+		t.Fatalf("after the call: %v, want none labelled pool", after)
+	}
+}
@@ -0,0 +1,145 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-routine-stress/internal/safego"
+)
+
+// Check is one real backend the server talks to, to be reached before it
+// starts serving.
+type Check struct {
+	Name    string
+	URL     string
+	Timeout time.Duration
+}
+
+// Result is the outcome of one Check.
+type Result struct {
+	Name string `json:"name"`
+	// Addr is the host:port dialed.
+	Addr  string `json:"addr"`
+	OK    bool   `json:"ok"`
+	Ms    int64  `json:"ms"`
+	Error string `json:"error,omitempty"`
+}
+
+func (r Result) String() string {
+	if r.OK {
+		return fmt.Sprintf("%s %s ok in %dms", r.Name, r.Addr, r.Ms)
+	}
+	return fmt.Sprintf("%s %s unreachable: %s", r.Name, r.Addr, r.Error)
+}
+
+// Run opens a TCP connection to every backend concurrently, each under its
+// own timeout, and returns the results in the order of checks. Reaching the
+// port is all it verifies: a backend accepting connections but failing
+// requests passes.
+func Run(ctx context.Context, checks []Check) []Result {
+	pending := make([]<-chan safego.Result[Result], len(checks))
+	for i, c := range checks {
+		pending[i] = safego.Go(ctx, "preflight."+c.Name, func(ctx context.Context) (Result, error) {
+			return probe(ctx, c), nil
+		})
+	}
+
+	out := make([]Result, len(checks))
+	for i, ch := range pending {
+		res := <-ch
+		out[i] = res.Val
+		if res.Err != nil {
+			out[i] = Result{Name: checks[i].Name, Error: res.Err.Error()}
+		}
+	}
+	return out
+}
+
+// Failed returns the results that did not reach their backend.
+func Failed(results []Result) []Result {
+	var out []Result
+	for _, r := range results {
+		if !r.OK {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func probe(ctx context.Context, c Check) Result {
+	res := Result{Name: c.Name}
+	addr, err := dialAddr(c.URL)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Addr = addr
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	res.Ms = time.Since(start).Milliseconds()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		res.Error = fmt.Sprintf("no connection within %s", c.Timeout)
+	case err != nil:
+		res.Error = err.Error()
+	default:
+		conn.Close()
+		res.OK = true
+	}
+	return res
+}
+
+// dialAddr returns the host:port to dial for u, defaulting the port by
+// scheme. Errors leave u out: URLs such as webhooks may embed a secret.
+func dialAddr(u string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", errors.New("invalid URL")
+	}
+	if parsed.Hostname() == "" {
+		return "", errors.New("URL has no host")
+	}
+	port := parsed.Port()
+	if port == "" {
+		switch parsed.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return "", fmt.Errorf("URL has no port for scheme %q", parsed.Scheme)
+		}
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+// ParseTimeouts parses "name=ms" entries separated by ';'.
+func ParseTimeouts(s string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, ms, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("preflight timeout %q: want name=ms", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(ms))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("preflight timeout %q: want a positive number of milliseconds", entry)
+		}
+		out[strings.TrimSpace(name)] = time.Duration(n) * time.Millisecond
+	}
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package readiness
+
+import (
+	"sort"
+	"sync"
+)
+
+// Checker aggregates named readiness checks. The server is ready only when
+// every check passes.
+type Checker struct {
+	mu     sync.RWMutex
+	checks map[string]func() error
+}
+
+// New creates a checker with no checks (always ready).
+func New() *Checker { return &Checker{checks: make(map[string]func() error)} }
+
+// Add registers a check under name, replacing any previous one.
+func (c *Checker) Add(name string, check func() error) {
+	c.mu.Lock()
+	c.checks[name] = check
+	c.mu.Unlock()
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Check runs all checks and reports whether all of them passed.
+func (c *Checker) Check() (bool, []Result) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ready := true
+	results := make([]Result, 0, len(c.checks))
+	for name, check := range c.checks {
+		r := Result{Name: name, OK: true}
+		if err := check(); err != nil {
+			r.OK, r.Error = false, err.Error()
+			ready = false
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return ready, results
+}
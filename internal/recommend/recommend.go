@@ -0,0 +1,97 @@
+package recommend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/stats"
+)
+
+// Recommendation is one suggested setting derived from measured traffic.
+type Recommendation struct {
+	Target  string `json:"target"`
+	Kind    string `json:"kind"` // "timeout" or "concurrency"
+	Setting string `json:"setting,omitempty"`
+	Value   int    `json:"value"`
+	Basis   string `json:"basis"`
+}
+
+// Report is the full set of recommendations for one measurement window.
+type Report struct {
+	At              time.Time        `json:"at"`
+	WindowSec       float64          `json:"windowSec"`
+	MarginPct       int              `json:"marginPct"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// timeoutSettings and limitSettings name the configuration each recommendation
+// feeds, where one exists; the rest are informational.
+var (
+	timeoutSettings = map[string]string{"B": "B_TRY_TIMEOUT_MS"}
+	limitSettings   = map[string]string{"A": "A_CONCURRENCY_LIMIT", "B": "B_CONCURRENCY_LIMIT"}
+)
+
+// Build recommends a timeout per dependency (p99.9 of successful calls plus
+// the margin) and a concurrency limit per dependency and endpoint from
+// Little's law (arrival rate × mean latency, plus the margin).
+func Build(at time.Time, window time.Duration, deps, endpoints []stats.EndpointStats, marginPct int) Report {
+	margin := 1 + float64(marginPct)/100
+	r := Report{At: at, WindowSec: window.Seconds(), MarginPct: marginPct, Recommendations: []Recommendation{}}
+
+	for _, es := range deps {
+		if es.Requests == 0 {
+			continue
+		}
+		r.Recommendations = append(r.Recommendations, Recommendation{
+			Target:  es.Endpoint,
+			Kind:    "timeout",
+			Setting: timeoutSettings[es.Endpoint],
+			Value:   int(math.Ceil(es.P999Ms * margin)),
+			Basis:   fmt.Sprintf("p99.9 %.0fms over %d calls", es.P999Ms, es.Requests),
+		}, littlesLaw(es, margin, limitSettings[es.Endpoint]))
+	}
+	for _, es := range endpoints {
+		if es.Requests == 0 {
+			continue
+		}
+		r.Recommendations = append(r.Recommendations, littlesLaw(es, margin, "SHED_MAX_INFLIGHT"))
+	}
+
+	sort.SliceStable(r.Recommendations, func(i, j int) bool {
+		return r.Recommendations[i].Target < r.Recommendations[j].Target
+	})
+	return r
+}
+
+func littlesLaw(es stats.EndpointStats, margin float64, setting string) Recommendation {
+	inflight := es.RPS * es.MeanMs / 1000
+	return Recommendation{
+		Target:  es.Endpoint,
+		Kind:    "concurrency",
+		Setting: setting,
+		Value:   max(int(math.Ceil(inflight*margin)), 1),
+		Basis:   fmt.Sprintf("%.1f rps × %.0fms mean = %.1f in flight", es.RPS, es.MeanMs, inflight),
+	}
+}
+
+// Recommender builds reports from the dependency and endpoint aggregators.
+type Recommender struct {
+	Clock     clock.Clock
+	Deps      *stats.Aggregator
+	Endpoints *stats.Aggregator
+	MarginPct int
+
+	// Started is the beginning of the run; windows never reach before it, so
+	// rates are not diluted by time the process was not serving.
+	Started time.Time
+}
+
+// Report recommends settings from the last window of traffic, capped at the
+// run so far and at the history the aggregators retain.
+func (r *Recommender) Report(window time.Duration) Report {
+	window = min(window, r.Clock.Since(r.Started), r.Endpoints.Retention(), r.Deps.Retention())
+	return Build(r.Clock.Now(), window, r.Deps.Summarize(window), r.Endpoints.Summarize(window), r.MarginPct)
+}
@@ -0,0 +1,168 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// State is where a Breaker stands. The values grow with how much traffic the
+// breaker holds back, so a gauge of them reads as severity.
+type State int
+
+const (
+	// StateClosed lets every call through, counting failures.
+	StateClosed State = iota
+	// StateHalfOpen lets a few trial calls through to test the dependency.
+	StateHalfOpen
+	// StateOpen fails every call at once, until the open period ends.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// ErrOpen is returned for calls the breaker does not let through.
+var ErrOpen = errors.New("circuit breaker open")
+
+// Breaker stops calling a dependency that keeps failing. Closed, it opens
+// after Failures consecutive failures. Open, it rejects calls for OpenFor,
+// then turns half-open and lets up to Probes trial calls through at a time:
+// Probes successes in a row close it again, and any failure reopens it.
+//
+// Calls ending with context.Canceled say nothing about the dependency, since
+// their caller gave up, and count neither way; deadline errors do count, as
+// a dependency too slow to answer in time is failing its callers.
+type Breaker struct {
+	clock    clock.Clock
+	failures int
+	openFor  time.Duration
+	probes   int
+
+	mu    sync.Mutex
+	state State
+	// gen counts transitions: a call let through in an earlier state ends
+	// without a say in the current one.
+	gen      uint64
+	failed   int // consecutive failures while closed
+	openedAt time.Time
+	// Trial calls in flight and succeeded while half-open.
+	trials, passed int
+	onChange       func(ctx context.Context, from, to State)
+}
+
+// NewBreaker creates a closed breaker.
+func NewBreaker(clk clock.Clock, failures int, openFor time.Duration, probes int) *Breaker {
+	return &Breaker{clock: clk, failures: failures, openFor: openFor, probes: probes}
+}
+
+// OnStateChange sets fn to be called on every transition, outside the
+// breaker's lock, with the context of the call that caused it.
+func (b *Breaker) OnStateChange(fn func(ctx context.Context, from, to State)) {
+	b.mu.Lock()
+	b.onChange = fn
+	b.mu.Unlock()
+}
+
+// State returns the breaker's state. An open breaker whose open period has
+// ended reports half-open, although it only turns so on the next call.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.clock.Since(b.openedAt) >= b.openFor {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Allow asks to make one call. If the breaker lets it through, done must be
+// called with the call's error once it ends; otherwise err is ErrOpen.
+func (b *Breaker) Allow(ctx context.Context) (done func(error), err error) {
+	b.mu.Lock()
+	from := b.state
+	if b.state == StateOpen && b.clock.Since(b.openedAt) >= b.openFor {
+		b.setLocked(StateHalfOpen)
+		b.trials, b.passed = 0, 0
+	}
+	switch {
+	case b.state == StateOpen, b.state == StateHalfOpen && b.trials >= b.probes:
+		err = ErrOpen
+	case b.state == StateHalfOpen:
+		b.trials++
+	}
+	gen := b.gen
+	b.unlockNotify(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(err error) { once.Do(func() { b.done(ctx, gen, err) }) }, nil
+}
+
+func (b *Breaker) done(ctx context.Context, gen uint64, err error) {
+	b.mu.Lock()
+	from := b.state
+	if gen != b.gen {
+		b.mu.Unlock()
+		return
+	}
+	canceled := errors.Is(err, context.Canceled)
+	switch b.state {
+	case StateClosed:
+		switch {
+		case err == nil:
+			b.failed = 0
+		case !canceled:
+			b.failed++
+			if b.failed >= b.failures {
+				b.openLocked()
+			}
+		}
+	case StateHalfOpen:
+		b.trials--
+		switch {
+		case err == nil:
+			b.passed++
+			if b.passed >= b.probes {
+				b.setLocked(StateClosed)
+				b.failed = 0
+			}
+		case !canceled:
+			b.openLocked()
+		}
+	}
+	b.unlockNotify(ctx, from)
+}
+
+func (b *Breaker) openLocked() {
+	b.setLocked(StateOpen)
+	b.openedAt = b.clock.Now()
+}
+
+func (b *Breaker) setLocked(s State) {
+	b.state = s
+	b.gen++
+}
+
+// unlockNotify releases the lock and reports a transition away from from, if
+// there was one.
+func (b *Breaker) unlockNotify(ctx context.Context, from State) {
+	to, fn := b.state, b.onChange
+	b.mu.Unlock()
+	if to != from && fn != nil {
+		fn(ctx, from, to)
+	}
+}
@@ -1,24 +1,145 @@
 package routers
 
 import (
+	"net/http"
+	"net/http/pprof"
+
 	"github.com/gin-gonic/gin"
 
+	"go-routine-stress/internal/auth"
 	"go-routine-stress/internal/handlers"
 	"go-routine-stress/internal/middleware"
+	"go-routine-stress/internal/mirror"
 	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/stats"
 )
 
+// modeMethods are the methods guarded mode endpoints answer.
+var modeMethods = []string{http.MethodGet, http.MethodPost}
+
 // NewRouter registers all endpoints and applies per-endpoint instrumentation.
-func NewRouter(m *observability.Metrics, h *handlers.Handlers) *gin.Engine {
+// tokens guards the admin and debug endpoints, which are only served here
+// with admin set (otherwise NewAdminRouter serves them on their own port);
+// an empty set leaves them open. A non-nil mr shadows a share of the mode
+// endpoints' traffic. With scripts set, mode endpoints follow the simulated
+// behavior in X-Sim-Script.
+func NewRouter(m *observability.Metrics, st *stats.Aggregator, h *handlers.Handlers, tokens *auth.Tokens, mr *mirror.Mirror, idem *middleware.Idempotency, scripts, admin bool) *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
+	r.Use(middleware.RequestID())
 
 	r.GET("/health", h.Health)
+	r.GET("/ready", h.Ready)
+	r.GET("/stats", h.Stats)
+	r.GET("/heatmap", h.Heatmap)
+	r.GET("/status", h.Status)
+	r.GET("/version", h.Version)
+	r.GET("/topology", h.Topology)
+	r.GET("/recommendations", h.Recommendations)
+	r.GET("/alerts", h.ListAlerts)
+
+	if admin {
+		registerAdmin(r, h, tokens, idem)
+	}
 
-	r.GET("/sync", middleware.Instrument(m, "sync", h.Sync))
-	r.GET("/async", middleware.Instrument(m, "async", h.Async))
-	r.GET("/async-limited", middleware.Instrument(m, "async-limited", h.AsyncLimited))
-	r.GET("/async-timeout", middleware.Instrument(m, "async-timeout", h.AsyncTimeout))
+	// Guarded modes also take POST, whose body sets the request's cost (see guardCost).
+	// Replays are answered before mirroring and instrumentation: no work
+	// is done for them.
+	modes := r.Group("", middleware.Idempotent(idem))
+	if mr != nil {
+		modes.Use(middleware.Mirror(mr))
+	}
+	if scripts {
+		modes.Use(middleware.SimScript())
+	}
+	modes.Match(modeMethods, "/sync", middleware.Instrument(m, st, h.Inflight, h.Toggles, "sync", h.Guard("sync", h.Sync)))
+	modes.Match(modeMethods, "/sync-budget", middleware.Instrument(m, st, h.Inflight, h.Toggles, "sync-budget", h.Guard("sync-budget", h.SyncBudget)))
+	modes.Match(modeMethods, "/async", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async", h.Guard("async", h.Async)))
+	modes.Match(modeMethods, "/async-limited", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async-limited", h.Guard("async-limited", h.AsyncLimited)))
+	modes.Match(modeMethods, "/async-timeout", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async-timeout", h.Guard("async-timeout", h.AsyncTimeout)))
+	modes.Match(modeMethods, "/async-hedged", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async-hedged", h.Guard("async-hedged", h.AsyncHedged)))
+	modes.Match(modeMethods, "/smart", middleware.Instrument(m, st, h.Inflight, h.Toggles, "smart", h.Guard("smart", h.Smart)))
+	modes.Match(modeMethods, "/balanced", middleware.Instrument(m, st, h.Inflight, h.Toggles, "balanced", h.Guard("balanced", h.Balanced)))
+	modes.Match(modeMethods, "/stream", middleware.Instrument(m, st, h.Inflight, h.Toggles, "stream", h.Guard("stream", h.Stream)))
+	modes.GET("/slow-write", middleware.Instrument(m, st, h.Inflight, h.Toggles, "slow-write", h.Guard("slow-write", h.SlowWrite)))
+	modes.Match(modeMethods, "/sequential-dependency", middleware.Instrument(m, st, h.Inflight, h.Toggles, "sequential-dependency", h.Guard("sequential-dependency", h.SequentialDependency)))
+	modes.Match(modeMethods, "/pipelined-dependency", middleware.Instrument(m, st, h.Inflight, h.Toggles, "pipelined-dependency", h.Guard("pipelined-dependency", h.PipelinedDependency)))
+	modes.Match(modeMethods, "/pool", middleware.Instrument(m, st, h.Inflight, h.Toggles, "pool", h.Guard("pool", h.Pool)))
+	modes.Match(modeMethods, "/race", middleware.Instrument(m, st, h.Inflight, h.Toggles, "race", h.Guard("race", h.Race)))
+	modes.Match(modeMethods, "/pipeline", middleware.Instrument(m, st, h.Inflight, h.Toggles, "pipeline", h.Guard("pipeline", h.Pipeline)))
+	modes.Match(modeMethods, "/quorum", middleware.Instrument(m, st, h.Inflight, h.Toggles, "quorum", h.Guard("quorum", h.Quorum)))
+	modes.Match(modeMethods, "/scatter", middleware.Instrument(m, st, h.Inflight, h.Toggles, "scatter", h.Guard("scatter", h.Scatter)))
+	modes.Match(modeMethods, "/async-fallback", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async-fallback", h.Guard("async-fallback", h.AsyncFallback)))
+	modes.Match(modeMethods, "/async-breaker", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async-breaker", h.Guard("async-breaker", h.AsyncBreaker)))
+	modes.Match(modeMethods, "/async-singleflight", middleware.Instrument(m, st, h.Inflight, h.Toggles, "async-singleflight", h.Guard("async-singleflight", h.AsyncSingleflight)))
+	modes.Match(modeMethods, "/regional", middleware.Instrument(m, st, h.Inflight, h.Toggles, "regional", h.Guard("regional", h.Regional)))
+	modes.Match(modeMethods, "/partial", middleware.Instrument(m, st, h.Inflight, h.Toggles, "partial", h.Guard("partial", h.Partial)))
+	modes.Match(modeMethods, "/compute", middleware.Instrument(m, st, h.Inflight, h.Toggles, "compute", h.Guard("compute", h.Compute)))
+	modes.Match(modeMethods, "/fanout", middleware.Instrument(m, st, h.Inflight, h.Toggles, "fanout", h.Guard("fanout", h.Fanout)))
+	modes.Match(modeMethods, "/ordered-fanin", middleware.Instrument(m, st, h.Inflight, h.Toggles, "ordered-fanin", h.Guard("ordered-fanin", h.OrderedFanIn)))
+	modes.Match(modeMethods, "/compare", middleware.Instrument(m, st, h.Inflight, h.Toggles, "compare", h.Guard("compare", h.Compare)))
+
+	// /v2 serves the modes answering CombinedResponse again, with a report of
+	// how each request was served (handlers.V2Routes). They share the v1
+	// endpoints' metrics and admission controls.
+	v2 := modes.Group("/v2")
+	for _, route := range h.V2Routes() {
+		v2.Match(modeMethods, "/"+route.Mode, middleware.Instrument(m, st, h.Inflight, h.Toggles, route.Mode, h.V2(h.Guard(route.Mode, route.Handle))))
+	}
+
+	return r
+}
 
+// NewAdminRouter serves the admin and debug endpoints alone, for a listener
+// of their own. Nothing here is instrumented, so operator traffic stays out
+// of the workload's metrics.
+func NewAdminRouter(h *handlers.Handlers, tokens *auth.Tokens, idem *middleware.Idempotency) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(middleware.RequestID())
+	registerAdmin(r, h, tokens, idem)
 	return r
 }
+
+// registerAdmin adds the admin and debug endpoints to r. They need a reader
+// token; changing anything needs an operator one. Those dumping process
+// internals (stacks, profiles, the command line) stay closed to remote
+// callers when no tokens are configured.
+func registerAdmin(r gin.IRouter, h *handlers.Handlers, tokens *auth.Tokens, idem *middleware.Idempotency) {
+	r.GET("/timeline/:requestID", middleware.RequireRole(tokens, auth.RoleReader), h.Timeline)
+	admin := r.Group("/admin", middleware.RequireRole(tokens, auth.RoleReader))
+	operator := middleware.RequireRole(tokens, auth.RoleOperator)
+	internals := middleware.RequireRoleOrLocal(tokens, auth.RoleReader)
+	// After the role checks, so a refused request never claims a key.
+	once := middleware.Idempotent(idem)
+	admin.GET("/audit", h.ListAudit)
+	admin.GET("/markers", h.ListMarkers)
+	admin.POST("/annotate", operator, once, h.Annotate)
+	admin.GET("/goroutines", internals, h.Goroutines)
+	admin.GET("/profiles", internals, h.ListProfiles)
+	admin.GET("/profiles/:id/:file", internals, h.GetProfile)
+	admin.POST("/snapshot", operator, internals, once, h.Snapshot)
+	admin.GET("/replicas", h.Replicas)
+	admin.GET("/inflight", h.ListInflight)
+	admin.DELETE("/inflight/:id", operator, h.CancelInflight)
+	admin.GET("/hedge-budget", h.HedgeBudgetStats)
+	admin.PUT("/hedge-budget", operator, h.SetHedgeBudget)
+	admin.GET("/background", h.BackgroundStats)
+	admin.POST("/drain", operator, once, h.Drain)
+	admin.POST("/undrain", operator, once, h.Undrain)
+	admin.GET("/config", h.GetConfig)
+	admin.PATCH("/config", operator, once, h.PatchConfig)
+	admin.GET("/toggles", h.ListToggles)
+	admin.PUT("/toggles", operator, h.SetToggles)
+
+	// The runtime runs one CPU profile at a time: /debug/pprof/profile fails
+	// while the CPU_PROFILE_WINDOW_MS sampler is mid-window, and vice versa.
+	debug := r.Group("/debug/pprof", internals)
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	debug.GET("/:profile", gin.WrapF(pprof.Index))
+}
@@ -0,0 +1,94 @@
+package safego
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Result is what a goroutine started by Go delivers to its fan-in.
+type Result[T any] struct {
+	Val T
+	Err error
+}
+
+// PanicError is delivered in place of a result when the goroutine panicked.
+type PanicError struct {
+	Name  string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("goroutine %s panicked: %v", e.Name, e.Value)
+}
+
+// panics counts recovered panics per goroutine name. The global meter delegates
+// to whatever provider SetupOTel installs later.
+var panics, _ = otel.Meter("go-goroutine-lab/safego").Int64Counter("goroutine_panics_total")
+
+// Go runs fn in a new goroutine and returns a buffered channel that receives
+// exactly one Result. A panic in fn is recovered, logged with its stack,
+// counted, and delivered as a *PanicError instead of crashing the process.
+func Go[T any](ctx context.Context, name string, fn func(context.Context) (T, error)) <-chan Result[T] {
+	ch := make(chan Result[T], 1)
+
+	go func() {
+		var res Result[T]
+		defer func() { ch <- res }()
+		defer Recover(ctx, name, &res.Err)
+
+		res.Val, res.Err = fn(ctx)
+	}()
+
+	return ch
+}
+
+// Inline runs fn on the calling goroutine with the same panic containment as
+// Go and delivers its result the same way, so a fan-in can treat both alike.
+func Inline[T any](ctx context.Context, name string, fn func(context.Context) (T, error)) <-chan Result[T] {
+	ch := make(chan Result[T], 1)
+	ch <- run(ctx, name, fn)
+	return ch
+}
+
+func run[T any](ctx context.Context, name string, fn func(context.Context) (T, error)) (res Result[T]) {
+	defer Recover(ctx, name, &res.Err)
+	res.Val, res.Err = fn(ctx)
+	return res
+}
+
+// Background runs a long-lived fn (ticker loops, workers) with the same panic
+// containment as Go. The returned channel is closed when fn returns.
+func Background(ctx context.Context, name string, fn func(context.Context)) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		var err error
+		defer close(done)
+		defer Recover(ctx, name, &err)
+
+		fn(ctx)
+	}()
+
+	return done
+}
+
+// Recover recovers a panic in the calling goroutine, logs it with its stack,
+// counts it, and stores it in *errp as a *PanicError. It must be called
+// directly via defer.
+func Recover(ctx context.Context, name string, errp *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := &PanicError{Name: name, Value: v, Stack: debug.Stack()}
+	log.Printf("%v\n%s", err, err.Stack)
+	panics.Add(ctx, 1, metric.WithAttributes(attribute.String("goroutine", name)))
+	*errp = err
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes activation times.
+type Schedule interface {
+	// Next returns the first activation strictly after t, or the zero time if none.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a cron expression. Supported forms:
+//   - 5 fields: minute hour day-of-month month day-of-week
+//   - 6 fields: second minute hour day-of-month month day-of-week
+//   - @every <duration>, @hourly, @daily
+//
+// Fields accept *, n, a-b, comma lists and /step.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(expr, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("cron %q: @every needs a duration of at least 1s", expr)
+		}
+		return every(d), nil
+	case expr == "@hourly":
+		expr = "0 0 * * * *"
+	case expr == "@daily":
+		expr = "0 0 0 * * *"
+	}
+
+	fields := strings.Fields(expr)
+	offset := 0 // for error messages in terms of the caller's fields
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+		offset = 1
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q: expected 5 or 6 fields, got %d", expr, len(fields))
+	}
+
+	ranges := [6][2]int{{0, 59}, {0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [6]uint64
+	for i, f := range fields {
+		set, err := parseField(f, ranges[i][0], ranges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: field %d: %w", expr, i+1-offset, err)
+		}
+		sets[i] = set
+	}
+
+	// Day-of-week 7 is an alias for Sunday.
+	if sets[5]&(1<<7) != 0 {
+		sets[5] |= 1
+	}
+
+	return &cronSchedule{
+		second: sets[0], minute: sets[1], hour: sets[2],
+		dom: sets[3], month: sets[4], dow: sets[5],
+		domStar: fields[3] == "*", dowStar: fields[5] == "*",
+	}, nil
+}
+
+func parseField(f string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step %q", stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("bad value %q", loStr)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("bad value %q", hiStr)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range [%d,%d]", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
+
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+}
+
+// Next walks forward field by field, from month down to second, resetting the
+// lower fields whenever a higher one advances.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+	loc := t.Location()
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.minute, t.Minute()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		case !has(s.second, t.Second()):
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron semantics: when both day fields are restricted, a
+// day matching either one qualifies.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(set uint64, v int) bool { return set&(1<<v) != 0 }
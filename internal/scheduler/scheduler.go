@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+)
+
+// Policy decides what happens when a task fires while a previous run is still going.
+type Policy string
+
+const (
+	// PolicySkip drops the new activation.
+	PolicySkip Policy = "skip"
+	// PolicyQueue runs once more after the current run (activations coalesce).
+	PolicyQueue Policy = "queue"
+	// PolicyParallel starts another run alongside the current one.
+	PolicyParallel Policy = "parallel"
+)
+
+// Job is the work a task performs.
+type Job func(ctx context.Context) error
+
+// Task is a named job on a schedule.
+type Task struct {
+	Name     string
+	Schedule Schedule
+	Policy   Policy
+	Job      Job
+}
+
+// Spec is a task definition from configuration, before its job is bound.
+type Spec struct {
+	Name   string
+	Expr   string
+	Policy Policy
+}
+
+// ParseSpecs parses "name|cron expr|policy" entries separated by ';'.
+// The policy is optional and defaults to skip.
+func ParseSpecs(s string) ([]Spec, error) {
+	var specs []Spec
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("schedule entry %q: want name|expr[|policy]", entry)
+		}
+		spec := Spec{Name: strings.TrimSpace(parts[0]), Expr: strings.TrimSpace(parts[1]), Policy: PolicySkip}
+		if len(parts) == 3 {
+			spec.Policy = Policy(strings.TrimSpace(parts[2]))
+		}
+		switch spec.Policy {
+		case PolicySkip, PolicyQueue, PolicyParallel:
+		default:
+			return nil, fmt.Errorf("schedule entry %q: unknown policy %q", entry, spec.Policy)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+type entry struct {
+	Task
+	next time.Time
+
+	mu      sync.Mutex
+	running int
+	pending bool
+}
+
+// Scheduler runs recurring tasks from a single timer loop; each activation
+// executes in its own panic-protected goroutine.
+type Scheduler struct {
+	clock   clock.Clock
+	m       *observability.Metrics
+	entries []*entry
+}
+
+// New creates an empty scheduler.
+func New(clk clock.Clock, m *observability.Metrics) *Scheduler {
+	return &Scheduler{clock: clk, m: m}
+}
+
+// Add registers a task. It must be called before Run.
+func (s *Scheduler) Add(t Task) {
+	s.entries = append(s.entries, &entry{Task: t})
+}
+
+// Run fires tasks until ctx is done, then waits for in-flight runs to finish.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	now := s.clock.Now()
+	for _, e := range s.entries {
+		e.next = e.Schedule.Next(now)
+	}
+
+	for {
+		var first *entry
+		for _, e := range s.entries {
+			if !e.next.IsZero() && (first == nil || e.next.Before(first.next)) {
+				first = e
+			}
+		}
+		if first == nil {
+			<-ctx.Done()
+			return
+		}
+
+		timer := time.NewTimer(first.next.Sub(s.clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := s.clock.Now()
+		for _, e := range s.entries {
+			if !e.next.IsZero() && !e.next.After(now) {
+				s.dispatch(ctx, e, &wg)
+				e.next = e.Schedule.Next(now)
+			}
+		}
+	}
+}
+
+// dispatch applies the overlap policy and starts a run if allowed.
+func (s *Scheduler) dispatch(ctx context.Context, e *entry, wg *sync.WaitGroup) {
+	e.mu.Lock()
+	if e.running > 0 {
+		switch e.Policy {
+		case PolicySkip:
+			e.mu.Unlock()
+			s.count(ctx, e, "skipped")
+			return
+		case PolicyQueue:
+			e.pending = true
+			e.mu.Unlock()
+			s.count(ctx, e, "queued")
+			return
+		}
+	}
+	e.running++
+	e.mu.Unlock()
+
+	wg.Add(1)
+	safego.Background(ctx, "scheduler."+e.Name, func(ctx context.Context) {
+		defer wg.Done()
+		for {
+			s.execute(ctx, e)
+
+			e.mu.Lock()
+			if !e.pending || ctx.Err() != nil {
+				e.running--
+				e.mu.Unlock()
+				return
+			}
+			e.pending = false
+			e.mu.Unlock()
+		}
+	})
+}
+
+// execute runs the job once, containing panics so the overlap bookkeeping stays correct.
+func (s *Scheduler) execute(ctx context.Context, e *entry) {
+	start := s.clock.Now()
+
+	var err error
+	func() {
+		defer safego.Recover(ctx, "scheduler."+e.Name, &err)
+		err = e.Job(ctx)
+	}()
+
+	s.m.SchedulerTaskDuration.Record(ctx, float64(s.clock.Since(start).Milliseconds()),
+		metric.WithAttributes(attribute.String("task", e.Name)),
+	)
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	s.count(ctx, e, outcome)
+}
+
+func (s *Scheduler) count(ctx context.Context, e *entry, outcome string) {
+	s.m.SchedulerTaskRuns.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("task", e.Name),
+		attribute.String("outcome", outcome),
+	))
+}
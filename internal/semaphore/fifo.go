@@ -0,0 +1,106 @@
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// FIFO is a weighted counting semaphore granting slots in strict arrival
+// order: each waiter takes a ticket for the slots it needs, and freed slots go
+// to the oldest ticket, never to a caller that arrives in between. A ticket
+// for many slots holds back later ones that would fit, so heavy callers are
+// not starved by a stream of light ones. The price over a channel is a mutex
+// on every acquire and release.
+type FIFO struct {
+	mu      sync.Mutex
+	size    int
+	held    int
+	waiters list.List // of *ticket, oldest first
+}
+
+type ticket struct {
+	n       int
+	ready   chan struct{}
+	granted bool
+}
+
+// NewFIFO creates a semaphore with size slots.
+func NewFIFO(size int) *FIFO {
+	return &FIFO{size: size}
+}
+
+// Acquire takes a slot, waiting behind every earlier caller. On success the
+// caller must call Release.
+func (s *FIFO) Acquire(ctx context.Context) error {
+	return s.AcquireN(ctx, 1)
+}
+
+// AcquireN takes n slots at once, n at most Cap, waiting behind every earlier
+// caller. On success the caller must call ReleaseN with the same n.
+func (s *FIFO) AcquireN(ctx context.Context, n int) error {
+	s.mu.Lock()
+	if s.held+n <= s.size && s.waiters.Len() == 0 {
+		s.held += n
+		s.mu.Unlock()
+		return nil
+	}
+	t := &ticket{n: n, ready: make(chan struct{})}
+	e := s.waiters.PushBack(t)
+	s.mu.Unlock()
+
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if t.granted {
+			// The slots were handed over while we gave up: pass them on.
+			s.mu.Unlock()
+			s.ReleaseN(n)
+		} else {
+			// Callers behind us may fit now.
+			s.waiters.Remove(e)
+			s.grantLocked()
+			s.mu.Unlock()
+		}
+		return context.Cause(ctx)
+	}
+}
+
+// Release frees a slot.
+func (s *FIFO) Release() {
+	s.ReleaseN(1)
+}
+
+// ReleaseN frees n slots, handing them straight to the oldest waiters that fit.
+func (s *FIFO) ReleaseN(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held -= n
+	s.grantLocked()
+}
+
+// grantLocked hands free slots to waiters in order, up to the first that does not fit.
+func (s *FIFO) grantLocked() {
+	for front := s.waiters.Front(); front != nil; front = s.waiters.Front() {
+		t := front.Value.(*ticket)
+		if s.held+t.n > s.size {
+			return
+		}
+		s.waiters.Remove(front)
+		s.held += t.n
+		t.granted = true
+		close(t.ready)
+	}
+}
+
+// InUse returns the number of held slots.
+func (s *FIFO) InUse() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.held
+}
+
+// Cap returns the number of slots.
+func (s *FIFO) Cap() int { return s.size }
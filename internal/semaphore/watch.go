@@ -0,0 +1,108 @@
+package semaphore
+
+import (
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Watch follows the waiters of a semaphore to expose how fairly it grants
+// slots. A channel semaphore wakes blocked senders in no guaranteed order, so
+// a waiter can be overtaken by later arrivals again and again; Watch counts
+// those overtakes (ordering inversions) and flags waits past a starvation
+// threshold.
+type Watch struct {
+	clock     clock.Clock
+	threshold time.Duration
+
+	mu      sync.Mutex
+	seq     uint64
+	waiting map[uint64]time.Time // arrival time by arrival order
+}
+
+// NewWatch creates a watch that considers waits over threshold starved.
+func NewWatch(clk clock.Clock, threshold time.Duration) *Watch {
+	return &Watch{clock: clk, threshold: threshold, waiting: make(map[uint64]time.Time)}
+}
+
+// Waiter is one pending acquisition.
+type Waiter struct {
+	w       *Watch
+	seq     uint64
+	arrived time.Time
+}
+
+// Grant describes how an acquisition went.
+type Grant struct {
+	Wait time.Duration
+	// Overtook is the number of earlier arrivals still waiting when this one
+	// got its slot: zero under strict FIFO.
+	Overtook int
+	Starved  bool
+}
+
+// Arrive registers a caller starting to wait.
+func (w *Watch) Arrive() *Waiter {
+	now := w.clock.Now()
+	w.mu.Lock()
+	w.seq++
+	seq := w.seq
+	w.waiting[seq] = now
+	w.mu.Unlock()
+	return &Waiter{w: w, seq: seq, arrived: now}
+}
+
+// Acquired ends the wait with a slot granted.
+func (wt *Waiter) Acquired() Grant {
+	w := wt.w
+	w.mu.Lock()
+	delete(w.waiting, wt.seq)
+	overtook := w.aheadLocked(wt.seq)
+	w.mu.Unlock()
+
+	wait := w.clock.Since(wt.arrived)
+	return Grant{Wait: wait, Overtook: overtook, Starved: wait > w.threshold}
+}
+
+// Ahead returns how many earlier arrivals are still waiting.
+func (wt *Waiter) Ahead() int {
+	w := wt.w
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.aheadLocked(wt.seq)
+}
+
+func (w *Watch) aheadLocked(seq uint64) int {
+	n := 0
+	for s := range w.waiting {
+		if s < seq {
+			n++
+		}
+	}
+	return n
+}
+
+// Abandoned ends the wait without a slot (the caller gave up) and reports
+// how long it waited.
+func (wt *Waiter) Abandoned() time.Duration {
+	w := wt.w
+	w.mu.Lock()
+	delete(w.waiting, wt.seq)
+	w.mu.Unlock()
+	return w.clock.Since(wt.arrived)
+}
+
+// Starving returns how many callers have been waiting longer than the threshold.
+func (w *Watch) Starving() int {
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, at := range w.waiting {
+		if now.Sub(at) > w.threshold {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"go-routine-stress/internal/apperr"
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/observability"
+	"go-routine-stress/internal/safego"
+)
+
+// Chain puts a tree of simulated downstream services behind Service B: after
+// its own work B calls Fanout children in parallel, each of which calls Fanout
+// children of its own, Depth levels deep (B → C1..Cn → D1..Dn → ...).
+type Chain struct {
+	Depth   int
+	Fanout  int
+	Profile Profile // behavior of every node in the chain
+
+	// Split is the share of its remaining deadline a node hands to its
+	// children (0 = children inherit the deadline unchanged).
+	Split float64
+}
+
+// SetChain makes every Service B call, including its instances, go through c.
+// It must be called before the services are used.
+func (s *Services) SetChain(c Chain) { s.chain = c }
+
+// Chain returns the downstream chain behind Service B (zero Depth = none).
+func (s *Services) Chain() Chain { return s.chain }
+
+// callChildren calls the children of the node at pos on level-1 and returns
+// the first failure; siblings of a failed child are cancelled.
+func (s *Services) callChildren(ctx context.Context, parent string, level, pos int) error {
+	if level > s.chain.Depth {
+		return nil
+	}
+
+	if dl, ok := ctx.Deadline(); ok && s.chain.Split > 0 {
+		budget := time.Duration(float64(dl.Sub(s.clock.Now())) * s.chain.Split)
+		var cancel context.CancelFunc
+		ctx, cancel = clock.WithTimeoutCause(ctx, s.clock, budget, apperr.ErrChainBudget)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make([]<-chan safego.Result[struct{}], s.chain.Fanout)
+	for i := range results {
+		name := fmt.Sprintf("%s>%c%d", parent, 'C'+level-1, i+1)
+		child := pos*s.chain.Fanout + i
+		results[i] = safego.Go(ctx, "chain."+name, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, s.callNode(ctx, name, level, child)
+		})
+	}
+
+	// Wait for every child so none outlives the call, keeping the first failure.
+	var first error
+	for _, ch := range results {
+		if res := <-ch; res.Err != nil && first == nil {
+			first = res.Err
+			cancel(apperr.ErrSiblingFailed)
+		}
+	}
+	return first
+}
+
+// callNode simulates one service in the chain: its own latency, then its children.
+func (s *Services) callNode(ctx context.Context, name string, level, pos int) error {
+	// The node is the peer its parent calls; the path tells its instances apart.
+	ctx, span := observability.StartClientSpan(ctx, name[strings.LastIndexByte(name, '>')+1:], name)
+	span.SetAttributes(
+		attribute.Int("chain.level", level),
+		attribute.Int("chain.position", pos),
+	)
+
+	err := s.node(ctx, name, level, pos)
+	observability.EndClientSpan(span, err, string(apperr.CodeOf(err)))
+	return err
+}
+
+func (s *Services) node(ctx context.Context, name string, level, pos int) error {
+	// Nodes draw from their own seeded streams, apart from A, B and the instances.
+	if st, ok := stepFor(ctx, name); ok {
+		if err := s.scripted(ctx, st); err != nil {
+			return apperr.Dependency(name, err)
+		}
+		return s.callChildren(ctx, name, level+1, pos)
+	}
+
+	rng := s.rngFor(ctx, int64(1000*level+pos))
+	p := s.chain.Profile
+	if rng.Float64() < p.ErrorRate {
+		return apperr.Dependency(name, ErrSimulatedFailure)
+	}
+
+	ms := randRange(rng, p.MinMs, p.MaxMs)
+	select {
+	case <-s.clock.After(time.Duration(ms) * time.Millisecond):
+	case <-ctx.Done():
+		return apperr.Dependency(name, context.Cause(ctx))
+	}
+
+	return s.callChildren(ctx, name, level+1, pos)
+}
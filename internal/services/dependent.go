@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// prepareShare is the part of a Service B call that does not depend on its
+// input (e.g. loading reference data), as a fraction of its latency.
+const prepareShare = 0.4
+
+// PreparedB is a Service B call whose input-independent part is done; Finish
+// completes it once the input is known.
+type PreparedB struct {
+	s        *Services
+	ms       int
+	finishMs int
+	fail     bool // scripted to fail once its latency is spent
+}
+
+// ServiceBWith simulates a Service B call that needs input, such as a value
+// from Service A's response. It draws the same latency and failure as
+// ServiceB, so seeded runs of dependent and independent modes compare.
+func (s *Services) ServiceBWith(ctx context.Context, input string) (ServiceBData, error) {
+	p, err := s.PrepareB(ctx)
+	if err != nil {
+		return ServiceBData{}, err
+	}
+	return p.Finish(ctx, input)
+}
+
+// PrepareB runs the input-independent part of a Service B call, so a caller
+// can overlap it with the call that produces the input.
+func (s *Services) PrepareB(ctx context.Context) (*PreparedB, error) {
+	var ms int
+	st, scripted := stepFor(ctx, "B")
+	if scripted {
+		ms = st.Ms
+	} else {
+		rng := s.rngFor(ctx, 2)
+		if rng.Float64() < DefaultBProfile.ErrorRate {
+			return nil, ErrSimulatedFailure
+		}
+		ms = randRange(rng, DefaultBProfile.MinMs, DefaultBProfile.MaxMs)
+	}
+	prepareMs := int(float64(ms) * prepareShare)
+
+	if err := s.sleep(ctx, prepareMs); err != nil {
+		return nil, err
+	}
+	return &PreparedB{s: s, ms: ms, finishMs: ms - prepareMs, fail: scripted && st.Fail}, nil
+}
+
+// Finish runs the rest of the call with its input, then the downstream chain.
+func (p *PreparedB) Finish(ctx context.Context, input string) (ServiceBData, error) {
+	if err := p.s.sleep(ctx, p.finishMs); err != nil {
+		return ServiceBData{}, err
+	}
+	if p.fail {
+		return ServiceBData{}, ErrScriptedFailure
+	}
+	if err := p.s.callChildren(ctx, "B", 1, 0); err != nil {
+		return ServiceBData{}, err
+	}
+	return ServiceBData{Value: "data-from-B(" + input + ")", SleepMs: p.ms}, nil
+}
+
+func (s *Services) sleep(ctx context.Context, ms int) error {
+	select {
+	case <-s.clock.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
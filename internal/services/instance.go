@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Profile is the simulated behavior of a Service B instance.
+type Profile struct {
+	MinMs     int
+	MaxMs     int
+	ErrorRate float64
+}
+
+// DefaultAProfile is the behavior of Service A.
+var DefaultAProfile = Profile{MinMs: 50, MaxMs: 150}
+
+// DefaultBProfile is the behavior of the plain Service B.
+var DefaultBProfile = Profile{MinMs: 300, MaxMs: 1200, ErrorRate: 0.05}
+
+func (p Profile) String() string {
+	return fmt.Sprintf("%d-%d:%g", p.MinMs, p.MaxMs, p.ErrorRate)
+}
+
+// ParseProfile parses "min-max:errorRate", e.g. "300-1200:0.05".
+func ParseProfile(s string) (Profile, error) {
+	lat, rate, ok := strings.Cut(strings.TrimSpace(s), ":")
+	lo, hi, ok2 := strings.Cut(lat, "-")
+	if !ok || !ok2 {
+		return Profile{}, fmt.Errorf("profile %q: want min-max:errorRate", s)
+	}
+	var (
+		p    Profile
+		err1 error
+		err2 error
+		err3 error
+	)
+	p.MinMs, err1 = strconv.Atoi(lo)
+	p.MaxMs, err2 = strconv.Atoi(hi)
+	p.ErrorRate, err3 = strconv.ParseFloat(rate, 64)
+	if err1 != nil || err2 != nil || err3 != nil || p.MinMs < 0 || p.MaxMs < p.MinMs || p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return Profile{}, fmt.Errorf("profile %q: want min-max:errorRate with 0 <= min <= max and 0 <= errorRate <= 1", s)
+	}
+	return p, nil
+}
+
+// Instance is one named copy of Service B with its own profile, e.g. a primary
+// and a fallback, or the replicas behind a client-side balancer.
+type Instance struct {
+	Name    string
+	Profile Profile
+	// Region is where a region replica runs; empty for other instances.
+	Region string
+
+	svcs *Services
+	salt int64
+}
+
+// Instances builds instances from "name=min-max:errorRate" entries separated by ';'.
+func (s *Services) Instances(spec string) ([]*Instance, error) {
+	var out []*Instance
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, profile, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("instance %q: want name=min-max:errorRate", entry)
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("instance %q: empty name", entry)
+		}
+		// Instances are looked up, scored and labelled by name, so a repeat
+		// would silently replace the first one.
+		if seen[name] {
+			return nil, fmt.Errorf("instance %q: duplicate name %q", entry, name)
+		}
+		seen[name] = true
+		p, err := ParseProfile(profile)
+		if err != nil {
+			return nil, fmt.Errorf("instance %q: %w", entry, err)
+		}
+		// Salts 1 and 2 belong to A and B; each instance gets its own seeded stream.
+		out = append(out, &Instance{Name: name, Profile: p, svcs: s, salt: int64(100 + len(out))})
+	}
+	return out, nil
+}
+
+// Call simulates one request to the instance.
+func (i *Instance) Call(ctx context.Context) (ServiceBData, error) {
+	return i.svcs.simulateB(ctx, i.Name, i.svcs.rngFor(ctx, i.salt), i.Profile, "data-from-B/"+i.Name)
+}
+
+// Replica returns the i-th (from 0) of a set of identical Service B replicas,
+// each drawing latencies and failures from its own seeded stream.
+func (s *Services) Replica(i int) *Instance {
+	return &Instance{Name: "replica-" + strconv.Itoa(i+1), Profile: DefaultBProfile, svcs: s, salt: int64(200 + i)}
+}
+
+// Shard returns the i-th (from 0) of a set of Service B shards, each holding
+// its own part of the data and drawing from its own seeded stream.
+func (s *Services) Shard(i int) *Instance {
+	return &Instance{Name: "shard-" + strconv.Itoa(i+1), Profile: DefaultBProfile, svcs: s, salt: int64(300 + i)}
+}
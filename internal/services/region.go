@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Region is where a Service B replica runs, as seen from this server: the
+// round trip it adds to every call.
+type Region struct {
+	Name   string
+	BaseMs int
+}
+
+// ParseRegions parses "name=baseMs" entries separated by ';', e.g.
+// "local=0;nearby=40;remote=150", and returns them nearest first.
+func ParseRegions(s string) ([]Region, error) {
+	var out []Region
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, ms, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("region %q: want name=baseMs", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(ms))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("region %q: want a base latency of 0 or more milliseconds", entry)
+		}
+		out = append(out, Region{Name: strings.TrimSpace(name), BaseMs: n})
+	}
+	slices.SortStableFunc(out, func(a, b Region) int { return a.BaseMs - b.BaseMs })
+	return out, nil
+}
+
+// RegionReplicas returns the Service B replica of each region: B's profile
+// shifted by the region's base latency, each drawing from its own seeded stream.
+func (s *Services) RegionReplicas(regions []Region) []*Instance {
+	out := make([]*Instance, len(regions))
+	for i, r := range regions {
+		p := DefaultBProfile
+		p.MinMs += r.BaseMs
+		p.MaxMs += r.BaseMs
+		out[i] = &Instance{Name: "region-" + r.Name, Profile: p, Region: r.Name, svcs: s, salt: int64(400 + i)}
+	}
+	return out
+}
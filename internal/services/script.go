@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ScriptHeader carries a per-request Script, honored only when the server
+// runs with scripts enabled.
+const ScriptHeader = "X-Sim-Script"
+
+// ErrScriptedFailure is returned by a call its request's script fails.
+var ErrScriptedFailure = errors.New("scripted failure")
+
+// MaxStepMs caps a scripted latency at an hour, far past any timeout: a
+// larger one would overflow a time.Duration and end at once.
+const MaxStepMs = 3600000
+
+// Step is the scripted behavior of one simulated call: it takes exactly Ms,
+// then fails if Fail is set.
+type Step struct {
+	Ms   int
+	Fail bool
+}
+
+// Script fixes the behavior of simulated calls for one request, keyed by
+// service name: "A", "B", an instance or replica name ("primary",
+// "replica-2") or a chain node path ("B>C1"). Calls it does not name keep
+// their random behavior.
+type Script map[string]Step
+
+// ParseScript parses "name=ms" or "name=ms:fail" entries separated by ';',
+// e.g. "A=50;B=2000" or "B=100:fail".
+func ParseScript(spec string) (Script, error) {
+	s := make(Script)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, step, ok := strings.Cut(entry, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return nil, fmt.Errorf("script %q: want name=ms or name=ms:fail", entry)
+		}
+		ms, outcome, _ := strings.Cut(strings.TrimSpace(step), ":")
+		n, err := strconv.Atoi(ms)
+		if err != nil || n < 0 || n > MaxStepMs || (outcome != "" && outcome != "fail") {
+			return nil, fmt.Errorf("script %q: want name=ms or name=ms:fail with ms in [0, %d]", entry, MaxStepMs)
+		}
+		s[name] = Step{Ms: n, Fail: outcome == "fail"}
+	}
+	return s, nil
+}
+
+type scriptKey struct{}
+
+// WithScript makes calls made with ctx follow s instead of drawing their
+// latencies and failures.
+func WithScript(ctx context.Context, s Script) context.Context {
+	return context.WithValue(ctx, scriptKey{}, s)
+}
+
+// stepFor returns the scripted step of the call named name, if any.
+func stepFor(ctx context.Context, name string) (Step, bool) {
+	s, _ := ctx.Value(scriptKey{}).(Script)
+	st, ok := s[name]
+	return st, ok
+}
+
+// scripted plays a step: it waits Ms, then fails if the step says so.
+func (s *Services) scripted(ctx context.Context, st Step) error {
+	if err := s.sleep(ctx, st.Ms); err != nil {
+		return err
+	}
+	if st.Fail {
+		return ErrScriptedFailure
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzParseScript feeds arbitrary X-Sim-Script headers: a script that parses
+// must only hold steps a call can really take, named by a non-blank name.
+func FuzzParseScript(f *testing.F) {
+	for _, seed := range []string{"", "A=50;B=2000", "B=100:fail", "primary=80; replica-2 = 50:fail", "B>C1=10", "A=-1", "A=3600001", "A=50:ok", "=5", ";;"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		s, err := ParseScript(spec)
+		if err != nil {
+			return
+		}
+		for name, st := range s {
+			if st.Ms < 0 || st.Ms > MaxStepMs {
+				t.Fatalf("%q: step %q takes %dms", spec, name, st.Ms)
+			}
+			if name == "" || strings.TrimSpace(name) != name {
+				t.Fatalf("%q: step name %q is not trimmed", spec, name)
+			}
+		}
+	})
+}
@@ -6,15 +6,33 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"go-routine-stress/internal/clock"
 )
 
+// RNG is the source of randomness for simulated latencies and failures.
+// Implementations must be safe for concurrent use.
+type RNG interface {
+	Intn(n int) int
+	Float64() float64
+}
+
 // Services simulates external dependencies used by the HTTP handlers.
 // Service B is intentionally slower and less reliable to create contention scenarios.
 type Services struct {
 	// Optional artificial contention. If enabled, ServiceB becomes serialized under load.
 	mu sync.Mutex
+
+	clock clock.Clock
+	rng   RNG
+
+	// Optional downstream services behind B.
+	chain Chain
 }
 
+// ErrSimulatedFailure is returned when Service B hits its simulated error rate.
+var ErrSimulatedFailure = errors.New("service B simulated failure")
+
 type ServiceAData struct {
 	Value   string `json:"value"`
 	SleepMs int    `json:"sleepMs"`
@@ -26,21 +44,97 @@ type ServiceBData struct {
 }
 
 // New creates a new Services instance.
-func New() *Services { return &Services{} }
+func New(clk clock.Clock, rng RNG) *Services { return &Services{clock: clk, rng: rng} }
+
+func randRange(rng RNG, min, max int) int {
+	return min + rng.Intn(max-min+1)
+}
+
+type seedKey struct{}
+
+// WithSeed makes calls made with ctx draw latencies and failures from RNGs
+// derived from seed, so the same seed reproduces the same simulated behavior
+// regardless of call order or concurrency mode.
+func WithSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, seedKey{}, seed)
+}
+
+// Salts of the draws other packages make on a request's behalf, clear of the
+// services' own: 1 and 2 for A and B, 100+ per instance, 200+ per replica,
+// 300+ per shard and 1000·level+position down the chain.
+const (
+	SaltBalancer int64 = 1<<32 + iota
+	SaltBrownout
+	SaltDNS
+	SaltConnPool
+	SaltMirror
+	SaltSplit
+)
+
+// RNGFor returns the RNG for one draw made for ctx. With a per-request seed,
+// each drawer gets its own source (offset by salt) so concurrent calls cannot
+// interleave draws; otherwise it is shared.
+func RNGFor(ctx context.Context, salt int64, shared RNG) RNG {
+	if seed, ok := ctx.Value(seedKey{}).(int64); ok {
+		return rand.New(rand.NewSource(seed + salt))
+	}
+	return shared
+}
+
+// rngFor returns the RNG for one call of a service.
+func (s *Services) rngFor(ctx context.Context, salt int64) RNG {
+	return RNGFor(ctx, salt, s.rng)
+}
+
+// RNG returns the shared RNG draws fall back to without a per-request seed.
+func (s *Services) RNG() RNG { return s.rng }
 
-func randRange(min, max int) int {
-	return min + rand.Intn(max-min+1)
+// GlobalRNG returns an RNG backed by the package-level math/rand source.
+func GlobalRNG() RNG { return globalRNG{} }
+
+type globalRNG struct{}
+
+func (globalRNG) Intn(n int) int   { return rand.Intn(n) }
+func (globalRNG) Float64() float64 { return rand.Float64() }
+
+// NewSeededRNG returns a deterministic, concurrency-safe RNG.
+func NewSeededRNG(seed int64) RNG {
+	return &lockedRNG{r: rand.New(rand.NewSource(seed))}
+}
+
+type lockedRNG struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRNG) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRNG) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
 }
 
 // ServiceA simulates a fast and stable dependency.
 func (s *Services) ServiceA(ctx context.Context) (ServiceAData, error) {
-	ms := randRange(50, 150)
+	if st, ok := stepFor(ctx, "A"); ok {
+		if err := s.scripted(ctx, st); err != nil {
+			return ServiceAData{}, err
+		}
+		return ServiceAData{Value: "data-from-A", SleepMs: st.Ms}, nil
+	}
+
+	ms := randRange(s.rngFor(ctx, 1), DefaultAProfile.MinMs, DefaultAProfile.MaxMs)
 
 	select {
-	case <-time.After(time.Duration(ms) * time.Millisecond):
+	case <-s.clock.After(time.Duration(ms) * time.Millisecond):
 		return ServiceAData{Value: "data-from-A", SleepMs: ms}, nil
 	case <-ctx.Done():
-		return ServiceAData{}, ctx.Err()
+		return ServiceAData{}, context.Cause(ctx)
 	}
 }
 
@@ -49,16 +143,34 @@ func (s *Services) ServiceA(ctx context.Context) (ServiceAData, error) {
 // - 5% error rate
 // - optional mutex contention (artificial bottleneck)
 func (s *Services) ServiceB(ctx context.Context) (ServiceBData, error) {
-	if rand.Float64() < 0.05 {
-		return ServiceBData{}, errors.New("service B simulated failure")
-	}
+	return s.simulateB(ctx, "B", s.rngFor(ctx, 2), DefaultBProfile, "data-from-B")
+}
 
-	ms := randRange(300, 1200)
+// simulateB sleeps for a latency drawn from p, failing at p's error rate, then
+// calls the downstream chain, if any. A scripted step for name replaces the draw.
+func (s *Services) simulateB(ctx context.Context, name string, rng RNG, p Profile, value string) (ServiceBData, error) {
+	var ms int
+	if st, ok := stepFor(ctx, name); ok {
+		if err := s.scripted(ctx, st); err != nil {
+			return ServiceBData{}, err
+		}
+		ms = st.Ms
+	} else {
+		if rng.Float64() < p.ErrorRate {
+			return ServiceBData{}, ErrSimulatedFailure
+		}
 
-	select {
-	case <-time.After(time.Duration(ms) * time.Millisecond):
-		return ServiceBData{Value: "data-from-B", SleepMs: ms}, nil
-	case <-ctx.Done():
-		return ServiceBData{}, ctx.Err()
+		ms = randRange(rng, p.MinMs, p.MaxMs)
+
+		select {
+		case <-s.clock.After(time.Duration(ms) * time.Millisecond):
+		case <-ctx.Done():
+			return ServiceBData{}, context.Cause(ctx)
+		}
+	}
+
+	if err := s.callChildren(ctx, "B", 1, 0); err != nil {
+		return ServiceBData{}, err
 	}
+	return ServiceBData{Value: value, SleepMs: ms}, nil
 }
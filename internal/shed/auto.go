@@ -0,0 +1,126 @@
+package shed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"strings"
+	"time"
+
+	"go-routine-stress/internal/safego"
+	"go-routine-stress/internal/stats"
+)
+
+// LimitMode selects how an endpoint's concurrency limit is derived.
+type LimitMode string
+
+const (
+	// LimitFixed keeps the configured absolute limit.
+	LimitFixed LimitMode = "fixed"
+	// LimitCPU uses GOMAXPROCS × factor, for CPU-bound work where more
+	// concurrency than cores only adds queueing.
+	LimitCPU LimitMode = "cpu"
+	// LimitIO uses Little's law, target RPS × mean latency, for IO-bound work
+	// where requests mostly wait on dependencies.
+	LimitIO LimitMode = "io"
+)
+
+// Tuner periodically recomputes the limits of limiters in cpu or io mode, so
+// they follow GOMAXPROCS changes (e.g. Go's container-aware updates when the
+// CPU quota changes) and observed latency instead of hand-tuned numbers.
+type Tuner struct {
+	Limiters map[string]*Limiter
+	Modes    map[string]LimitMode
+	Agg      *stats.Aggregator
+
+	CPUFactor float64
+	TargetRPS float64
+	Min, Max  int
+	Interval  time.Duration
+}
+
+// Run recomputes immediately, then every Interval until ctx is done.
+func (t *Tuner) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		t.tuneSafely(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tuner) tuneSafely(ctx context.Context) {
+	var err error
+	defer safego.Recover(ctx, "shed.tuner", &err)
+	t.Tune()
+}
+
+// Tune applies the derived limit to every tuned endpoint.
+func (t *Tuner) Tune() {
+	procs := runtime.GOMAXPROCS(0)
+
+	meanMs := make(map[string]float64)
+	if t.Agg != nil {
+		for _, es := range t.Agg.Snapshot().Endpoints {
+			meanMs[es.Endpoint] = es.MeanMs
+		}
+	}
+
+	for endpoint, mode := range t.Modes {
+		l, ok := t.Limiters[endpoint]
+		if !ok {
+			continue
+		}
+
+		var n int
+		switch mode {
+		case LimitCPU:
+			n = int(math.Ceil(float64(procs) * t.CPUFactor))
+		case LimitIO:
+			ms, ok := meanMs[endpoint]
+			if !ok || ms == 0 {
+				continue // no traffic yet: keep the current limit
+			}
+			n = int(math.Ceil(t.TargetRPS * ms / 1000))
+		default:
+			continue
+		}
+		n = min(max(n, t.Min), t.Max)
+
+		if old := l.Limit(); old != n {
+			l.SetLimit(n)
+			log.Printf("shed limit %s: %d -> %d (%s mode, GOMAXPROCS=%d)", endpoint, old, n, mode, procs)
+		}
+	}
+}
+
+// ParseLimitModes parses "endpoint=mode" entries separated by ';'.
+func ParseLimitModes(s string) (map[string]LimitMode, error) {
+	out := make(map[string]LimitMode)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, mode, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("limit mode %q: want endpoint=mode", entry)
+		}
+		m := LimitMode(strings.TrimSpace(mode))
+		switch m {
+		case LimitFixed, LimitCPU, LimitIO:
+		default:
+			return nil, fmt.Errorf("limit mode %q: unknown mode %q", entry, m)
+		}
+		out[strings.TrimSpace(endpoint)] = m
+	}
+	return out, nil
+}
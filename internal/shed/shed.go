@@ -0,0 +1,366 @@
+package shed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Policy decides what an endpoint does with a request that arrives while it is
+// at its concurrency limit.
+type Policy string
+
+const (
+	// PolicyReject fails the request immediately with 503.
+	PolicyReject Policy = "reject"
+	// PolicyQueue waits up to the queue timeout for a slot, then fails with 503.
+	PolicyQueue Policy = "queue"
+	// PolicyDegrade answers from fallback data instead of doing the work.
+	PolicyDegrade Policy = "degrade"
+	// PolicyThrottle delays the request, then processes it anyway.
+	PolicyThrottle Policy = "throttle"
+)
+
+var (
+	// ErrOverloaded is returned when no slot is free and the policy does not wait.
+	ErrOverloaded = errors.New("overloaded: concurrency limit reached")
+	// ErrQueueTimeout is returned when a queued request did not get a slot in time.
+	ErrQueueTimeout = errors.New("overloaded: queue timeout")
+)
+
+// Priority orders the requests waiting in a queue-policy limiter: a free slot
+// goes to the highest class waiting, and to the earliest within a class.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+
+	numPriorities = iota
+)
+
+// Priorities lists the classes, highest first.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	}
+	return "normal"
+}
+
+// ParsePriority parses "high", "normal" or "low"; anything else is normal.
+func ParsePriority(s string) Priority {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high":
+		return PriorityHigh
+	case "low":
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// ParseCeilings parses "tenant=priority" entries separated by ';', the
+// highest class each tenant may ask for.
+func ParseCeilings(s string) (map[string]Priority, error) {
+	out := make(map[string]Priority)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, class, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("priority ceiling %q: want tenant=priority", entry)
+		}
+		class = strings.ToLower(strings.TrimSpace(class))
+		p := ParsePriority(class)
+		if p.String() != class {
+			return nil, fmt.Errorf("priority ceiling %q: want high, normal or low", entry)
+		}
+		out[strings.TrimSpace(tenant)] = p
+	}
+	return out, nil
+}
+
+// ClassStats is what one priority class has been through in a queue.
+type ClassStats struct {
+	// Promotions counts the classes gained by aging, summed over requests.
+	Promotions int64
+	// MaxWait is the longest any request of the class waited, including
+	// those waiting now.
+	MaxWait time.Duration
+}
+
+// Limiter bounds the concurrent requests of one endpoint and applies its
+// policy when the bound is reached. The bound can be changed while running.
+type Limiter struct {
+	clock        clock.Clock
+	policy       Policy
+	queueTimeout time.Duration
+	throttle     time.Duration
+	aging        time.Duration
+
+	mu    sync.Mutex
+	limit int
+	inUse int
+	// Waiters per class, each in arrival order. Aging never reorders a
+	// class, so only the heads compete for a free slot.
+	queues  [numPriorities][]*waiter
+	queued  int
+	seq     uint64
+	classes [numPriorities]ClassStats
+}
+
+// waiter is a request queued for a slot; ready is closed when it is granted one.
+type waiter struct {
+	class   Priority
+	arrived time.Time
+	seq     uint64 // arrival order across classes
+	ready   chan struct{}
+}
+
+// New creates a limiter allowing limit concurrent requests. Under the queue
+// policy a waiting request moves up one priority class per aging it has
+// waited, so a steady stream of high-priority requests cannot starve the
+// others; aging 0 keeps strict priority.
+func New(clk clock.Clock, policy Policy, limit int, queueTimeout, throttle, aging time.Duration) *Limiter {
+	return &Limiter{
+		clock:        clk,
+		policy:       policy,
+		queueTimeout: queueTimeout,
+		throttle:     throttle,
+		aging:        aging,
+		limit:        limit,
+	}
+}
+
+// Policy returns the limiter's overload policy.
+func (l *Limiter) Policy() Policy { return l.policy }
+
+// Limit returns the concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// SetLimit changes the concurrency limit. Lowering it never interrupts
+// admitted requests; new ones wait until usage falls below the new limit.
+func (l *Limiter) SetLimit(n int) {
+	l.mu.Lock()
+	l.limit = n
+	l.grantLocked()
+	l.mu.Unlock()
+}
+
+// InUse returns how many slots are taken.
+func (l *Limiter) InUse() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse
+}
+
+// Queued returns how many requests are waiting for a slot.
+func (l *Limiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queued
+}
+
+// Classes returns the queue statistics of each priority class.
+func (l *Limiter) Classes() map[Priority]ClassStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	out := make(map[Priority]ClassStats, len(Priorities))
+	for _, p := range Priorities {
+		out[p] = l.classes[p]
+	}
+	// Requests still waiting count as far as they got.
+	for p, q := range l.queues {
+		st := out[Priority(p)]
+		for _, w := range q {
+			st.Promotions += int64(w.class - l.levelLocked(w, now))
+		}
+		if len(q) > 0 {
+			st.MaxWait = max(st.MaxWait, now.Sub(q[0].arrived))
+		}
+		out[Priority(p)] = st
+	}
+	return out
+}
+
+// tryAcquire takes a slot if one is free.
+func (l *Limiter) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse < l.limit {
+		l.inUse++
+		return true
+	}
+	return false
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	l.inUse--
+	l.grantLocked()
+	l.mu.Unlock()
+}
+
+// enqueue takes a slot if one freed up since tryAcquire, otherwise queues a
+// waiter of class p.
+func (l *Limiter) enqueue(p Priority) (w *waiter, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse < l.limit {
+		l.inUse++
+		return nil, true
+	}
+	l.seq++
+	w = &waiter{class: p, arrived: l.clock.Now(), seq: l.seq, ready: make(chan struct{})}
+	l.queues[p] = append(l.queues[p], w)
+	l.queued++
+	return w, false
+}
+
+// leave takes w out of the queue, recording its wait, and reports whether it
+// had been granted a slot.
+func (l *Limiter) leave(w *waiter) (granted bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	st := &l.classes[w.class]
+	st.Promotions += int64(w.class - l.levelLocked(w, now))
+	st.MaxWait = max(st.MaxWait, now.Sub(w.arrived))
+	q := l.queues[w.class]
+	if i := slices.Index(q, w); i >= 0 {
+		l.queues[w.class] = slices.Delete(q, i, i+1)
+		l.queued--
+		return false
+	}
+	return true
+}
+
+// grantLocked hands free slots to waiters, highest effective class first.
+func (l *Limiter) grantLocked() {
+	now := l.clock.Now()
+	for l.inUse < l.limit && l.queued > 0 {
+		// The head of a class has waited longest, so it has aged the most:
+		// the best head wins, the earliest arrival among equal levels.
+		best := Priority(-1)
+		var bestLevel Priority
+		for p, q := range l.queues {
+			if len(q) == 0 {
+				continue
+			}
+			level := l.levelLocked(q[0], now)
+			if best < 0 || level < bestLevel || level == bestLevel && q[0].seq < l.queues[best][0].seq {
+				best, bestLevel = Priority(p), level
+			}
+		}
+		w := l.queues[best][0]
+		l.queues[best][0] = nil
+		l.queues[best] = l.queues[best][1:]
+		l.queued--
+		l.inUse++
+		close(w.ready)
+	}
+}
+
+// levelLocked is the class w competes in at now, aged up from its own.
+func (l *Limiter) levelLocked(w *waiter, now time.Time) Priority {
+	if l.aging <= 0 {
+		return w.class
+	}
+	return max(PriorityHigh, w.class-Priority(now.Sub(w.arrived)/l.aging))
+}
+
+// Acquire admits a request of priority p. On success the returned release
+// must be called when the request finishes; saturated is true when the limit
+// was reached on arrival, even if the request was eventually admitted (queue,
+// throttle). Reject and degrade fail with ErrOverloaded, queue with
+// ErrQueueTimeout or the context's cause. Only the queue policy looks at p.
+func (l *Limiter) Acquire(ctx context.Context, p Priority) (release func(), saturated bool, err error) {
+	if l.tryAcquire() {
+		return l.release, false, nil
+	}
+
+	switch l.policy {
+	case PolicyQueue:
+		w, ok := l.enqueue(p)
+		if ok {
+			return l.release, true, nil
+		}
+
+		select {
+		case <-w.ready:
+		case <-l.clock.After(l.queueTimeout):
+		case <-ctx.Done():
+		}
+		granted := l.leave(w)
+		switch {
+		// A slot may be granted just as the request gives up: never admit a
+		// request that is already gone, and pass the slot on instead.
+		case granted && ctx.Err() == nil:
+			return l.release, true, nil
+		case granted:
+			l.release()
+			return nil, true, context.Cause(ctx)
+		case ctx.Err() != nil:
+			return nil, true, context.Cause(ctx)
+		}
+		return nil, true, ErrQueueTimeout
+
+	case PolicyThrottle:
+		select {
+		case <-l.clock.After(l.throttle):
+		case <-ctx.Done():
+		}
+		// Checked apart from the select, which may pick the timer even then.
+		if ctx.Err() != nil {
+			return nil, true, context.Cause(ctx)
+		}
+		// Take a slot if one freed up meanwhile; otherwise run over the limit.
+		if l.tryAcquire() {
+			return l.release, true, nil
+		}
+		return func() {}, true, nil
+	}
+
+	return nil, true, ErrOverloaded
+}
+
+// ParsePolicies parses "endpoint=policy" entries separated by ';'.
+func ParsePolicies(s string) (map[string]Policy, error) {
+	out := make(map[string]Policy)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, policy, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("shed policy %q: want endpoint=policy", entry)
+		}
+		p := Policy(strings.TrimSpace(policy))
+		switch p {
+		case PolicyReject, PolicyQueue, PolicyDegrade, PolicyThrottle:
+		default:
+			return nil, fmt.Errorf("shed policy %q: unknown policy %q", entry, p)
+		}
+		out[strings.TrimSpace(endpoint)] = p
+	}
+	return out, nil
+}
@@ -0,0 +1,352 @@
+package stats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-routine-stress/internal/clock"
+	"go-routine-stress/internal/safego"
+)
+
+// BoundsMs are the upper bounds of the latency histogram buckets; the last
+// bucket is open-ended. Percentiles are reported as bucket upper bounds.
+var BoundsMs = []float64{5, 10, 25, 50, 75, 100, 150, 200, 300, 400, 500, 600, 800, 1000, 1200, 1500, 2000, 3000, 5000, 10000}
+
+// Apdex holds the Apdex thresholds: successful responses within SatisfiedMs
+// are satisfied, within ToleratingMs tolerating; slower ones and errors are
+// frustrated.
+type Apdex struct {
+	SatisfiedMs  float64
+	ToleratingMs float64
+}
+
+// Bucket accumulates the requests of one endpoint during one tick.
+type Bucket struct {
+	Count  int64
+	Errors int64
+	SumMs  float64
+	Hist   []int64 // len(BoundsMs)+1
+
+	// Apdex classification, counted at observation time since the
+	// thresholds need not fall on histogram bounds.
+	Satisfied  int64
+	Tolerating int64
+}
+
+func newBucket() Bucket { return Bucket{Hist: make([]int64, len(BoundsMs)+1)} }
+
+func (b *Bucket) observe(ms float64, isErr bool, apdex Apdex) {
+	b.Count++
+	b.SumMs += ms
+	switch {
+	case isErr:
+		b.Errors++
+	case ms <= apdex.SatisfiedMs:
+		b.Satisfied++
+	case ms <= apdex.ToleratingMs:
+		b.Tolerating++
+	}
+	b.Hist[sort.SearchFloat64s(BoundsMs, ms)]++
+}
+
+func (b *Bucket) merge(o Bucket) {
+	b.Count += o.Count
+	b.Errors += o.Errors
+	b.SumMs += o.SumMs
+	b.Satisfied += o.Satisfied
+	b.Tolerating += o.Tolerating
+	for i, n := range o.Hist {
+		b.Hist[i] += n
+	}
+}
+
+// Apdex returns (satisfied + tolerating/2) / count, or 0 without requests.
+func (b *Bucket) Apdex() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return (float64(b.Satisfied) + float64(b.Tolerating)/2) / float64(b.Count)
+}
+
+// Quantile returns the upper bound of the bucket holding quantile q (0..1).
+func (b *Bucket) Quantile(q float64) float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	rank := int64(q * float64(b.Count))
+	var cum int64
+	for i, n := range b.Hist {
+		cum += n
+		if cum > rank || cum == b.Count {
+			if i < len(BoundsMs) {
+				return BoundsMs[i]
+			}
+			return BoundsMs[len(BoundsMs)-1]
+		}
+	}
+	return BoundsMs[len(BoundsMs)-1]
+}
+
+// EndpointStats summarizes one endpoint over the rolling window.
+type EndpointStats struct {
+	Endpoint  string  `json:"endpoint"`
+	Requests  int64   `json:"requests"`
+	RPS       float64 `json:"rps"`
+	ErrorRate float64 `json:"errorRate"`
+	MeanMs    float64 `json:"meanMs"`
+	P50Ms     float64 `json:"p50Ms"`
+	P95Ms     float64 `json:"p95Ms"`
+	P99Ms     float64 `json:"p99Ms"`
+	P999Ms    float64 `json:"p999Ms"`
+	Apdex     float64 `json:"apdex"`
+}
+
+// Snapshot is the aggregated view published after every tick.
+type Snapshot struct {
+	At        time.Time       `json:"at"`
+	WindowSec float64         `json:"windowSec"`
+	Endpoints []EndpointStats `json:"endpoints"`
+}
+
+// Endpoint returns the stats of one endpoint, if it saw traffic in the window.
+func (s Snapshot) Endpoint(name string) (EndpointStats, bool) {
+	for _, es := range s.Endpoints {
+		if es.Endpoint == name {
+			return es, true
+		}
+	}
+	return EndpointStats{}, false
+}
+
+// Aggregator computes per-endpoint rolling stats. Observations are cheap
+// (one mutex-protected increment); all aggregation happens on a ticker in a
+// single background goroutine started by Run.
+type Aggregator struct {
+	clock  clock.Clock
+	tick   time.Duration
+	window int // ticks summarized in the published snapshot
+	retain int // ticks kept for Summarize
+
+	mu      sync.Mutex
+	apdex   Apdex
+	current map[string]*Bucket
+	ring    map[string][]Bucket
+	pos     int
+
+	snap atomic.Pointer[Snapshot]
+}
+
+// New creates an aggregator that rolls every tick, publishes a snapshot over
+// window, and keeps retain of history (at least window) for Summarize.
+func New(clk clock.Clock, tick, window, retain time.Duration) *Aggregator {
+	a := &Aggregator{
+		clock:   clk,
+		tick:    tick,
+		window:  max(int(window/tick), 1),
+		current: make(map[string]*Bucket),
+		ring:    make(map[string][]Bucket),
+	}
+	a.retain = max(int(retain/tick), a.window)
+	a.snap.Store(&Snapshot{At: clk.Now()})
+	return a
+}
+
+// Observe records one finished request.
+func (a *Aggregator) Observe(endpoint string, status int, d time.Duration) {
+	ms := float64(d.Microseconds()) / 1000
+
+	a.mu.Lock()
+	b, ok := a.current[endpoint]
+	if !ok {
+		nb := newBucket()
+		b = &nb
+		a.current[endpoint] = b
+	}
+	b.observe(ms, status >= 400, a.apdex)
+	a.mu.Unlock()
+}
+
+// SetApdex sets the Apdex thresholds applied to requests observed from now on.
+func (a *Aggregator) SetApdex(satisfied, tolerating time.Duration) {
+	a.mu.Lock()
+	a.apdex = Apdex{SatisfiedMs: float64(satisfied.Microseconds()) / 1000, ToleratingMs: float64(tolerating.Microseconds()) / 1000}
+	a.mu.Unlock()
+}
+
+// Snapshot returns the most recently published stats.
+func (a *Aggregator) Snapshot() Snapshot { return *a.snap.Load() }
+
+// ApdexScores returns the Apdex of every endpoint with requests in the
+// latest snapshot.
+func (a *Aggregator) ApdexScores() map[string]float64 {
+	out := make(map[string]float64)
+	for _, es := range a.Snapshot().Endpoints {
+		if es.Requests > 0 {
+			out[es.Endpoint] = es.Apdex
+		}
+	}
+	return out
+}
+
+// Run aggregates on every tick until ctx is done. The ticker is stopped on
+// return, and a panic during one tick is contained so the loop keeps running.
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.rollSafely(ctx)
+		}
+	}
+}
+
+func (a *Aggregator) rollSafely(ctx context.Context) {
+	var err error
+	defer safego.Recover(ctx, "stats.roll", &err)
+	a.roll()
+}
+
+// Summarize computes per-endpoint stats over the last d (capped at the
+// retained history), e.g. for alert rules with their own windows.
+func (a *Aggregator) Summarize(d time.Duration) []EndpointStats {
+	n := min(max(int(d/a.tick), 1), a.retain)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.summarizeLocked(n)
+}
+
+// Retention is how much history Summarize can cover.
+func (a *Aggregator) Retention() time.Duration { return time.Duration(a.retain) * a.tick }
+
+// Heatmap holds, per endpoint, a latency histogram for each of a run of
+// consecutive time columns, rows bounded by BoundsMs.
+type Heatmap struct {
+	// Columns are the start times of the columns, oldest first, each StepSec long.
+	Columns  []time.Time `json:"columns"`
+	StepSec  float64     `json:"stepSec"`
+	BoundsMs []float64   `json:"boundsMs"` // row upper bounds; one more row is open-ended
+
+	Endpoints []HeatmapSeries `json:"endpoints"`
+}
+
+// HeatmapSeries is one endpoint's matrix: Counts[i][j] requests in column i
+// finished with a latency in row j.
+type HeatmapSeries struct {
+	Endpoint string    `json:"endpoint"`
+	Counts   [][]int64 `json:"counts"`
+}
+
+// Heatmap returns, for each endpoint with requests in the last d (capped at
+// the retained history), its latency histograms over columns of step
+// (rounded to whole ticks). With endpoint set, only that one is included.
+func (a *Aggregator) Heatmap(d, step time.Duration, endpoint string) Heatmap {
+	n := min(max(int(d/a.tick), 1), a.retain)
+	per := min(max(int(step/a.tick), 1), n)
+	cols := (n + per - 1) / per
+	end := a.Snapshot().At
+
+	h := Heatmap{
+		Columns:   make([]time.Time, cols),
+		StepSec:   (time.Duration(per) * a.tick).Seconds(),
+		BoundsMs:  BoundsMs,
+		Endpoints: []HeatmapSeries{},
+	}
+	for c := range h.Columns {
+		h.Columns[c] = end.Add(-time.Duration(n-c*per) * a.tick)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for ep, slots := range a.ring {
+		if endpoint != "" && ep != endpoint {
+			continue
+		}
+		counts := make([][]int64, cols)
+		for c := range counts {
+			counts[c] = make([]int64, len(BoundsMs)+1)
+		}
+		var total int64
+		// Tick i ticks before the newest one lands in column (n-1-i)/per.
+		for i := 0; i < n; i++ {
+			b := slots[(a.pos-i+a.retain)%a.retain]
+			if b.Count == 0 {
+				continue
+			}
+			row := counts[(n-1-i)/per]
+			for j, k := range b.Hist {
+				row[j] += k
+			}
+			total += b.Count
+		}
+		if total > 0 {
+			h.Endpoints = append(h.Endpoints, HeatmapSeries{Endpoint: ep, Counts: counts})
+		}
+	}
+	sort.Slice(h.Endpoints, func(i, j int) bool { return h.Endpoints[i].Endpoint < h.Endpoints[j].Endpoint })
+	return h
+}
+
+// roll moves the current buckets into the ring and publishes a new snapshot.
+func (a *Aggregator) roll() {
+	a.mu.Lock()
+	current := a.current
+	a.current = make(map[string]*Bucket, len(current))
+	a.pos = (a.pos + 1) % a.retain
+
+	for ep := range current {
+		if _, ok := a.ring[ep]; !ok {
+			a.ring[ep] = make([]Bucket, a.retain)
+		}
+	}
+	for ep, slots := range a.ring {
+		if b, ok := current[ep]; ok {
+			slots[a.pos] = *b
+		} else {
+			slots[a.pos] = Bucket{}
+		}
+	}
+
+	snap := &Snapshot{
+		At:        a.clock.Now(),
+		WindowSec: (time.Duration(a.window) * a.tick).Seconds(),
+		Endpoints: a.summarizeLocked(a.window),
+	}
+	a.mu.Unlock()
+
+	a.snap.Store(snap)
+}
+
+// summarizeLocked merges the newest n slots of every endpoint. a.mu must be held.
+func (a *Aggregator) summarizeLocked(n int) []EndpointStats {
+	windowSec := (time.Duration(n) * a.tick).Seconds()
+
+	out := make([]EndpointStats, 0, len(a.ring))
+	for ep, slots := range a.ring {
+		t := newBucket()
+		for i := 0; i < n; i++ {
+			if b := slots[(a.pos-i+a.retain)%a.retain]; b.Count > 0 {
+				t.merge(b)
+			}
+		}
+
+		es := EndpointStats{Endpoint: ep, Requests: t.Count, RPS: float64(t.Count) / windowSec}
+		if t.Count > 0 {
+			es.ErrorRate = float64(t.Errors) / float64(t.Count)
+			es.MeanMs = t.SumMs / float64(t.Count)
+			es.P50Ms, es.P95Ms, es.P99Ms = t.Quantile(0.50), t.Quantile(0.95), t.Quantile(0.99)
+			es.P999Ms = t.Quantile(0.999)
+			es.Apdex = t.Apdex()
+		}
+		out = append(out, es)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Endpoint < out[j].Endpoint })
+	return out
+}
@@ -0,0 +1,72 @@
+package timeline
+
+import (
+	"html/template"
+	"io"
+)
+
+const (
+	chartWidth = 900
+	labelWidth = 140
+	rowHeight  = 26
+)
+
+// bar is one Gantt row in SVG coordinates.
+type bar struct {
+	Event
+	Y, X, W float64
+}
+
+var page = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Timeline {{.Rec.RequestID}}</title>
+<style>
+body { font-family: sans-serif; margin: 24px; }
+rect.phase { fill: #4e79a7; } rect.instant { fill: #e15759; }
+text { font-size: 12px; }
+</style></head>
+<body>
+<h2>{{.Rec.Mode}} &mdash; {{.Rec.RequestID}}</h2>
+<p>status {{.Rec.Status}}, started {{.Rec.StartedAt.Format "15:04:05.000"}}, total {{printf "%.1f" .TotalMs}}ms</p>
+<svg width="{{.SVGWidth}}" height="{{.SVGHeight}}" xmlns="http://www.w3.org/2000/svg">
+{{range .Bars}}
+  <text x="0" y="{{.Y}}" dy="16">{{.Name}}{{if .Goroutine}} (g{{.Goroutine}}){{end}}</text>
+  <rect class="{{if eq .StartMs .EndMs}}instant{{else}}phase{{end}}" x="{{.X}}" y="{{.Y}}" width="{{.W}}" height="20">
+    <title>{{.Name}}: {{printf "%.1f" .StartMs}}–{{printf "%.1f" .EndMs}}ms {{.Detail}}</title>
+  </rect>
+{{end}}
+</svg>
+</body>
+</html>
+`))
+
+// RenderHTML writes rec as an HTML page with an SVG Gantt chart, one row per event.
+func RenderHTML(w io.Writer, rec Record) error {
+	var total float64
+	for _, e := range rec.Events {
+		total = max(total, e.EndMs)
+	}
+	scale := float64(chartWidth)
+	if total > 0 {
+		scale /= total
+	}
+
+	bars := make([]bar, len(rec.Events))
+	for i, e := range rec.Events {
+		bars[i] = bar{
+			Event: e,
+			Y:     float64(i * rowHeight),
+			X:     labelWidth + e.StartMs*scale,
+			// Instantaneous events still get a visible tick.
+			W: max((e.EndMs-e.StartMs)*scale, 2),
+		}
+	}
+
+	return page.Execute(w, map[string]any{
+		"Rec":       rec,
+		"TotalMs":   total,
+		"Bars":      bars,
+		"SVGWidth":  labelWidth + chartWidth + 10,
+		"SVGHeight": len(bars)*rowHeight + 10,
+	})
+}
@@ -0,0 +1,62 @@
+package timeline
+
+import (
+	"sync"
+	"time"
+)
+
+// Record is a finished request timeline kept for later inspection.
+type Record struct {
+	RequestID string    `json:"requestId"`
+	Mode      string    `json:"mode"`
+	Status    int       `json:"status"`
+	StartedAt time.Time `json:"startedAt"`
+	Events    []Event   `json:"events"`
+}
+
+// Ring keeps the most recent records in a fixed-size buffer; once full, the
+// oldest record is overwritten.
+type Ring struct {
+	mu   sync.Mutex
+	buf  []Record
+	next int
+	byID map[string]int
+}
+
+// NewRing creates a ring holding up to size records. A size below 1 disables recording.
+func NewRing(size int) *Ring {
+	if size < 1 {
+		return &Ring{}
+	}
+	return &Ring{buf: make([]Record, size), byID: make(map[string]int, size)}
+}
+
+// Add stores rec, evicting the oldest record if the ring is full.
+func (r *Ring) Add(rec Record) {
+	if len(r.buf) == 0 || rec.RequestID == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A later record may have reused the evicted one's ID (a client-supplied
+	// X-Request-ID); only drop the index entry if it still points here.
+	if old := r.buf[r.next]; old.RequestID != "" && r.byID[old.RequestID] == r.next {
+		delete(r.byID, old.RequestID)
+	}
+	r.buf[r.next] = rec
+	r.byID[rec.RequestID] = r.next
+	r.next = (r.next + 1) % len(r.buf)
+}
+
+// Get returns the record for requestID if it is still buffered.
+func (r *Ring) Get(requestID string) (Record, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.byID[requestID]
+	if !ok {
+		return Record{}, false
+	}
+	return r.buf[i], true
+}
@@ -0,0 +1,114 @@
+package timeline
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-routine-stress/internal/clock"
+)
+
+// Event is one phase of a request's execution, relative to the request start.
+type Event struct {
+	Name      string  `json:"name"`
+	Goroutine int64   `json:"goroutine,omitempty"`
+	StartMs   float64 `json:"startMs"`
+	EndMs     float64 `json:"endMs"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+// Timeline collects events for a single request. A nil *Timeline is valid and
+// records nothing, so callers never need to check whether debug mode is on.
+type Timeline struct {
+	clock clock.Clock
+	start time.Time
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// New starts a timeline at the current time.
+func New(clk clock.Clock) *Timeline {
+	return &Timeline{clock: clk, start: clk.Now()}
+}
+
+type ctxKey struct{}
+
+// WithTimeline attaches t to ctx.
+func WithTimeline(ctx context.Context, t *Timeline) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext returns the timeline attached to ctx, or nil.
+func FromContext(ctx context.Context) *Timeline {
+	t, _ := ctx.Value(ctxKey{}).(*Timeline)
+	return t
+}
+
+// Begin records the start of a phase on the calling goroutine and returns a
+// function that ends it with an optional detail.
+func (t *Timeline) Begin(name string) func(detail string) {
+	if t == nil {
+		return func(string) {}
+	}
+	gid := GoroutineID()
+	startMs := t.sinceStartMs()
+
+	return func(detail string) {
+		t.add(Event{Name: name, Goroutine: gid, StartMs: startMs, EndMs: t.sinceStartMs(), Detail: detail})
+	}
+}
+
+// Mark records an instantaneous event, e.g. which select case fired.
+func (t *Timeline) Mark(name, detail string) {
+	if t == nil {
+		return
+	}
+	at := t.sinceStartMs()
+	t.add(Event{Name: name, Goroutine: GoroutineID(), StartMs: at, EndMs: at, Detail: detail})
+}
+
+// Start returns when the timeline began.
+func (t *Timeline) Start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.start
+}
+
+// Events returns a copy of the recorded events in completion order.
+func (t *Timeline) Events() []Event {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Event(nil), t.events...)
+}
+
+func (t *Timeline) add(e Event) {
+	t.mu.Lock()
+	t.events = append(t.events, e)
+	t.mu.Unlock()
+}
+
+func (t *Timeline) sinceStartMs() float64 {
+	return float64(t.clock.Since(t.start).Microseconds()) / 1000
+}
+
+// GoroutineID parses the current goroutine's ID from its stack header. The Go
+// runtime deliberately hides this value; it is exposed here for teaching only
+// and must never be used for program logic.
+func GoroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,155 @@
+package toggles
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Toggle is a named runtime switch with a fixed set of values, the first of
+// which is the default. Reads are a single atomic load, cheap enough for
+// every request.
+type Toggle struct {
+	name   string
+	doc    string
+	values []string
+	cur    atomic.Int32
+}
+
+// Value returns the current value.
+func (t *Toggle) Value() string { return t.values[t.cur.Load()] }
+
+// Is reports whether the current value is v.
+func (t *Toggle) Is(v string) bool { return t.Value() == v }
+
+// State describes a toggle for the admin API.
+type State struct {
+	Name   string   `json:"name"`
+	Value  string   `json:"value"`
+	Values []string `json:"values"`
+	Doc    string   `json:"doc"`
+}
+
+// Change is one applied toggle flip.
+type Change struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Set is a fixed collection of toggles, registered at startup.
+type Set struct {
+	toggles []*Toggle
+}
+
+// Add registers a toggle; it must happen before the set is shared.
+func (s *Set) Add(name, doc string, values ...string) *Toggle {
+	t := &Toggle{name: name, doc: doc, values: values}
+	s.toggles = append(s.toggles, t)
+	return t
+}
+
+func (s *Set) lookup(name string) *Toggle {
+	for _, t := range s.toggles {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Apply sets every name=value pair, all or nothing: an unknown name or value
+// fails before anything changes. Pairs that do not change a value are
+// left out of the result.
+func (s *Set) Apply(pairs [][2]string) ([]Change, error) {
+	idx := make([]int, len(pairs))
+	for i, p := range pairs {
+		t := s.lookup(p[0])
+		if t == nil {
+			return nil, fmt.Errorf("unknown toggle %q", p[0])
+		}
+		idx[i] = slices.Index(t.values, p[1])
+		if idx[i] < 0 {
+			return nil, fmt.Errorf("toggle %s: unknown value %q, want one of %s", p[0], p[1], strings.Join(t.values, ", "))
+		}
+	}
+
+	changes := []Change{}
+	for i, p := range pairs {
+		t := s.lookup(p[0])
+		if old := t.cur.Swap(int32(idx[i])); int(old) != idx[i] {
+			changes = append(changes, Change{Name: t.name, Old: t.values[old], New: t.values[idx[i]]})
+		}
+	}
+	return changes, nil
+}
+
+// ApplySpec applies a GODEBUG-style "name=value,name=value" list.
+func (s *Set) ApplySpec(spec string) ([]Change, error) {
+	pairs, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return s.Apply(pairs)
+}
+
+// List returns every toggle in registration order.
+func (s *Set) List() []State {
+	out := make([]State, 0, len(s.toggles))
+	for _, t := range s.toggles {
+		out = append(out, State{Name: t.name, Value: t.Value(), Values: t.values, Doc: t.doc})
+	}
+	return out
+}
+
+// Parse parses a GODEBUG-style "name=value,name=value" list.
+func Parse(spec string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("toggle %q: want name=value", entry)
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+	}
+	return pairs, nil
+}
+
+// Values of the runtime toggles.
+const (
+	On  = "on"
+	Off = "off"
+
+	JSONBuffered = "buffered" // marshal the whole body, then write it
+	JSONStreamed = "streamed" // encode straight onto the connection
+
+	InstrumentationFull  = "full"  // context audit, allocation sampling, CPU labels
+	InstrumentationBasic = "basic" // request metrics and spans only
+
+	SpawnGoroutines = "goroutines"  // every fanned-out call on its own goroutine
+	SpawnCallerRuns = "caller-runs" // Service A on the handler's goroutine
+)
+
+// Runtime is the set of switches the server consults while serving.
+type Runtime struct {
+	Set
+	Tracing         *Toggle
+	JSON            *Toggle
+	Instrumentation *Toggle
+	Spawn           *Toggle
+}
+
+// NewRuntime registers the runtime toggles with their defaults.
+func NewRuntime() *Runtime {
+	r := &Runtime{}
+	r.Tracing = r.Add("tracing", "sample new traces (spans already started still end)", On, Off)
+	r.JSON = r.Add("json", "how mode endpoints write their JSON bodies", JSONBuffered, JSONStreamed)
+	r.Instrumentation = r.Add("instrumentation", "per-request instrumentation beyond metrics and spans", InstrumentationFull, InstrumentationBasic)
+	r.Spawn = r.Add("spawn", "how fan-out modes start their Service A and B calls", SpawnGoroutines, SpawnCallerRuns)
+	return r
+}